@@ -4,6 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"mattermost-log-scrubber/config"
 	"mattermost-log-scrubber/constants"
@@ -14,24 +16,107 @@ func ParseFlags() config.CLIFlags {
 	var flags config.CLIFlags
 
 	// Define flags
-	flag.StringVar(&flags.InputFile, "i", "", "Input log file path (required)")
-	flag.StringVar(&flags.Input, "input", "", "Input log file path (required)")
-	flag.StringVar(&flags.OutputFile, "o", "", "Output file path (optional)")
-	flag.StringVar(&flags.Output, "output", "", "Output file path (optional)")
+	flag.Var((*stringListFlag)(&flags.InputFile), "i", "Input log file path or glob pattern (required, repeatable)")
+	flag.Var((*stringListFlag)(&flags.Input), "input", "Input log file path or glob pattern (required, repeatable)")
+	flag.StringVar(&flags.InputList, "input-list", "", "Text file listing input paths, one per line (# comments allowed)")
+	flag.BoolVar(&flags.SkipMissing, "skip-missing", false, "Warn about and skip input files that don't exist instead of failing")
+	flag.BoolVar(&flags.Recursive, "recursive", false, "With a directory input, also scrub log files in its subdirectories")
+	flag.Var((*stringListFlag)(&flags.ExcludeGlobs), "exclude-glob", "With a directory input, skip files whose name or path in the directory matches this glob (repeatable)")
+	flag.StringVar(&flags.OutputDir, "output-dir", "", "Directory for scrubbed output files, named <input>_scrubbed.<ext>")
+	flag.StringVar(&flags.OutputFile, "o", "", "Output file path, or - for stdout (optional)")
+	flag.StringVar(&flags.Output, "output", "", "Output file path, or - for stdout (optional)")
 	flag.IntVar(&flags.Level, "l", 0, "Scrubbing level 1-3 (required)")
 	flag.IntVar(&flags.LevelLong, "level", 0, "Scrubbing level 1-3 (required)")
 	flag.StringVar(&flags.ConfigFile, "c", "", "Config file path (default: scrubber_config.json)")
 	flag.StringVar(&flags.ConfigLong, "config", "", "Config file path (default: scrubber_config.json)")
+	flag.StringVar(&flags.Profile, "profile", "", "Apply the named profile from the config file's Profiles over the rest of the config")
+	flag.StringVar(&flags.Rules, "rules", "", "Rules file with patterns, field types, scrub paths and domain lists, merged into the config")
 	flag.BoolVar(&flags.DryRun, "dry-run", false, "Preview changes without writing output")
+	flag.BoolVar(&flags.ToTemp, "to-temp", false, "With --dry-run, write the scrubbed output to a temp file for inspection")
 	flag.BoolVar(&flags.Verbose, "v", false, "Verbose output")
 	flag.BoolVar(&flags.VerboseLong, "verbose", false, "Verbose output")
+	flag.IntVar(&flags.SampleChanges, "sample-changes", 0, "After the run, print up to N before/after examples per scrub type")
+	flag.StringVar(&flags.SampleOrig, "sample-originals", "", "How --sample-changes shows originals: show, mask or hide (default: show)")
 	flag.StringVar(&flags.AuditFile, "a", "", "Audit file path for tracking mappings (optional)")
 	flag.StringVar(&flags.AuditLong, "audit", "", "Audit file path for tracking mappings (optional)")
-	flag.StringVar(&flags.AuditType, "audit-type", "", "Audit file format: csv or json (default: csv)")
+	flag.BoolVar(&flags.HashOriginals, "audit-hash-originals", false, "Record a salted hash of each original value in the audit instead of plaintext")
+	flag.StringVar(&flags.AuditSalt, "audit-salt", "", "Salt for --audit-hash-originals (default: randomly generated and printed)")
+	flag.StringVar(&flags.NoAuditTypes, "no-audit-types", "", "Comma-separated scrub types to scrub but leave out of the audit, e.g. uid,ip")
+	flag.StringVar(&flags.AuditCSVDelim, "audit-csv-delimiter", "", "Field delimiter of the CSV audit, e.g. ';' or '\\t' (default: ,)")
+	flag.BoolVar(&flags.AuditCSVQuote, "audit-csv-quote-all", false, "Quote every field of the CSV audit")
+	flag.StringVar(&flags.AuditType, "audit-type", "", "Audit file format: csv, json, ndjson, or a comma-separated list like csv,json (default: csv)")
 	flag.StringVar(&flags.OverwriteAction, "overwrite", "", "Action when files exist: prompt, overwrite, timestamp, cancel (default: prompt)")
+	flag.StringVar(&flags.RenameScheme, "rename-scheme", "", "How renamed files are suffixed: timestamp or sequential (default: timestamp)")
+	flag.BoolVar(&flags.RoleTokens, "role-tokens", false, "Map users with a known role to role-based tokens (e.g., admin1)")
+	flag.BoolVar(&flags.Hash, "hash", false, "Derive user and domain tokens from a salted hash (e.g., user_a1b2c3d4), stable across runs")
+	flag.StringVar(&flags.HashSalt, "hash-salt", "", "Secret salt for --hash tokens; runs with the same salt give a value the same token")
+	flag.BoolVar(&flags.HashPrefix, "hash-prefix", false, "Start --hash tokens with a hash of the original's first 2 characters so related values cluster; leaks a little entropy")
+	flag.BoolVar(&flags.ContainerLogs, "container-logs", false, "Input is Docker/Kubernetes JSON log records; scrub the log field and keep the envelope")
+	flag.StringVar(&flags.InputFormat, "input-format", "", "Input line format: json, plain to skip JSON parsing, or delimited for tab/space-separated columns (default: json)")
+	flag.StringVar(&flags.Format, "format", "", "Same as --input-format")
+	flag.StringVar(&flags.Delimiter, "delimiter", "", "Column separator of delimited input, e.g. ' ' (default: \\t, a tab)")
+	flag.StringVar(&flags.ScrubColumns, "scrub-columns", "", "Columns of delimited input to scrub: 0-based indexes or header names, optionally =type, e.g. 0=ip,user=username")
+	flag.StringVar(&flags.LogKind, "log-kind", "", "Log format hint for field handling: auto, app, notifications (default: auto)")
+	flag.StringVar(&flags.CancelScope, "cancel-scope", "", "What a cancelled file conflict affects: run, file (default: run)")
+	flag.StringVar(&flags.LineRange, "line-range", "", "Scrub only lines START:END of the input (1-based, inclusive; either side may be omitted)")
+	flag.StringVar(&flags.ByteRange, "byte-range", "", "Scrub only lines starting within byte offsets START:END (e.g., 1GB:2GB)")
 	flag.StringVar(&flags.MaxFileSize, "max-file-size", "", "Maximum input file size: 150MB, 1GB, etc. (default: 150MB)")
 	flag.BoolVar(&flags.Compress, "z", false, "Compress output file with gzip")
 	flag.BoolVar(&flags.CompressLong, "compress", false, "Compress output file with gzip")
+	flag.StringVar(&flags.Manifest, "manifest", "", "Write a JSON manifest of all artifacts with sizes and SHA-256 checksums")
+	flag.StringVar(&flags.Trace, "trace", "", "Write a per-line trace of detector matches to a file for debugging (contains original values; small inputs only)")
+	flag.StringVar(&flags.Metrics, "metrics", "", "Write run statistics in Prometheus text format (e.g., metrics.prom)")
+	flag.StringVar(&flags.Stats, "stats", "", "Write versioned JSON counts of the run for reporting tools (e.g., stats.json, or - for stdout)")
+	flag.StringVar(&flags.FrequencyReport, "frequency-report", "", "Write a CSV of anonymized values ranked by count (e.g., freq.csv)")
+	flag.StringVar(&flags.HTMLReport, "report-html", "", "Write a readable HTML summary of the run for sharing, with anonymized values only (e.g., report.html)")
+	flag.StringVar(&flags.IdentityReport, "identity-report", "", "Write a JSON report grouping each person's original values and tokens (contains original values)")
+	flag.BoolVar(&flags.Checksums, "checksums", false, "Record SHA-256 checksums of the input and scrubbed output")
+	flag.BoolVar(&flags.CanonicalJSON, "canonical-json", false, "Re-marshal JSON lines with sorted keys for diff-friendly output")
+	flag.BoolVar(&flags.CollapseRepeats, "collapse-repeats", false, "Write runs of identical scrubbed lines once, followed by (repeated N times)")
+	flag.BoolVar(&flags.Deterministic, "deterministic", false, "Make the output and audit byte-identical across runs over the same input (implies --canonical-json)")
+	flag.StringVar(&flags.SplitSize, "split-size", "", "Split the scrubbed output into numbered parts of at most this size (e.g., 100MB)")
+	flag.BoolVar(&flags.TwoPass, "two-pass", false, "Read each input twice: map every username/email pair first, then scrub")
+	flag.BoolVar(&flags.Resume, "resume", false, "Continue an interrupted batch, skipping the inputs it completed")
+	flag.StringVar(&flags.StateFile, "state-file", "", "Batch progress file for --resume (default: "+constants.DefaultStateFile+" in the output directory)")
+	flag.StringVar(&flags.Throttle, "throttle", "", "Limit processing rate in lines/sec (e.g., 2000) or bytes/sec (e.g., 5MB)")
+	flag.StringVar(&flags.Timeout, "timeout", "", "Stop the run once it has taken this long, e.g. 10m or 90s (default: no limit)")
+	flag.StringVar(&flags.TimeoutOutput, "timeout-output", "", "What to do with the output of a run stopped by --timeout: keep or discard (default: keep)")
+	flag.StringVar(&flags.ConfirmAbove, "confirm-above", "", "Ask for confirmation before interactively scrubbing more than this size (default: 1GB, 0 = never)")
+	flag.BoolVar(&flags.Yes, "yes", false, "Skip the large input confirmation")
+	flag.BoolVar(&flags.NoConfirm, "no-confirm", false, "Skip the large input confirmation")
+	flag.BoolVar(&flags.NoOutput, "no-output", false, "Scrub and write the audit, but skip writing the scrubbed log")
+	flag.BoolVar(&flags.FailOnEmpty, "fail-on-empty", false, "Exit with an error if the input has no non-empty lines")
+	flag.BoolVar(&flags.RequireChanges, "require-changes", false, "Exit with status 3 if no values were replaced")
+	flag.BoolVar(&flags.LenientEmails, "lenient-emails", false, "Also detect emails with spaces around the @ or wrapped across lines, e.g. alice @ acme.com")
+	flag.BoolVar(&flags.AllowShortNames, "allow-short-usernames", false, "Also scrub always-scrub values that are short or generic words, e.g. a or test, everywhere they appear")
+	flag.StringVar(&flags.EmailTemplate, "email-template", "", "Format of anonymized emails with {n}, {token} and {domain}, e.g. anon.{n}@{domain} (default: "+constants.DefaultEmailTemplate+")")
+	flag.StringVar(&flags.InternalDomains, "internal-domains", "", "Comma-separated internal email domains, e.g. acme.com,acme.net: their addresses keep the domain, all others lose it")
+	flag.StringVar(&flags.DomainMap, "domain-map", "", "JSON file of fixed domain mappings, e.g. {\"acme.com\": \"companyA.test\"}")
+	flag.StringVar(&flags.RedactList, "redact-list", "", "File of terms to replace with [redacted] wherever they appear, one literal or /regex/ per line")
+	flag.StringVar(&flags.JSONFailAction, "json-failure-action", "", "What to do with lines that aren't valid JSON: scrub, drop, redact (default: scrub)")
+	flag.Float64Var(&flags.MaxJSONFailRate, "max-json-failure-rate", 0, "Fail if more than this fraction of non-empty lines aren't valid JSON (e.g., 0.2; default: disabled)")
+	flag.StringVar(&flags.TimeFormat, "normalize-time", "", "Rewrite timestamp fields as rfc3339, epoch-ms or relative (offset from the first timestamp)")
+	flag.StringVar(&flags.IPStrategy, "ip-strategy", "", "How IP addresses are replaced: mask or class (default: mask)")
+	flag.Var((*stringListFlag)(&flags.ScrubPaths), "scrub-path", "JSON path whose value is always scrubbed, e.g. props.acct.email or data[0].user=username (repeatable)")
+	flag.StringVar(&flags.Passthrough, "passthrough-fields", "", "Comma-separated JSON fields whose values are never scrubbed, e.g. trace_id,level,status")
+	flag.BoolVar(&flags.StrictAllowlist, "strict-allowlist", false, "Only scrub values at scrub paths and field types; drop or redact lines that aren't JSON")
+	flag.StringVar(&flags.MaskChar, "mask-char", "", "Character used in masks (default: "+constants.DefaultMaskChar+")")
+	flag.StringVar(&flags.MaskCharEmail, "mask-char-email", "", "Mask character for emails (default: --mask-char)")
+	flag.StringVar(&flags.MaskCharUser, "mask-char-username", "", "Mask character for usernames (default: --mask-char)")
+	flag.StringVar(&flags.MaskCharIP, "mask-char-ip", "", "Mask character for IP addresses (default: --mask-char)")
+	flag.StringVar(&flags.MaskCharUID, "mask-char-uid", "", "Mask character for internal IDs (default: --mask-char)")
+	flag.StringVar(&flags.MaskCharHost, "mask-char-host", "", "Mask character for hostnames (default: --mask-char)")
+	flag.StringVar(&flags.UserPrefix, "user-prefix", "", "Prefix of mapped users, e.g. member for member1 (default: "+constants.UserTokenPrefix+")")
+	flag.StringVar(&flags.DomainPrefix, "domain-prefix", "", "Prefix of mapped domains, e.g. org for org1 (default: "+constants.DomainTokenPrefix+")")
+	flag.BoolVar(&flags.FixedWidth, "fixed-width", false, "Replace values with masks of identical length (disables consistent mapping)")
+
+	flag.StringVar(&flags.VerifyFixture, "verify-fixture", "", "Scrub a fixture input and compare it with an expected output given as the last argument")
+	flag.BoolVar(&flags.UpdateFixture, "update-fixture", false, "With --verify-fixture, rewrite the expected output instead of comparing")
+	flag.BoolVar(&flags.Reverse, "reverse", false, "Restore original values in a scrubbed log (-i) from the JSON audit (-a) of its run")
+	flag.BoolVar(&flags.SelfTest, "self-test", false, "Scrub a built-in sample log, report pass/fail per PII category and exit")
+	flag.BoolVar(&flags.InitConfig, "init-config", false, "Write a starter config file to the -c path (default: "+constants.DefaultConfigFile+") and exit")
+	flag.StringVar(&flags.ServeAddr, "addr", constants.DefaultServeAddr, "Listen address for the serve command")
+	flag.BoolVar(&flags.PrintConfig, "print-config", false, "Print each config setting's effective value and source (cli, config or default) and exit")
 
 	// Version and help flags
 	var showVersion bool
@@ -49,6 +134,13 @@ func ParseFlags() config.CLIFlags {
 
 	flag.Parse()
 
+	// Keep parsing flags that follow a positional argument, e.g. --verify-fixture in.log expected.log --update-fixture
+	var positional []string
+	for flag.NArg() > 0 {
+		positional = append(positional, flag.Arg(0))
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
 	// Handle help flag
 	if showHelp || showHelpLong {
 		PrintUsage()
@@ -61,26 +153,126 @@ func ParseFlags() config.CLIFlags {
 		os.Exit(0)
 	}
 
+	// The serve subcommand runs the HTTP service; other flags become its default settings
+	if len(positional) > 0 && positional[0] == constants.ServeCommand {
+		flags.Serve = true
+		positional = positional[1:]
+	}
+
+	// --verify-fixture takes the expected output as a positional argument
+	flags.FixtureArgs = positional
+
 	return flags
 }
 
+// stringListFlag collects the values of a flag that may be given more than once
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
 // PrintUsage prints the application usage information
 func PrintUsage() {
 	fmt.Fprintf(os.Stderr, "%s\n\n", constants.Description)
-	fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s %s [--addr %s] [options]   Scrub logs uploaded over HTTP\n\n", os.Args[0], constants.ServeCommand, constants.DefaultServeAddr)
 	fmt.Fprintf(os.Stderr, "Required flags (unless using config file):\n")
-	fmt.Fprintf(os.Stderr, "  -i, --input string    Input log file path\n")
+	fmt.Fprintf(os.Stderr, "  -i, --input string    Input log file path, glob like 'logs/*.log', a directory of logs, unix:///path/to/sock, or - for stdin (repeatable)\n")
 	fmt.Fprintf(os.Stderr, "  -l, --level int       Scrubbing level (1, 2, or 3)\n\n")
 	fmt.Fprintf(os.Stderr, "Optional flags:\n")
-	fmt.Fprintf(os.Stderr, "  -c, --config string   Config file path (default: %s)\n", constants.DefaultConfigFile)
-	fmt.Fprintf(os.Stderr, "  -o, --output string   Output file path (default: <input>%s.<ext>)\n", constants.ScrubSuffix)
-	fmt.Fprintf(os.Stderr, "  -a, --audit string    Audit file path for tracking mappings (default: <input>%s.csv)\n", constants.AuditSuffix)
-	fmt.Fprintf(os.Stderr, "  --audit-type string   Audit file format: %s or %s (default: %s)\n", constants.AuditTypeCSV, constants.AuditTypeJSON, constants.AuditTypeCSV)
+	fmt.Fprintf(os.Stderr, "  -c, --config string   Config file path (default: %s, then $XDG_CONFIG_HOME/%s/%s)\n", constants.DefaultConfigFile, constants.AppName, constants.UserConfigFile)
+	fmt.Fprintf(os.Stderr, "  --profile string      Apply the named profile from the config file's Profiles over the rest of the config\n")
+	fmt.Fprintf(os.Stderr, "  --rules string        Rules file with patterns, field types, scrub paths and domain lists, merged into the config\n")
+	fmt.Fprintf(os.Stderr, "  --input-list string   Text file listing input paths, one per line (# comments allowed)\n")
+	fmt.Fprintf(os.Stderr, "  --skip-missing        Warn about and skip input files that don't exist instead of failing\n")
+	fmt.Fprintf(os.Stderr, "  --recursive           With a directory input, also scrub log files in its subdirectories\n")
+	fmt.Fprintf(os.Stderr, "  --exclude-glob string With a directory input, skip files whose name or path matches the glob (repeatable)\n")
+	fmt.Fprintf(os.Stderr, "  -o, --output string   Output file path, s3://bucket/key, or - for stdout (default: <input>%s.<ext>)\n", constants.ScrubSuffix)
+	fmt.Fprintf(os.Stderr, "  --output-dir string   Directory or s3://bucket/prefix/ for scrubbed output files, named <input>%s.<ext>\n", constants.ScrubSuffix)
+	fmt.Fprintf(os.Stderr, "  -a, --audit string    Audit file path or s3://bucket/key (default: <input>%s.csv)\n", constants.AuditSuffix)
+	fmt.Fprintf(os.Stderr, "  --audit-hash-originals Record a salted hash of each original value in the audit instead of plaintext\n")
+	fmt.Fprintf(os.Stderr, "  --audit-salt string   Salt for --audit-hash-originals (default: randomly generated and printed)\n")
+	fmt.Fprintf(os.Stderr, "  --no-audit-types string Comma-separated scrub types to scrub but leave out of the audit, e.g. uid,ip\n")
+	fmt.Fprintf(os.Stderr, "  --audit-csv-delimiter string Field delimiter of the CSV audit, e.g. ';' or '\\t' (default: ,)\n")
+	fmt.Fprintf(os.Stderr, "  --audit-csv-quote-all Quote every field of the CSV audit\n")
+	fmt.Fprintf(os.Stderr, "  --audit-type string   Audit file format: %s, %s, %s, or a list like %s,%s (default: %s)\n", constants.AuditTypeCSV, constants.AuditTypeJSON, constants.AuditTypeNDJSON, constants.AuditTypeCSV, constants.AuditTypeJSON, constants.AuditTypeCSV)
 	fmt.Fprintf(os.Stderr, "  --overwrite string    Action when files exist: %s, %s, %s, %s (default: %s)\n", constants.OverwritePrompt, constants.OverwriteOverwrite, constants.OverwriteTimestamp, constants.OverwriteCancel, constants.OverwritePrompt)
+	fmt.Fprintf(os.Stderr, "  --rename-scheme string How renamed files are suffixed: %s (_20060102_150405) or %s (_1, _2, ...) (default: %s)\n", constants.RenameSchemeTimestamp, constants.RenameSchemeSequential, constants.RenameSchemeTimestamp)
+	fmt.Fprintf(os.Stderr, "  --role-tokens         Map users with a known role to role-based tokens (e.g., admin1)\n")
+	fmt.Fprintf(os.Stderr, "  --hash                Derive user and domain tokens from a salted hash (e.g., user_a1b2c3d4), stable across runs\n")
+	fmt.Fprintf(os.Stderr, "  --hash-salt string    Secret salt for --hash tokens; runs with the same salt give a value the same token\n")
+	fmt.Fprintf(os.Stderr, "  --hash-prefix         Start --hash tokens with a hash of the original's first 2 characters so related values cluster; leaks a little entropy\n")
+	fmt.Fprintf(os.Stderr, "  --container-logs      Input is Docker/Kubernetes JSON log records; scrub the log field and keep the envelope\n")
+	fmt.Fprintf(os.Stderr, "  --input-format, --format  Input line format: json, plain to skip JSON parsing, or delimited for tab/space-separated columns (default: json)\n")
+	fmt.Fprintf(os.Stderr, "  --delimiter           Column separator of delimited input, e.g. ' ' (default: \\t, a tab)\n")
+	fmt.Fprintf(os.Stderr, "  --scrub-columns       Columns of delimited input to scrub: indexes or header names, optionally =type\n")
+	fmt.Fprintf(os.Stderr, "  --log-kind string     Log format hint for field handling: %s, %s, %s (default: %s)\n", constants.LogKindAuto, constants.LogKindApp, constants.LogKindNotifications, constants.LogKindAuto)
+	fmt.Fprintf(os.Stderr, "  --cancel-scope string What a cancelled file conflict affects: %s aborts, %s skips that file (default: %s)\n", constants.CancelScopeRun, constants.CancelScopeFile, constants.CancelScopeRun)
+	fmt.Fprintf(os.Stderr, "  --line-range string   Scrub only lines START:END of the input (1-based, inclusive; either side may be omitted)\n")
+	fmt.Fprintf(os.Stderr, "  --byte-range string   Scrub only lines starting within byte offsets START:END (e.g., 1GB:2GB)\n")
 	fmt.Fprintf(os.Stderr, "  --max-file-size string Maximum input file size: 150MB, 1GB, etc. (default: 150MB)\n")
 	fmt.Fprintf(os.Stderr, "  -z, --compress        Compress output file with gzip\n")
+	fmt.Fprintf(os.Stderr, "  --lenient-emails      Also detect emails with spaces around the @ or wrapped across lines, e.g. alice @ acme.com\n")
+	fmt.Fprintf(os.Stderr, "  --allow-short-usernames Also scrub always-scrub values that are short or generic words, e.g. a or test\n")
+	fmt.Fprintf(os.Stderr, "  --email-template string Format of anonymized emails with {n}, {token} and {domain} (default: %s)\n", constants.DefaultEmailTemplate)
+	fmt.Fprintf(os.Stderr, "  --internal-domains string Comma-separated internal email domains: their addresses keep the domain, all others lose it\n")
+	fmt.Fprintf(os.Stderr, "  --domain-map string   JSON file of fixed domain mappings, e.g. {\"acme.com\": \"companyA.test\"}\n")
+	fmt.Fprintf(os.Stderr, "  --redact-list string  File of terms to replace with [redacted], one literal or /regex/ per line\n")
+	fmt.Fprintf(os.Stderr, "  --json-failure-action string What to do with lines that aren't valid JSON: %s, %s, %s (default: %s)\n", constants.JSONFailureScrub, constants.JSONFailureDrop, constants.JSONFailureRedact, constants.JSONFailureScrub)
+	fmt.Fprintf(os.Stderr, "  --max-json-failure-rate float Fail if more than this fraction of non-empty lines aren't valid JSON (e.g., 0.2; default: disabled)\n")
+	fmt.Fprintf(os.Stderr, "  --normalize-time string Rewrite timestamp fields as %s, %s or %s (offset from the first timestamp)\n", constants.TimeFormatRFC3339, constants.TimeFormatEpochMS, constants.TimeFormatRelative)
+	fmt.Fprintf(os.Stderr, "  --ip-strategy string  How IP addresses are replaced: %s or %s (default: %s)\n", constants.IPStrategyMask, constants.IPStrategyClass, constants.IPStrategyMask)
+	fmt.Fprintf(os.Stderr, "  --scrub-path string   JSON path whose value is always scrubbed, e.g. data[0].user (repeatable)\n")
+	fmt.Fprintf(os.Stderr, "  --passthrough-fields string Comma-separated JSON fields whose values are never scrubbed, e.g. trace_id,level\n")
+	fmt.Fprintf(os.Stderr, "  --strict-allowlist    Only scrub values at scrub paths and field types; drop or redact lines that aren't JSON\n")
+	fmt.Fprintf(os.Stderr, "  --fixed-width         Replace values with same-length masks (no consistent mapping)\n")
+	fmt.Fprintf(os.Stderr, "  --mask-char string    Character used in masks (default: %s)\n", constants.DefaultMaskChar)
+	fmt.Fprintf(os.Stderr, "  --mask-char-email, --mask-char-username, --mask-char-ip, --mask-char-uid, --mask-char-host string\n")
+	fmt.Fprintf(os.Stderr, "                        Mask character for one type, overriding --mask-char\n")
+	fmt.Fprintf(os.Stderr, "  --user-prefix string  Prefix of mapped users, e.g. member for member1 (default: %s)\n", constants.UserTokenPrefix)
+	fmt.Fprintf(os.Stderr, "  --domain-prefix string Prefix of mapped domains, e.g. org for org1 (default: %s)\n", constants.DomainTokenPrefix)
 	fmt.Fprintf(os.Stderr, "  --dry-run             Preview changes without writing output\n")
+	fmt.Fprintf(os.Stderr, "  --to-temp             With --dry-run, write the scrubbed output to a temp file for inspection\n")
+	fmt.Fprintf(os.Stderr, "  --manifest string     Write a JSON manifest of all artifacts with sizes and SHA-256 checksums\n")
+	fmt.Fprintf(os.Stderr, "  --trace string        Write a per-line trace of detector matches to a file for debugging (contains original values; small inputs only)\n")
+	fmt.Fprintf(os.Stderr, "  --metrics string      Write run statistics in Prometheus text format (e.g., metrics.prom)\n")
+	fmt.Fprintf(os.Stderr, "  --stats string        Write versioned JSON counts of the run for reporting tools (e.g., stats.json, or - for stdout)\n")
+	fmt.Fprintf(os.Stderr, "  --frequency-report string Write a CSV of anonymized values ranked by count (e.g., freq.csv)\n")
+	fmt.Fprintf(os.Stderr, "  --report-html string  Write a readable HTML summary of the run for sharing, with anonymized values only\n")
+	fmt.Fprintf(os.Stderr, "  --identity-report string Write a JSON report grouping each person's original values and tokens (contains original values)\n")
+	fmt.Fprintf(os.Stderr, "  --checksums           Record SHA-256 checksums of the input and scrubbed output\n")
+	fmt.Fprintf(os.Stderr, "  --canonical-json      Re-marshal JSON lines with sorted keys for diff-friendly output\n")
+	fmt.Fprintf(os.Stderr, "  --collapse-repeats    Write runs of identical scrubbed lines once, followed by (repeated N times)\n")
+	fmt.Fprintf(os.Stderr, "  --deterministic       Make the output and audit byte-identical across runs over the same input (implies --canonical-json)\n")
+	fmt.Fprintf(os.Stderr, "  --split-size string   Split the scrubbed output into numbered parts of at most this size (e.g., 100MB)\n")
+	fmt.Fprintf(os.Stderr, "  --throttle string     Limit processing rate in lines/sec (e.g., 2000) or bytes/sec (e.g., 5MB)\n")
+	fmt.Fprintf(os.Stderr, "  --two-pass            Read each input twice: map every username/email pair first, then scrub\n")
+	fmt.Fprintf(os.Stderr, "  --resume              Continue an interrupted batch, skipping the inputs it completed\n")
+	fmt.Fprintf(os.Stderr, "  --state-file string   Batch progress file for --resume (default: %s in the output directory)\n", constants.DefaultStateFile)
+	fmt.Fprintf(os.Stderr, "  --timeout string      Stop the run once it has taken this long, e.g. 10m or 90s (default: no limit)\n")
+	fmt.Fprintf(os.Stderr, "  --timeout-output string What to do with the output of a run stopped by --timeout: keep or discard (default: keep)\n")
+	fmt.Fprintf(os.Stderr, "  --confirm-above string Ask for confirmation before interactively scrubbing more than this size (default: 1GB, 0 = never)\n")
+	fmt.Fprintf(os.Stderr, "  --yes, --no-confirm   Skip the large input confirmation\n")
+	fmt.Fprintf(os.Stderr, "  --no-output           Scrub and write the audit, but skip writing the scrubbed log\n")
+	fmt.Fprintf(os.Stderr, "  --fail-on-empty       Exit with an error if the input has no non-empty lines\n")
+	fmt.Fprintf(os.Stderr, "  --require-changes     Exit with status %d if no values were replaced\n", constants.ExitNoChanges)
+	fmt.Fprintf(os.Stderr, "  --verify-fixture string EXPECTED\n")
+	fmt.Fprintf(os.Stderr, "                        Scrub a fixture input with the current settings and fail if it differs from EXPECTED\n")
+	fmt.Fprintf(os.Stderr, "  --update-fixture      With --verify-fixture, rewrite EXPECTED from the current output instead of comparing\n")
+	fmt.Fprintf(os.Stderr, "  --reverse             Restore original values in a scrubbed log (-i) from the JSON audit (-a) of its run\n")
+	fmt.Fprintf(os.Stderr, "  --self-test           Scrub a built-in sample log, report pass/fail per PII category and exit\n")
+	fmt.Fprintf(os.Stderr, "  --init-config         Write a starter config file to the -c path (default: %s) and exit\n", constants.DefaultConfigFile)
+	fmt.Fprintf(os.Stderr, "  --addr string         Listen address for the serve command (default: %s)\n", constants.DefaultServeAddr)
+	fmt.Fprintf(os.Stderr, "  --print-config        Print each config setting's effective value and source (cli, config or default) and exit\n")
 	fmt.Fprintf(os.Stderr, "  -v, --verbose         Verbose output\n")
+	fmt.Fprintf(os.Stderr, "  --sample-changes int  After the run, print up to N before/after examples per scrub type\n")
+	fmt.Fprintf(os.Stderr, "  --sample-originals string How --sample-changes shows originals: show, mask or hide (default: show)\n")
 	fmt.Fprintf(os.Stderr, "  -V, --version         Show version and exit\n")
 	fmt.Fprintf(os.Stderr, "  -h, --help            Show this help message\n\n")
 	fmt.Fprintf(os.Stderr, "Examples:\n")
@@ -93,6 +285,7 @@ func PrintUsage() {
 	fmt.Fprintf(os.Stderr, "  %s -i mattermost.log -l 1 --compress\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "  %s -i mattermost.log -l 1 --overwrite %s\n", os.Args[0], constants.OverwriteTimestamp)
 	fmt.Fprintf(os.Stderr, "  %s -i large.log -l 1 --max-file-size 500MB\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s -i 'logs/*.log' -l 2 -o scrubbed/\n", os.Args[0])
 }
 
 // GetConfigPath determines the configuration file path from CLI flags
@@ -106,9 +299,34 @@ func GetConfigPath(flags config.CLIFlags) (string, bool) {
 	userSpecifiedConfig := flags.ConfigFile != "" || flags.ConfigLong != ""
 
 	// Set default config path if not specified
+	// Search order: local scrubber_config.json, then the per-user config, then built-in defaults
 	if configPath == "" {
 		configPath = constants.DefaultConfigFile
+		if _, err := os.Stat(configPath); err != nil {
+			if userConfig := getUserConfigPath(); userConfig != "" {
+				configPath = userConfig
+			}
+		}
 	}
 
 	return configPath, userSpecifiedConfig
+}
+
+// getUserConfigPath returns the first per-user config that exists, checking $XDG_CONFIG_HOME and then
+// ~/.config, or "" when there is none
+func getUserConfigPath() string {
+	var configHomes []string
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		configHomes = append(configHomes, configHome)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		configHomes = append(configHomes, filepath.Join(home, ".config"))
+	}
+	for _, configHome := range configHomes {
+		path := filepath.Join(configHome, constants.AppName, constants.UserConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
 }
\ No newline at end of file
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mattermost-log-scrubber/config"
+	"mattermost-log-scrubber/constants"
+)
+
+// writeUserConfig writes an empty user config under configHome and returns its path
+func writeUserConfig(t *testing.T, configHome string) string {
+	t.Helper()
+	path := filepath.Join(configHome, constants.AppName, constants.UserConfigFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetConfigPathFindsUserConfig(t *testing.T) {
+	// No local config
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	home := t.TempDir()
+	xdg := t.TempDir()
+	t.Setenv("HOME", home)
+	homeConfig := writeUserConfig(t, filepath.Join(home, ".config"))
+
+	// An XDG_CONFIG_HOME without a config falls back to ~/.config
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	if got, _ := GetConfigPath(config.CLIFlags{}); got != homeConfig {
+		t.Errorf("empty XDG_CONFIG_HOME: config path = %s, want %s", got, homeConfig)
+	}
+
+	// A config under XDG_CONFIG_HOME comes first
+	xdgConfig := writeUserConfig(t, xdg)
+	if got, _ := GetConfigPath(config.CLIFlags{}); got != xdgConfig {
+		t.Errorf("config path = %s, want %s", got, xdgConfig)
+	}
+
+	// Neither: the default path, which the loader treats as built-in defaults
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if got, _ := GetConfigPath(config.CLIFlags{}); got != constants.DefaultConfigFile {
+		t.Errorf("no user config: config path = %s, want %s", got, constants.DefaultConfigFile)
+	}
+}
@@ -2,38 +2,93 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"mattermost-log-scrubber/constants"
+	"mattermost-log-scrubber/scrubber"
 )
 
 // FileSettings contains file-related configuration
 type FileSettings struct {
 	InputFile          string `json:"InputFile"`
+	InputList          string `json:"InputList"`
+	OutputDir          string `json:"OutputDir"`
 	OutputFile         string `json:"OutputFile"`
 	AuditFile          string `json:"AuditFile"`
 	AuditFileType      string `json:"AuditFileType"`
+	NoAuditTypes       string `json:"NoAuditTypes"`
+	AuditCSVDelimiter  string `json:"AuditCSVDelimiter"`
+	AuditCSVQuoteAll   bool   `json:"AuditCSVQuoteAll"`
 	CompressOutputFile bool   `json:"CompressOutputFile"`
 	OverwriteAction    string `json:"OverwriteAction"`
+	CancelScope        string `json:"CancelScope"`
+	RenameScheme       string `json:"RenameScheme"`
+	StateFile          string `json:"StateFile"`
+	RulesFile          string `json:"RulesFile"`
 }
 
 // ScrubSettings contains scrubbing-related configuration
 type ScrubSettings struct {
-	ScrubLevel int `json:"ScrubLevel"`
+	ScrubLevel          int                          `json:"ScrubLevel"`
+	FixedWidth          bool                         `json:"FixedWidth"`
+	IPStrategy          string                       `json:"IPStrategy"`
+	TimeFormat          string                       `json:"TimeFormat"`
+	PreservePatterns    []string                     `json:"PreservePatterns"`
+	ScrubPaths          []string                     `json:"ScrubPaths"`
+	FieldTypes          map[string]string            `json:"FieldTypes"`
+	PassthroughFields   []string                     `json:"PassthroughFields"`
+	StrictAllowlist     bool                         `json:"StrictAllowlist"`
+	LogKind             string                       `json:"LogKind"`
+	ContainerLogs       bool                         `json:"ContainerLogs"`
+	InputFormat         string                       `json:"InputFormat"`
+	Delimiter           string                       `json:"Delimiter"`
+	ScrubColumns        string                       `json:"ScrubColumns"`
+	RoleTokens          bool                         `json:"RoleTokens"`
+	HashMode            bool                         `json:"HashMode"`
+	HashPrefix          bool                         `json:"HashPrefix"`
+	NationalIDPatterns  []scrubber.NationalIDPattern `json:"NationalIDPatterns"`
+	DomainMapFile       string                       `json:"DomainMapFile"`
+	RedactListFile      string                       `json:"RedactListFile"`
+	InternalDomains     []string                     `json:"InternalDomains"`
+	EmailTemplate       string                       `json:"EmailTemplate"`
+	LenientEmails       bool                         `json:"LenientEmails"`
+	JSONFailureAction   string                       `json:"JSONFailureAction"`
+	MaxJSONFailureRate  float64                      `json:"MaxJSONFailureRate"`
+	MaskChar            string                       `json:"MaskChar"`
+	MaskChars           map[string]string            `json:"MaskChars"`
+	UserPrefix          string                       `json:"UserPrefix"`
+	DomainPrefix        string                       `json:"DomainPrefix"`
+	AlwaysScrub         []string                     `json:"AlwaysScrub"`
+	AllowShortUsernames bool                         `json:"AllowShortUsernames"`
+	Allowlist           []string                     `json:"Allowlist"`
 }
 
 // OutputSettings contains output-related configuration
 type OutputSettings struct {
-	Verbose bool `json:"Verbose"`
+	Verbose         bool   `json:"Verbose"`
+	SampleChanges   int    `json:"SampleChanges"`
+	SampleOriginals string `json:"SampleOriginals"`
 }
 
 // ProcessingSettings contains processing-related configuration
 type ProcessingSettings struct {
-	MaxInputFileSize string `json:"MaxInputFileSize"`
+	MaxInputFileSize string                   `json:"MaxInputFileSize"`
+	Throttle         string                   `json:"Throttle"`
+	ConfirmAboveSize string                   `json:"ConfirmAboveSize"`
+	TwoPass          bool                     `json:"TwoPass"`
+	Timeout          string                   `json:"Timeout"`
+	TimeoutOutput    string                   `json:"TimeoutOutput"`
+	CustomPatterns   []scrubber.CustomPattern `json:"CustomPatterns"`
 }
 
 // Config represents the complete configuration structure
@@ -42,6 +97,7 @@ type Config struct {
 	ScrubSettings       ScrubSettings       `json:"ScrubSettings"`
 	OutputSettings      OutputSettings      `json:"OutputSettings"`
 	ProcessingSettings  ProcessingSettings  `json:"ProcessingSettings"`
+	Profiles            map[string]ProfileSettings `json:"Profiles,omitempty"` // Named variants selected with --profile
 }
 
 // LoadConfig loads configuration from a JSON file
@@ -106,8 +162,23 @@ func parseFileSize(sizeStr string) (int64, error) {
 	return int64(size * float64(multiplier)), nil
 }
 
-// formatFileSize formats a file size in bytes to human-readable format
-func formatFileSize(bytes int64) string {
+// ParseList splits a comma-separated value into trimmed, lowercase, de-duplicated entries
+func ParseList(value string) []string {
+	var items []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		items = append(items, item)
+	}
+	return items
+}
+
+// FormatFileSize formats a file size in bytes to human-readable format
+func FormatFileSize(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
 		return fmt.Sprintf("%d B", bytes)
@@ -122,53 +193,253 @@ func formatFileSize(bytes int64) string {
 
 // ResolvedSettings contains all resolved configuration values
 type ResolvedSettings struct {
-	InputPath          string
-	OutputPath         string
+	InputPath          string   // First input file
+	InputPaths         []string // Every input file, after glob expansion
+	OutputPath         string   // Output file for the first input, or the output directory for several
+	OutputPaths        []string // Output file per input, in InputPaths order
+	InputList          string   // Text file listing more input paths, read by ReadInputList
+	OutputDir          string   // Directory for scrubbed output, named after each input
+	SkipMissing        bool     // Warn about and skip inputs that don't exist instead of failing
+	Recursive          bool     // Directory inputs include log files in their subdirectories
+	ExcludeGlobs       []string // Globs of directory input files to skip, matched against the name and the path in the directory
 	AuditPath          string
-	AuditFileType      string
+	AuditFileTypes     []string
+	NoAuditTypes       []string // Scrub types left out of the audit files
+	AuditCSVDelimiter  string   // Field delimiter of the CSV audit ("" = comma)
+	AuditCSVQuoteAll   bool     // Quote every CSV audit field
+	AuditOutputs       []AuditOutput // Resolved audit file per requested format
 	ScrubLevel         int
 	Verbose            bool
+	SampleChanges      int    // Before/after examples printed per type after the run (0 = none)
+	SampleOriginals    string // How sampled originals are shown: show, mask or hide
 	DryRun             bool
+	ToTemp             bool // With DryRun, write the scrubbed output to a temp directory for inspection
 	CompressOutputFile bool
 	OverwriteAction    string
 	MaxInputFileSize   int64
+	LineRange          string
+	ByteRange          string
+	InputRange         scrubber.InputRange // Parsed from LineRange or ByteRange by ValidateSettings
+	ConfirmAbove       string
+	ConfirmAboveSize   int64 // Ask before interactively scrubbing more than this many bytes (0 = never)
+	AssumeYes          bool
+	FixedWidth         bool
+	FailOnEmpty        bool
+	RequireChanges     bool
+	IPStrategy         string
+	TimeFormat         string // Format timestamps are normalized to (empty = unchanged)
+	MaskChar           string
+	MaskChars          map[string]string // key: scrub type -> mask character overriding MaskChar
+	UserPrefix         string            // Prefix of mapped users, e.g. user in user1
+	DomainPrefix       string            // Prefix of mapped domains, e.g. domain in domain1
+	AlwaysScrub        []string          // Literal values mapped to user tokens wherever they appear
+	AllowShortUsernames bool             // Also scrub always-scrub values short or generic enough to over-match
+	Allowlist          []string          // Literal and /regex/ values never scrubbed as emails, usernames or IPs
+	PreservePatterns   []string
+	NoOutput           bool
+	ScrubPaths         []string
+	FieldTypes         map[string]string
+	PassthroughFields  []string // JSON field names whose values are never changed
+	StrictAllowlist    bool // Only scrub ScrubPaths and FieldTypes values; leave everything else untouched
+	LogKind            string
+	ContainerLogs      bool
+	InputFormat        string // json, plain to skip JSON parsing, or delimited for tabular logs where only ScrubColumns are scrubbed
+	Delimiter          string // Column separator of delimited input, `\t` for a tab
+	ScrubColumns       string // Comma-separated 0-based indexes or header names, each optionally =type
+	RoleTokens         bool
+	HashMode           bool   // Derive user and domain tokens from a salted hash of the original
+	HashSalt           string // HMAC key of hash-mode tokens; CLI only, as it must stay private
+	HashPrefix         bool   // Start hash-mode tokens with a hash of the original's first characters
+	AuditHashOriginals bool
+	AuditSalt          string
+	NationalIDPatterns []scrubber.NationalIDPattern
+	DomainMapFile      string
+	DomainMap          map[string]string // Fixed domain mappings, loaded by ValidateSettings
+	RedactListFile     string
+	RedactList         []string          // Literal and /regex/ entries replaced with [redacted], loaded by ValidateSettings
+	InternalDomains    []string          // Email domains kept as-is; other email domains are removed
+	EmailTemplate      string            // Format of anonymized emails with {n}, {token} and {domain}
+	LenientEmails      bool              // Also detect emails with whitespace around the @ or wrapped in the domain
+	JSONFailureAction  string
+	MaxJSONFailureRate float64 // Fail the run when more of the non-empty lines aren't JSON (0 = never)
+	SplitSize          string
+	SplitBytes         int64 // Maximum bytes per output part (0 = single file)
+	CanonicalJSON      bool
+	CollapseRepeats    bool // Write runs of identical scrubbed lines once with a repeat count
+	Deterministic      bool // Byte-reproducible output and audit; implies CanonicalJSON
+	CustomPatterns     []scrubber.CustomPattern
+	Patterns           *scrubber.PatternSet // User-supplied regexes, compiled by ValidateSettings
+	ManifestPath       string
+	MetricsPath        string
+	StatsPath          string // Versioned JSON counts of the run, or "-" for standard output
+	TracePath          string // Per-line decision trace for debugging; holds original values
+	FrequencyReport    string
+	IdentityReport     string // Per-person grouping of original values and tokens; holds original values
+	HTMLReport         string // Human-readable summary page; anonymized values only
+	CancelScope        string
+	RenameScheme       string
+	Checksums          bool
+	Throttle           string
+	ThrottleLines      int64 // Lines per second (0 = unlimited)
+	ThrottleBytes      int64 // Bytes per second (0 = unlimited)
+	TwoPass            bool  // Build every user mapping in a first read of the inputs, then scrub
+	Timeout            string
+	TimeoutDuration    time.Duration // Wall-clock limit of the run (0 = none)
+	TimeoutOutput      string        // keep or discard the output of a run stopped by the timeout
+	Resume             bool   // Skip inputs a previous, interrupted batch completed, per the state file
+	StatePath          string // Batch progress file written while several inputs are processed
+	RulesFile          string // Rules file merged into the config before resolving, if any
+}
+
+// AuditOutput pairs an audit file format with the path it is written to
+type AuditOutput struct {
+	Type string
+	Path string
 }
 
 // CLIFlags represents command line flag values
 type CLIFlags struct {
-	InputFile       string
-	Input           string
+	InputFile       []string
+	Input           []string
+	InputList       string
+	OutputDir       string
+	SkipMissing     bool
+	Recursive       bool
+	ExcludeGlobs    []string
 	OutputFile      string
 	Output          string
 	Level           int
 	LevelLong       int
 	ConfigFile      string
 	ConfigLong      string
+	Rules           string
+	Profile         string
 	AuditFile       string
 	AuditLong       string
 	AuditType       string
+	NoAuditTypes    string
+	AuditCSVDelim   string
+	AuditCSVQuote   bool
 	OverwriteAction string
 	MaxFileSize     string
 	Verbose         bool
 	VerboseLong     bool
+	SampleChanges   int
+	SampleOrig      string
 	DryRun          bool
+	ToTemp          bool
 	Compress        bool
 	CompressLong    bool
+	FixedWidth      bool
+	FailOnEmpty     bool
+	RequireChanges  bool
+	IPStrategy      string
+	TimeFormat      string
+	MaskChar        string
+	MaskCharEmail   string
+	MaskCharUser    string
+	MaskCharIP      string
+	MaskCharUID     string
+	MaskCharHost    string
+	UserPrefix      string
+	DomainPrefix    string
+	NoOutput        bool
+	ScrubPaths      []string
+	Manifest        string
+	CancelScope     string
+	RenameScheme    string
+	LogKind         string
+	ContainerLogs   bool
+	InputFormat     string
+	Format          string // Short alias of InputFormat
+	Delimiter       string
+	ScrubColumns    string
+	RoleTokens      bool
+	Hash            bool
+	HashSalt        string
+	HashPrefix      bool
+	StrictAllowlist bool
+	HashOriginals   bool
+	AuditSalt       string
+	Checksums       bool
+	Throttle        string
+	TwoPass         bool
+	Timeout         string
+	TimeoutOutput   string
+	Resume          bool
+	StateFile       string
+	SplitSize       string
+	CanonicalJSON   bool
+	CollapseRepeats bool
+	Deterministic   bool
+	DomainMap       string
+	RedactList      string
+	InternalDomains string
+	Passthrough     string
+	EmailTemplate   string
+	LenientEmails   bool
+	AllowShortNames bool
+	SelfTest        bool
+	Reverse         bool
+	VerifyFixture   string
+	UpdateFixture   bool
+	FixtureArgs     []string // Positional arguments; the expected output for --verify-fixture
+	InitConfig      bool
+	PrintConfig     bool
+	Serve           bool   // The serve subcommand was given
+	ServeAddr       string // Listen address for serve mode
+	JSONFailAction  string
+	MaxJSONFailRate float64
+	ConfirmAbove    string
+	Metrics         string
+	Stats           string
+	Trace           string
+	FrequencyReport string
+	IdentityReport  string
+	HTMLReport      string
+	LineRange       string
+	ByteRange       string
+	Yes             bool
+	NoConfirm       bool
 }
 
 // ResolveSettings resolves final configuration values from CLI flags and config file
 // CLI flags take precedence over config file values when both are provided
-func ResolveSettings(flags CLIFlags, config *Config) ResolvedSettings {
+// The returned Provenance records which source each config-file setting was taken from
+func ResolveSettings(flags CLIFlags, config *Config) (ResolvedSettings, Provenance) {
 	settings := ResolvedSettings{}
+	sources := Provenance{}
 
-	// Resolve input path
-	settings.InputPath = flags.InputFile
-	if settings.InputPath == "" {
-		settings.InputPath = flags.Input
+	// Resolve input paths (-i and --input may each be repeated)
+	settings.InputPaths = append(append([]string{}, flags.InputFile...), flags.Input...)
+	if len(settings.InputPaths) == 0 && config != nil && config.FileSettings.InputFile != "" {
+		settings.InputPaths = []string{config.FileSettings.InputFile}
 	}
-	if settings.InputPath == "" && config != nil {
-		settings.InputPath = config.FileSettings.InputFile
+	if len(settings.InputPaths) > 0 {
+		settings.InputPath = settings.InputPaths[0]
 	}
+	sources.record("FileSettings.InputFile", len(flags.InputFile)+len(flags.Input) > 0, config != nil && config.FileSettings.InputFile != "")
+
+	// Resolve input list file
+	settings.InputList = flags.InputList
+	if settings.InputList == "" && config != nil {
+		settings.InputList = config.FileSettings.InputList
+	}
+	sources.record("FileSettings.InputList", flags.InputList != "", config != nil && config.FileSettings.InputList != "")
+
+	// Resolve output directory
+	settings.OutputDir = flags.OutputDir
+	if settings.OutputDir == "" && config != nil {
+		settings.OutputDir = config.FileSettings.OutputDir
+	}
+	sources.record("FileSettings.OutputDir", flags.OutputDir != "", config != nil && config.FileSettings.OutputDir != "")
+
+	// Set missing input handling (CLI only)
+	settings.SkipMissing = flags.SkipMissing
+
+	// Set recursive directory inputs and their exclusions (CLI only)
+	settings.Recursive = flags.Recursive
+	settings.ExcludeGlobs = flags.ExcludeGlobs
 
 	// Resolve output path
 	settings.OutputPath = flags.OutputFile
@@ -178,6 +449,7 @@ func ResolveSettings(flags CLIFlags, config *Config) ResolvedSettings {
 	if settings.OutputPath == "" && config != nil {
 		settings.OutputPath = config.FileSettings.OutputFile
 	}
+	sources.record("FileSettings.OutputFile", flags.OutputFile != "" || flags.Output != "", config != nil && config.FileSettings.OutputFile != "")
 
 	// Resolve scrub level
 	settings.ScrubLevel = flags.Level
@@ -187,12 +459,29 @@ func ResolveSettings(flags CLIFlags, config *Config) ResolvedSettings {
 	if settings.ScrubLevel == 0 && config != nil {
 		settings.ScrubLevel = config.ScrubSettings.ScrubLevel
 	}
+	sources.record("ScrubSettings.ScrubLevel", flags.Level != 0 || flags.LevelLong != 0, config != nil && config.ScrubSettings.ScrubLevel != 0)
 
 	// Resolve verbose setting
 	settings.Verbose = flags.Verbose || flags.VerboseLong
 	if !settings.Verbose && config != nil {
 		settings.Verbose = config.OutputSettings.Verbose
 	}
+	sources.record("OutputSettings.Verbose", flags.Verbose || flags.VerboseLong, config != nil && config.OutputSettings.Verbose)
+
+	// Resolve the change samples printed after the run
+	settings.SampleChanges = flags.SampleChanges
+	if settings.SampleChanges == 0 && config != nil {
+		settings.SampleChanges = config.OutputSettings.SampleChanges
+	}
+	sources.record("OutputSettings.SampleChanges", flags.SampleChanges != 0, config != nil && config.OutputSettings.SampleChanges != 0)
+	settings.SampleOriginals = strings.ToLower(flags.SampleOrig)
+	if settings.SampleOriginals == "" && config != nil {
+		settings.SampleOriginals = strings.ToLower(config.OutputSettings.SampleOriginals)
+	}
+	if settings.SampleOriginals == "" {
+		settings.SampleOriginals = constants.SampleOriginalsShow
+	}
+	sources.record("OutputSettings.SampleOriginals", flags.SampleOrig != "", config != nil && config.OutputSettings.SampleOriginals != "")
 
 	// Resolve audit path
 	settings.AuditPath = flags.AuditFile
@@ -202,24 +491,363 @@ func ResolveSettings(flags CLIFlags, config *Config) ResolvedSettings {
 	if settings.AuditPath == "" && config != nil {
 		settings.AuditPath = config.FileSettings.AuditFile
 	}
+	sources.record("FileSettings.AuditFile", flags.AuditFile != "" || flags.AuditLong != "", config != nil && config.FileSettings.AuditFile != "")
 
-	// Resolve audit file type
-	settings.AuditFileType = flags.AuditType
-	if settings.AuditFileType == "" && config != nil {
-		settings.AuditFileType = config.FileSettings.AuditFileType
+	// Resolve audit file types (comma-separated list, e.g. "csv,json")
+	auditType := flags.AuditType
+	if auditType == "" && config != nil {
+		auditType = config.FileSettings.AuditFileType
+	}
+	if auditType == "" {
+		auditType = constants.AuditTypeCSV
 	}
-	if settings.AuditFileType == "" {
-		settings.AuditFileType = constants.AuditTypeCSV
+	settings.AuditFileTypes = ParseList(auditType)
+	sources.record("FileSettings.AuditFileType", flags.AuditType != "", config != nil && config.FileSettings.AuditFileType != "")
+
+	// Resolve scrub types left out of the audit (comma-separated list, e.g. "uid,ip")
+	noAuditTypes := flags.NoAuditTypes
+	if noAuditTypes == "" && config != nil {
+		noAuditTypes = config.FileSettings.NoAuditTypes
 	}
+	settings.NoAuditTypes = ParseList(noAuditTypes)
+	sources.record("FileSettings.NoAuditTypes", flags.NoAuditTypes != "", config != nil && config.FileSettings.NoAuditTypes != "")
+
+	// Resolve the CSV audit format
+	settings.AuditCSVDelimiter = flags.AuditCSVDelim
+	if settings.AuditCSVDelimiter == "" && config != nil {
+		settings.AuditCSVDelimiter = config.FileSettings.AuditCSVDelimiter
+	}
+	sources.record("FileSettings.AuditCSVDelimiter", flags.AuditCSVDelim != "", config != nil && config.FileSettings.AuditCSVDelimiter != "")
+	settings.AuditCSVQuoteAll = flags.AuditCSVQuote
+	if !settings.AuditCSVQuoteAll && config != nil {
+		settings.AuditCSVQuoteAll = config.FileSettings.AuditCSVQuoteAll
+	}
+	sources.record("FileSettings.AuditCSVQuoteAll", flags.AuditCSVQuote, config != nil && config.FileSettings.AuditCSVQuoteAll)
 
 	// Set dry run (CLI only)
 	settings.DryRun = flags.DryRun
+	settings.ToTemp = flags.ToTemp
+
+	// Set no output (CLI only)
+	settings.NoOutput = flags.NoOutput
+
+	// Set manifest path (CLI only)
+	settings.ManifestPath = flags.Manifest
+
+	// Set metrics path (CLI only)
+	settings.MetricsPath = flags.Metrics
+
+	// Set stats path (CLI only)
+	settings.StatsPath = flags.Stats
+
+	// Set trace path (CLI only)
+	settings.TracePath = flags.Trace
+
+	// Set frequency report path (CLI only)
+	settings.FrequencyReport = flags.FrequencyReport
+
+	// Set identity report path (CLI only)
+	settings.IdentityReport = flags.IdentityReport
+
+	// Set HTML report path (CLI only)
+	settings.HTMLReport = flags.HTMLReport
+
+	// Set input range (CLI only); parsed by ValidateSettings
+	settings.LineRange = flags.LineRange
+	settings.ByteRange = flags.ByteRange
+
+	// Set checksum computation (CLI only)
+	settings.Checksums = flags.Checksums
+
+	// Set audit original hashing (CLI only)
+	settings.AuditHashOriginals = flags.HashOriginals
+	settings.AuditSalt = flags.AuditSalt
+
+	// Set output split size (CLI only); invalid values are reported by ValidateSettings
+	settings.SplitSize = flags.SplitSize
+	if settings.SplitSize != "" {
+		settings.SplitBytes, _ = parseFileSize(settings.SplitSize)
+	}
+
+	// Set deterministic mode (CLI only), which needs canonical JSON output
+	settings.Deterministic = flags.Deterministic
+
+	// Set canonical JSON output (CLI only)
+	settings.CanonicalJSON = flags.CanonicalJSON || settings.Deterministic
+
+	// Set repeated line collapsing (CLI only)
+	settings.CollapseRepeats = flags.CollapseRepeats
+
+	// Set fail on empty input (CLI only)
+	settings.FailOnEmpty = flags.FailOnEmpty
+
+	// Set require changes (CLI only)
+	settings.RequireChanges = flags.RequireChanges
 
 	// Resolve compression setting
 	settings.CompressOutputFile = flags.Compress || flags.CompressLong
 	if !settings.CompressOutputFile && config != nil {
 		settings.CompressOutputFile = config.FileSettings.CompressOutputFile
 	}
+	sources.record("FileSettings.CompressOutputFile", flags.Compress || flags.CompressLong, config != nil && config.FileSettings.CompressOutputFile)
+
+	// Resolve fixed-width mode
+	settings.FixedWidth = flags.FixedWidth
+	if !settings.FixedWidth && config != nil {
+		settings.FixedWidth = config.ScrubSettings.FixedWidth
+	}
+	sources.record("ScrubSettings.FixedWidth", flags.FixedWidth, config != nil && config.ScrubSettings.FixedWidth)
+
+	// Resolve IP strategy
+	settings.IPStrategy = flags.IPStrategy
+	if settings.IPStrategy == "" && config != nil {
+		settings.IPStrategy = config.ScrubSettings.IPStrategy
+	}
+	if settings.IPStrategy == "" {
+		settings.IPStrategy = constants.IPStrategyMask
+	}
+	sources.record("ScrubSettings.IPStrategy", flags.IPStrategy != "", config != nil && config.ScrubSettings.IPStrategy != "")
+
+	// Resolve timestamp normalization (off unless a format is given)
+	settings.TimeFormat = strings.ToLower(flags.TimeFormat)
+	if settings.TimeFormat == "" && config != nil {
+		settings.TimeFormat = strings.ToLower(config.ScrubSettings.TimeFormat)
+	}
+	sources.record("ScrubSettings.TimeFormat", flags.TimeFormat != "", config != nil && config.ScrubSettings.TimeFormat != "")
+
+	// Resolve mask characters; per-type flags override per-type config entries
+	settings.MaskChar = flags.MaskChar
+	if settings.MaskChar == "" && config != nil {
+		settings.MaskChar = config.ScrubSettings.MaskChar
+	}
+	if settings.MaskChar == "" {
+		settings.MaskChar = constants.DefaultMaskChar
+	}
+	sources.record("ScrubSettings.MaskChar", flags.MaskChar != "", config != nil && config.ScrubSettings.MaskChar != "")
+	settings.MaskChars = make(map[string]string)
+	if config != nil {
+		for valueType, char := range config.ScrubSettings.MaskChars {
+			settings.MaskChars[strings.ToLower(strings.TrimSpace(valueType))] = char
+		}
+	}
+	maskCharFlags := map[string]string{
+		constants.TypeEmail:    flags.MaskCharEmail,
+		constants.TypeUsername: flags.MaskCharUser,
+		constants.TypeIP:       flags.MaskCharIP,
+		constants.TypeUID:      flags.MaskCharUID,
+		constants.TypeHost:     flags.MaskCharHost,
+	}
+	maskCharsFromCLI := false
+	for valueType, char := range maskCharFlags {
+		if char != "" {
+			settings.MaskChars[valueType] = char
+			maskCharsFromCLI = true
+		}
+	}
+	sources.record("ScrubSettings.MaskChars", maskCharsFromCLI, config != nil && len(config.ScrubSettings.MaskChars) > 0)
+
+	// Resolve mapped-value prefixes
+	settings.UserPrefix = flags.UserPrefix
+	if settings.UserPrefix == "" && config != nil {
+		settings.UserPrefix = config.ScrubSettings.UserPrefix
+	}
+	if settings.UserPrefix == "" {
+		settings.UserPrefix = constants.UserTokenPrefix
+	}
+	sources.record("ScrubSettings.UserPrefix", flags.UserPrefix != "", config != nil && config.ScrubSettings.UserPrefix != "")
+	settings.DomainPrefix = flags.DomainPrefix
+	if settings.DomainPrefix == "" && config != nil {
+		settings.DomainPrefix = config.ScrubSettings.DomainPrefix
+	}
+	if settings.DomainPrefix == "" {
+		settings.DomainPrefix = constants.DomainTokenPrefix
+	}
+	sources.record("ScrubSettings.DomainPrefix", flags.DomainPrefix != "", config != nil && config.ScrubSettings.DomainPrefix != "")
+
+	// Resolve strict allowlist mode
+	settings.StrictAllowlist = flags.StrictAllowlist
+	if !settings.StrictAllowlist && config != nil {
+		settings.StrictAllowlist = config.ScrubSettings.StrictAllowlist
+	}
+	sources.record("ScrubSettings.StrictAllowlist", flags.StrictAllowlist, config != nil && config.ScrubSettings.StrictAllowlist)
+
+	// Resolve what happens to lines that aren't valid JSON
+	// Strict allowlist mode never scrubs them as plain text, so it redacts them by default
+	settings.JSONFailureAction = strings.ToLower(flags.JSONFailAction)
+	if settings.JSONFailureAction == "" && config != nil {
+		settings.JSONFailureAction = strings.ToLower(config.ScrubSettings.JSONFailureAction)
+	}
+	if settings.JSONFailureAction == "" && settings.StrictAllowlist {
+		settings.JSONFailureAction = constants.JSONFailureRedact
+	}
+	if settings.JSONFailureAction == "" {
+		settings.JSONFailureAction = constants.JSONFailureScrub
+	}
+	sources.record("ScrubSettings.JSONFailureAction", flags.JSONFailAction != "", config != nil && config.ScrubSettings.JSONFailureAction != "")
+
+	// Resolve the JSON failure rate that fails the run (0 = disabled)
+	settings.MaxJSONFailureRate = flags.MaxJSONFailRate
+	if settings.MaxJSONFailureRate == 0 && config != nil {
+		settings.MaxJSONFailureRate = config.ScrubSettings.MaxJSONFailureRate
+	}
+	sources.record("ScrubSettings.MaxJSONFailureRate", flags.MaxJSONFailRate != 0, config != nil && config.ScrubSettings.MaxJSONFailureRate != 0)
+
+	// Resolve preserve patterns (config only)
+	if config != nil {
+		settings.PreservePatterns = config.ScrubSettings.PreservePatterns
+	}
+	sources.record("ScrubSettings.PreservePatterns", false, len(settings.PreservePatterns) > 0)
+
+	// Resolve always-scrub values (config only)
+	if config != nil {
+		settings.AlwaysScrub = config.ScrubSettings.AlwaysScrub
+	}
+	sources.record("ScrubSettings.AlwaysScrub", false, len(settings.AlwaysScrub) > 0)
+
+	// Resolve whether short and generic always-scrub values are used
+	settings.AllowShortUsernames = flags.AllowShortNames
+	if !settings.AllowShortUsernames && config != nil {
+		settings.AllowShortUsernames = config.ScrubSettings.AllowShortUsernames
+	}
+	sources.record("ScrubSettings.AllowShortUsernames", flags.AllowShortNames, config != nil && config.ScrubSettings.AllowShortUsernames)
+
+	// Resolve the allowlist (config only); an empty list in the config turns off the default
+	settings.Allowlist = scrubber.DefaultAllowlist
+	if config != nil && config.ScrubSettings.Allowlist != nil {
+		settings.Allowlist = config.ScrubSettings.Allowlist
+	}
+	sources.record("ScrubSettings.Allowlist", false, config != nil && config.ScrubSettings.Allowlist != nil)
+
+	// Resolve field-to-type mapping (config only)
+	if config != nil {
+		settings.FieldTypes = config.ScrubSettings.FieldTypes
+	}
+	sources.record("ScrubSettings.FieldTypes", false, len(settings.FieldTypes) > 0)
+
+	// Resolve passthrough fields (comma-separated list, e.g. "trace_id,level")
+	settings.PassthroughFields = ParseList(flags.Passthrough)
+	if len(settings.PassthroughFields) == 0 && config != nil {
+		settings.PassthroughFields = ParseList(strings.Join(config.ScrubSettings.PassthroughFields, ","))
+	}
+	sources.record("ScrubSettings.PassthroughFields", flags.Passthrough != "", config != nil && len(config.ScrubSettings.PassthroughFields) > 0)
+
+	// Resolve role-based user tokens
+	settings.RoleTokens = flags.RoleTokens
+	if !settings.RoleTokens && config != nil {
+		settings.RoleTokens = config.ScrubSettings.RoleTokens
+	}
+	sources.record("ScrubSettings.RoleTokens", flags.RoleTokens, config != nil && config.ScrubSettings.RoleTokens)
+
+	// Resolve hash-mode tokens; the salt is CLI only, so it never ends up in a shared config or report
+	settings.HashMode = flags.Hash
+	if !settings.HashMode && config != nil {
+		settings.HashMode = config.ScrubSettings.HashMode
+	}
+	sources.record("ScrubSettings.HashMode", flags.Hash, config != nil && config.ScrubSettings.HashMode)
+	settings.HashSalt = flags.HashSalt
+	settings.HashPrefix = flags.HashPrefix
+	if !settings.HashPrefix && config != nil {
+		settings.HashPrefix = config.ScrubSettings.HashPrefix
+	}
+	sources.record("ScrubSettings.HashPrefix", flags.HashPrefix, config != nil && config.ScrubSettings.HashPrefix)
+
+	// Resolve national ID patterns (config only)
+	if config != nil {
+		settings.NationalIDPatterns = config.ScrubSettings.NationalIDPatterns
+	}
+	sources.record("ScrubSettings.NationalIDPatterns", false, len(settings.NationalIDPatterns) > 0)
+
+	// Resolve domain map file
+	settings.DomainMapFile = flags.DomainMap
+	if settings.DomainMapFile == "" && config != nil {
+		settings.DomainMapFile = config.ScrubSettings.DomainMapFile
+	}
+	sources.record("ScrubSettings.DomainMapFile", flags.DomainMap != "", config != nil && config.ScrubSettings.DomainMapFile != "")
+
+	// Resolve redact list file
+	settings.RedactListFile = flags.RedactList
+	if settings.RedactListFile == "" && config != nil {
+		settings.RedactListFile = config.ScrubSettings.RedactListFile
+	}
+	sources.record("ScrubSettings.RedactListFile", flags.RedactList != "", config != nil && config.ScrubSettings.RedactListFile != "")
+
+	// Resolve internal email domains (comma-separated list, e.g. "acme.com,acme.net")
+	settings.InternalDomains = ParseList(flags.InternalDomains)
+	if len(settings.InternalDomains) == 0 && config != nil {
+		settings.InternalDomains = ParseList(strings.Join(config.ScrubSettings.InternalDomains, ","))
+	}
+	sources.record("ScrubSettings.InternalDomains", flags.InternalDomains != "", config != nil && len(config.ScrubSettings.InternalDomains) > 0)
+
+	// Resolve the anonymized email format
+	settings.EmailTemplate = flags.EmailTemplate
+	if settings.EmailTemplate == "" && config != nil {
+		settings.EmailTemplate = config.ScrubSettings.EmailTemplate
+	}
+	if settings.EmailTemplate == "" {
+		settings.EmailTemplate = constants.DefaultEmailTemplate
+	}
+	sources.record("ScrubSettings.EmailTemplate", flags.EmailTemplate != "", config != nil && config.ScrubSettings.EmailTemplate != "")
+
+	// Resolve lenient email detection
+	settings.LenientEmails = flags.LenientEmails
+	if !settings.LenientEmails && config != nil {
+		settings.LenientEmails = config.ScrubSettings.LenientEmails
+	}
+	sources.record("ScrubSettings.LenientEmails", flags.LenientEmails, config != nil && config.ScrubSettings.LenientEmails)
+
+	// Resolve log kind
+	settings.LogKind = strings.ToLower(flags.LogKind)
+	if settings.LogKind == "" && config != nil {
+		settings.LogKind = strings.ToLower(config.ScrubSettings.LogKind)
+	}
+	if settings.LogKind == "" {
+		settings.LogKind = constants.LogKindAuto
+	}
+	sources.record("ScrubSettings.LogKind", flags.LogKind != "", config != nil && config.ScrubSettings.LogKind != "")
+
+	// Resolve container log mode
+	settings.ContainerLogs = flags.ContainerLogs
+	if !settings.ContainerLogs && config != nil {
+		settings.ContainerLogs = config.ScrubSettings.ContainerLogs
+	}
+	sources.record("ScrubSettings.ContainerLogs", flags.ContainerLogs, config != nil && config.ScrubSettings.ContainerLogs)
+
+	// Resolve input format
+	settings.InputFormat = strings.ToLower(flags.InputFormat)
+	if settings.InputFormat == "" {
+		settings.InputFormat = strings.ToLower(flags.Format)
+	}
+	if settings.InputFormat == "" && config != nil {
+		settings.InputFormat = strings.ToLower(config.ScrubSettings.InputFormat)
+	}
+	if settings.InputFormat == "" {
+		settings.InputFormat = constants.InputFormatJSON
+	}
+	sources.record("ScrubSettings.InputFormat", flags.InputFormat != "" || flags.Format != "", config != nil && config.ScrubSettings.InputFormat != "")
+
+	// Resolve the column delimiter of delimited input (default: tab)
+	settings.Delimiter = flags.Delimiter
+	if settings.Delimiter == "" && config != nil {
+		settings.Delimiter = config.ScrubSettings.Delimiter
+	}
+	if settings.Delimiter == "" && settings.InputFormat == constants.InputFormatDelimited {
+		settings.Delimiter = `\t`
+	}
+	sources.record("ScrubSettings.Delimiter", flags.Delimiter != "", config != nil && config.ScrubSettings.Delimiter != "")
+
+	// Resolve the columns of delimited input to scrub
+	settings.ScrubColumns = flags.ScrubColumns
+	if settings.ScrubColumns == "" && config != nil {
+		settings.ScrubColumns = config.ScrubSettings.ScrubColumns
+	}
+	sources.record("ScrubSettings.ScrubColumns", flags.ScrubColumns != "", config != nil && config.ScrubSettings.ScrubColumns != "")
+
+	// Resolve scrub paths - CLI flags replace the config file list
+	settings.ScrubPaths = flags.ScrubPaths
+	if len(settings.ScrubPaths) == 0 && config != nil {
+		settings.ScrubPaths = config.ScrubSettings.ScrubPaths
+	}
+	sources.record("ScrubSettings.ScrubPaths", len(flags.ScrubPaths) > 0, config != nil && len(config.ScrubSettings.ScrubPaths) > 0)
 
 	// Resolve overwrite action
 	settings.OverwriteAction = flags.OverwriteAction
@@ -229,12 +857,34 @@ func ResolveSettings(flags CLIFlags, config *Config) ResolvedSettings {
 	if settings.OverwriteAction == "" {
 		settings.OverwriteAction = constants.OverwritePrompt
 	}
+	sources.record("FileSettings.OverwriteAction", flags.OverwriteAction != "", config != nil && config.FileSettings.OverwriteAction != "")
+
+	// Resolve cancel scope
+	settings.CancelScope = flags.CancelScope
+	if settings.CancelScope == "" && config != nil {
+		settings.CancelScope = config.FileSettings.CancelScope
+	}
+	if settings.CancelScope == "" {
+		settings.CancelScope = constants.CancelScopeRun
+	}
+	sources.record("FileSettings.CancelScope", flags.CancelScope != "", config != nil && config.FileSettings.CancelScope != "")
+
+	// Resolve rename scheme
+	settings.RenameScheme = strings.ToLower(flags.RenameScheme)
+	if settings.RenameScheme == "" && config != nil {
+		settings.RenameScheme = strings.ToLower(config.FileSettings.RenameScheme)
+	}
+	if settings.RenameScheme == "" {
+		settings.RenameScheme = constants.RenameSchemeTimestamp
+	}
+	sources.record("FileSettings.RenameScheme", flags.RenameScheme != "", config != nil && config.FileSettings.RenameScheme != "")
 
 	// Resolve max input file size - CLI flags take precedence over config file
 	maxFileSizeStr := flags.MaxFileSize
 	if maxFileSizeStr == "" && config != nil {
 		maxFileSizeStr = config.ProcessingSettings.MaxInputFileSize
 	}
+	sources.record("ProcessingSettings.MaxInputFileSize", flags.MaxFileSize != "", config != nil && config.ProcessingSettings.MaxInputFileSize != "")
 	
 	var err error
 	settings.MaxInputFileSize, err = parseFileSize(maxFileSizeStr)
@@ -243,15 +893,502 @@ func ResolveSettings(flags CLIFlags, config *Config) ResolvedSettings {
 		settings.MaxInputFileSize = constants.DefaultMaxFileSize
 	}
 
-	return settings
+	// Resolve large input confirmation threshold; invalid values are reported by ValidateSettings
+	settings.ConfirmAbove = flags.ConfirmAbove
+	if settings.ConfirmAbove == "" && config != nil {
+		settings.ConfirmAbove = config.ProcessingSettings.ConfirmAboveSize
+	}
+	sources.record("ProcessingSettings.ConfirmAboveSize", flags.ConfirmAbove != "", config != nil && config.ProcessingSettings.ConfirmAboveSize != "")
+	settings.ConfirmAboveSize = constants.DefaultConfirmSize
+	if settings.ConfirmAbove != "" {
+		settings.ConfirmAboveSize, _ = parseFileSize(settings.ConfirmAbove)
+	}
+
+	// Skip confirmations (CLI only)
+	settings.AssumeYes = flags.Yes || flags.NoConfirm
+
+	// Resolve processing throttle - CLI flags take precedence over config file
+	settings.Throttle = flags.Throttle
+	if settings.Throttle == "" && config != nil {
+		settings.Throttle = config.ProcessingSettings.Throttle
+	}
+	sources.record("ProcessingSettings.Throttle", flags.Throttle != "", config != nil && config.ProcessingSettings.Throttle != "")
+	// Invalid values are reported by ValidateSettings
+	settings.ThrottleLines, settings.ThrottleBytes, _ = parseThrottle(settings.Throttle)
+
+	// Resolve the run timeout and what happens to the output when it is reached
+	settings.Timeout = flags.Timeout
+	if settings.Timeout == "" && config != nil {
+		settings.Timeout = config.ProcessingSettings.Timeout
+	}
+	sources.record("ProcessingSettings.Timeout", flags.Timeout != "", config != nil && config.ProcessingSettings.Timeout != "")
+	// Invalid values are reported by ValidateSettings
+	settings.TimeoutDuration, _ = parseTimeout(settings.Timeout)
+	settings.TimeoutOutput = strings.ToLower(flags.TimeoutOutput)
+	if settings.TimeoutOutput == "" && config != nil {
+		settings.TimeoutOutput = strings.ToLower(config.ProcessingSettings.TimeoutOutput)
+	}
+	if settings.TimeoutOutput == "" {
+		settings.TimeoutOutput = constants.TimeoutOutputKeep
+	}
+	sources.record("ProcessingSettings.TimeoutOutput", flags.TimeoutOutput != "", config != nil && config.ProcessingSettings.TimeoutOutput != "")
+
+	// Resolve two-pass mode
+	settings.TwoPass = flags.TwoPass
+	if !settings.TwoPass && config != nil {
+		settings.TwoPass = config.ProcessingSettings.TwoPass
+	}
+	sources.record("ProcessingSettings.TwoPass", flags.TwoPass, config != nil && config.ProcessingSettings.TwoPass)
+
+	// Resolve custom patterns (config only)
+	if config != nil {
+		settings.CustomPatterns = config.ProcessingSettings.CustomPatterns
+	}
+	sources.record("ProcessingSettings.CustomPatterns", false, len(settings.CustomPatterns) > 0)
+
+	// Set resume (CLI only)
+	settings.Resume = flags.Resume
+
+	// Resolve the batch state file, kept with the output by default
+	settings.StatePath = flags.StateFile
+	if settings.StatePath == "" && config != nil {
+		settings.StatePath = config.FileSettings.StateFile
+	}
+	sources.record("FileSettings.StateFile", flags.StateFile != "", config != nil && config.FileSettings.StateFile != "")
+	if settings.StatePath == "" && !scrubber.IsObjectStoreURI(settings.OutputDir) {
+		settings.StatePath = filepath.Join(settings.OutputDir, constants.DefaultStateFile)
+	}
+	if settings.StatePath == "" {
+		settings.StatePath = constants.DefaultStateFile
+	}
+
+	// Record the rules file; its rules were already merged into the config by ApplyRules
+	settings.RulesFile = flags.Rules
+	if settings.RulesFile == "" && config != nil {
+		settings.RulesFile = config.FileSettings.RulesFile
+	}
+	sources.record("FileSettings.RulesFile", flags.Rules != "", config != nil && config.FileSettings.RulesFile != "")
+
+	return settings, sources
+}
+
+// parseTimeout parses a run timeout such as "90s" or "10m"; empty or "0" means no timeout
+func parseTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid timeout '%s': use a duration such as 90s or 10m", value)
+	}
+	return timeout, nil
 }
 
-// ValidateSettings validates the resolved configuration settings
-func ValidateSettings(settings ResolvedSettings) error {
-	if settings.InputPath == "" {
+// parseThrottle parses a processing rate limit such as "2000" or "2000/s" (lines per second)
+// or "5MB" / "5MB/s" (bytes per second). Returns the lines and bytes per second limits.
+func parseThrottle(value string) (int64, int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, 0, nil
+	}
+	rate := strings.TrimSuffix(strings.ToLower(value), "/s")
+
+	// A bare number is a line rate
+	if lines, err := strconv.ParseInt(strings.TrimSpace(rate), 10, 64); err == nil {
+		if lines <= 0 {
+			return 0, 0, fmt.Errorf("throttle must be greater than zero: %s", value)
+		}
+		return lines, 0, nil
+	}
+
+	// A size with a unit is a byte rate
+	bytes, err := parseFileSize(rate)
+	if err != nil || bytes <= 0 {
+		return 0, 0, fmt.Errorf("invalid throttle: %s (expected lines per second like '2000' or a size per second like '5MB')", value)
+	}
+	return 0, bytes, nil
+}
+
+// parseInputRange parses --line-range or --byte-range values of the form START:END, where either
+// side may be omitted. Byte offsets accept size units like 1GB.
+func parseInputRange(lineRange, byteRange string) (scrubber.InputRange, error) {
+	if lineRange != "" && byteRange != "" {
+		return scrubber.InputRange{}, fmt.Errorf("use either --line-range or --byte-range, not both")
+	}
+
+	value, flagName, parse := lineRange, "--line-range", func(s string) (int64, error) {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	if byteRange != "" {
+		value, flagName, parse = byteRange, "--byte-range", parseFileSize
+	}
+
+	startStr, endStr, found := strings.Cut(value, ":")
+	if !found || (strings.TrimSpace(startStr) == "" && strings.TrimSpace(endStr) == "") {
+		return scrubber.InputRange{}, fmt.Errorf("invalid %s: %s (expected START:END, e.g. 500000:520000; either side may be omitted)", flagName, value)
+	}
+
+	inputRange := scrubber.InputRange{ByBytes: byteRange != ""}
+	var err error
+	if strings.TrimSpace(startStr) != "" {
+		if inputRange.Start, err = parse(strings.TrimSpace(startStr)); err != nil || inputRange.Start < 0 {
+			return scrubber.InputRange{}, fmt.Errorf("invalid %s start: %s", flagName, startStr)
+		}
+	}
+	if strings.TrimSpace(endStr) != "" {
+		if inputRange.End, err = parse(strings.TrimSpace(endStr)); err != nil || inputRange.End <= 0 {
+			return scrubber.InputRange{}, fmt.Errorf("invalid %s end: %s", flagName, endStr)
+		}
+	}
+	if inputRange.End > 0 && inputRange.End < inputRange.Start {
+		return scrubber.InputRange{}, fmt.Errorf("invalid %s: end %d is before start %d", flagName, inputRange.End, inputRange.Start)
+	}
+	return inputRange, nil
+}
+
+// ReadInputList reads input paths from a text file, one per line
+// Blank lines and lines starting with # are ignored; paths may also be glob patterns
+func ReadInputList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input list: %w", err)
+	}
+
+	var inputs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		inputs = append(inputs, line)
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("input list '%s' contains no input paths", path)
+	}
+	return inputs, nil
+}
+
+// DropMissingInputs removes input files that don't exist and returns them, for --skip-missing
+// Socket inputs and standard input are kept, since they can only be checked by reading them
+func DropMissingInputs(settings *ResolvedSettings) []string {
+	var kept, missing []string
+	for _, inputPath := range settings.InputPaths {
+		if _, err := os.Stat(inputPath); os.IsNotExist(err) && !strings.HasPrefix(inputPath, constants.UnixSocketScheme) && !scrubber.IsStdio(inputPath) {
+			missing = append(missing, inputPath)
+			continue
+		}
+		kept = append(kept, inputPath)
+	}
+
+	settings.InputPaths = kept
+	settings.InputPath = ""
+	if len(kept) > 0 {
+		settings.InputPath = kept[0]
+	}
+	return missing
+}
+
+// InputMatch records how many files an input glob pattern or directory expanded to
+type InputMatch struct {
+	Pattern   string
+	Count     int
+	Directory bool // Pattern is a directory, expanded to the log files it contains
+	Skipped   int  // Log files in the directory left out as earlier output or by --exclude-glob
+}
+
+// directoryLogFiles returns the log files in a directory, in lexical order: *.log and rotated or
+// compressed *.log.* files such as mattermost.log.1 and mattermost.log.2.gz. Files scrubbed or
+// restored by an earlier run (*_scrubbed.*, *_restored.*) are skipped, so a directory can be scrubbed
+// again in place, as are files matching one of the exclude globs. With recursive, subdirectories are
+// searched too. Also returns the number of log files skipped.
+func directoryLogFiles(dir string, recursive bool, excludeGlobs []string) ([]string, int, error) {
+	var paths []string
+	skipped := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isLogFileName(entry.Name()) || !entry.Type().IsRegular() {
+			return nil
+		}
+		if isScrubberOutput(entry.Name()) || isExcluded(dir, path, excludeGlobs) {
+			skipped++
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading input directory '%s': %w", dir, err)
+	}
+	return paths, skipped, nil
+}
+
+// isLogFileName reports whether a file name is a log or rotated log
+func isLogFileName(name string) bool {
+	return strings.HasSuffix(name, constants.ExtLog) || strings.Contains(name, constants.ExtLog+".")
+}
+
+// isScrubberOutput reports whether a file name is the output of an earlier scrub or restore
+func isScrubberOutput(name string) bool {
+	return strings.Contains(name, constants.ScrubSuffix+".") || strings.Contains(name, constants.RestoredSuffix+".")
+}
+
+// isExcluded reports whether a file found in dir matches an exclude glob, either by its name or by
+// its slash-separated path inside dir, so both '*_old.log' and 'archive/*' can be used
+func isExcluded(dir, file string, excludeGlobs []string) bool {
+	name := filepath.Base(file)
+	relative, err := filepath.Rel(dir, file)
+	if err != nil {
+		relative = name
+	}
+	relative = filepath.ToSlash(relative)
+	for _, glob := range excludeGlobs {
+		// Globs were checked by ExpandInputs, so a match can't fail
+		if matched, _ := filepath.Match(glob, name); matched {
+			return true
+		}
+		if matched, _ := path.Match(glob, relative); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGlobMeta reports whether an input path contains glob metacharacters
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// ExpandInputs expands glob patterns in the input paths with filepath.Glob, so wildcards work
+// even where the shell passes them through unexpanded (e.g. Windows). Files matched more than
+// once are processed once. A directory expands to the log files it contains, see directoryLogFiles.
+// Returns the match count per pattern or directory; one matching nothing is an error.
+func ExpandInputs(settings *ResolvedSettings) ([]InputMatch, error) {
+	for _, glob := range settings.ExcludeGlobs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude glob '%s': %w", glob, err)
+		}
+	}
+
+	var matches []InputMatch
+	var expanded []string
+	seen := make(map[string]bool)
+	directories := 0
+	for _, input := range settings.InputPaths {
+		paths := []string{input}
+		if info, err := os.Stat(input); err == nil && info.IsDir() {
+			directories++
+			var skipped int
+			paths, skipped, err = directoryLogFiles(input, settings.Recursive, settings.ExcludeGlobs)
+			if err != nil {
+				return nil, err
+			}
+			if len(paths) == 0 && skipped > 0 {
+				return nil, fmt.Errorf("input directory '%s' has no log files left after skipping %d", input, skipped)
+			}
+			if len(paths) == 0 {
+				return nil, fmt.Errorf("input directory '%s' contains no log files (*.log or *.log.*)", input)
+			}
+			matches = append(matches, InputMatch{Pattern: input, Count: len(paths), Directory: true, Skipped: skipped})
+		} else if !strings.HasPrefix(input, constants.UnixSocketScheme) && hasGlobMeta(input) {
+			var err error
+			paths, err = filepath.Glob(input)
+			if err != nil {
+				return nil, fmt.Errorf("invalid input pattern '%s': %w", input, err)
+			}
+			if len(paths) == 0 {
+				return nil, fmt.Errorf("input pattern '%s' matched no files", input)
+			}
+			matches = append(matches, InputMatch{Pattern: input, Count: len(paths)})
+		}
+		for _, path := range paths {
+			if !seen[path] {
+				seen[path] = true
+				expanded = append(expanded, path)
+			}
+		}
+	}
+
+	// Exclusions only filter directory walks, so without one they would silently do nothing
+	if len(settings.ExcludeGlobs) > 0 && directories == 0 {
+		return nil, fmt.Errorf("--exclude-glob applies to directory inputs, but no input is a directory")
+	}
+
+	settings.InputPaths = expanded
+	if len(expanded) > 0 {
+		settings.InputPath = expanded[0]
+	}
+	return matches, nil
+}
+
+// Artifact is a file a run writes besides the scrubbed log and audit, with the flag that requests it
+type Artifact struct {
+	Flag string
+	Path string
+}
+
+// ReportArtifacts returns the reports and other files a run writes besides the scrubbed log and audit,
+// with an empty path for those not requested. Each is written under the overwrite action, so a new
+// one belongs here to be listed with the other existing files before a run overwrites them.
+func ReportArtifacts(settings ResolvedSettings) []Artifact {
+	return []Artifact{
+		{"--manifest", settings.ManifestPath},
+		{"--metrics", settings.MetricsPath},
+		{"--stats", settings.StatsPath},
+		{"--trace", settings.TracePath},
+		{"--frequency-report", settings.FrequencyReport},
+		{"--identity-report", settings.IdentityReport},
+		{"--report-html", settings.HTMLReport},
+	}
+}
+
+// ValidateSettings validates the resolved configuration settings, including the input and output paths
+// User-supplied regular expressions are compiled here once and stored in settings.Patterns,
+// and the domain map file is loaded into settings.DomainMap
+func ValidateSettings(settings *ResolvedSettings) error {
+	if len(settings.InputPaths) == 0 {
 		return fmt.Errorf("input file path is required")
 	}
 
+	if err := ValidateScrubSettings(settings); err != nil {
+		return err
+	}
+
+	// An output directory replaces the output path
+	if settings.OutputDir != "" {
+		if settings.OutputPath != "" {
+			return fmt.Errorf("use either --output or --output-dir, not both")
+		}
+		if info, err := os.Stat(settings.OutputDir); !scrubber.IsObjectStoreURI(settings.OutputDir) && (err != nil || !info.IsDir()) {
+			return fmt.Errorf("output directory '%s' does not exist", settings.OutputDir)
+		}
+	}
+
+	// The output and audit may go to an S3-compatible bucket; every other file stays local
+	if scrubber.IsObjectStoreURI(settings.OutputDir) || scrubber.IsObjectStoreURI(settings.OutputPath) || scrubber.IsObjectStoreURI(settings.AuditPath) {
+		if err := scrubber.ValidateObjectStore(); err != nil {
+			return err
+		}
+		if settings.ManifestPath != "" {
+			return fmt.Errorf("--manifest reads back every artifact to checksum it, so it needs local output and audit paths")
+		}
+		if settings.TimeoutDuration > 0 && settings.TimeoutOutput == constants.TimeoutOutputDiscard {
+			return fmt.Errorf("--timeout-output discard removes a partial output, so it needs a local output path")
+		}
+		if settings.Resume {
+			return fmt.Errorf("--resume verifies completed outputs on disk, so it needs local output paths")
+		}
+	}
+	localArtifacts := append(ReportArtifacts(*settings), Artifact{"--state-file", settings.StatePath})
+	for _, artifact := range localArtifacts {
+		if scrubber.IsObjectStoreURI(artifact.Path) {
+			return fmt.Errorf("only the output and audit can be written to an object store; %s must be a local path", artifact.Flag)
+		}
+	}
+
+	// Standard output holds a single stream, and nothing can be read back from or removed from it
+	if scrubber.IsStdio(settings.OutputPath) {
+		switch {
+		case len(settings.InputPaths) > 1:
+			return fmt.Errorf("with %d input files, --output - can't write one scrubbed file per input to standard output", len(settings.InputPaths))
+		case settings.SplitSize != "":
+			return fmt.Errorf("--split-size writes several part files, so it needs an output path rather than standard output")
+		case settings.ManifestPath != "":
+			return fmt.Errorf("--manifest reads back every artifact to checksum it, so it needs an output path rather than standard output")
+		case settings.TimeoutDuration > 0 && settings.TimeoutOutput == constants.TimeoutOutputDiscard:
+			return fmt.Errorf("--timeout-output discard removes a partial output, so it needs an output path rather than standard output")
+		case settings.Resume:
+			return fmt.Errorf("--resume verifies completed outputs on disk, so it needs an output path rather than standard output")
+		case scrubber.IsStdio(settings.StatsPath):
+			return fmt.Errorf("--stats - and --output - can't both write to standard output")
+		}
+	}
+
+	// With several inputs, an explicit output must be a directory (or key prefix) to hold one scrubbed file per input
+	if len(settings.InputPaths) > 1 && settings.OutputPath != "" && !scrubber.IsObjectStoreURI(settings.OutputPath) {
+		if info, err := os.Stat(settings.OutputPath); err != nil || !info.IsDir() {
+			return fmt.Errorf("with %d input files, --output must be an existing directory", len(settings.InputPaths))
+		}
+	}
+
+	// Only a window of the input is scrubbed with a range, so the size limit doesn't apply
+	maxInputFileSize := settings.MaxInputFileSize
+	if settings.InputRange != (scrubber.InputRange{}) {
+		maxInputFileSize = math.MaxInt64
+	}
+	for _, inputPath := range settings.InputPaths {
+		if scrubber.IsStdio(inputPath) {
+			if settings.TwoPass {
+				return fmt.Errorf("--two-pass reads each input twice, which isn't possible for standard input")
+			}
+			if settings.Resume {
+				return fmt.Errorf("--resume re-reads completed inputs, which isn't possible for standard input")
+			}
+		}
+		if settings.TwoPass && scrubber.IsUnixSocketInput(inputPath) {
+			return fmt.Errorf("--two-pass reads each input twice, which isn't possible for socket input '%s'", inputPath)
+		}
+		if settings.Resume && scrubber.IsUnixSocketInput(inputPath) {
+			return fmt.Errorf("--resume re-reads completed inputs, which isn't possible for socket input '%s'", inputPath)
+		}
+		if err := validateInputFile(inputPath, maxInputFileSize); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateScrubSettings validates the settings that control scrubbing, without checking inputs or outputs,
+// then compiles their regular expressions and loads the domain map and redact list they name
+// Serve mode uses it on its own at startup, since its input arrives with each request
+func ValidateScrubSettings(settings *ResolvedSettings) error {
+	if err := CheckScrubSettings(settings); err != nil {
+		return err
+	}
+
+	// Compile user-supplied regular expressions so errors surface before processing
+	patterns, err := scrubber.CompilePatterns(settings.PreservePatterns, settings.NationalIDPatterns)
+	if err != nil {
+		return fmt.Errorf("invalid ScrubSettings: %w", err)
+	}
+	if err := patterns.AddCustomPatterns(settings.CustomPatterns); err != nil {
+		return fmt.Errorf("invalid ProcessingSettings: %w", err)
+	}
+	settings.Patterns = patterns
+
+	// Load fixed domain mappings
+	if settings.DomainMapFile != "" {
+		domainMap, err := scrubber.LoadDomainMap(settings.DomainMapFile)
+		if err != nil {
+			return err
+		}
+		settings.DomainMap = domainMap
+	}
+
+	// Load the redact list
+	if settings.RedactListFile != "" {
+		redactList, err := scrubber.LoadRedactList(settings.RedactListFile)
+		if err != nil {
+			return err
+		}
+		settings.RedactList = redactList
+	}
+
+	return nil
+}
+
+// CheckScrubSettings validates the settings that control scrubbing without compiling patterns or
+// loading files, so serve mode can check the settings a request overrides against ones validated once
+func CheckScrubSettings(settings *ResolvedSettings) error {
 	if settings.ScrubLevel < constants.ScrubLevelLow || settings.ScrubLevel > constants.ScrubLevelHigh {
 		return fmt.Errorf("scrubbing level must be %d, %d, or %d", 
 			constants.ScrubLevelLow, constants.ScrubLevelMedium, constants.ScrubLevelHigh)
@@ -276,23 +1413,269 @@ func ValidateSettings(settings ResolvedSettings) error {
 			constants.OverwritePrompt, constants.OverwriteOverwrite, constants.OverwriteTimestamp, constants.OverwriteCancel)
 	}
 
+	// Validate cancel scope
+	if settings.CancelScope != constants.CancelScopeRun && settings.CancelScope != constants.CancelScopeFile {
+		return fmt.Errorf("cancel scope must be one of: %s, %s", constants.CancelScopeRun, constants.CancelScopeFile)
+	}
+
+	// Validate rename scheme
+	if settings.RenameScheme != constants.RenameSchemeTimestamp && settings.RenameScheme != constants.RenameSchemeSequential {
+		return fmt.Errorf("rename scheme must be one of: %s, %s", constants.RenameSchemeTimestamp, constants.RenameSchemeSequential)
+	}
+
+	// Validate audit file types
+	if len(settings.AuditFileTypes) == 0 {
+		return fmt.Errorf("at least one audit file type is required")
+	}
+	for _, auditType := range settings.AuditFileTypes {
+		if auditType != constants.AuditTypeCSV && auditType != constants.AuditTypeJSON && auditType != constants.AuditTypeNDJSON {
+			return fmt.Errorf("audit file type '%s' is not supported (use %s, %s, %s or a comma-separated list)",
+				auditType, constants.AuditTypeCSV, constants.AuditTypeJSON, constants.AuditTypeNDJSON)
+		}
+	}
+
+	if err := scrubber.ValidateNoAuditTypes(settings.NoAuditTypes); err != nil {
+		return err
+	}
+
+	if err := scrubber.ValidateAuditCSVDelimiter(settings.AuditCSVDelimiter); err != nil {
+		return err
+	}
+
+	// Temp output is a dry-run variant
+	if settings.ToTemp && !settings.DryRun {
+		return fmt.Errorf("--to-temp requires --dry-run")
+	}
+	// Only real runs that write output record progress to resume from
+	if settings.Resume && (settings.DryRun || settings.NoOutput) {
+		return fmt.Errorf("--resume cannot be combined with --dry-run or --no-output")
+	}
+
+	if settings.ToTemp && settings.NoOutput {
+		return fmt.Errorf("--to-temp cannot be combined with --no-output")
+	}
+
+	// Hash-mode tokens replace counters, which fixed-width masks don't use at all
+	if settings.HashSalt != "" && !settings.HashMode {
+		return fmt.Errorf("--hash-salt requires --hash")
+	}
+	if settings.HashPrefix && !settings.HashMode {
+		return fmt.Errorf("--hash-prefix requires --hash")
+	}
+	if settings.HashMode && settings.FixedWidth {
+		return fmt.Errorf("--hash derives mapped tokens, but --fixed-width replaces values with masks instead; use one or the other")
+	}
+
+	// A salt is only meaningful when originals are hashed
+	if settings.AuditSalt != "" && !settings.AuditHashOriginals {
+		return fmt.Errorf("--audit-salt requires --audit-hash-originals")
+	}
+
+	// A generated salt differs on every run, and so would the hashed originals
+	if settings.Deterministic && settings.AuditHashOriginals && settings.AuditSalt == "" {
+		return fmt.Errorf("--deterministic with --audit-hash-originals requires --audit-salt")
+	}
+
+	// Validate IP strategy
+	if settings.IPStrategy != constants.IPStrategyMask && settings.IPStrategy != constants.IPStrategyClass {
+		return fmt.Errorf("IP strategy must be one of: %s, %s", constants.IPStrategyMask, constants.IPStrategyClass)
+	}
+
+	// Validate mask characters
+	if err := scrubber.ValidateMaskChar(settings.MaskChar, settings.FixedWidth); err != nil {
+		return fmt.Errorf("invalid mask character: %w", err)
+	}
+	if err := scrubber.ValidateMaskChars(settings.MaskChars, settings.FixedWidth); err != nil {
+		return fmt.Errorf("invalid mask character: %w", err)
+	}
+
+	// Validate mapped-value prefixes
+	if err := scrubber.ValidateTokenPrefixes(settings.UserPrefix, settings.DomainPrefix); err != nil {
+		return fmt.Errorf("invalid mapped-value prefix: %w", err)
+	}
+	if err := scrubber.ValidateAlwaysScrub(settings.AlwaysScrub, settings.UserPrefix, settings.DomainPrefix, settings.EmailTemplate); err != nil {
+		return fmt.Errorf("invalid ScrubSettings: %w", err)
+	}
+	if err := scrubber.ValidateAllowlist(settings.Allowlist); err != nil {
+		return fmt.Errorf("invalid ScrubSettings: %w", err)
+	}
+
+	// Validate timestamp format
+	if settings.TimeFormat != "" && settings.TimeFormat != constants.TimeFormatRFC3339 && settings.TimeFormat != constants.TimeFormatEpochMS && settings.TimeFormat != constants.TimeFormatRelative {
+		return fmt.Errorf("time format must be one of: %s, %s, %s", constants.TimeFormatRFC3339, constants.TimeFormatEpochMS, constants.TimeFormatRelative)
+	}
+
+	// Validate JSON failure action
+	if settings.JSONFailureAction != constants.JSONFailureScrub && settings.JSONFailureAction != constants.JSONFailureDrop && settings.JSONFailureAction != constants.JSONFailureRedact {
+		return fmt.Errorf("JSON failure action must be one of: %s, %s, %s", constants.JSONFailureScrub, constants.JSONFailureDrop, constants.JSONFailureRedact)
+	}
+
+	// Strict allowlist mode only touches the allowlisted values, so it needs some and rules out rewriting anything else
+	if settings.StrictAllowlist {
+		if len(settings.ScrubPaths) == 0 && len(settings.FieldTypes) == 0 {
+			return fmt.Errorf("--strict-allowlist requires the sensitive fields in ScrubSettings.FieldTypes or --scrub-path")
+		}
+		if settings.JSONFailureAction == constants.JSONFailureScrub {
+			return fmt.Errorf("--strict-allowlist never scrubs lines as plain text: JSON failure action must be %s or %s", constants.JSONFailureDrop, constants.JSONFailureRedact)
+		}
+		if settings.TimeFormat != "" {
+			return fmt.Errorf("--strict-allowlist cannot be combined with --normalize-time, which rewrites fields outside the allowlist")
+		}
+		if settings.RedactListFile != "" {
+			return fmt.Errorf("--strict-allowlist cannot be combined with --redact-list, which rewrites values outside the allowlist")
+		}
+	}
+
+	// Delimited input scrubs only its selected columns, so it needs some and rules out JSON-only modes
+	// Plain input never parses JSON, so the JSON-only modes and JSON failure handling don't apply either
+	switch settings.InputFormat {
+	case constants.InputFormatJSON, constants.InputFormatPlain:
+		if settings.ScrubColumns != "" || settings.Delimiter != "" {
+			return fmt.Errorf("--scrub-columns and --delimiter require --input-format %s", constants.InputFormatDelimited)
+		}
+		if settings.InputFormat == constants.InputFormatPlain {
+			if settings.ContainerLogs || settings.StrictAllowlist || settings.TimeFormat != "" {
+				return fmt.Errorf("--input-format %s cannot be combined with --container-logs, --strict-allowlist or --normalize-time, which only apply to JSON lines", constants.InputFormatPlain)
+			}
+			if settings.JSONFailureAction != constants.JSONFailureScrub || settings.MaxJSONFailureRate > 0 {
+				return fmt.Errorf("--input-format %s never parses JSON, so --json-failure-action and --max-json-failure-rate don't apply", constants.InputFormatPlain)
+			}
+		}
+	case constants.InputFormatDelimited:
+		if settings.ScrubColumns == "" {
+			return fmt.Errorf("--input-format %s requires the columns to scrub in --scrub-columns", constants.InputFormatDelimited)
+		}
+		if _, err := scrubber.ParseScrubColumns(settings.ScrubColumns); err != nil {
+			return err
+		}
+		if settings.ContainerLogs || settings.StrictAllowlist || settings.TimeFormat != "" {
+			return fmt.Errorf("--input-format %s cannot be combined with --container-logs, --strict-allowlist or --normalize-time, which only apply to JSON lines", constants.InputFormatDelimited)
+		}
+	default:
+		return fmt.Errorf("input format must be one of: %s, %s, %s", constants.InputFormatJSON, constants.InputFormatPlain, constants.InputFormatDelimited)
+	}
+
+	// Validate the maximum JSON failure rate, a fraction of the non-empty lines
+	if settings.MaxJSONFailureRate < 0 || settings.MaxJSONFailureRate >= 1 {
+		return fmt.Errorf("maximum JSON failure rate must be a fraction between 0 and 1 (e.g. 0.2), got %g", settings.MaxJSONFailureRate)
+	}
+
+	// Validate log kind
+	if settings.LogKind != constants.LogKindAuto && settings.LogKind != constants.LogKindApp && settings.LogKind != constants.LogKindNotifications {
+		return fmt.Errorf("log kind must be one of: %s, %s, %s", constants.LogKindAuto, constants.LogKindApp, constants.LogKindNotifications)
+	}
+
+	// Validate field-to-type mapping
+	if err := scrubber.ValidateFieldTypes(settings.FieldTypes); err != nil {
+		return err
+	}
+
+	// Validate split size
+	if settings.SplitSize != "" {
+		if _, err := parseFileSize(settings.SplitSize); err != nil {
+			return fmt.Errorf("invalid split size: %w", err)
+		}
+		if settings.SplitBytes <= 0 {
+			return fmt.Errorf("split size must be greater than zero")
+		}
+	}
+
+	// Validate input range
+	if settings.LineRange != "" || settings.ByteRange != "" {
+		inputRange, err := parseInputRange(settings.LineRange, settings.ByteRange)
+		if err != nil {
+			return err
+		}
+		if len(settings.InputPaths) > 1 {
+			return fmt.Errorf("--line-range and --byte-range apply to a single input file")
+		}
+		if settings.Checksums {
+			return fmt.Errorf("--checksums cannot be combined with --line-range or --byte-range, which read only part of the input")
+		}
+		settings.InputRange = inputRange
+	}
+
+	// Validate large input confirmation threshold
+	if settings.ConfirmAbove != "" {
+		if _, err := parseFileSize(settings.ConfirmAbove); err != nil {
+			return fmt.Errorf("invalid confirmation threshold: %w", err)
+		}
+	}
+
+	// Validate throttle
+	if _, _, err := parseThrottle(settings.Throttle); err != nil {
+		return err
+	}
+
+	// Validate the change samples
+	if settings.SampleChanges < 0 {
+		return fmt.Errorf("sample changes must be 0 or more examples per type")
+	}
+	switch settings.SampleOriginals {
+	case constants.SampleOriginalsShow, constants.SampleOriginalsMask, constants.SampleOriginalsHide:
+	default:
+		return fmt.Errorf("sample originals must be %s, %s or %s", constants.SampleOriginalsShow, constants.SampleOriginalsMask, constants.SampleOriginalsHide)
+	}
+
+	// Validate the run timeout
+	if _, err := parseTimeout(settings.Timeout); err != nil {
+		return err
+	}
+	if settings.TimeoutOutput != constants.TimeoutOutputKeep && settings.TimeoutOutput != constants.TimeoutOutputDiscard {
+		return fmt.Errorf("timeout output must be %s or %s", constants.TimeoutOutputKeep, constants.TimeoutOutputDiscard)
+	}
+
+	// Validate the anonymized email format
+	if err := scrubber.ValidateEmailTemplate(settings.EmailTemplate, settings.RoleTokens, settings.HashMode); err != nil {
+		return err
+	}
+
+	// Validate internal email domains
+	if err := scrubber.ValidateInternalDomains(settings.InternalDomains); err != nil {
+		return err
+	}
+
+	// Validate scrub paths
+	for _, expr := range settings.ScrubPaths {
+		if _, err := scrubber.ParseJSONPath(expr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateInputFile checks that an input file exists, is within the size limit and can be read
+func validateInputFile(inputPath string, maxInputFileSize int64) error {
+	// Socket and standard input are streams, so their size limit is enforced while reading instead
+	if strings.HasPrefix(inputPath, constants.UnixSocketScheme) || scrubber.IsStdio(inputPath) {
+		return nil
+	}
+
 	// Check if input file exists and get its size
-	fileInfo, err := os.Stat(settings.InputPath)
+	fileInfo, err := os.Stat(inputPath)
 	if os.IsNotExist(err) {
-		return fmt.Errorf("input file '%s' does not exist", settings.InputPath)
+		return fmt.Errorf("input file '%s' does not exist", inputPath)
+	}
+	if errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("cannot read '%s': permission denied; check the ownership and permissions of its directory", inputPath)
 	}
 	if err != nil {
-		return fmt.Errorf("failed to get file info for '%s': %w", settings.InputPath, err)
+		return fmt.Errorf("failed to get file info for '%s': %w", inputPath, err)
 	}
 
 	// Check file size against limit
 	fileSize := fileInfo.Size()
-	if fileSize > settings.MaxInputFileSize {
+	if fileSize > maxInputFileSize {
 		return fmt.Errorf("input file '%s' size (%s) exceeds maximum allowed size (%s). Use --max-file-size or config setting to override",
-			settings.InputPath,
-			formatFileSize(fileSize),
-			formatFileSize(settings.MaxInputFileSize))
+			inputPath,
+			FormatFileSize(fileSize),
+			FormatFileSize(maxInputFileSize))
 	}
 
+	// A file that exists but can't be opened would otherwise only fail once the run reaches it
+	if !fileInfo.IsDir() {
+		return checkInputReadable(inputPath)
+	}
 	return nil
 }
\ No newline at end of file
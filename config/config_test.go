@@ -0,0 +1,148 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/scrubber"
+)
+
+// writeTestConfig writes a config file into a temporary directory and loads it
+func writeTestConfig(t *testing.T, content string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scrubber_config.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return config
+}
+
+func TestValidateScrubSettingsRejectsBadRegex(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr []string // Parts the error must name: the setting, the entry and the pattern
+	}{
+		{
+			name:    "preserve pattern",
+			config:  `{"ScrubSettings":{"PreservePatterns":["\\[REDACTED\\]","<anon:(\\d+>"]}}`,
+			wantErr: []string{"ScrubSettings", "preserve pattern #2", `<anon:(\d+>`},
+		},
+		{
+			name:    "national ID pattern",
+			config:  `{"ScrubSettings":{"NationalIDPatterns":[{"Name":"ca_sin","Pattern":"\\d{3}[ -]\\d{3"},{"Name":"uk_nino","Pattern":"([A-Z]{2}"}]}}`,
+			wantErr: []string{"ScrubSettings", "national ID pattern #2 'uk_nino'", "([A-Z]{2}"},
+		},
+		{
+			name:    "custom pattern",
+			config:  `{"ProcessingSettings":{"CustomPatterns":[{"Name":"ticket","Regex":"TICKET-[0-9+"}]}}`,
+			wantErr: []string{"ProcessingSettings", "custom pattern #1 'ticket'", "TICKET-[0-9+"},
+		},
+		{
+			name:    "custom pattern matching empty text",
+			config:  `{"ProcessingSettings":{"CustomPatterns":[{"Name":"ticket","Regex":"[0-9]*"}]}}`,
+			wantErr: []string{"custom pattern #1 'ticket'", "matches empty text"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, _ := ResolveSettings(CLIFlags{Level: 1}, writeTestConfig(t, tt.config))
+			err := ValidateScrubSettings(&settings)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, part := range tt.wantErr {
+				if !strings.Contains(err.Error(), part) {
+					t.Errorf("error %q does not mention %q", err, part)
+				}
+			}
+			if settings.Patterns != nil {
+				t.Error("patterns were installed despite the error")
+			}
+		})
+	}
+}
+
+func TestValidateScrubSettingsCompilesPatternsOnce(t *testing.T) {
+	config := writeTestConfig(t, `{
+		"ScrubSettings": {"PreservePatterns": ["<anon:\\d+>"], "NationalIDPatterns": [{"Name": "ca_sin", "Pattern": "\\b\\d{3} \\d{3} \\d{3}\\b"}]},
+		"ProcessingSettings": {"CustomPatterns": [{"Name": "ticket", "Regex": "TICKET-[0-9]+"}]}
+	}`)
+	settings, _ := ResolveSettings(CLIFlags{Level: 1}, config)
+	if err := ValidateScrubSettings(&settings); err != nil {
+		t.Fatalf("ValidateScrubSettings: %v", err)
+	}
+	if settings.Patterns == nil {
+		t.Fatal("no compiled patterns in the resolved settings")
+	}
+
+	s := scrubber.NewScrubber(1, false)
+	s.SetPatterns(settings.Patterns)
+	got, err := s.ScrubLine(`{"msg":"<anon:4> filed TICKET-42 with sin 046 454 286"}`, "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"msg":"<anon:4> filed [ticket-redacted] with sin [ca_sin-redacted]"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestExpandInputsExcludeGlobs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app.log", "app_old.log", "app_scrubbed.log", "notes.txt", "archive/app.log", "archive/app.log.1", "current/app.log.2"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("line\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := ResolvedSettings{InputPaths: []string{dir}, Recursive: true, ExcludeGlobs: []string{"*_old.log", "archive/*"}}
+	matches, err := ExpandInputs(&settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "app.log"), filepath.Join(dir, "current", "app.log.2")}
+	if len(settings.InputPaths) != len(want) || settings.InputPaths[0] != want[0] || settings.InputPaths[1] != want[1] {
+		t.Errorf("inputs = %v, want %v", settings.InputPaths, want)
+	}
+	// The old log, the earlier output and both archived logs; notes.txt was never a log file
+	if len(matches) != 1 || matches[0].Count != 2 || matches[0].Skipped != 4 {
+		t.Errorf("matches = %+v, want 2 files with 4 skipped", matches)
+	}
+}
+
+func TestExpandInputsExcludeGlobErrors(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(logPath, []byte("line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		settings ResolvedSettings
+		wantErr  string
+	}{
+		{"bad glob", ResolvedSettings{InputPaths: []string{dir}, ExcludeGlobs: []string{"[app"}}, "invalid exclude glob '[app'"},
+		{"no directory input", ResolvedSettings{InputPaths: []string{logPath}, ExcludeGlobs: []string{"*.1"}}, "no input is a directory"},
+		{"everything excluded", ResolvedSettings{InputPaths: []string{dir}, ExcludeGlobs: []string{"*.log"}}, "no log files left after skipping 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExpandInputs(&tt.settings)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"mattermost-log-scrubber/constants"
+	"mattermost-log-scrubber/scrubber"
+)
+
+// checkInputReadable opens an input file to make sure it can be read, so a permission problem is
+// reported before processing starts rather than when the scrubber gets to that input
+func checkInputReadable(inputPath string) error {
+	file, err := os.Open(inputPath)
+	if errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("cannot read '%s': permission denied; check the file's ownership and permissions", inputPath)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read '%s': %w", inputPath, err)
+	}
+	return file.Close()
+}
+
+// ValidateOutputPermissions checks that every local directory the run will write to exists and
+// is writable, so permission problems surface before any input is processed. It needs the
+// resolved output and audit paths, so it runs once default paths have been filled in.
+func ValidateOutputPermissions(settings ResolvedSettings) error {
+	type artifact struct{ label, path string }
+	var artifacts []artifact
+	if settings.DryRun {
+		// Dry runs only write the trace, plus outputs that --to-temp sends to a fresh temp directory
+		artifacts = append(artifacts, artifact{"trace file", settings.TracePath})
+	} else {
+		if !settings.NoOutput {
+			for _, outputPath := range settings.OutputPaths {
+				artifacts = append(artifacts, artifact{"output file", outputPath})
+			}
+			if len(settings.InputPaths) > 1 {
+				artifacts = append(artifacts, artifact{"batch state file", settings.StatePath})
+			}
+		}
+		for _, audit := range settings.AuditOutputs {
+			artifacts = append(artifacts, artifact{"audit file", audit.Path})
+		}
+		artifacts = append(artifacts,
+			artifact{"manifest", settings.ManifestPath},
+			artifact{"metrics file", settings.MetricsPath},
+			artifact{"stats file", settings.StatsPath},
+			artifact{"trace file", settings.TracePath},
+			artifact{"frequency report", settings.FrequencyReport},
+			artifact{"identity report", settings.IdentityReport},
+			artifact{"HTML report", settings.HTMLReport},
+		)
+	}
+
+	// Each directory is checked once, for the first artifact that goes there
+	checked := make(map[string]bool)
+	for _, a := range artifacts {
+		if a.path == "" || scrubber.IsObjectStoreURI(a.path) || scrubber.IsStdio(a.path) {
+			continue
+		}
+		dir := filepath.Dir(a.path)
+		if checked[dir] {
+			continue
+		}
+		checked[dir] = true
+		if err := checkDirWritable(dir); err != nil {
+			return fmt.Errorf("cannot write %s '%s': %w", a.label, a.path, err)
+		}
+	}
+	return nil
+}
+
+// checkDirWritable creates and removes a temporary file in dir, which is the only reliable test of
+// write access across platforms, ACLs and read-only mounts
+func checkDirWritable(dir string) error {
+	info, err := os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("directory '%s' does not exist", dir)
+	}
+	if err == nil && !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", dir)
+	}
+
+	probe, err := os.CreateTemp(dir, "."+constants.AppName+"-write-check-*")
+	if errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("permission denied in directory '%s'; check the directory's ownership and permissions", dir)
+	}
+	if err != nil {
+		return fmt.Errorf("directory '%s' is not writable: %w", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
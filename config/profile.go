@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ProfileSettings is a named variant of the config file, selected with --profile. Each section holds
+// only the settings the profile changes, in the same form as the config file's sections.
+type ProfileSettings struct {
+	FileSettings       json.RawMessage `json:"FileSettings,omitempty"`
+	ScrubSettings      json.RawMessage `json:"ScrubSettings,omitempty"`
+	OutputSettings     json.RawMessage `json:"OutputSettings,omitempty"`
+	ProcessingSettings json.RawMessage `json:"ProcessingSettings,omitempty"`
+}
+
+// ApplyProfile returns a copy of the config with the named profile applied over it, and the config
+// paths of the settings the profile sets, sorted. Each setting the profile names replaces the config
+// file's value, even with false, zero or an empty list, and every other setting is kept.
+func ApplyProfile(config *Config, name string) (*Config, []string, error) {
+	if config == nil {
+		return nil, nil, fmt.Errorf("profile '%s' was selected, but no config file was loaded to define it", name)
+	}
+	profile, exists := config.Profiles[name]
+	if !exists {
+		if len(config.Profiles) == 0 {
+			return nil, nil, fmt.Errorf("unknown profile '%s': the config file defines no Profiles", name)
+		}
+		return nil, nil, fmt.Errorf("unknown profile '%s'; the config file defines: %s", name, strings.Join(config.ProfileNames(), ", "))
+	}
+
+	merged := *config
+	sections := []struct {
+		name   string
+		raw    json.RawMessage
+		target interface{}
+	}{
+		{"FileSettings", profile.FileSettings, &merged.FileSettings},
+		{"ScrubSettings", profile.ScrubSettings, &merged.ScrubSettings},
+		{"OutputSettings", profile.OutputSettings, &merged.OutputSettings},
+		{"ProcessingSettings", profile.ProcessingSettings, &merged.ProcessingSettings},
+	}
+
+	var settings []string
+	for _, section := range sections {
+		if len(section.raw) == 0 {
+			continue
+		}
+		set, err := overlaySection(section.target, section.raw, section.name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid profile '%s': %w", name, err)
+		}
+		settings = append(settings, set...)
+	}
+	sort.Strings(settings)
+	return &merged, settings, nil
+}
+
+// ProfileNames returns the names of the profiles the config file defines, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// overlaySection replaces the fields of the section target points to with those set in raw, and
+// returns their config paths. Unknown keys are rejected, so a mistyped setting isn't silently ignored.
+// The profile's values are decoded into a fresh section, so maps aren't shared with the config file.
+func overlaySection(target interface{}, raw json.RawMessage, sectionName string) ([]string, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", sectionName, err)
+	}
+	present := make(map[string]bool, len(keys))
+	for key := range keys {
+		present[strings.ToLower(key)] = true
+	}
+
+	overlay := reflect.New(reflect.TypeOf(target).Elem())
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(overlay.Interface()); err != nil {
+		return nil, fmt.Errorf("%s: %w", sectionName, err)
+	}
+
+	section := reflect.ValueOf(target).Elem()
+	var settings []string
+	for i := 0; i < section.NumField(); i++ {
+		field := section.Type().Field(i)
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		if present[strings.ToLower(key)] {
+			section.Field(i).Set(overlay.Elem().Field(i))
+			settings = append(settings, sectionName+"."+field.Name)
+		}
+	}
+	return settings, nil
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Setting sources, from highest to lowest precedence
+const (
+	SourceCLI     = "cli"
+	SourceProfile = "profile"
+	SourceConfig  = "config"
+	SourceRules   = "rules"
+	SourceDefault = "default"
+)
+
+// Provenance records where each config-file setting's resolved value came from, keyed by its
+// config path such as "FileSettings.OverwriteAction"
+type Provenance map[string]string
+
+// record stores the source of a setting: the CLI if it was given there, otherwise the config file
+// if it set a value, otherwise the built-in default
+func (p Provenance) record(setting string, fromCLI, fromConfig bool) {
+	switch {
+	case fromCLI:
+		p[setting] = SourceCLI
+	case fromConfig:
+		p[setting] = SourceConfig
+	default:
+		p[setting] = SourceDefault
+	}
+}
+
+// AttributeRules marks the settings a rules file set as coming from it rather than the config file,
+// which they were merged into; settings given on the CLI keep that source
+func (p Provenance) AttributeRules(rules *Rules) {
+	for _, setting := range rules.Settings() {
+		if p[setting] == SourceConfig {
+			p[setting] = SourceRules
+		}
+	}
+}
+
+// AttributeProfile marks the settings a --profile set as coming from it, including those it set to
+// an empty value, which would otherwise show as defaults; settings given on the CLI keep that source
+func (p Provenance) AttributeProfile(settings []string) {
+	for _, setting := range settings {
+		if source, exists := p[setting]; exists && source != SourceCLI {
+			p[setting] = SourceProfile
+		}
+	}
+}
+
+// From returns the settings that came from the given source, sorted
+func (p Provenance) From(source string) []string {
+	var settings []string
+	for setting, settingSource := range p {
+		if settingSource == source {
+			settings = append(settings, setting)
+		}
+	}
+	sort.Strings(settings)
+	return settings
+}
+
+// EffectiveConfig returns the resolved settings in config file form, so they can be shown or saved
+// as a config file. Sizes are given in bytes and inputs beyond the first are left out, as the config
+// file holds a single input.
+func EffectiveConfig(settings ResolvedSettings) Config {
+	var config Config
+
+	config.FileSettings.InputFile = settings.InputPath
+	config.FileSettings.InputList = settings.InputList
+	config.FileSettings.OutputDir = settings.OutputDir
+	config.FileSettings.OutputFile = settings.OutputPath
+	config.FileSettings.AuditFile = settings.AuditPath
+	config.FileSettings.AuditFileType = strings.Join(settings.AuditFileTypes, ",")
+	config.FileSettings.NoAuditTypes = strings.Join(settings.NoAuditTypes, ",")
+	config.FileSettings.AuditCSVDelimiter = settings.AuditCSVDelimiter
+	config.FileSettings.AuditCSVQuoteAll = settings.AuditCSVQuoteAll
+	config.FileSettings.CompressOutputFile = settings.CompressOutputFile
+	config.FileSettings.OverwriteAction = settings.OverwriteAction
+	config.FileSettings.CancelScope = settings.CancelScope
+	config.FileSettings.RenameScheme = settings.RenameScheme
+	config.FileSettings.StateFile = settings.StatePath
+	config.FileSettings.RulesFile = settings.RulesFile
+
+	config.ScrubSettings.ScrubLevel = settings.ScrubLevel
+	config.ScrubSettings.FixedWidth = settings.FixedWidth
+	config.ScrubSettings.IPStrategy = settings.IPStrategy
+	config.ScrubSettings.TimeFormat = settings.TimeFormat
+	config.ScrubSettings.PreservePatterns = settings.PreservePatterns
+	config.ScrubSettings.ScrubPaths = settings.ScrubPaths
+	config.ScrubSettings.FieldTypes = settings.FieldTypes
+	config.ScrubSettings.PassthroughFields = settings.PassthroughFields
+	config.ScrubSettings.StrictAllowlist = settings.StrictAllowlist
+	config.ScrubSettings.LogKind = settings.LogKind
+	config.ScrubSettings.ContainerLogs = settings.ContainerLogs
+	config.ScrubSettings.InputFormat = settings.InputFormat
+	config.ScrubSettings.Delimiter = settings.Delimiter
+	config.ScrubSettings.ScrubColumns = settings.ScrubColumns
+	config.ScrubSettings.RoleTokens = settings.RoleTokens
+	config.ScrubSettings.HashMode = settings.HashMode
+	config.ScrubSettings.HashPrefix = settings.HashPrefix
+	config.ScrubSettings.NationalIDPatterns = settings.NationalIDPatterns
+	config.ScrubSettings.DomainMapFile = settings.DomainMapFile
+	config.ScrubSettings.RedactListFile = settings.RedactListFile
+	config.ScrubSettings.InternalDomains = settings.InternalDomains
+	config.ScrubSettings.EmailTemplate = settings.EmailTemplate
+	config.ScrubSettings.LenientEmails = settings.LenientEmails
+	config.ScrubSettings.JSONFailureAction = settings.JSONFailureAction
+	config.ScrubSettings.MaxJSONFailureRate = settings.MaxJSONFailureRate
+	config.ScrubSettings.MaskChar = settings.MaskChar
+	config.ScrubSettings.MaskChars = settings.MaskChars
+	config.ScrubSettings.UserPrefix = settings.UserPrefix
+	config.ScrubSettings.DomainPrefix = settings.DomainPrefix
+	config.ScrubSettings.AlwaysScrub = settings.AlwaysScrub
+	config.ScrubSettings.AllowShortUsernames = settings.AllowShortUsernames
+	config.ScrubSettings.Allowlist = settings.Allowlist
+
+	config.OutputSettings.Verbose = settings.Verbose
+	config.OutputSettings.SampleChanges = settings.SampleChanges
+	config.OutputSettings.SampleOriginals = settings.SampleOriginals
+
+	config.ProcessingSettings.MaxInputFileSize = strconv.FormatInt(settings.MaxInputFileSize, 10) + "B"
+	config.ProcessingSettings.Throttle = settings.Throttle
+	config.ProcessingSettings.ConfirmAboveSize = settings.ConfirmAbove
+	config.ProcessingSettings.TwoPass = settings.TwoPass
+	config.ProcessingSettings.Timeout = settings.Timeout
+	config.ProcessingSettings.TimeoutOutput = settings.TimeoutOutput
+	config.ProcessingSettings.CustomPatterns = settings.CustomPatterns
+
+	return config
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProvenanceMixedSources(t *testing.T) {
+	config := writeTestConfig(t, `{
+		"FileSettings": {"OverwriteAction": "overwrite"},
+		"ScrubSettings": {"ScrubLevel": 2, "IPStrategy": "hash", "PreservePatterns": ["<anon:\\d+>"]},
+		"OutputSettings": {"Verbose": true}
+	}`)
+	flags := CLIFlags{Level: 3, IPStrategy: "mask", FixedWidth: true}
+
+	settings, sources := ResolveSettings(flags, config)
+	if settings.ScrubLevel != 3 || settings.IPStrategy != "mask" {
+		t.Errorf("CLI values lost: level %d, IP strategy %q", settings.ScrubLevel, settings.IPStrategy)
+	}
+
+	tests := []struct {
+		setting string
+		want    string
+	}{
+		{"ScrubSettings.ScrubLevel", SourceCLI},        // Set in both, the flag wins
+		{"ScrubSettings.IPStrategy", SourceCLI},        // Set in both, the flag wins
+		{"ScrubSettings.FixedWidth", SourceCLI},        // Flag only
+		{"FileSettings.OverwriteAction", SourceConfig}, // Config only
+		{"OutputSettings.Verbose", SourceConfig},       // Config only
+		{"ScrubSettings.PreservePatterns", SourceConfig},
+		{"ScrubSettings.MaskChar", SourceDefault}, // Neither
+		{"FileSettings.AuditFile", SourceDefault},
+	}
+	for _, tt := range tests {
+		if got := sources[tt.setting]; got != tt.want {
+			t.Errorf("%s came from %q, want %q", tt.setting, got, tt.want)
+		}
+	}
+
+	wantCLI := []string{"ScrubSettings.FixedWidth", "ScrubSettings.IPStrategy", "ScrubSettings.ScrubLevel"}
+	if got := sources.From(SourceCLI); !reflect.DeepEqual(got, wantCLI) {
+		t.Errorf("From(cli) = %v, want %v", got, wantCLI)
+	}
+}
+
+func TestProvenanceWithoutConfig(t *testing.T) {
+	_, sources := ResolveSettings(CLIFlags{Level: 1}, nil)
+	for setting, source := range sources {
+		if setting == "ScrubSettings.ScrubLevel" {
+			if source != SourceCLI {
+				t.Errorf("%s came from %q, want %q", setting, source, SourceCLI)
+			}
+		} else if source != SourceDefault {
+			t.Errorf("%s came from %q with no config file, want %q", setting, source, SourceDefault)
+		}
+	}
+}
+
+func TestProvenanceAttributeProfile(t *testing.T) {
+	config := writeTestConfig(t, `{
+		"ScrubSettings": {"IPStrategy": "hash", "MaskChar": "#"},
+		"Profiles": {"support": {"ScrubSettings": {"IPStrategy": "subnet", "FixedWidth": false, "MaskChar": "x"}}}
+	}`)
+	profiled, profileSettings, err := ApplyProfile(config, "support")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The flag overrides the profile's mask character, so that setting stays with the CLI
+	_, sources := ResolveSettings(CLIFlags{Level: 1, MaskChar: "*"}, profiled)
+	sources.AttributeProfile(profileSettings)
+
+	tests := []struct {
+		setting string
+		want    string
+	}{
+		{"ScrubSettings.IPStrategy", SourceProfile},
+		{"ScrubSettings.FixedWidth", SourceProfile}, // Set to false, which alone would read as the default
+		{"ScrubSettings.MaskChar", SourceCLI},
+		{"ScrubSettings.ScrubLevel", SourceCLI},
+		{"FileSettings.OverwriteAction", SourceDefault},
+	}
+	for _, tt := range tests {
+		if got := sources[tt.setting]; got != tt.want {
+			t.Errorf("%s came from %q, want %q", tt.setting, got, tt.want)
+		}
+	}
+}
+
+func TestProvenanceAttributeRules(t *testing.T) {
+	rulesPath := filepath.Join(t.TempDir(), "rules.json")
+	rulesJSON := `{"PreservePatterns": ["\\[REDACTED\\]"], "PassthroughFields": ["caller"], "ScrubPaths": ["$.props.email"]}`
+	if err := os.WriteFile(rulesPath, []byte(rulesJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := writeTestConfig(t, `{"ScrubSettings": {"IPStrategy": "hash", "PassthroughFields": ["level"]}}`)
+
+	_, sources := ResolveSettings(CLIFlags{Level: 1, IPStrategy: "mask"}, ApplyRules(config, rules))
+	sources.AttributeRules(rules)
+
+	tests := []struct {
+		setting string
+		want    string
+	}{
+		{"ScrubSettings.PreservePatterns", SourceRules},
+		{"ScrubSettings.ScrubPaths", SourceRules},
+		{"ScrubSettings.PassthroughFields", SourceRules}, // Merged with the config file's list, the rules file is named
+		{"ScrubSettings.IPStrategy", SourceCLI},
+		{"ScrubSettings.FieldTypes", SourceDefault},
+	}
+	for _, tt := range tests {
+		if got := sources[tt.setting]; got != tt.want {
+			t.Errorf("%s came from %q, want %q", tt.setting, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"mattermost-log-scrubber/scrubber"
+)
+
+// Rules holds scrubbing rule definitions kept apart from operational settings, so one rules file can
+// be shared by runs with different inputs, outputs and overwrite actions
+type Rules struct {
+	PreservePatterns   []string                     `json:"PreservePatterns"`
+	NationalIDPatterns []scrubber.NationalIDPattern `json:"NationalIDPatterns"`
+	ScrubPaths         []string                     `json:"ScrubPaths"`
+	FieldTypes         map[string]string            `json:"FieldTypes"`
+	PassthroughFields  []string                     `json:"PassthroughFields"`
+	InternalDomains    []string                     `json:"InternalDomains"`
+	DomainMapFile      string                       `json:"DomainMapFile"`
+	RedactListFile     string                       `json:"RedactListFile"`
+}
+
+// LoadRules loads and validates a rules file
+// Unknown keys are rejected, so operational settings put there by mistake aren't silently ignored
+func LoadRules(rulesPath string) (*Rules, error) {
+	data, err := os.ReadFile(rulesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var rules Rules
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file '%s': %w", rulesPath, err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to parse rules file '%s': unexpected data after the rules object", rulesPath)
+	}
+
+	if err := rules.validate(); err != nil {
+		return nil, fmt.Errorf("invalid rules file '%s': %w", rulesPath, err)
+	}
+	return &rules, nil
+}
+
+// validate checks every rule the way ValidateSettings would, so a bad shared rules file is reported
+// against the rules file rather than the run that happened to load it
+func (r *Rules) validate() error {
+	if _, err := scrubber.CompilePatterns(r.PreservePatterns, r.NationalIDPatterns); err != nil {
+		return err
+	}
+	for _, expr := range r.ScrubPaths {
+		if _, err := scrubber.ParseJSONPath(expr); err != nil {
+			return fmt.Errorf("invalid scrub path: %w", err)
+		}
+	}
+	if err := scrubber.ValidateFieldTypes(r.FieldTypes); err != nil {
+		return err
+	}
+
+	passthrough := make(map[string]bool, len(r.PassthroughFields))
+	for _, field := range r.PassthroughFields {
+		name := strings.ToLower(strings.TrimSpace(field))
+		if name == "" {
+			return fmt.Errorf("invalid passthrough field: field name is empty")
+		}
+		passthrough[name] = true
+	}
+	for field := range r.FieldTypes {
+		if passthrough[strings.ToLower(strings.TrimSpace(field))] {
+			return fmt.Errorf("field '%s' is both in FieldTypes and PassthroughFields", field)
+		}
+	}
+
+	if err := scrubber.ValidateInternalDomains(ParseList(strings.Join(r.InternalDomains, ","))); err != nil {
+		return err
+	}
+	if r.DomainMapFile != "" {
+		if _, err := scrubber.LoadDomainMap(r.DomainMapFile); err != nil {
+			return err
+		}
+	}
+	if r.RedactListFile != "" {
+		if _, err := scrubber.LoadRedactList(r.RedactListFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Settings returns the config paths of the settings the rules file sets, sorted
+func (r *Rules) Settings() []string {
+	var settings []string
+	if r.DomainMapFile != "" {
+		settings = append(settings, "ScrubSettings.DomainMapFile")
+	}
+	if len(r.FieldTypes) > 0 {
+		settings = append(settings, "ScrubSettings.FieldTypes")
+	}
+	if len(r.InternalDomains) > 0 {
+		settings = append(settings, "ScrubSettings.InternalDomains")
+	}
+	if len(r.NationalIDPatterns) > 0 {
+		settings = append(settings, "ScrubSettings.NationalIDPatterns")
+	}
+	if len(r.PassthroughFields) > 0 {
+		settings = append(settings, "ScrubSettings.PassthroughFields")
+	}
+	if len(r.PreservePatterns) > 0 {
+		settings = append(settings, "ScrubSettings.PreservePatterns")
+	}
+	if r.RedactListFile != "" {
+		settings = append(settings, "ScrubSettings.RedactListFile")
+	}
+	if len(r.ScrubPaths) > 0 {
+		settings = append(settings, "ScrubSettings.ScrubPaths")
+	}
+	return settings
+}
+
+// ApplyRules returns a copy of the config file with the rules merged into its ScrubSettings, or a
+// config holding just the rules when there is no config file. Lists are added after the config
+// file's entries, field types are merged with the rules file winning for a field named in both, and
+// a domain map or redact list file in the rules replaces the config file's.
+func ApplyRules(config *Config, rules *Rules) *Config {
+	if rules == nil {
+		return config
+	}
+	var merged Config
+	if config != nil {
+		merged = *config
+	}
+	scrub := &merged.ScrubSettings
+
+	scrub.PreservePatterns = append(append([]string(nil), scrub.PreservePatterns...), rules.PreservePatterns...)
+	scrub.NationalIDPatterns = append(append([]scrubber.NationalIDPattern(nil), scrub.NationalIDPatterns...), rules.NationalIDPatterns...)
+	scrub.ScrubPaths = append(append([]string(nil), scrub.ScrubPaths...), rules.ScrubPaths...)
+	scrub.PassthroughFields = append(append([]string(nil), scrub.PassthroughFields...), rules.PassthroughFields...)
+	scrub.InternalDomains = append(append([]string(nil), scrub.InternalDomains...), rules.InternalDomains...)
+
+	if len(rules.FieldTypes) > 0 {
+		fieldTypes := make(map[string]string, len(scrub.FieldTypes)+len(rules.FieldTypes))
+		for field, valueType := range scrub.FieldTypes {
+			fieldTypes[field] = valueType
+		}
+		for field, valueType := range rules.FieldTypes {
+			// Field names match case-insensitively, so a config entry differing only in case is replaced
+			for existing := range fieldTypes {
+				if strings.EqualFold(existing, field) {
+					delete(fieldTypes, existing)
+				}
+			}
+			fieldTypes[field] = valueType
+		}
+		scrub.FieldTypes = fieldTypes
+	}
+
+	if rules.DomainMapFile != "" {
+		scrub.DomainMapFile = rules.DomainMapFile
+	}
+	if rules.RedactListFile != "" {
+		scrub.RedactListFile = rules.RedactListFile
+	}
+	return &merged
+}
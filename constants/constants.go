@@ -7,24 +7,55 @@ const (
 	Description = "A Golang application that scrubs identifying information from Mattermost log files."
 )
 
+// Exit codes
+const (
+	ExitError     = 1 // Any failure
+	ExitNoChanges = 3 // --require-changes and nothing was replaced (2 is used by flag parsing errors)
+	ExitTimeout   = 4 // --timeout stopped the run before every input was read
+)
+
 // File-related constants
 const (
 	DefaultConfigFile = "scrubber_config.json"
+	UserConfigFile    = "config.json" // Per-user config inside $XDG_CONFIG_HOME/<AppName>/
 	ScrubSuffix       = "_scrubbed"
+	RestoredSuffix    = "_restored" // Default output of --reverse: <input>_restored.<ext>
 	AuditSuffix       = "_audit"
+	DryRunSuffix      = ".dryrun" // Marks scrubbed output written to a temp directory by --dry-run --to-temp
+	UnixSocketScheme  = "unix://" // Input prefix for reading NDJSON from a Unix domain socket
+	StdioPath         = "-"       // Input or output path for standard input or standard output
+	StdinSourceName   = "stdin"   // Audit source and default artifact name of standard input
+	ObjectStoreScheme = "s3://"   // Output and audit prefix for writing to an S3-compatible bucket
+)
+
+// Environment variables configuring the S3-compatible object store client
+const (
+	EnvS3AccessKey     = "AWS_ACCESS_KEY_ID"
+	EnvS3SecretKey     = "AWS_SECRET_ACCESS_KEY"
+	EnvS3SessionToken  = "AWS_SESSION_TOKEN"
+	EnvS3Region        = "AWS_REGION"
+	EnvS3DefaultRegion = "AWS_DEFAULT_REGION"
+	EnvS3Endpoint      = "AWS_ENDPOINT_URL_S3" // Custom endpoint of an S3-compatible service, e.g. http://minio:9000
+	EnvEndpoint        = "AWS_ENDPOINT_URL"
+	DefaultS3Region    = "us-east-1"
 )
 
 // Audit file types
 const (
-	AuditTypeCSV  = "csv"
-	AuditTypeJSON = "json"
+	AuditTypeCSV    = "csv"
+	AuditTypeJSON   = "json"
+	AuditTypeNDJSON = "ndjson" // One compact JSON object per line, for streaming ingestion
 )
 
 // File extensions
 const (
-	ExtCSV  = ".csv"
-	ExtJSON = ".json"
-	ExtGZ   = ".gz"
+	ExtCSV    = ".csv"
+	ExtJSON   = ".json"
+	ExtNDJSON = ".ndjson"
+	ExtGZ     = ".gz"
+	ExtBZ2    = ".bz2"
+	ExtXZ     = ".xz"
+	ExtLog    = ".log"
 )
 
 // Scrubbing levels
@@ -38,19 +69,64 @@ const (
 
 // Processing constants
 const (
-	ProgressInterval = 1000 // Show progress every N lines
-	MinUIDLength     = 20   // Minimum UID length for scrubbing
-	UIDTargetLength  = 26   // Target UID length after scrubbing
-	UIDKeepChars     = 8    // Characters to keep at end of UID
+	ProgressInterval  = 1000             // Show progress every N lines
+	MinUIDLength      = 20               // Minimum UID length for scrubbing
+	UIDTargetLength   = 26               // Target UID length after scrubbing
+	UIDKeepChars      = 8                // Characters to keep at end of UID
+	WideLineThreshold = 1024 * 1024      // JSON lines at least this long use the streaming scrubber
+	MaxLineLength     = 64 * 1024 * 1024 // Longest single line the scanner accepts
+
+	MaxEmbeddedJSONDepth = 5 // Levels of JSON documents stored as strings inside JSON that are scrubbed as documents
 )
 
 // Scrubbing type constants
 const (
-	TypeEmail    = "email"
-	TypeUsername = "username"
-	TypeIP       = "ip"
-	TypeUID      = "uid"
-	TypeFQDN     = "fqdn"
+	TypeEmail      = "email"
+	TypeUsername   = "username"
+	TypeIP         = "ip"
+	TypeUID        = "uid"
+	TypeFQDN       = "fqdn"
+	TypeHost       = "host"
+	TypeDomain     = "domain"
+	TypeMessage    = "message"
+	TypeNationalID = "national_id"
+	TypeNumericID  = "numeric_id"
+	TypePhone      = "phone"
+	TypeRedact     = "redact"
+)
+
+// Serve mode: run as an HTTP service that scrubs uploaded logs
+const (
+	ServeCommand     = "serve"
+	DefaultServeAddr = ":8080"
+)
+
+// DefaultStateFile is the batch progress file --resume reads, written in the output directory
+// (or the working directory) while a run with several inputs is in progress
+const DefaultStateFile = "scrub_batch_state.json"
+
+// DefaultEmailTemplate formats anonymized emails as the user token at the mapped domain, e.g. user5@domain1
+const DefaultEmailTemplate = "{token}@{domain}"
+
+// ExternalEmailDomain replaces the domain of email addresses outside the internal domains
+const ExternalEmailDomain = "external.invalid"
+
+// DefaultMaskChar is the character masks are made of unless --mask-char says otherwise
+const DefaultMaskChar = "*"
+
+// Mapped user token prefixes
+const (
+	UserTokenPrefix  = "user"  // Default token prefix (user1, user2, ...)
+	DomainTokenPrefix = "domain" // Default mapped domain prefix (domain1, domain2, ...)
+	AdminTokenPrefix = "admin" // Role token for system admins when role tokens are enabled
+	GuestTokenPrefix = "guest" // Role token for guests when role tokens are enabled
+)
+
+// Log kind constants
+const (
+	LogKindAuto          = "auto"          // Detect notification log lines automatically
+	LogKindApp           = "app"           // Main application log (mattermost.log)
+	LogKindNotifications = "notifications" // Push notification log (notifications.log)
 )
 
 // Overwrite action constants
@@ -61,7 +137,70 @@ const (
 	OverwriteCancel    = "cancel"    // Cancel operation on any conflict
 )
 
+// Rename scheme constants for the rename overwrite choice
+const (
+	RenameSchemeTimestamp  = "timestamp"  // Append _YYYYMMDD_HHMMSS
+	RenameSchemeSequential = "sequential" // Append _1, _2, ... using the first name that doesn't exist
+)
+
+// Cancel scope constants
+const (
+	CancelScopeRun  = "run"  // A cancelled file conflict aborts the whole run
+	CancelScopeFile = "file" // A cancelled file conflict skips only that artifact
+)
+
+// IP scrubbing strategy constants
+const (
+	IPStrategyMask  = "mask"  // Mask octets according to the scrubbing level
+	IPStrategyClass = "class" // Replace with stable class labels like ip_private_1 or ip_public_2
+)
+
+// Input format constants
+const (
+	InputFormatJSON      = "json"      // JSON log lines, with plain-text fallback
+	InputFormatDelimited = "delimited" // Tab- or space-delimited columns, e.g. reverse proxy access logs
+	InputFormatPlain     = "plain"     // Plain-text lines, scrubbed without attempting to parse JSON
+)
+
+// JSON failure action constants
+const (
+	JSONFailureScrub  = "scrub"  // Scrub lines that aren't valid JSON as plain text
+	JSONFailureDrop   = "drop"   // Omit lines that aren't valid JSON from the output
+	JSONFailureRedact = "redact" // Replace lines that aren't valid JSON with RedactedLineMarker
+
+	RedactedLineMarker = "[unparseable line redacted]"
+)
+
+// RedactedTermMarker replaces every match of a redact list entry
+const RedactedTermMarker = "[redacted]"
+
+// HTMLReportTopValues is how many of the most frequent anonymized values the HTML report lists
+const HTMLReportTopValues = 20
+
+// Timestamp normalization formats
+const (
+	TimeFormatRFC3339  = "rfc3339"  // UTC RFC 3339 strings with milliseconds
+	TimeFormatEpochMS  = "epoch-ms" // Millisecond epoch integers
+	TimeFormatRelative = "relative" // Offsets from the first timestamp, e.g. "+00:01:23.456"
+)
+
+// What happens to the output of a run stopped by --timeout
+const (
+	TimeoutOutputKeep    = "keep"    // Keep the output written so far and write the audit and reports for it
+	TimeoutOutputDiscard = "discard" // Remove the output written by the run and skip the audit and reports
+)
+
+// How --sample-changes shows the original of each sampled replacement
+const (
+	SampleOriginalsShow = "show" // As recorded in the audit: plaintext, or hashed with --audit-hash-originals
+	SampleOriginalsMask = "mask" // First character of each word only, e.g. a****@a***.com
+	SampleOriginalsHide = "hide" // Only the replacement
+)
+
 // File size constants
 const (
-	DefaultMaxFileSize = 150 * 1024 * 1024 // 150MB default limit
+	DefaultMaxFileSize   = 150 * 1024 * 1024  // 150MB default limit
+	DefaultConfirmSize   = 1024 * 1024 * 1024 // Ask before scrubbing more than 1GB interactively
+	EstimatedBytesPerSec = 20 * 1024 * 1024   // Rough scrubbing throughput used for time estimates
+	TraceWarnSize        = 10 * 1024 * 1024   // Warn that --trace is meant for small inputs above 10MB
 )
\ No newline at end of file
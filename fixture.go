@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mattermost-log-scrubber/config"
+	"mattermost-log-scrubber/constants"
+)
+
+// maxFixtureDiffLines limits how many differing lines a failed fixture check prints
+const maxFixtureDiffLines = 20
+
+// runFixture scrubs a fixture input with the current settings and compares the result with the
+// expected output, or rewrites the expected output with --update-fixture
+// A mismatch is returned as an error so CI jobs fail
+func runFixture(flags config.CLIFlags) error {
+	if flags.VerifyFixture == "" {
+		return fmt.Errorf("--update-fixture requires --verify-fixture")
+	}
+	if len(flags.FixtureArgs) != 1 {
+		return fmt.Errorf("--verify-fixture needs the expected output file as the last argument, e.g. --verify-fixture input.log expected.log")
+	}
+	expectedPath := flags.FixtureArgs[0]
+
+	// The fixture replaces any configured input
+	flags.InputFile, flags.Input, flags.InputList = []string{flags.VerifyFixture}, nil, ""
+	settings, err := setupApplication(flags)
+	if err != nil {
+		return err
+	}
+	if len(settings.InputPaths) != 1 {
+		return fmt.Errorf("--verify-fixture scrubs a single input, but %d are configured", len(settings.InputPaths))
+	}
+
+	actual, err := scrubFixture(settings)
+	if err != nil {
+		return err
+	}
+
+	if flags.UpdateFixture {
+		if err := os.WriteFile(expectedPath, []byte(actual), 0644); err != nil {
+			return fmt.Errorf("writing expected output: %w", err)
+		}
+		fmt.Printf("Fixture updated: %s\n", expectedPath)
+		return nil
+	}
+
+	expected, err := os.ReadFile(expectedPath)
+	if err != nil {
+		return fmt.Errorf("reading expected output: %w", err)
+	}
+	if diffs := printFixtureDiff(string(expected), actual); diffs > 0 {
+		return fmt.Errorf("fixture '%s' does not match '%s': %d line(s) differ", flags.VerifyFixture, expectedPath, diffs)
+	}
+	fmt.Printf("Fixture matches: %s\n", expectedPath)
+	return nil
+}
+
+// scrubFixture runs the normal scrub path on the fixture into a temp file and returns the scrubbed text
+// Options that only change how output is packaged (compression, splitting, --no-output) are ignored
+func scrubFixture(settings config.ResolvedSettings) (string, error) {
+	settings.CompressOutputFile = false
+	settings.SplitBytes = 0
+	settings.NoOutput = false
+
+	s, _, err := newScrubber(settings)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp("", constants.AppName+"-fixture-")
+	if err != nil {
+		return "", fmt.Errorf("creating temp directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	outputPath := filepath.Join(dir, "scrubbed"+constants.ExtLog)
+	if _, err := s.ProcessFile(settings.InputPath, outputPath, false, false, constants.OverwriteOverwrite); err != nil {
+		return "", fmt.Errorf("processing fixture '%s': %w", settings.InputPath, err)
+	}
+	scrubbed, err := os.ReadFile(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("reading scrubbed fixture: %w", err)
+	}
+	return string(scrubbed), nil
+}
+
+// printFixtureDiff prints the lines that differ between expected and actual output, line by line,
+// and returns how many differ
+func printFixtureDiff(expected, actual string) int {
+	expectedLines := strings.Split(strings.TrimSuffix(expected, "\n"), "\n")
+	actualLines := strings.Split(strings.TrimSuffix(actual, "\n"), "\n")
+
+	lineCount := len(expectedLines)
+	if len(actualLines) > lineCount {
+		lineCount = len(actualLines)
+	}
+
+	diffs := 0
+	for i := 0; i < lineCount; i++ {
+		var want, got string
+		if i < len(expectedLines) {
+			want = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			got = actualLines[i]
+		}
+		if want == got && i < len(expectedLines) && i < len(actualLines) {
+			continue
+		}
+
+		diffs++
+		if diffs > maxFixtureDiffLines {
+			continue
+		}
+		fmt.Printf("Line %d:\n", i+1)
+		if i < len(expectedLines) {
+			fmt.Printf("  - %s\n", want)
+		}
+		if i < len(actualLines) {
+			fmt.Printf("  + %s\n", got)
+		}
+	}
+	if diffs > maxFixtureDiffLines {
+		fmt.Printf("... and %d more differing line(s)\n", diffs-maxFixtureDiffLines)
+	}
+	if len(expectedLines) != len(actualLines) {
+		fmt.Printf("Expected %d line(s), got %d\n", len(expectedLines), len(actualLines))
+	}
+	return diffs
+}
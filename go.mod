@@ -1,3 +1,7 @@
 module mattermost-log-scrubber
 
 go 1.21
+
+require golang.org/x/text v0.21.0
+
+require github.com/ulikunitz/xz v0.5.15
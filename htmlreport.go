@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"time"
+
+	"mattermost-log-scrubber/config"
+	"mattermost-log-scrubber/constants"
+	"mattermost-log-scrubber/scrubber"
+)
+
+// htmlReportData is everything shown in the HTML report; it never holds an original value
+type htmlReportData struct {
+	AppName        string
+	Version        string
+	GeneratedAt    string
+	Duration       string
+	Level          int
+	Inputs         []string
+	Stats          scrubber.RunStats
+	Types          []htmlReportType
+	Total          int
+	Identities     int
+	FixedWidth     bool
+	TopValues      []scrubber.FrequencyEntry
+	DistinctValues int
+	Settings       []effectiveSetting
+}
+
+// htmlReportType is the replacement count of one scrub type
+type htmlReportType struct {
+	Type         string
+	Replacements int
+	Unique       int
+}
+
+// htmlReportTemplate lays out the report as a single self-contained page
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Log scrub report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 60em; color: #222; }
+h1 { font-size: 1.6em; } h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #ddd; }
+table { border-collapse: collapse; margin: 0.5em 0; } th, td { padding: 0.3em 0.9em; text-align: left; border-bottom: 1px solid #eee; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; } code { font-size: 0.9em; }
+.note { color: #666; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>Log scrub report</h1>
+<p>Generated {{.GeneratedAt}} by {{.AppName}} v{{.Version}} at scrub level {{.Level}}, in {{.Duration}}.</p>
+<p class="note">This report only contains anonymized values, never the original values they replaced.</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Values replaced</th><td class="n">{{.Total}}</td></tr>
+<tr><th>Identities anonymized</th><td class="n">{{if .FixedWidth}}not tracked with fixed-width masks{{else}}{{.Identities}}{{end}}</td></tr>
+<tr><th>Input files</th><td class="n">{{.Stats.Files}}</td></tr>
+</table>
+
+<h2>Replacements by type</h2>
+{{if .Types}}<table>
+<tr><th>Type</th><th>Replacements</th><th>Unique values</th></tr>
+{{range .Types}}<tr><td>{{.Type}}</td><td class="n">{{.Replacements}}</td><td class="n">{{.Unique}}</td></tr>
+{{end}}</table>{{else}}<p>No values were replaced.</p>{{end}}
+
+<h2>Most frequent anonymized values</h2>
+{{if .TopValues}}<table>
+<tr><th>Anonymized value</th><th>Type</th><th>Count</th></tr>
+{{range .TopValues}}<tr><td><code>{{.NewValue}}</code></td><td>{{.Type}}</td><td class="n">{{.Count}}</td></tr>
+{{end}}</table>
+{{if gt .DistinctValues (len .TopValues)}}<p class="note">Top {{len .TopValues}} of {{.DistinctValues}} anonymized values.</p>{{end}}{{else}}<p>None.</p>{{end}}
+
+<h2>Processing</h2>
+<table>
+<tr><th>Lines read</th><td class="n">{{.Stats.Lines}}</td></tr>
+<tr><th>Lines scrubbed</th><td class="n">{{.Stats.LinesScrubbed}}</td></tr>
+<tr><th>Empty lines skipped</th><td class="n">{{.Stats.LinesEmpty}}</td></tr>
+<tr><th>JSON lines</th><td class="n">{{.Stats.JSONLines}}</td></tr>
+<tr><th>Lines that weren't JSON</th><td class="n">{{.Stats.JSONFailures}}</td></tr>
+{{if .Stats.LinesDropped}}<tr><th>Unparseable lines dropped</th><td class="n">{{.Stats.LinesDropped}}</td></tr>{{end}}
+{{if .Stats.LinesFailed}}<tr><th>Lines that failed and were kept</th><td class="n">{{.Stats.LinesFailed}}</td></tr>{{end}}
+{{if .Stats.LinesCollapsed}}<tr><th>Repeated lines collapsed</th><td class="n">{{.Stats.LinesCollapsed}}</td></tr>{{end}}
+</table>
+<p>Inputs:</p>
+<ul>
+{{range .Inputs}}<li><code>{{.}}</code></li>
+{{end}}</ul>
+
+<h2>Settings used</h2>
+<p class="note">Settings left at their empty default are not shown.</p>
+<table>
+{{range .Settings}}<tr><td><code>{{.Name}}</code></td><td><code>{{.Value}}</code></td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// writeHTMLReport writes a human-readable summary of the run: counts per type, identities, the most
+// frequent anonymized values, processing statistics and the settings used. Values come from the
+// frequency table, which only holds anonymized values, so the page can be shared like the scrubbed log.
+// Returns the actual report path used (which may differ if renamed)
+func writeHTMLReport(s *scrubber.Scrubber, settings config.ResolvedSettings, inputs []processedInput, duration time.Duration) (string, error) {
+	stats := s.Stats()
+	data := htmlReportData{
+		AppName:     constants.AppName,
+		Version:     constants.Version,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Duration:    duration.Round(time.Millisecond).String(),
+		Level:       settings.ScrubLevel,
+		Stats:       stats,
+		Total:       stats.TotalReplacements(),
+		Identities:  s.IdentityCount(),
+		FixedWidth:  settings.FixedWidth,
+	}
+	for _, input := range inputs {
+		data.Inputs = append(data.Inputs, input.path)
+	}
+
+	for valueType, count := range stats.Replacements {
+		data.Types = append(data.Types, htmlReportType{Type: valueType, Replacements: count, Unique: stats.UniqueValues[valueType]})
+	}
+	sort.Slice(data.Types, func(i, j int) bool {
+		if data.Types[i].Replacements != data.Types[j].Replacements {
+			return data.Types[i].Replacements > data.Types[j].Replacements
+		}
+		return data.Types[i].Type < data.Types[j].Type
+	})
+
+	table := s.FrequencyTable()
+	data.DistinctValues = len(table)
+	if len(table) > constants.HTMLReportTopValues {
+		table = table[:constants.HTMLReportTopValues]
+	}
+	data.TopValues = table
+
+	effective, err := effectiveSettings(settings)
+	if err != nil {
+		return "", err
+	}
+	for _, setting := range effective {
+		switch setting.Value {
+		case `""`, "0", "false", "null", "[]", "{}":
+			continue
+		}
+		data.Settings = append(data.Settings, setting)
+	}
+
+	reportPath, err := s.ResolveArtifactPath(settings.HTMLReport, settings.OverwriteAction, "HTML report")
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Create(reportPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTML report: %w", err)
+	}
+	defer file.Close()
+
+	if err := htmlReportTemplate.Execute(file, data); err != nil {
+		return "", fmt.Errorf("failed to write HTML report: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write HTML report: %w", err)
+	}
+	return reportPath, nil
+}
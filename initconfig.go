@@ -0,0 +1,49 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+
+	"mattermost-log-scrubber/cli"
+	"mattermost-log-scrubber/config"
+	"mattermost-log-scrubber/constants"
+)
+
+//go:embed example_scrubber_config.json
+var starterConfig []byte
+
+// errNoInput means the run has no input from flags or any config file, which is what a first run looks like
+var errNoInput = errors.New("no input file given and no config file found")
+
+// initConfig writes the example config to the -c path (default: scrubber_config.json) as a starting point
+// An existing file is never replaced
+func initConfig(flags config.CLIFlags) error {
+	configPath := flags.ConfigFile
+	if configPath == "" {
+		configPath = flags.ConfigLong
+	}
+	if configPath == "" {
+		configPath = constants.DefaultConfigFile
+	}
+
+	if _, err := os.Stat(configPath); err == nil {
+		return fmt.Errorf("config file '%s' already exists; remove it or choose another path with -c", configPath)
+	}
+	if err := os.WriteFile(configPath, starterConfig, 0644); err != nil {
+		return fmt.Errorf("writing config file: %w", err)
+	}
+
+	fmt.Printf("Starter config written to: %s\n", configPath)
+	fmt.Println("Edit FileSettings.InputFile and the other values, then run the scrubber again.")
+	return nil
+}
+
+// printGettingStarted explains how to make a first run, followed by the full usage
+func printGettingStarted() {
+	fmt.Fprintf(os.Stderr, "%s needs a log file to scrub. To get started, either:\n", constants.AppName)
+	fmt.Fprintf(os.Stderr, "  - pass one on the command line:  %s -i mattermost.log -l 1\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  - or create a config file:       %s --init-config\n\n", os.Args[0])
+	cli.PrintUsage()
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"mattermost-log-scrubber/cli"
 	"mattermost-log-scrubber/config"
@@ -14,8 +20,18 @@ import (
 
 func main() {
 	if err := runApplication(); err != nil {
+		if errors.Is(err, errNoInput) {
+			printGettingStarted()
+			os.Exit(constants.ExitError)
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		if errors.Is(err, errNoChanges) {
+			os.Exit(constants.ExitNoChanges)
+		}
+		if errors.Is(err, scrubber.ErrDeadlineExceeded) {
+			os.Exit(constants.ExitTimeout)
+		}
+		os.Exit(constants.ExitError)
 	}
 }
 
@@ -24,6 +40,36 @@ func runApplication() error {
 	// Parse command line flags
 	flags := cli.ParseFlags()
 
+	// The self-test needs no input or config
+	if flags.SelfTest {
+		return runSelfTest()
+	}
+
+	// Fixture checks scrub their own input and write nothing but the expected file
+	if flags.VerifyFixture != "" || flags.UpdateFixture {
+		return runFixture(flags)
+	}
+
+	// Reversing a scrub needs only the scrubbed log and its audit
+	if flags.Reverse {
+		return runReverse(flags)
+	}
+
+	// Writing a starter config needs no input either
+	if flags.InitConfig {
+		return initConfig(flags)
+	}
+
+	// Serve mode takes its input from each request
+	if flags.Serve {
+		return runServe(flags)
+	}
+
+	// Showing the effective configuration doesn't need a valid or complete one
+	if flags.PrintConfig {
+		return printConfig(flags)
+	}
+
 	// Setup configuration
 	settings, err := setupApplication(flags)
 	if err != nil {
@@ -32,135 +78,1245 @@ func runApplication() error {
 
 	// Resolve file paths
 	resolveFilePaths(&settings)
+	if err := checkDistinctOutputs(settings); err != nil {
+		return err
+	}
+
+	// A dry run can still write its output, to a temp directory instead of the real path
+	if settings.DryRun && settings.ToTemp {
+		if err := useTempOutputs(&settings); err != nil {
+			return err
+		}
+	}
+
+	// Report unwritable output and audit directories before any input is processed
+	if err := config.ValidateOutputPermissions(settings); err != nil {
+		return err
+	}
 
 	// Show configuration info
 	showConfigInfo(settings)
 
+	// Give the user a chance to back out of an accidentally huge run
+	if err := confirmLargeInput(settings); err != nil {
+		return err
+	}
+
+	// Prompts read stdin, so they can't be answered while stdin is being scrubbed
+	if err := checkStdinPrompts(settings); err != nil {
+		return err
+	}
+
+	// Confirm all overwrites at once when several files would be replaced
+	if err := confirmOverwriteSummary(&settings); err != nil {
+		return err
+	}
+
 	// Run scrubbing process
 	return runScrubbing(settings)
 }
 
-// setupApplication handles configuration loading and validation
-func setupApplication(flags config.CLIFlags) (config.ResolvedSettings, error) {
-	// Get config file path
+// runSelfTest scrubs the embedded sample and prints a pass/fail line per PII category
+// Any failure is returned as an error so the process exits non-zero
+func runSelfTest() error {
+	fmt.Printf("%s v%s self-test\n", constants.AppName, constants.Version)
+
+	results, err := scrubber.RunSelfTest()
+	if err != nil {
+		return fmt.Errorf("self-test: %w", err)
+	}
+
+	failed := 0
+	for _, result := range results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+			failed++
+		}
+		if result.Detail != "" {
+			fmt.Printf("  [%s] %s: %s\n", status, result.Category, result.Detail)
+		} else {
+			fmt.Printf("  [%s] %s\n", status, result.Category)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("self-test failed: %d of %d categories did not pass", failed, len(results))
+	}
+	fmt.Printf("Self-test passed: all %d categories scrubbed correctly\n", len(results))
+	return nil
+}
+
+// loadConfigFile loads the config file if it exists, returning nil when there is none
+// A config file named with -c must exist
+func loadConfigFile(flags config.CLIFlags) (*config.Config, string, error) {
 	configPath, userSpecifiedConfig := cli.GetConfigPath(flags)
 
-	// Load config file if it exists
-	var configFile *config.Config
 	if _, err := os.Stat(configPath); err == nil {
-		configFile, err = config.LoadConfig(configPath)
+		configFile, err := config.LoadConfig(configPath)
 		if err != nil {
-			return config.ResolvedSettings{}, fmt.Errorf("loading config file '%s': %w", configPath, err)
+			return nil, configPath, fmt.Errorf("loading config file '%s': %w", configPath, err)
 		}
+		return configFile, configPath, nil
 	} else if userSpecifiedConfig {
-		return config.ResolvedSettings{}, fmt.Errorf("specified config file '%s' does not exist", configPath)
+		return nil, configPath, fmt.Errorf("specified config file '%s' does not exist", configPath)
+	}
+	return nil, configPath, nil
+}
+
+// loadProfile applies the profile named with --profile over the config file, returning the config
+// unchanged when no profile is selected, and the settings the profile set
+func loadProfile(flags config.CLIFlags, configFile *config.Config) (*config.Config, []string, error) {
+	if flags.Profile == "" {
+		return configFile, nil, nil
+	}
+	return config.ApplyProfile(configFile, flags.Profile)
+}
+
+// loadRulesFile loads the rules file named with --rules, or else by the config file, and returns the
+// config with the rules merged in; without a rules file the config is returned unchanged
+func loadRulesFile(flags config.CLIFlags, configFile *config.Config) (*config.Config, *config.Rules, string, error) {
+	rulesPath := flags.Rules
+	if rulesPath == "" && configFile != nil {
+		rulesPath = configFile.FileSettings.RulesFile
+	}
+	if rulesPath == "" {
+		return configFile, nil, "", nil
+	}
+
+	rules, err := config.LoadRules(rulesPath)
+	if err != nil {
+		return nil, nil, rulesPath, err
+	}
+	return config.ApplyRules(configFile, rules), rules, rulesPath, nil
+}
+
+// setupApplication handles configuration loading and validation
+func setupApplication(flags config.CLIFlags) (config.ResolvedSettings, error) {
+	// Load config file if it exists
+	configFile, configPath, err := loadConfigFile(flags)
+	if err != nil {
+		return config.ResolvedSettings{}, err
 	}
 
-	// Resolve settings from CLI and config
-	settings := config.ResolveSettings(flags, configFile)
+	// A profile is applied before the rules file, which may be named by the profile
+	profiledConfig, profileSettings, err := loadProfile(flags, configFile)
+	if err != nil {
+		return config.ResolvedSettings{}, err
+	}
+	mergedConfig, rules, rulesPath, err := loadRulesFile(flags, profiledConfig)
+	if err != nil {
+		return config.ResolvedSettings{}, err
+	}
+
+	// Resolve settings from CLI, config, profile and rules
+	settings, sources := config.ResolveSettings(flags, mergedConfig)
+	sources.AttributeProfile(profileSettings)
+	if rules != nil {
+		sources.AttributeRules(rules)
+	}
+	useStdio(&settings)
+	out := messageOutput(settings)
 	
-	// Only show config file message if config values are actually being used
-	if configFile != nil && isConfigFileUsed(flags) {
-		fmt.Printf("Using config file at %s\n", configPath)
+	// Say which settings the config and rules files provided, so their influence is never hidden
+	if configFile != nil {
+		showConfigSources(out, configPath, settings.Verbose, sources)
+	}
+	if flags.Profile != "" {
+		fromProfile := sources.From(config.SourceProfile)
+		if len(fromProfile) == 0 {
+			fmt.Fprintf(out, "Profile '%s' was applied, but it sets nothing the command line didn't override\n", flags.Profile)
+		} else {
+			fmt.Fprintf(out, "Using profile '%s' for: %s\n", flags.Profile, strings.Join(fromProfile, ", "))
+		}
+	}
+	if rules != nil {
+		fromRules := sources.From(config.SourceRules)
+		if len(fromRules) == 0 {
+			fmt.Fprintf(out, "Rules file at %s was loaded, but it sets no rules the command line didn't override\n", rulesPath)
+		} else {
+			fmt.Fprintf(out, "Using rules file at %s for: %s\n", rulesPath, strings.Join(fromRules, ", "))
+		}
+	}
+
+	// A first run with nothing to go on gets guidance rather than a validation error
+	if configFile == nil && len(settings.InputPaths) == 0 && settings.InputList == "" {
+		return settings, errNoInput
+	}
+
+	// Add inputs listed in a file
+	if settings.InputList != "" {
+		listed, err := config.ReadInputList(settings.InputList)
+		if err != nil {
+			return settings, err
+		}
+		fmt.Fprintf(out, "Input list '%s' names %d input(s)\n", settings.InputList, len(listed))
+		settings.InputPaths = append(settings.InputPaths, listed...)
+		settings.InputPath = settings.InputPaths[0]
+	}
+
+	// Expand input wildcards ourselves, since not every shell does
+	matches, err := config.ExpandInputs(&settings)
+	if err != nil {
+		return settings, err
+	}
+	for _, match := range matches {
+		if match.Directory && match.Skipped > 0 {
+			fmt.Fprintf(out, "Input directory '%s' contains %d log file(s); %d file(s) skipped as earlier output or by --exclude-glob\n", match.Pattern, match.Count, match.Skipped)
+		} else if match.Directory {
+			fmt.Fprintf(out, "Input directory '%s' contains %d log file(s)\n", match.Pattern, match.Count)
+		} else {
+			fmt.Fprintf(out, "Input pattern '%s' matched %d file(s)\n", match.Pattern, match.Count)
+		}
+	}
+
+	// Report missing inputs and carry on with the rest
+	if settings.SkipMissing {
+		missing := config.DropMissingInputs(&settings)
+		for _, inputPath := range missing {
+			fmt.Fprintf(out, "Warning: input file '%s' does not exist and will be skipped\n", inputPath)
+		}
+		if len(missing) > 0 && len(settings.InputPaths) == 0 {
+			return settings, fmt.Errorf("none of the %d input files exist", len(missing))
+		}
 	}
 
 	// Validate settings
-	if err := config.ValidateSettings(settings); err != nil {
+	if err := config.ValidateSettings(&settings); err != nil {
 		return settings, err
 	}
 
 	return settings, nil
 }
 
-// isConfigFileUsed checks if essential CLI flags are missing and config file would provide them
-func isConfigFileUsed(flags config.CLIFlags) bool {
-	// Only show message if required flags are missing (input file or scrub level)
-	inputProvided := flags.InputFile != "" || flags.Input != ""
-	levelProvided := flags.Level != 0 || flags.LevelLong != 0
-	
-	return !inputProvided || !levelProvided
+// useStdio reads piped standard input when no input is given, and writes the scrubbed log of standard
+// input to standard output unless an output is given. With the log on standard output, messages go to
+// stderr instead, see messageOutput.
+func useStdio(settings *config.ResolvedSettings) {
+	if len(settings.InputPaths) == 0 && settings.InputList == "" && stdinIsPiped() {
+		settings.InputPaths = []string{constants.StdioPath}
+		settings.InputPath = constants.StdioPath
+	}
+	if len(settings.InputPaths) == 1 && scrubber.IsStdio(settings.InputPath) && settings.OutputPath == "" && settings.OutputDir == "" {
+		settings.OutputPath = constants.StdioPath
+	}
+}
+
+// messageOutput returns where a run prints progress, warnings and summaries: standard output, or
+// standard error when the scrubbed log or --stats goes to standard output, so the piped stream stays clean
+func messageOutput(settings config.ResolvedSettings) io.Writer {
+	if scrubber.IsStdio(settings.OutputPath) || scrubber.IsStdio(settings.StatsPath) {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// stdinIsPiped reports whether stdin is a pipe or a redirected file, rather than a terminal or the null device
+func stdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular()
+}
+
+// showConfigSources reports which settings came from the config file
+// With --verbose, every setting is listed with its source (cli, config or default)
+func showConfigSources(out io.Writer, configPath string, verbose bool, sources config.Provenance) {
+	fromConfig := sources.From(config.SourceConfig)
+	if len(fromConfig) == 0 {
+		fmt.Fprintf(out, "Config file at %s was found, but every setting came from the command line or defaults\n", configPath)
+	} else {
+		fmt.Fprintf(out, "Using config file at %s for: %s\n", configPath, strings.Join(fromConfig, ", "))
+	}
+
+	if verbose {
+		fmt.Fprintln(out, "Setting sources:")
+		for _, source := range []string{config.SourceCLI, config.SourceProfile, config.SourceConfig, config.SourceRules, config.SourceDefault} {
+			for _, setting := range sources.From(source) {
+				fmt.Fprintf(out, "  %s: %s\n", setting, source)
+			}
+		}
+	}
 }
 
 // resolveFilePaths sets default file paths if not specified
 func resolveFilePaths(settings *config.ResolvedSettings) {
-	// Set default output path if not specified
-	if settings.OutputPath == "" {
-		ext := filepath.Ext(settings.InputPath)
-		base := strings.TrimSuffix(settings.InputPath, ext)
-		settings.OutputPath = base + constants.ScrubSuffix + ext
+	// Default paths are derived from the input file name
+	inputPath := defaultPathBase(settings.InputPath)
+
+	// Several inputs get one output each, next to the input or inside the output directory
+	outputDir := settings.OutputDir
+	if len(settings.InputPaths) > 1 && settings.OutputPath != "" {
+		outputDir = settings.OutputPath
 	}
-	
-	// Add .gz extension if compression is enabled and not already present
-	if settings.CompressOutputFile && !strings.HasSuffix(settings.OutputPath, constants.ExtGZ) {
-		settings.OutputPath += constants.ExtGZ
+	if len(settings.InputPaths) > 1 || outputDir != "" {
+		settings.OutputPaths = nil
+		for _, path := range settings.InputPaths {
+			outputPath := scrubbedPath(defaultPathBase(path))
+			if outputDir != "" {
+				outputPath = scrubber.JoinArtifactPath(outputDir, filepath.Base(outputPath))
+			}
+			if settings.CompressOutputFile {
+				outputPath += constants.ExtGZ
+			}
+			settings.OutputPaths = append(settings.OutputPaths, outputPath)
+		}
+		if len(settings.InputPaths) == 1 {
+			settings.OutputPath = settings.OutputPaths[0]
+		}
+	} else {
+		// Set default output path if not specified
+		if settings.OutputPath == "" {
+			settings.OutputPath = scrubbedPath(inputPath)
+		}
+
+		// Add .gz extension if compression is enabled and not already present
+		if settings.CompressOutputFile && !strings.HasSuffix(settings.OutputPath, constants.ExtGZ) && !scrubber.IsStdio(settings.OutputPath) {
+			settings.OutputPath += constants.ExtGZ
+		}
+		settings.OutputPaths = []string{settings.OutputPath}
 	}
 
-	// Set default audit path if not specified
-	if settings.AuditPath == "" {
-		ext := filepath.Ext(settings.InputPath)
-		base := strings.TrimSuffix(settings.InputPath, ext)
-		if settings.AuditFileType == constants.AuditTypeJSON {
-			settings.AuditPath = base + constants.AuditSuffix + constants.ExtJSON
-		} else {
-			settings.AuditPath = base + constants.AuditSuffix + constants.ExtCSV
+	// Derive one audit path per requested format
+	// A user-specified audit path is used as-is for a single format, or as the base name for several
+	// The default audit goes next to the first input, or into the output directory when one is set
+	auditBase := settings.AuditPath
+	if auditBase == "" {
+		ext := filepath.Ext(inputPath)
+		auditBase = strings.TrimSuffix(inputPath, ext) + constants.AuditSuffix
+		if settings.OutputDir != "" {
+			auditBase = scrubber.JoinArtifactPath(settings.OutputDir, filepath.Base(auditBase))
+		}
+	} else if len(settings.AuditFileTypes) > 1 {
+		auditBase = strings.TrimSuffix(auditBase, filepath.Ext(auditBase))
+	}
+
+	settings.AuditOutputs = nil
+	for _, auditType := range settings.AuditFileTypes {
+		path := auditBase + auditExtension(auditType)
+		if settings.AuditPath != "" && len(settings.AuditFileTypes) == 1 {
+			path = settings.AuditPath
+		}
+		settings.AuditOutputs = append(settings.AuditOutputs, config.AuditOutput{Type: auditType, Path: path})
+	}
+}
+
+// checkDistinctOutputs makes sure no two inputs would be scrubbed into the same output file,
+// e.g. logs/a/mattermost.log and logs/b/mattermost.log with a shared output directory
+func checkDistinctOutputs(settings config.ResolvedSettings) error {
+	seen := make(map[string]string)
+	for i, outputPath := range settings.OutputPaths {
+		if other, exists := seen[outputPath]; exists {
+			return fmt.Errorf("inputs '%s' and '%s' would both be written to '%s'", other, settings.InputPaths[i], outputPath)
 		}
+		seen[outputPath] = settings.InputPaths[i]
 	}
+	return nil
+}
+
+// defaultPathBase returns the path default output and audit names are derived from
+// Socket and standard input have no file of their own, so defaults go to the current directory,
+// named after the socket or as stdin.log
+func defaultPathBase(inputPath string) string {
+	if scrubber.IsStdio(inputPath) {
+		return constants.StdinSourceName + constants.ExtLog
+	}
+	if scrubber.IsUnixSocketInput(inputPath) {
+		socketName := filepath.Base(strings.TrimPrefix(inputPath, constants.UnixSocketScheme))
+		return strings.TrimSuffix(socketName, filepath.Ext(socketName)) + constants.ExtLog
+	}
+	// Compressed inputs are written decompressed, so app.log.xz becomes app_scrubbed.log
+	return scrubber.TrimCompressedExt(inputPath)
+}
+
+// scrubbedPath returns the default output path for an input: <input>_scrubbed.<ext>
+// A rotated log keeps its number after the extension, so mattermost.log.1 gives mattermost_scrubbed.log.1
+func scrubbedPath(inputPath string) string {
+	ext := filepath.Ext(inputPath)
+	if rotated := strings.TrimSuffix(inputPath, ext); ext != "" && strings.Trim(ext[1:], "0123456789") == "" && strings.HasSuffix(rotated, constants.ExtLog) {
+		return strings.TrimSuffix(rotated, constants.ExtLog) + constants.ScrubSuffix + constants.ExtLog + ext
+	}
+	return strings.TrimSuffix(inputPath, ext) + constants.ScrubSuffix + ext
+}
+
+// useTempOutputs redirects every output to a new temp directory, leaving the intended paths untouched
+// Files are named <input>_scrubbed.dryrun.<ext> so they are clearly dry-run artifacts, and are never deleted
+func useTempOutputs(settings *config.ResolvedSettings) error {
+	tempDir, err := os.MkdirTemp("", constants.AppName+"-dryrun-")
+	if err != nil {
+		return fmt.Errorf("creating temp directory for dry-run output: %w", err)
+	}
+
+	for i, outputPath := range settings.OutputPaths {
+		name := filepath.Base(outputPath)
+		gz := strings.HasSuffix(name, constants.ExtGZ)
+		name = strings.TrimSuffix(name, constants.ExtGZ)
+		ext := filepath.Ext(name)
+		name = strings.TrimSuffix(name, ext) + constants.DryRunSuffix + ext
+		if gz {
+			name += constants.ExtGZ
+		}
+		settings.OutputPaths[i] = filepath.Join(tempDir, name)
+	}
+	settings.OutputPath = settings.OutputPaths[0]
+	return nil
+}
+
+// stdioName returns a path for display, naming "-" as the standard stream, e.g. (standard input)
+func stdioName(path, stream string) string {
+	if scrubber.IsStdio(path) {
+		return "(standard " + stream + ")"
+	}
+	return path
+}
+
+// auditExtension returns the default file extension for an audit file type
+func auditExtension(auditType string) string {
+	switch auditType {
+	case constants.AuditTypeJSON:
+		return constants.ExtJSON
+	case constants.AuditTypeNDJSON:
+		return constants.ExtNDJSON
+	}
+	return constants.ExtCSV
 }
 
 // showConfigInfo displays the current configuration
 func showConfigInfo(settings config.ResolvedSettings) {
-	fmt.Printf("Input file: %s\n", settings.InputPath)
-	fmt.Printf("Output file: %s\n", settings.OutputPath)
-	fmt.Printf("Audit file: %s\n", settings.AuditPath)
-	fmt.Printf("Scrubbing level: %d\n", settings.ScrubLevel)
-	fmt.Printf("Compress output: %t\n", settings.CompressOutputFile)
-	fmt.Printf("Dry run: %t\n", settings.DryRun)
+	out := messageOutput(settings)
+	if len(settings.InputPaths) > 1 {
+		fmt.Fprintf(out, "Input files: %d\n", len(settings.InputPaths))
+		for i, inputPath := range settings.InputPaths {
+			if settings.NoOutput {
+				fmt.Fprintf(out, "  %s\n", inputPath)
+			} else {
+				fmt.Fprintf(out, "  %s -> %s\n", inputPath, settings.OutputPaths[i])
+			}
+		}
+		if settings.NoOutput {
+			fmt.Fprintln(out, "Output files: (not written, --no-output)")
+		}
+	} else {
+		fmt.Fprintf(out, "Input file: %s\n", stdioName(settings.InputPath, "input"))
+		if settings.NoOutput {
+			fmt.Fprintln(out, "Output file: (not written, --no-output)")
+		} else {
+			fmt.Fprintf(out, "Output file: %s\n", stdioName(settings.OutputPath, "output"))
+		}
+	}
+	for _, audit := range settings.AuditOutputs {
+		fmt.Fprintf(out, "Audit file: %s\n", audit.Path)
+	}
+	fmt.Fprintf(out, "Scrubbing level: %d\n", settings.ScrubLevel)
+	active, inactive := scrubCategories(settings)
+	fmt.Fprintf(out, "Level %d scrubs: %s\n", settings.ScrubLevel, strings.Join(active, ", "))
+	if len(inactive) > 0 {
+		fmt.Fprintf(out, "NOT scrubbed at level %d: %s\n", settings.ScrubLevel, strings.Join(inactive, ", "))
+	}
+	fmt.Fprintf(out, "Compress output: %t\n", settings.CompressOutputFile)
+	fmt.Fprintf(out, "Dry run: %t\n", settings.DryRun)
+	if settings.DryRun && settings.ToTemp {
+		fmt.Fprintln(out, "Dry-run output goes to a temp directory; the real output path is not touched")
+	}
+	if settings.LineRange != "" || settings.ByteRange != "" {
+		fmt.Fprintf(out, "Input range: %s\n", settings.InputRange)
+	}
+	if settings.SplitBytes > 0 {
+		fmt.Fprintf(out, "Split size: %d bytes per part\n", settings.SplitBytes)
+	}
+	if settings.TimeoutDuration > 0 {
+		fmt.Fprintf(out, "Timeout: %s (output of a stopped run: %s)\n", settings.TimeoutDuration, settings.TimeoutOutput)
+	}
+	if settings.ThrottleLines > 0 {
+		fmt.Fprintf(out, "Throttle: %d lines/s\n", settings.ThrottleLines)
+	} else if settings.ThrottleBytes > 0 {
+		fmt.Fprintf(out, "Throttle: %d bytes/s\n", settings.ThrottleBytes)
+	}
+	if settings.ManifestPath != "" {
+		fmt.Fprintf(out, "Manifest file: %s\n", settings.ManifestPath)
+	}
+	if settings.FrequencyReport != "" {
+		fmt.Fprintf(out, "Frequency report: %s\n", settings.FrequencyReport)
+	}
+	if settings.SampleChanges > 0 {
+		fmt.Fprintf(out, "Sample changes: up to %d per type (originals: %s)\n", settings.SampleChanges, settings.SampleOriginals)
+	}
+	if settings.IdentityReport != "" {
+		fmt.Fprintf(out, "Identity report: %s (contains original values; do not share)\n", settings.IdentityReport)
+	}
+	if settings.HTMLReport != "" {
+		fmt.Fprintf(out, "HTML report: %s\n", settings.HTMLReport)
+	}
+	if settings.MetricsPath != "" {
+		fmt.Fprintf(out, "Metrics file: %s\n", settings.MetricsPath)
+	}
+	if settings.StatsPath != "" {
+		fmt.Fprintf(out, "Stats file: %s\n", stdioName(settings.StatsPath, "output"))
+	}
+	if settings.TracePath != "" {
+		fmt.Fprintf(out, "Trace file: %s (contains original values; delete it after use)\n", settings.TracePath)
+		if size := totalInputSize(settings.InputPaths); size > constants.TraceWarnSize {
+			fmt.Fprintf(out, "Warning: --trace writes several lines per input line and is meant for small inputs; these inputs total %s\n", config.FormatFileSize(size))
+		}
+	}
+	if settings.DomainMapFile != "" {
+		fmt.Fprintf(out, "Domain map: %s (%d fixed mappings)\n", settings.DomainMapFile, len(settings.DomainMap))
+	}
+	if settings.RedactListFile != "" {
+		fmt.Fprintf(out, "Redact list: %s (%d entries)\n", settings.RedactListFile, len(settings.RedactList))
+	}
+	if len(settings.InternalDomains) > 0 {
+		fmt.Fprintf(out, "Internal email domains: %s\n", strings.Join(settings.InternalDomains, ", "))
+	}
+	if len(settings.AlwaysScrub) > 0 {
+		fmt.Fprintf(out, "Always-scrub values: %d\n", len(settings.AlwaysScrub))
+		risky := scrubber.OverMatchingValues(settings.AlwaysScrub)
+		switch {
+		case len(risky) > 0 && settings.AllowShortUsernames:
+			fmt.Fprintf(out, "Warning: these always-scrub values are short or generic and will also replace unrelated words: %s\n", strings.Join(risky, ", "))
+		case len(risky) > 0:
+			fmt.Fprintf(out, "Warning: skipped always-scrub values that are short or generic and would replace unrelated words (use --allow-short-usernames to scrub them): %s\n", strings.Join(risky, ", "))
+		}
+	}
+	if strings.Join(settings.Allowlist, "\n") != strings.Join(scrubber.DefaultAllowlist, "\n") {
+		fmt.Fprintf(out, "Allowlist: %s\n", strings.Join(settings.Allowlist, ", "))
+	}
+	if settings.EmailTemplate != constants.DefaultEmailTemplate {
+		fmt.Fprintf(out, "Email template: %s\n", settings.EmailTemplate)
+	}
+	if settings.HashMode {
+		if settings.HashSalt == "" {
+			fmt.Fprintln(out, "Hash mode: user and domain tokens are derived from an unsalted hash; anyone can hash a guessed value to find its token, so set --hash-salt")
+		} else {
+			fmt.Fprintln(out, "Hash mode: user and domain tokens are derived from a salted hash, the same in every run with this salt")
+		}
+		if settings.HashPrefix {
+			fmt.Fprintln(out, "Hash prefix: tokens start with a hash of the original's first 2 characters, which shows which values share them")
+		}
+	}
+	if settings.LenientEmails {
+		fmt.Fprintln(out, "Lenient email detection: addresses with spaces around the @ or wrapped lines are scrubbed too")
+	}
+	if settings.JSONFailureAction != constants.JSONFailureScrub {
+		fmt.Fprintf(out, "Lines that aren't valid JSON: %s\n", settings.JSONFailureAction)
+	}
+	if len(settings.PassthroughFields) > 0 {
+		fmt.Fprintf(out, "Passthrough fields (never scrubbed): %s\n", strings.Join(settings.PassthroughFields, ", "))
+	}
+	if settings.StrictAllowlist {
+		fmt.Fprintln(out, "Strict allowlist mode: only scrub path and field type values are changed")
+	}
+	if settings.CollapseRepeats {
+		fmt.Fprintln(out, "Collapse repeats: identical consecutive scrubbed lines are written once with a repeat count")
+	}
+	if settings.Deterministic {
+		fmt.Fprintln(out, "Deterministic mode: canonical JSON output, byte-identical across runs")
+	}
+	if settings.TwoPass {
+		fmt.Fprintln(out, "Two-pass mode: user mappings are built from the whole input before scrubbing")
+	}
+	if settings.MaxJSONFailureRate > 0 {
+		fmt.Fprintf(out, "Maximum JSON failure rate: %.1f%%\n", settings.MaxJSONFailureRate*100)
+	}
+	if len(settings.NoAuditTypes) > 0 {
+		fmt.Fprintf(out, "Left out of the audit: %s\n", strings.Join(settings.NoAuditTypes, ", "))
+	}
+	if settings.AuditCSVDelimiter != "" || settings.AuditCSVQuoteAll {
+		fmt.Fprintf(out, "CSV audit format: delimiter %q, quote all fields: %t\n", settings.AuditCSVDelimiter, settings.AuditCSVQuoteAll)
+	}
+	if settings.TimeFormat != "" {
+		fmt.Fprintf(out, "Normalize timestamps: %s\n", settings.TimeFormat)
+	}
+	if settings.ContainerLogs {
+		fmt.Fprintln(out, "Container logs: true (the log field of each record is scrubbed)")
+	}
+	if settings.InputFormat == constants.InputFormatPlain {
+		fmt.Fprintln(out, "Plain-text input: lines are scrubbed as plain text without attempting to parse JSON")
+	}
+	if settings.InputFormat == constants.InputFormatDelimited {
+		fmt.Fprintf(out, "Delimited input: only columns %s are scrubbed (delimiter %q)\n", settings.ScrubColumns, scrubber.ParseDelimiter(settings.Delimiter))
+	}
+	if settings.UserPrefix != constants.UserTokenPrefix || settings.DomainPrefix != constants.DomainTokenPrefix {
+		fmt.Fprintf(out, "Mapped value prefixes: %s1 for users, %s1 for domains\n", settings.UserPrefix, settings.DomainPrefix)
+	}
+	if settings.MaskChar != constants.DefaultMaskChar || len(settings.MaskChars) > 0 {
+		fmt.Fprintf(out, "Mask character: %s\n", settings.MaskChar)
+		types := make([]string, 0, len(settings.MaskChars))
+		for valueType := range settings.MaskChars {
+			types = append(types, valueType)
+		}
+		sort.Strings(types)
+		for _, valueType := range types {
+			fmt.Fprintf(out, "  %s: %s\n", valueType, settings.MaskChars[valueType])
+		}
+	}
+	if settings.FixedWidth {
+		fmt.Fprintln(out, "Fixed width: true (values are masked in place; mapping consistency is disabled)")
+	}
 }
 
-// runScrubbing executes the scrubbing process
-func runScrubbing(settings config.ResolvedSettings) error {
-	// Initialize scrubber
+// scrubCategories lists the kinds of data the run will and will not scrub, so users know
+// up front why IPs or IDs may remain in the output
+func scrubCategories(settings config.ResolvedSettings) (active, inactive []string) {
+	active = []string{"emails", "usernames", "URLs", "SSNs/national IDs"}
+
+	if settings.ScrubLevel >= constants.ScrubLevelMedium {
+		active = append(active, "phone numbers", "hostnames")
+		if settings.IPStrategy == constants.IPStrategyClass {
+			active = append(active, "IP addresses (class labels)")
+		} else if settings.ScrubLevel == constants.ScrubLevelMedium {
+			active = append(active, "IP addresses (partial)")
+		} else {
+			active = append(active, "IP addresses")
+		}
+	} else {
+		inactive = append(inactive, "phone numbers", "hostnames", "IP addresses")
+	}
+
+	if settings.ScrubLevel >= constants.ScrubLevelHigh {
+		active = append(active, "internal IDs")
+	} else {
+		inactive = append(inactive, "internal IDs")
+	}
+
+	switch settings.LogKind {
+	case constants.LogKindNotifications:
+		active = append(active, "push notification payloads")
+	case constants.LogKindAuto:
+		active = append(active, "push notification payloads (when detected)")
+	}
+	if len(settings.ScrubPaths) > 0 {
+		active = append(active, fmt.Sprintf("%d JSON path(s)", len(settings.ScrubPaths)))
+	}
+	if len(settings.FieldTypes) > 0 {
+		active = append(active, fmt.Sprintf("%d mapped field(s)", len(settings.FieldTypes)))
+	}
+	return active, inactive
+}
+
+// totalInputSize returns the combined size of the inputs that exist as files
+func totalInputSize(inputPaths []string) int64 {
+	var totalSize int64
+	for _, inputPath := range inputPaths {
+		if info, err := os.Stat(inputPath); err == nil {
+			totalSize += info.Size()
+		}
+	}
+	return totalSize
+}
+
+// confirmLargeInput asks before scrubbing inputs larger than the confirmation threshold in total
+// Only applies when stdin is a terminal; --yes/--no-confirm and non-interactive runs proceed without asking
+func confirmLargeInput(settings config.ResolvedSettings) error {
+	out := messageOutput(settings)
+	if settings.AssumeYes || settings.ConfirmAboveSize <= 0 || !stdinIsTerminal() {
+		return nil
+	}
+
+	totalSize := totalInputSize(settings.InputPaths)
+	if totalSize <= settings.ConfirmAboveSize {
+		return nil
+	}
+
+	minutes := math.Ceil(float64(totalSize) / constants.EstimatedBytesPerSec / 60)
+	fmt.Fprintf(out, "Input is %s, estimated ~%.0f minute(s), proceed? (y/N) ", config.FormatFileSize(totalSize), minutes)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return fmt.Errorf("operation cancelled by user")
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return fmt.Errorf("operation cancelled by user")
+	}
+	return nil
+}
+
+// stdinIsTerminal reports whether stdin is an interactive terminal rather than a pipe, file or the null device
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	if devNull, err := os.Stat(os.DevNull); err == nil && os.SameFile(info, devNull) {
+		return false
+	}
+	return true
+}
+
+// checkStdinPrompts fails when standard input is scrubbed and an existing file would need an overwrite
+// prompt, since the answer would be read from the log being scrubbed
+func checkStdinPrompts(settings config.ResolvedSettings) error {
+	if settings.DryRun || settings.OverwriteAction != constants.OverwritePrompt {
+		return nil
+	}
+	readsStdin := false
+	for _, inputPath := range settings.InputPaths {
+		readsStdin = readsStdin || scrubber.IsStdio(inputPath)
+	}
+	if !readsStdin {
+		return nil
+	}
+
+	var paths []string
+	if !settings.NoOutput {
+		paths = append(paths, settings.OutputPaths...)
+	}
+	for _, audit := range settings.AuditOutputs {
+		paths = append(paths, audit.Path)
+	}
+	for _, path := range paths {
+		if scrubber.IsStdio(path) {
+			continue
+		}
+		exists, err := scrubber.PathExists(path)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("'%s' already exists and standard input can't answer an overwrite prompt; use --overwrite with overwrite, timestamp or cancel", path)
+		}
+	}
+	return nil
+}
+
+// existingOutputs returns the files of a run that already exist: scrubbed logs, audits and every
+// report artifact, so a single confirmation never covers a file it didn't list
+func existingOutputs(settings config.ResolvedSettings) ([]string, error) {
+	var paths []string
+	if !settings.NoOutput {
+		paths = append(paths, settings.OutputPaths...)
+	}
+	for _, audit := range settings.AuditOutputs {
+		paths = append(paths, audit.Path)
+	}
+	for _, artifact := range config.ReportArtifacts(settings) {
+		if artifact.Path != "" && !scrubber.IsStdio(artifact.Path) {
+			paths = append(paths, artifact.Path)
+		}
+	}
+
+	var existing []string
+	for _, path := range paths {
+		exists, err := scrubber.PathExists(path)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			existing = append(existing, path)
+		}
+	}
+	return existing, nil
+}
+
+// confirmOverwriteSummary lists every existing file the run would replace and asks for a single confirmation
+// Only applies to the prompt overwrite action when more than one file conflicts
+func confirmOverwriteSummary(settings *config.ResolvedSettings) error {
+	out := messageOutput(*settings)
+	if settings.DryRun || settings.OverwriteAction != constants.OverwritePrompt {
+		return nil
+	}
+
+	conflicts, err := existingOutputs(*settings)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) < 2 {
+		return nil
+	}
+
+	fmt.Fprintf(out, "The following %d files will be overwritten:\n", len(conflicts))
+	for _, path := range conflicts {
+		fmt.Fprintf(out, "  %s\n", path)
+	}
+	fmt.Fprint(out, "Proceed? (y/N) ")
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return fmt.Errorf("operation cancelled by user")
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		// With a file-level cancel scope, fall back to asking about each file
+		if settings.CancelScope == constants.CancelScopeFile {
+			fmt.Fprintln(out, "Each conflicting file will be confirmed individually.")
+			return nil
+		}
+		return fmt.Errorf("operation cancelled by user")
+	}
+
+	// The user approved every conflict up front, so skip the per-file prompts
+	settings.OverwriteAction = constants.OverwriteOverwrite
+	return nil
+}
+
+// newScrubber creates a scrubber configured from the resolved settings
+// Returns the audit salt in use, which is generated when hashing originals without a configured salt
+func newScrubber(settings config.ResolvedSettings) (*scrubber.Scrubber, string, error) {
 	s := scrubber.NewScrubber(settings.ScrubLevel, settings.Verbose)
+	s.SetMessageOutput(messageOutput(settings))
+	s.SetFixedWidth(settings.FixedWidth)
+	s.SetMaskChars(settings.MaskChar, settings.MaskChars)
+	s.SetTokenPrefixes(settings.UserPrefix, settings.DomainPrefix)
+	s.SetMaxInputSize(settings.MaxInputFileSize)
+	s.SetFailOnEmpty(settings.FailOnEmpty)
+	s.SetSkipOutput(settings.NoOutput)
+	s.SetCancelScope(settings.CancelScope)
+	s.SetRenameScheme(settings.RenameScheme)
+	s.SetLogKind(settings.LogKind)
+	s.SetContainerLogs(settings.ContainerLogs)
+	s.SetPlainTextInput(settings.InputFormat == constants.InputFormatPlain)
+	if settings.InputFormat == constants.InputFormatDelimited {
+		columns, err := scrubber.ParseScrubColumns(settings.ScrubColumns)
+		if err != nil {
+			return nil, "", err
+		}
+		s.SetDelimitedInput(settings.Delimiter, columns)
+	}
+	s.SetRoleTokens(settings.RoleTokens)
+	s.SetHashMode(settings.HashMode, settings.HashSalt)
+	s.SetHashPrefix(settings.HashPrefix)
+	auditSalt, err := s.SetAuditHashOriginals(settings.AuditHashOriginals, settings.AuditSalt)
+	if err != nil {
+		return nil, "", err
+	}
+	s.SetChecksums(settings.Checksums)
+	s.SetSplitSize(settings.SplitBytes)
+	s.SetCanonicalJSON(settings.CanonicalJSON)
+	s.SetCollapseRepeats(settings.CollapseRepeats)
+	s.SetThrottle(settings.ThrottleLines, settings.ThrottleBytes)
+	s.SetIPStrategy(settings.IPStrategy)
+	s.SetNoAuditTypes(settings.NoAuditTypes)
+	if err := s.SetAuditCSVFormat(settings.AuditCSVDelimiter, settings.AuditCSVQuoteAll); err != nil {
+		return nil, "", err
+	}
+	s.SetIdentityReport(settings.IdentityReport != "")
+	s.SetTimeFormat(settings.TimeFormat)
+	s.SetJSONFailureAction(settings.JSONFailureAction)
+	s.SetStrictAllowlist(settings.StrictAllowlist)
+	s.SetInputRange(settings.InputRange)
+	s.SetPatterns(settings.Patterns)
+	s.SetDomainMap(settings.DomainMap, settings.DomainMapFile)
+	s.SetInternalDomains(settings.InternalDomains)
+	s.SetAlwaysScrub(settings.AlwaysScrub, settings.AllowShortUsernames)
+	if err := s.SetAllowlist(settings.Allowlist); err != nil {
+		return nil, "", err
+	}
+	s.SetEmailTemplate(settings.EmailTemplate)
+	s.SetLenientEmails(settings.LenientEmails)
+	if err := s.SetScrubPaths(settings.ScrubPaths); err != nil {
+		return nil, "", err
+	}
+	if err := s.SetFieldTypes(settings.FieldTypes); err != nil {
+		return nil, "", err
+	}
+	if err := s.SetPassthroughFields(settings.PassthroughFields); err != nil {
+		return nil, "", err
+	}
+	if err := s.SetRedactList(settings.RedactList); err != nil {
+		return nil, "", err
+	}
+	return s, auditSalt, nil
+}
 
-	// Process the file
-	actualOutputPath, err := s.ProcessFile(settings.InputPath, settings.OutputPath, settings.DryRun, settings.CompressOutputFile, settings.OverwriteAction)
+// runScrubbing executes the scrubbing process
+func runScrubbing(settings config.ResolvedSettings) error {
+	out := messageOutput(settings)
+	s, auditSalt, err := newScrubber(settings)
 	if err != nil {
-		return fmt.Errorf("processing file: %w", err)
+		return err
+	}
+
+	// Process each input with the same scrubber, so mappings are consistent across files
+	// and a single audit covers them all
+	// --to-temp dry runs write their output to a fresh temp directory, so nothing there can conflict
+	processDryRun, overwriteAction := settings.DryRun, settings.OverwriteAction
+	if settings.DryRun && settings.ToTemp {
+		processDryRun, overwriteAction = false, constants.OverwriteOverwrite
+	}
+
+	// The trace covers every input, including dry runs, since it is a debugging aid
+	if settings.TracePath != "" {
+		tracePath, err := s.OpenTrace(settings.TracePath, settings.OverwriteAction)
+		if err != nil {
+			return fmt.Errorf("opening trace file: %w", err)
+		}
+		settings.TracePath = tracePath
+		defer s.CloseTrace()
+	}
+
+	startTime := time.Now()
+	if settings.TimeoutDuration > 0 {
+		s.SetDeadline(startTime.Add(settings.TimeoutDuration))
+	}
+
+	// In two-pass mode every input is read once up front, so users are linked across all of them
+	// A run stopped by --timeout keeps what it has processed so far and skips the remaining inputs
+	var stopped error
+	if settings.TwoPass {
+		for _, inputPath := range settings.InputPaths {
+			if err := s.MapUsers(inputPath); errors.Is(err, scrubber.ErrDeadlineExceeded) {
+				stopped = err
+				break
+			} else if err != nil {
+				return fmt.Errorf("mapping users in '%s': %w", inputPath, err)
+			}
+		}
+	}
+
+	// Batches record each completed input, so an interrupted run can be continued with --resume
+	var state *batchState
+	if settings.Resume {
+		state, err = loadBatchState(settings.StatePath, settings.InputPaths, settings.ScrubLevel)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Resuming batch from %s (%d of %d inputs completed)\n", settings.StatePath, len(state.Completed), len(state.Inputs))
+	} else if len(settings.InputPaths) > 1 && !settings.DryRun && !settings.NoOutput && !scrubber.IsObjectStoreURI(settings.OutputPaths[0]) {
+		state = &batchState{Inputs: settings.InputPaths, Level: settings.ScrubLevel}
 	}
 
-	// Update settings with actual output path used
-	settings.OutputPath = actualOutputPath
+	var inputs []processedInput
+	for i, inputPath := range settings.InputPaths {
+		if stopped != nil {
+			break
+		}
+		if len(settings.InputPaths) > 1 {
+			fmt.Fprintf(out, "\n[%d/%d] %s\n", i+1, len(settings.InputPaths), inputPath)
+		}
+
+		// A completed input is still read, without writing output, since later tokens depend on its mappings
+		if settings.Resume {
+			if outputs, completed := state.verifiedOutputs(i); completed {
+				fmt.Fprintln(out, "Completed by the interrupted run; re-reading it to restore mappings and the audit (output kept)")
+				s.SetSkipOutput(true)
+				_, err := s.ProcessFile(inputPath, "", false, false, overwriteAction)
+				s.SetSkipOutput(false)
+				if err != nil {
+					return fmt.Errorf("re-reading file '%s': %w", inputPath, err)
+				}
+				settings.OutputPaths[i] = ""
+				if len(outputs) > 0 {
+					settings.OutputPaths[i] = outputs[0]
+				}
+				inputChecksum, _ := s.Checksums()
+				inputs = append(inputs, processedInput{path: inputPath, checksum: inputChecksum, outputParts: outputs})
+				continue
+			}
+		}
+
+		actualOutputPath, err := s.ProcessFile(inputPath, settings.OutputPaths[i], processDryRun, settings.CompressOutputFile, overwriteAction)
+		if errors.Is(err, scrubber.ErrDeadlineExceeded) {
+			stopped = err
+		} else if err != nil {
+			return fmt.Errorf("processing file '%s': %w", inputPath, err)
+		}
+
+		// Record the actual output path used
+		settings.OutputPaths[i] = actualOutputPath
+		inputChecksum, _ := s.Checksums()
+		inputs = append(inputs, processedInput{path: inputPath, checksum: inputChecksum, outputParts: s.OutputParts(), written: true, truncated: stopped != nil})
+		if state != nil && stopped == nil {
+			if err := state.complete(settings.StatePath, i, s.OutputParts()); err != nil {
+				return err
+			}
+		}
+	}
+	if len(settings.InputPaths) == 1 {
+		settings.OutputPath = settings.OutputPaths[0]
+	}
+	if settings.TracePath != "" {
+		if err := s.CloseTrace(); err != nil {
+			return fmt.Errorf("writing trace file: %w", err)
+		}
+		fmt.Fprintf(out, "Trace written to: %s (contains original values)\n", settings.TracePath)
+	}
+	if stopped != nil {
+		return finishStoppedRun(s, settings, inputs, state != nil, stopped, time.Since(startTime))
+	}
+
+	// A mostly non-JSON input is probably the wrong file, so stop before writing the audit and reports
+	if err := checkJSONFailureRate(s.Stats(), settings.MaxJSONFailureRate); err != nil {
+		return err
+	}
 
 	// Write output
-	return writeOutput(s, settings)
+	if err := writeOutput(s, settings, inputs, time.Since(startTime)); err != nil {
+		return err
+	}
+	if state != nil {
+		if err := removeBatchState(settings.StatePath); err != nil {
+			return err
+		}
+	}
+
+	// The salt is needed to verify hashed originals, so make sure it is recorded somewhere
+	if settings.AuditHashOriginals && !settings.DryRun {
+		fmt.Fprintln(out, "Audit originals are hashed (HMAC-SHA256) rather than stored in plaintext.")
+		if settings.AuditSalt == "" {
+			fmt.Fprintf(out, "Generated audit salt (keep private to verify values): %s\n", auditSalt)
+		}
+	}
+
+	// Guard automation against runs where the detectors matched nothing
+	if settings.RequireChanges && s.Stats().TotalReplacements() == 0 {
+		return errNoChanges
+	}
+	return nil
+}
+
+// finishStoppedRun ends a run stopped by --timeout. The input in progress was written up to the line
+// it reached; --timeout-output decides whether that partial output is kept along with its audit and
+// reports, or removed with everything else this run wrote. The batch state file is kept for --resume.
+func finishStoppedRun(s *scrubber.Scrubber, settings config.ResolvedSettings, inputs []processedInput, resumable bool, stopped error, duration time.Duration) error {
+	completed := 0
+	for _, input := range inputs {
+		if !input.truncated {
+			completed++
+		}
+	}
+	summary := fmt.Sprintf("run stopped by --timeout %s after %s, with %d of %d inputs completed and %d lines processed",
+		settings.TimeoutDuration, duration.Round(time.Millisecond), completed, len(settings.InputPaths), s.Stats().Lines)
+
+	if settings.TimeoutOutput == constants.TimeoutOutputDiscard {
+		for _, input := range inputs {
+			if !input.written {
+				continue
+			}
+			for _, part := range input.outputParts {
+				if err := os.Remove(part); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return fmt.Errorf("removing partial output: %w", err)
+				}
+			}
+		}
+		if resumable && !settings.Resume {
+			if err := removeBatchState(settings.StatePath); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("%s (%w); the output written by this run was removed and no audit or reports were written (--timeout-output discard)", summary, stopped)
+	}
+
+	// Two-pass runs can stop while mapping users, before any output was written
+	if len(inputs) == 0 {
+		return fmt.Errorf("%s (%w); no output was written", summary, stopped)
+	}
+	if err := writeOutput(s, settings, inputs, duration); err != nil {
+		return err
+	}
+	if resumable {
+		return fmt.Errorf("%s (%w); the output, audit and reports only cover what was read. Run again with --resume to continue the batch", summary, stopped)
+	}
+	return fmt.Errorf("%s (%w); the output, audit and reports only cover what was read", summary, stopped)
+}
+
+// checkJSONFailureRate fails the run when more than maxRate of the non-empty lines weren't valid JSON
+// The rate is only known once every input is processed, so the scrubbed output has already been written
+func checkJSONFailureRate(stats scrubber.RunStats, maxRate float64) error {
+	parsed := stats.JSONLines + stats.JSONFailures
+	if maxRate <= 0 || parsed == 0 {
+		return nil
+	}
+	rate := float64(stats.JSONFailures) / float64(parsed)
+	if rate <= maxRate {
+		return nil
+	}
+	return fmt.Errorf("%d of %d non-empty lines (%.1f%%) were not valid JSON, above --max-json-failure-rate %.1f%%; check that the input is a JSON Mattermost log. The scrubbed output was written, but no audit or report files were",
+		stats.JSONFailures, parsed, rate*100, maxRate*100)
+}
+
+// errNoChanges is returned for --require-changes runs that replaced nothing; it exits with constants.ExitNoChanges
+var errNoChanges = errors.New("no values were replaced (--require-changes); check the scrubbing level and that the input is a Mattermost log")
+
+// processedInput records what ProcessFile read and wrote for one input
+type processedInput struct {
+	path        string
+	checksum    string   // Input SHA-256 (empty without --checksums)
+	outputParts []string // Output files written (empty when skipped or not written)
+	written     bool     // Output was written by this run rather than kept from a resumed one
+	truncated   bool     // Stopped by --timeout before the end of the input
 }
 
 // writeOutput handles audit file writing and success messages
-func writeOutput(s *scrubber.Scrubber, settings config.ResolvedSettings) error {
-	var actualAuditPath string
+func writeOutput(s *scrubber.Scrubber, settings config.ResolvedSettings, inputs []processedInput, duration time.Duration) error {
+	out := messageOutput(settings)
+	var actualAuditPaths []string
 	
-	// Write audit file if not dry run
+	// Write each requested audit file if not dry run
 	if !settings.DryRun {
-		var err error
-		if settings.AuditFileType == constants.AuditTypeJSON {
-			actualAuditPath, err = s.WriteAuditFileJSON(settings.AuditPath, settings.OverwriteAction)
-			if err != nil {
-				return fmt.Errorf("writing JSON audit file: %w", err)
+		for _, audit := range settings.AuditOutputs {
+			var actualAuditPath string
+			var err error
+			switch audit.Type {
+			case constants.AuditTypeJSON:
+				actualAuditPath, err = s.WriteAuditFileJSON(audit.Path, settings.OverwriteAction)
+			case constants.AuditTypeNDJSON:
+				actualAuditPath, err = s.WriteAuditFileNDJSON(audit.Path, settings.OverwriteAction)
+			default:
+				actualAuditPath, err = s.WriteAuditFile(audit.Path, settings.OverwriteAction)
+			}
+			if errors.Is(err, scrubber.ErrArtifactSkipped) {
+				continue
 			}
-		} else {
-			actualAuditPath, err = s.WriteAuditFile(settings.AuditPath, settings.OverwriteAction)
 			if err != nil {
-				return fmt.Errorf("writing CSV audit file: %w", err)
+				return fmt.Errorf("writing %s audit file: %w", strings.ToUpper(audit.Type), err)
 			}
+			actualAuditPaths = append(actualAuditPaths, actualAuditPath)
+		}
+	}
+
+	// Report PII instances removed, distinguishing repeats from distinct values
+	s.PrintReplacementSummary()
+	if settings.SampleChanges > 0 {
+		s.PrintChangeSamples(settings.SampleChanges, settings.SampleOriginals)
+	}
+
+	// Write the ranked view of anonymized values
+	var frequencyPath string
+	if settings.FrequencyReport != "" && !settings.DryRun {
+		var err error
+		frequencyPath, err = s.WriteFrequencyReport(settings.FrequencyReport, settings.OverwriteAction)
+		if err != nil && !errors.Is(err, scrubber.ErrArtifactSkipped) {
+			return fmt.Errorf("writing frequency report: %w", err)
+		}
+	}
+
+	// Write the per-person view of the audit
+	var identityPath string
+	if settings.IdentityReport != "" && !settings.DryRun {
+		var err error
+		identityPath, err = s.WriteIdentityReport(settings.IdentityReport, settings.OverwriteAction)
+		if err != nil && !errors.Is(err, scrubber.ErrArtifactSkipped) {
+			return fmt.Errorf("writing identity report: %w", err)
+		}
+	}
+
+	// Write the human-readable summary
+	var htmlReportPath string
+	if settings.HTMLReport != "" && !settings.DryRun {
+		var err error
+		htmlReportPath, err = writeHTMLReport(s, settings, inputs, duration)
+		if err != nil && !errors.Is(err, scrubber.ErrArtifactSkipped) {
+			return fmt.Errorf("writing HTML report: %w", err)
+		}
+	}
+
+	// Write scrub statistics for monitoring
+	var metricsPath string
+	if settings.MetricsPath != "" && !settings.DryRun {
+		var err error
+		metricsPath, err = writeMetrics(s, settings, duration)
+		if err != nil && !errors.Is(err, scrubber.ErrArtifactSkipped) {
+			return fmt.Errorf("writing metrics: %w", err)
+		}
+	}
+
+	// Write machine-readable counts for reporting
+	var statsPath string
+	if settings.StatsPath != "" && !settings.DryRun {
+		var err error
+		statsPath, err = writeStats(s, settings, duration)
+		if err != nil && !errors.Is(err, scrubber.ErrArtifactSkipped) {
+			return fmt.Errorf("writing stats: %w", err)
+		}
+	}
+
+	// Write the manifest once every other artifact is complete
+	var manifestPath string
+	if settings.ManifestPath != "" && !settings.DryRun {
+		artifacts := map[string][]string{artifactAudit: actualAuditPaths}
+		for _, input := range inputs {
+			artifacts[artifactOutput] = append(artifacts[artifactOutput], input.outputParts...)
+		}
+		if frequencyPath != "" {
+			artifacts[artifactFreq] = []string{frequencyPath}
+		}
+		if identityPath != "" {
+			artifacts[artifactIdentity] = []string{identityPath}
+		}
+		if htmlReportPath != "" {
+			artifacts[artifactHTMLReport] = []string{htmlReportPath}
+		}
+		if metricsPath != "" {
+			artifacts[artifactMetrics] = []string{metricsPath}
+		}
+		if statsPath != "" && !scrubber.IsStdio(statsPath) {
+			artifacts[artifactStats] = []string{statsPath}
+		}
+
+		var err error
+		manifestPath, err = writeManifest(s, settings, inputs, artifacts)
+		if err != nil && !errors.Is(err, scrubber.ErrArtifactSkipped) {
+			return fmt.Errorf("writing manifest: %w", err)
 		}
 	}
 
 	// Show completion message
+	outcome := "completed successfully"
+	if len(inputs) > 0 && inputs[len(inputs)-1].truncated {
+		outcome = "stopped by --timeout"
+	}
 	if settings.DryRun {
-		fmt.Println("Dry run completed successfully. No files were modified.")
+		if !settings.ToTemp {
+			fmt.Fprintf(out, "Dry run %s. No files were modified.\n", outcome)
+		} else {
+			fmt.Fprintf(out, "Dry run %s. The real output and audit files were not modified.\n", outcome)
+			fmt.Fprintln(out, "Dry-run output for inspection (temporary, not deleted automatically):")
+			for _, input := range inputs {
+				for _, part := range input.outputParts {
+					fmt.Fprintf(out, "  %s\n", part)
+				}
+			}
+		}
 	} else {
-		fmt.Printf("Log scrubbing completed successfully. Output written to: %s\n", settings.OutputPath)
-		fmt.Printf("Audit log written to: %s\n", actualAuditPath)
+		if settings.NoOutput {
+			fmt.Fprintf(out, "Log scrubbing %s. No scrubbed log was written (--no-output).\n", outcome)
+		} else if len(inputs) > 1 {
+			fmt.Fprintf(out, "Log scrubbing %s for %d input files:\n", outcome, len(inputs))
+			for _, input := range inputs {
+				if len(input.outputParts) == 0 {
+					fmt.Fprintf(out, "  %s -> (skipped due to a file conflict)\n", input.path)
+				}
+				for _, part := range input.outputParts {
+					fmt.Fprintf(out, "  %s -> %s\n", input.path, part)
+				}
+			}
+		} else if settings.OutputPath == "" {
+			fmt.Fprintf(out, "Log scrubbing %s. The scrubbed log was skipped due to a file conflict.\n", outcome)
+		} else if parts := inputs[0].outputParts; len(parts) > 1 {
+			fmt.Fprintf(out, "Log scrubbing %s. Output written to %d parts:\n", outcome, len(parts))
+			for _, part := range parts {
+				fmt.Fprintf(out, "  %s\n", part)
+			}
+		} else {
+			fmt.Fprintf(out, "Log scrubbing %s. Output written to: %s\n", outcome, stdioName(settings.OutputPath, "output"))
+		}
+		for _, actualAuditPath := range actualAuditPaths {
+			fmt.Fprintf(out, "Audit log written to: %s\n", actualAuditPath)
+		}
+		if frequencyPath != "" {
+			fmt.Fprintf(out, "Frequency report written to: %s\n", frequencyPath)
+		}
+		if identityPath != "" {
+			fmt.Fprintf(out, "Identity report written to: %s (contains original values; do not share)\n", identityPath)
+		}
+		if htmlReportPath != "" {
+			fmt.Fprintf(out, "HTML report written to: %s\n", htmlReportPath)
+		}
+		if metricsPath != "" {
+			fmt.Fprintf(out, "Metrics written to: %s\n", metricsPath)
+		}
+		if statsPath != "" {
+			fmt.Fprintf(out, "Stats written to: %s\n", stdioName(statsPath, "output"))
+		}
+		if manifestPath != "" {
+			fmt.Fprintf(out, "Manifest written to: %s\n", manifestPath)
+		}
+		if legend := s.RoleTokenLegend(); legend != nil {
+			fmt.Fprintf(out, "Role tokens: %s\n", strings.Join(legend, ", "))
+		}
 	}
 
 	return nil
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/config"
+	"mattermost-log-scrubber/constants"
+	"mattermost-log-scrubber/scrubber"
+)
+
+func TestExistingOutputsListsEveryArtifact(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	settings := config.ResolvedSettings{
+		OutputPaths:     []string{path("out.log")},
+		AuditOutputs:    []config.AuditOutput{{Type: constants.AuditTypeCSV, Path: path("audit.csv")}},
+		ManifestPath:    path("manifest.json"),
+		MetricsPath:     path("m.prom"),
+		StatsPath:       path("stats.json"),
+		TracePath:       path("trace.log"),
+		FrequencyReport: path("frequency.csv"),
+		IdentityReport:  path("identities.json"),
+		HTMLReport:      path("report.html"),
+	}
+
+	// Every file but the trace exists
+	var want []string
+	for _, name := range []string{"out.log", "audit.csv", "manifest.json", "m.prom", "stats.json", "frequency.csv", "identities.json", "report.html"} {
+		if err := os.WriteFile(path(name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, path(name))
+	}
+
+	got, err := existingOutputs(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("existing outputs = %v, want %v", got, want)
+	}
+
+	// A scrubbed log that isn't written can't conflict, and standard output is never a file
+	settings.NoOutput = true
+	settings.StatsPath = constants.StdioPath
+	got, err = existingOutputs(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want)-2 {
+		t.Errorf("existing outputs = %v, want the scrubbed log and stats left out", got)
+	}
+}
+
+func TestReportUnscrubWarnsAboutAmbiguousValues(t *testing.T) {
+	var out strings.Builder
+	reportUnscrub(&out, scrubber.UnscrubResult{
+		Lines:        2,
+		Restored:     2,
+		Entries:      2,
+		Ambiguous:    map[string]int{"user2": 2, "***.***.***.***": 3},
+		HashedValues: 1,
+	}, "restored.log")
+
+	want := `Restored 2 values in 2 lines from 2 audit mappings
+Warning: 1 audit entries record hashed originals and were left scrubbed
+Warning: 2 new values map to several originals and were left scrubbed:
+  ***.***.***.*** (3 originals)
+  user2 (2 originals)
+Restored log written to: restored.log (contains original values; do not share)
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
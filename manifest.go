@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"mattermost-log-scrubber/config"
+	"mattermost-log-scrubber/constants"
+	"mattermost-log-scrubber/scrubber"
+)
+
+// Artifact types recorded in the manifest
+const (
+	artifactOutput     = "output"
+	artifactAudit      = "audit"
+	artifactMetrics    = "metrics"
+	artifactFreq       = "frequency_report"
+	artifactIdentity   = "identity_report"
+	artifactHTMLReport = "html_report"
+	artifactStats      = "stats"
+)
+
+// Manifest lists every artifact a run wrote so automation can collect and verify them
+type Manifest struct {
+	Version      string             `json:"Version"`
+	GeneratedAt  string             `json:"GeneratedAt"`
+	Input        string             `json:"Input"`
+	InputSHA256  string             `json:"InputSHA256,omitempty"`
+	Inputs       []ManifestInput    `json:"Inputs,omitempty"`
+	SettingsHash string             `json:"SettingsHash"`
+	Artifacts    []ManifestArtifact `json:"Artifacts"`
+}
+
+// ManifestInput describes one of several input files; Input and InputSHA256 cover the first
+type ManifestInput struct {
+	Path   string `json:"Path"`
+	SHA256 string `json:"SHA256,omitempty"`
+}
+
+// ManifestArtifact describes a single file written by the run
+type ManifestArtifact struct {
+	Type   string `json:"Type"`
+	Path   string `json:"Path"`
+	Size   int64  `json:"Size"`
+	SHA256 string `json:"SHA256"`
+}
+
+// writeManifest records the final path, size and checksum of each artifact
+// Returns the actual manifest path used (which may differ if renamed)
+func writeManifest(s *scrubber.Scrubber, settings config.ResolvedSettings, inputs []processedInput, artifacts map[string][]string) (string, error) {
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return "", fmt.Errorf("hashing settings: %w", err)
+	}
+	settingsHash := sha256.Sum256(settingsJSON)
+
+	// Input checksums are only known when --checksums hashed them during processing
+	manifest := Manifest{
+		Version:      constants.Version,
+		GeneratedAt:  time.Now().UTC().Format(time.RFC3339),
+		Input:        inputs[0].path,
+		SettingsHash: hex.EncodeToString(settingsHash[:]),
+		InputSHA256:  inputs[0].checksum,
+		Artifacts:    []ManifestArtifact{},
+	}
+	if len(inputs) > 1 {
+		for _, input := range inputs {
+			manifest.Inputs = append(manifest.Inputs, ManifestInput{Path: input.path, SHA256: input.checksum})
+		}
+	}
+
+	for _, artifactType := range []string{artifactOutput, artifactAudit, artifactFreq, artifactIdentity, artifactHTMLReport, artifactMetrics, artifactStats} {
+		for _, path := range artifacts[artifactType] {
+			size, checksum, err := fileChecksum(path)
+			if err != nil {
+				return "", fmt.Errorf("reading artifact '%s': %w", path, err)
+			}
+			manifest.Artifacts = append(manifest.Artifacts, ManifestArtifact{
+				Type:   artifactType,
+				Path:   path,
+				Size:   size,
+				SHA256: checksum,
+			})
+		}
+	}
+
+	manifestPath, err := s.ResolveArtifactPath(settings.ManifestPath, settings.OverwriteAction, "Manifest")
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Create(manifestPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create manifest file: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return "", fmt.Errorf("failed to write manifest file: %w", err)
+	}
+
+	return manifestPath, nil
+}
+
+// fileChecksum returns the size and hex SHA-256 of a file
+func fileChecksum(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mattermost-log-scrubber/config"
+	"mattermost-log-scrubber/scrubber"
+)
+
+// metricsPrefix namespaces every metric written by --metrics
+const metricsPrefix = "mattermost_log_scrubber_"
+
+// metricsWriter formats metrics in the Prometheus text exposition format
+type metricsWriter struct {
+	b strings.Builder
+}
+
+// gauge writes a single unlabelled gauge with its HELP and TYPE lines
+func (m *metricsWriter) gauge(name, help string, value float64) {
+	fmt.Fprintf(&m.b, "# HELP %s%s %s\n", metricsPrefix, name, help)
+	fmt.Fprintf(&m.b, "# TYPE %s%s gauge\n", metricsPrefix, name)
+	fmt.Fprintf(&m.b, "%s%s %s\n", metricsPrefix, name, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// gaugeByType writes a gauge with one sample per scrub type, sorted for stable output
+func (m *metricsWriter) gaugeByType(name, help string, values map[string]int) {
+	fmt.Fprintf(&m.b, "# HELP %s%s %s\n", metricsPrefix, name, help)
+	fmt.Fprintf(&m.b, "# TYPE %s%s gauge\n", metricsPrefix, name)
+	types := make([]string, 0, len(values))
+	for valueType := range values {
+		types = append(types, valueType)
+	}
+	sort.Strings(types)
+	for _, valueType := range types {
+		fmt.Fprintf(&m.b, "%s%s{type=%q} %d\n", metricsPrefix, name, valueType, values[valueType])
+	}
+}
+
+// writeMetrics writes the run's statistics as a Prometheus textfile, for node_exporter's textfile collector
+// Values describe the last completed run, so they are gauges; a failed run writes nothing and leaves
+// last_run_timestamp_seconds stale
+// Returns the actual metrics path used (which may differ if renamed)
+func writeMetrics(s *scrubber.Scrubber, settings config.ResolvedSettings, duration time.Duration) (string, error) {
+	stats := s.Stats()
+
+	var m metricsWriter
+	m.gauge("files_processed", "Input files scrubbed in the last run.", float64(stats.Files))
+	m.gauge("lines_read", "Lines read from the input, including empty lines.", float64(stats.Lines))
+	m.gauge("lines_processed", "Lines scrubbed and written to the output.", float64(stats.LinesScrubbed))
+	m.gauge("lines_empty", "Empty lines skipped.", float64(stats.LinesEmpty))
+	m.gauge("lines_dropped", "Lines that were not valid JSON and were dropped.", float64(stats.LinesDropped))
+	m.gauge("lines_failed", "Lines that failed processing and were written unchanged.", float64(stats.LinesFailed))
+	m.gauge("lines_collapsed", "Repeated lines written as a repeat count instead of in full.", float64(stats.LinesCollapsed))
+	m.gauge("json_lines", "Lines parsed as JSON.", float64(stats.JSONLines))
+	m.gauge("json_failures", "Lines that were not valid JSON.", float64(stats.JSONFailures))
+	m.gauge("timestamps_normalized", "Timestamp fields rewritten by --normalize-time.", float64(stats.TimestampsNormalized))
+	m.gaugeByType("replacements", "Values replaced, by scrub type.", stats.Replacements)
+	m.gaugeByType("unique_values", "Distinct original values replaced, by scrub type.", stats.UniqueValues)
+	m.gauge("scrub_level", "Scrubbing level used.", float64(settings.ScrubLevel))
+	m.gauge("duration_seconds", "Wall-clock duration of the last run.", duration.Seconds())
+	m.gauge("last_run_timestamp_seconds", "Unix time the last run completed.", float64(time.Now().Unix()))
+
+	metricsPath, err := s.ResolveArtifactPath(settings.MetricsPath, settings.OverwriteAction, "Metrics file")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(metricsPath, []byte(m.b.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	return metricsPath, nil
+}
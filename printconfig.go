@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"mattermost-log-scrubber/config"
+)
+
+// printConfig prints every config-file setting with its effective value and where that value came
+// from (cli, profile, config, rules or default), then exits without scrubbing
+// Settings aren't validated, so a config that fails validation can still be inspected
+func printConfig(flags config.CLIFlags) error {
+	configFile, configPath, err := loadConfigFile(flags)
+	if err != nil {
+		return err
+	}
+	profiledConfig, profileSettings, err := loadProfile(flags, configFile)
+	if err != nil {
+		return err
+	}
+	mergedConfig, rules, rulesPath, err := loadRulesFile(flags, profiledConfig)
+	if err != nil {
+		return err
+	}
+	settings, sources := config.ResolveSettings(flags, mergedConfig)
+	sources.AttributeProfile(profileSettings)
+	if rules != nil {
+		sources.AttributeRules(rules)
+	}
+
+	if configFile != nil {
+		fmt.Printf("Config file: %s\n", configPath)
+	} else {
+		fmt.Printf("Config file: none (%s not found)\n", configPath)
+	}
+	if flags.Profile != "" {
+		fmt.Printf("Profile: %s\n", flags.Profile)
+	}
+	if rules != nil {
+		fmt.Printf("Rules file: %s\n", rulesPath)
+	}
+
+	effective, err := effectiveSettings(settings)
+	if err != nil {
+		return err
+	}
+	for _, setting := range effective {
+		fmt.Printf("  %-38s %-8s %s\n", setting.Name, sources[setting.Name], setting.Value)
+	}
+	return nil
+}
+
+// effectiveSetting is one config-file setting and its effective value as JSON
+type effectiveSetting struct {
+	Name  string // Config path, e.g. "FileSettings.OverwriteAction"
+	Value string
+}
+
+// effectiveSettings lists every config-file setting of the resolved settings, in config file order
+func effectiveSettings(settings config.ResolvedSettings) ([]effectiveSetting, error) {
+	var list []effectiveSetting
+	effective := reflect.ValueOf(config.EffectiveConfig(settings))
+	for i := 0; i < effective.NumField(); i++ {
+		section := effective.Field(i)
+		sectionName := effective.Type().Field(i).Name
+		// Profiles are resolved into the sections rather than being settings themselves
+		if section.Kind() != reflect.Struct {
+			continue
+		}
+		for j := 0; j < section.NumField(); j++ {
+			setting := sectionName + "." + section.Type().Field(j).Name
+			value, err := json.Marshal(section.Field(j).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("formatting %s: %w", setting, err)
+			}
+			list = append(list, effectiveSetting{Name: setting, Value: string(value)})
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// batchState is the progress file of a run with several inputs, rewritten after each input completes
+// and removed when the whole batch succeeds, so it only remains after an interrupted run
+type batchState struct {
+	Inputs    []string         `json:"Inputs"` // Every input of the batch, in processing order
+	Level     int              `json:"Level"`
+	Completed []completedInput `json:"Completed"`
+}
+
+// completedInput is an input whose scrubbed output was fully written
+type completedInput struct {
+	Input   string        `json:"Input"`
+	Outputs []stateOutput `json:"Outputs"`
+}
+
+// stateOutput is one output file of a completed input, with its checksum at completion
+type stateOutput struct {
+	Path   string `json:"Path"`
+	SHA256 string `json:"SHA256"`
+}
+
+// loadBatchState reads the state file left by an interrupted batch for --resume
+// It must describe the same inputs in the same order and the same level, as tokens depend on both
+func loadBatchState(path string, inputs []string, level int) (*batchState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no batch state file at '%s' to resume from; it is removed when a batch completes", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading batch state file: %w", err)
+	}
+
+	var state batchState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing batch state file '%s': %w", path, err)
+	}
+	if !reflect.DeepEqual(state.Inputs, inputs) || state.Level != level {
+		return nil, fmt.Errorf("batch state file '%s' is for a different batch; resume with the same inputs, in the same order, and the same level", path)
+	}
+	return &state, nil
+}
+
+// verifiedOutputs returns the output files of the input at index i if the interrupted run completed
+// it and every output is still as it was written
+func (bs *batchState) verifiedOutputs(i int) ([]string, bool) {
+	if i >= len(bs.Completed) || bs.Completed[i].Input != bs.Inputs[i] {
+		return nil, false
+	}
+	var paths []string
+	for _, output := range bs.Completed[i].Outputs {
+		if _, checksum, err := fileChecksum(output.Path); err != nil || checksum != output.SHA256 {
+			return nil, false
+		}
+		paths = append(paths, output.Path)
+	}
+	return paths, true
+}
+
+// complete records that an input's output was fully written and saves the state file
+// Inputs are processed in order, so completed inputs are always the first ones of the batch
+func (bs *batchState) complete(path string, i int, outputs []string) error {
+	completed := completedInput{Input: bs.Inputs[i]}
+	for _, output := range outputs {
+		_, checksum, err := fileChecksum(output)
+		if err != nil {
+			return fmt.Errorf("hashing output '%s': %w", output, err)
+		}
+		completed.Outputs = append(completed.Outputs, stateOutput{Path: output, SHA256: checksum})
+	}
+	bs.Completed = append(bs.Completed[:i], completed)
+	return bs.save(path)
+}
+
+// save writes the state file through a temporary file, so an interruption never leaves it half written
+func (bs *batchState) save(path string) error {
+	data, err := json.MarshalIndent(bs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding batch state: %w", err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing batch state file: %w", err)
+	}
+	if _, err := temp.Write(append(data, '\n')); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return fmt.Errorf("writing batch state file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("writing batch state file: %w", err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("writing batch state file: %w", err)
+	}
+	return nil
+}
+
+// removeBatchState deletes the state file once a batch has completed
+func removeBatchState(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing batch state file: %w", err)
+	}
+	return nil
+}
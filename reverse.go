@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mattermost-log-scrubber/config"
+	"mattermost-log-scrubber/constants"
+	"mattermost-log-scrubber/scrubber"
+)
+
+// runReverse restores the original values of a scrubbed log from the JSON audit of the run that
+// scrubbed it, for de-anonymizing a specific incident after the fact. Ambiguous new values are
+// reported and left scrubbed.
+func runReverse(flags config.CLIFlags) error {
+	inputs := append(append([]string(nil), flags.InputFile...), flags.Input...)
+	if len(inputs) != 1 {
+		return fmt.Errorf("--reverse restores one scrubbed log: give it with -i")
+	}
+	inputPath := inputs[0]
+	if scrubber.IsStdio(inputPath) || scrubber.IsUnixSocketInput(inputPath) {
+		return fmt.Errorf("--reverse reads a scrubbed log file, not '%s'", inputPath)
+	}
+
+	auditPath := flags.AuditFile
+	if auditPath == "" {
+		auditPath = flags.AuditLong
+	}
+	if auditPath == "" {
+		return fmt.Errorf("--reverse needs the JSON audit of the scrubbing run: give it with -a")
+	}
+
+	outputPath := flags.OutputFile
+	if outputPath == "" {
+		outputPath = flags.Output
+	}
+	if outputPath == "" {
+		outputPath = restoredPath(inputPath)
+	}
+	// Messages go to stderr when the restored log is written to standard output
+	out := io.Writer(os.Stdout)
+	if scrubber.IsStdio(outputPath) {
+		out = os.Stderr
+	}
+	if !scrubber.IsStdio(outputPath) && flags.OverwriteAction != constants.OverwriteOverwrite {
+		exists, err := scrubber.PathExists(outputPath)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("output file '%s' already exists; use --overwrite overwrite to replace it", outputPath)
+		}
+	}
+
+	result, err := scrubber.Unscrub(auditPath, inputPath, outputPath)
+	if err != nil {
+		return err
+	}
+
+	reportUnscrub(out, result, outputPath)
+	return nil
+}
+
+// reportUnscrub prints what --reverse restored, warning about the values it had to leave scrubbed
+func reportUnscrub(out io.Writer, result scrubber.UnscrubResult, outputPath string) {
+	fmt.Fprintf(out, "Restored %d values in %d lines from %d audit mappings\n", result.Restored, result.Lines, result.Entries)
+	if result.HashedValues > 0 {
+		fmt.Fprintf(out, "Warning: %d audit entries record hashed originals and were left scrubbed\n", result.HashedValues)
+	}
+	if len(result.Ambiguous) > 0 {
+		ambiguous := make([]string, 0, len(result.Ambiguous))
+		for newValue := range result.Ambiguous {
+			ambiguous = append(ambiguous, newValue)
+		}
+		sort.Strings(ambiguous)
+		fmt.Fprintf(out, "Warning: %d new values map to several originals and were left scrubbed:\n", len(ambiguous))
+		for _, newValue := range ambiguous {
+			fmt.Fprintf(out, "  %s (%d originals)\n", newValue, result.Ambiguous[newValue])
+		}
+	}
+	fmt.Fprintf(out, "Restored log written to: %s (contains original values; do not share)\n", stdioName(outputPath, "output"))
+}
+
+// restoredPath returns the default output of --reverse: app_scrubbed.log gives app_restored.log
+func restoredPath(inputPath string) string {
+	inputPath = scrubber.TrimCompressedExt(inputPath)
+	ext := filepath.Ext(inputPath)
+	base := strings.TrimSuffix(strings.TrimSuffix(inputPath, ext), constants.ScrubSuffix)
+	return base + constants.RestoredSuffix + ext
+}
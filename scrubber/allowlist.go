@@ -0,0 +1,70 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultAllowlist keeps loopback and unspecified IP addresses, which identify no one and show how a
+// service was bound or reached. It is used when ScrubSettings.Allowlist isn't set.
+var DefaultAllowlist = []string{`/127\.\d{1,3}\.\d{1,3}\.\d{1,3}/`, "0.0.0.0"}
+
+// compileAllowlistEntry compiles a /regex/ allowlist entry to match whole values only, or returns nil
+// for a literal
+func compileAllowlistEntry(entry string) (*regexp.Regexp, error) {
+	if len(entry) <= 2 || !strings.HasPrefix(entry, "/") || !strings.HasSuffix(entry, "/") {
+		return nil, nil
+	}
+	re, err := regexp.Compile(`^(?:` + entry[1:len(entry)-1] + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowlist regex %s: %w", entry, err)
+	}
+	return re, nil
+}
+
+// ValidateAllowlist checks that every /regex/ allowlist entry compiles and no entry is empty
+func ValidateAllowlist(entries []string) error {
+	for i, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			return fmt.Errorf("allowlist entry #%d is empty", i+1)
+		}
+		if _, err := compileAllowlistEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetAllowlist sets values that the email, username and IP scrubbers, and ScrubSettings.AlwaysScrub,
+// leave unchanged and out of the audit, such as the system account or 127.0.0.1. An entry is a literal,
+// compared case-insensitively with the whole value, or a /regex/ that must match the whole value.
+func (s *Scrubber) SetAllowlist(entries []string) error {
+	s.allowlistValues = make(map[string]bool)
+	s.allowlistPatterns = nil
+	for _, entry := range entries {
+		re, err := compileAllowlistEntry(entry)
+		if err != nil {
+			return err
+		}
+		if re != nil {
+			s.allowlistPatterns = append(s.allowlistPatterns, re)
+			continue
+		}
+		s.allowlistValues[s.normalizeKey(strings.TrimSpace(entry))] = true
+	}
+	return nil
+}
+
+// isAllowlisted reports whether a whole value is on the allowlist
+func (s *Scrubber) isAllowlisted(value string) bool {
+	if s.allowlistValues[s.normalizeKey(value)] {
+		return true
+	}
+	for _, re := range s.allowlistPatterns {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package scrubber
+
+import (
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+func TestAllowlistPrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		want      string
+		wantTypes map[string]int // Audit entries by type
+	}{
+		{
+			name:      "allowlist wins over always-scrub",
+			line:      `{"user":"system","msg":"system restarted the job"}`,
+			want:      `{"user":"system","msg":"system restarted the job"}`,
+			wantTypes: map[string]int{},
+		},
+		{
+			name:      "always-scrub wins over heuristics that miss free text",
+			line:      `{"msg":"jdoe restarted the job"}`,
+			want:      `{"msg":"user1 restarted the job"}`,
+			wantTypes: map[string]int{constants.TypeUsername: 1},
+		},
+		{
+			name:      "heuristics skip allowlisted values but scrub the rest",
+			line:      `{"msg":"from noreply@corp.com to alice@corp.com"}`,
+			want:      `{"msg":"from noreply@corp.com to user1@domain1"}`,
+			wantTypes: map[string]int{constants.TypeEmail: 1},
+		},
+		{
+			name:      "allowlisted user pair creates no mapping",
+			line:      `{"user":"system","email":"noreply@corp.com"}`,
+			want:      `{"user":"system","email":"noreply@corp.com"}`,
+			wantTypes: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScrubber(1, false)
+			s.SetAlwaysScrub([]string{"system", "jdoe"}, true)
+			if err := s.SetAllowlist([]string{"System", "/noreply@.*/"}); err != nil {
+				t.Fatalf("SetAllowlist: %v", err)
+			}
+
+			got, err := s.ScrubLine(tt.line, "test.log")
+			if err != nil {
+				t.Fatalf("ScrubLine: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			types := map[string]int{}
+			for _, entry := range s.AuditEntries() {
+				types[entry.Type]++
+			}
+			if len(types) != len(tt.wantTypes) {
+				t.Errorf("audit types %v, want %v", types, tt.wantTypes)
+			}
+			for valueType, count := range tt.wantTypes {
+				if types[valueType] != count {
+					t.Errorf("%d %s audit entries, want %d", types[valueType], valueType, count)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultAllowlistKeepsLoopback(t *testing.T) {
+	tests := []struct {
+		ip   string
+		keep bool
+	}{
+		{"127.0.0.1", true},
+		{"127.10.20.30", true},
+		{"0.0.0.0", true},
+		{"10.0.0.1", false},
+		{"128.0.0.1", false},
+		{"1127.0.0.1", false},
+	}
+
+	s := NewScrubber(2, false)
+	if err := s.SetAllowlist(DefaultAllowlist); err != nil {
+		t.Fatalf("SetAllowlist: %v", err)
+	}
+	for _, tt := range tests {
+		got := s.scrubIPValue(tt.ip, "test.log")
+		if kept := got == tt.ip; kept != tt.keep {
+			t.Errorf("%s: got %s, keep = %v, want %v", tt.ip, got, kept, tt.keep)
+		}
+	}
+}
+
+func TestValidateAllowlist(t *testing.T) {
+	tests := []struct {
+		entries []string
+		wantErr bool
+	}{
+		{DefaultAllowlist, false},
+		{[]string{"system", "/bot-[a-z]+/"}, false},
+		{[]string{" "}, true},
+		{[]string{"/[unclosed/"}, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateAllowlist(tt.entries); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateAllowlist(%q) error = %v, want error %v", tt.entries, err, tt.wantErr)
+		}
+	}
+}
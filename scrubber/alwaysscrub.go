@@ -0,0 +1,150 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateAlwaysScrub checks the values of ScrubSettings.AlwaysScrub. The values are matched after the
+// typed scrubbers, so any that their replacements would contain are rejected: a value that reads like
+// a mapped token (user3, domain1 or user_a1b2c3d4 with the configured prefixes), a token prefix itself,
+// or a word of the email template outside its placeholders, such as anon in anon.{n}@{domain}.
+func ValidateAlwaysScrub(values []string, userPrefix, domainPrefix, emailTemplate string) error {
+	tokenRegex := regexp.MustCompile(`(?i)^(?:` + regexp.QuoteMeta(userPrefix) + `|` + regexp.QuoteMeta(domainPrefix) + `)(?:\d+|_[0-9a-f]+)$`)
+	templateText := strings.NewReplacer(emailPlaceholderN, " ", emailPlaceholderToken, " ", emailPlaceholderDomain, " ").Replace(emailTemplate)
+	templateWords := strings.FieldsFunc(strings.ToLower(templateText), func(r rune) bool { return !isRedactWordRune(r) })
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return fmt.Errorf("always-scrub value #%d is empty", i+1)
+		}
+		if tokenRegex.MatchString(value) {
+			return fmt.Errorf("always-scrub value '%s' looks like a mapped token", value)
+		}
+		if strings.EqualFold(value, userPrefix) || strings.EqualFold(value, domainPrefix) {
+			return fmt.Errorf("always-scrub value '%s' is the prefix of mapped tokens", value)
+		}
+		for _, word := range templateWords {
+			if strings.EqualFold(value, word) {
+				return fmt.Errorf("always-scrub value '%s' is part of the email template '%s', so it would be found again in anonymized emails", value, emailTemplate)
+			}
+		}
+	}
+	return nil
+}
+
+// minAlwaysScrubLength is the length below which an always-scrub value, such as "a" or "jd", is skipped
+// unless short values are allowed, since it matches as a whole word in unrelated text
+const minAlwaysScrubLength = 3
+
+// genericUsernames are common words and shared account names that, as always-scrub values, would
+// replace the word wherever a log uses it rather than only where it names the user
+var genericUsernames = map[string]bool{
+	"admin": true, "administrator": true, "bot": true, "default": true, "demo": true, "dev": true,
+	"guest": true, "info": true, "mattermost": true, "root": true, "service": true, "support": true,
+	"system": true, "sysadmin": true, "test": true, "user": true,
+}
+
+// OverMatchingValues returns the always-scrub values that are too short or too generic to be matched
+// as whole words without also replacing unrelated text, e.g. "a" or "test", in the order given
+func OverMatchingValues(values []string) []string {
+	var risky []string
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" && (utf8.RuneCountInString(value) < minAlwaysScrubLength || genericUsernames[strings.ToLower(value)]) {
+			risky = append(risky, value)
+		}
+	}
+	return risky
+}
+
+// SetAlwaysScrub sets literal values, such as known usernames, that are scrubbed wherever they appear
+// as a whole word, including free text the username heuristics don't look at. They are compiled into
+// one case-insensitive alternation, longest first so a value wins over a shorter value it contains.
+// Values OverMatchingValues reports are skipped unless allowShort is set, and returned so they can be
+// reported.
+func (s *Scrubber) SetAlwaysScrub(values []string, allowShort bool) []string {
+	s.alwaysScrubRegex = nil
+	var skipped []string
+	if !allowShort {
+		skipped = OverMatchingValues(values)
+	}
+	skip := make(map[string]bool, len(skipped))
+	for _, value := range skipped {
+		skip[value] = true
+	}
+	literals := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" && !skip[value] {
+			literals = append(literals, value)
+		}
+	}
+	if len(literals) == 0 {
+		return skipped
+	}
+
+	sort.SliceStable(literals, func(i, j int) bool { return len(literals[i]) > len(literals[j]) })
+	alternatives := make([]string, len(literals))
+	for i, literal := range literals {
+		alternatives[i] = literalRedactPattern(literal)
+	}
+	s.alwaysScrubRegex = regexp.MustCompile(`(?i)(?:` + strings.Join(alternatives, "|") + `)`)
+	return skipped
+}
+
+// generatedPlaceholderRegex finds the placeholders earlier stages insert that hold whole words, e.g.
+// [redacted], [ssn-redacted], [message redacted, 12 chars] and +1-XXX-XXX-0001
+var generatedPlaceholderRegex = regexp.MustCompile(`\[[^\[\]]*redacted[^\[\]]*\]|\+\d{1,3}-XXX-XXX-\d{4}`)
+
+// scrubAlwaysScrub maps always-scrub values to user tokens like any username (all levels). It runs after
+// the typed scrubbers, so a value they already mapped, e.g. in a "user" field, keeps its single token,
+// and case variants share a token through the user mappings. Matches inside the placeholders those
+// scrubbers inserted are skipped, so a value like "redacted" never rewrites [redacted].
+func (s *Scrubber) scrubAlwaysScrub(text, source string) string {
+	if s.alwaysScrubRegex == nil {
+		return text
+	}
+	matches := s.alwaysScrubRegex.FindAllStringIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+	placeholders := generatedPlaceholderRegex.FindAllStringIndex(text, -1)
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range matches {
+		match := text[loc[0]:loc[1]]
+		// Placeholders of protected values are never matched into
+		if strings.Contains(match, preservePlaceholderMarker) || insideSpan(loc, placeholders) {
+			continue
+		}
+		replacement := s.scrubUsernameValue(match, source)
+		if replacement == match {
+			continue
+		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(replacement)
+		last = loc[1]
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// insideSpan reports whether the match at loc overlaps one of spans, which are in text order
+func insideSpan(loc []int, spans [][]int) bool {
+	for _, span := range spans {
+		if span[0] >= loc[1] {
+			return false
+		}
+		if span[1] > loc[0] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package scrubber
+
+import (
+	"reflect"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+func TestOverMatchingValues(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+	}{
+		{[]string{"jdoe", "falcon.ops", "bob"}, nil},
+		{[]string{"a", "jd", " x "}, []string{"a", "jd", "x"}},
+		{[]string{"Test", "admin", "jdoe", "ROOT"}, []string{"Test", "admin", "ROOT"}},
+		{[]string{"zoë"}, nil}, // Three characters, though four bytes
+	}
+	for _, tt := range tests {
+		if got := OverMatchingValues(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("OverMatchingValues(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestAlwaysScrubSkipsShortAndGenericValues(t *testing.T) {
+	const line = `{"msg":"a test build by jdoe passed"}`
+	tests := []struct {
+		allowShort  bool
+		wantSkipped []string
+		want        string
+	}{
+		{false, []string{"a", "test"}, `{"msg":"a test build by user1 passed"}`},
+		{true, nil, `{"msg":"user1 user2 build by user3 passed"}`},
+	}
+	for _, tt := range tests {
+		s := NewScrubber(constants.ScrubLevelHigh, false)
+		skipped := s.SetAlwaysScrub([]string{"a", "test", "jdoe"}, tt.allowShort)
+		if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+			t.Errorf("allow short %t: skipped %q, want %q", tt.allowShort, skipped, tt.wantSkipped)
+		}
+		got, err := s.ScrubLine(line, "test.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("allow short %t:\n got  %s\n want %s", tt.allowShort, got, tt.want)
+		}
+	}
+}
+
+func TestAlwaysScrubLeavesPlaceholdersAlone(t *testing.T) {
+	s := NewScrubber(constants.ScrubLevelMedium, false)
+	if err := s.SetRedactList([]string{"falcon"}); err != nil {
+		t.Fatal(err)
+	}
+	s.SetAlwaysScrub([]string{"redacted", "XXX", "jdoe"}, false)
+	got, err := s.ScrubLine(`{"msg":"falcon redacted by jdoe, call 555-123-4567 or XXX"}`, "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"msg":"[redacted] user1 by user2, call +1-XXX-XXX-0001 or user3"}`; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestValidateAlwaysScrub(t *testing.T) {
+	tests := []struct {
+		values  []string
+		wantErr bool
+	}{
+		{[]string{"jdoe", "falcon.ops"}, false},
+		{[]string{"jdoe", " "}, true},
+		{[]string{"user3"}, true},
+		{[]string{"Domain1"}, true},
+		{[]string{"user"}, true},
+		{[]string{"DOMAIN"}, true},
+		{[]string{"anon"}, true}, // A word of the email template below
+		{[]string{"anonymous"}, false},
+	}
+	for _, tt := range tests {
+		err := ValidateAlwaysScrub(tt.values, "user", "domain", "anon.{n}@{domain}")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateAlwaysScrub(%q) = %v, want error %t", tt.values, err, tt.wantErr)
+		}
+	}
+}
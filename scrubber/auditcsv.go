@@ -0,0 +1,100 @@
+package scrubber
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseCSVDelimiter returns the delimiter rune, accepting \t for a tab since it is awkward to pass on
+// the command line. Empty means a comma.
+func parseCSVDelimiter(delimiter string) rune {
+	switch delimiter {
+	case "":
+		return ','
+	case `\t`:
+		return '\t'
+	}
+	r, _ := utf8.DecodeRuneInString(delimiter)
+	return r
+}
+
+// ValidateAuditCSVDelimiter checks that an audit CSV delimiter is a single rune that can separate fields
+func ValidateAuditCSVDelimiter(delimiter string) error {
+	if delimiter == "" || delimiter == `\t` {
+		return nil
+	}
+	if utf8.RuneCountInString(delimiter) != 1 {
+		return fmt.Errorf("audit CSV delimiter '%s' must be a single character", delimiter)
+	}
+	r := parseCSVDelimiter(delimiter)
+	if r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return fmt.Errorf("audit CSV delimiter %q is not allowed", delimiter)
+	}
+	return nil
+}
+
+// SetAuditCSVFormat sets the delimiter of the CSV audit (default: comma) and whether every field
+// is quoted rather than only those that need it
+func (s *Scrubber) SetAuditCSVFormat(delimiter string, quoteAll bool) error {
+	if err := ValidateAuditCSVDelimiter(delimiter); err != nil {
+		return err
+	}
+	s.auditCSVDelimiter = parseCSVDelimiter(delimiter)
+	s.auditCSVQuoteAll = quoteAll
+	return nil
+}
+
+// auditCSVWriter writes CSV audit rows in the configured format
+// encoding/csv only quotes fields that need it, so quoting every field is done here
+type auditCSVWriter struct {
+	csv      *csv.Writer
+	buffered *bufio.Writer
+	comma    string
+	err      error
+}
+
+// newAuditCSVWriter returns a writer for the scrubber's audit CSV format
+func (s *Scrubber) newAuditCSVWriter(w io.Writer) *auditCSVWriter {
+	comma := s.auditCSVDelimiter
+	if comma == 0 {
+		comma = ','
+	}
+	if s.auditCSVQuoteAll {
+		return &auditCSVWriter{buffered: bufio.NewWriter(w), comma: string(comma)}
+	}
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+	return &auditCSVWriter{csv: writer}
+}
+
+// Write writes one row
+func (a *auditCSVWriter) Write(record []string) error {
+	if a.csv != nil {
+		return a.csv.Write(record)
+	}
+	if a.err != nil {
+		return a.err
+	}
+	quoted := make([]string, len(record))
+	for i, field := range record {
+		quoted[i] = `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+	}
+	_, a.err = a.buffered.WriteString(strings.Join(quoted, a.comma) + "\n")
+	return a.err
+}
+
+// Flush writes any buffered rows and returns the first error that occurred
+func (a *auditCSVWriter) Flush() error {
+	if a.csv != nil {
+		a.csv.Flush()
+		return a.csv.Error()
+	}
+	if a.err != nil {
+		return a.err
+	}
+	return a.buffered.Flush()
+}
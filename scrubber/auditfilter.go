@@ -0,0 +1,54 @@
+package scrubber
+
+import (
+	"fmt"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// auditTypes are the built-in scrub types recorded in the audit
+var auditTypes = []string{
+	constants.TypeEmail,
+	constants.TypeUsername,
+	constants.TypeIP,
+	constants.TypeUID,
+	constants.TypeFQDN,
+	constants.TypeHost,
+	constants.TypeDomain,
+	constants.TypeNationalID,
+	constants.TypeRedact,
+	constants.TypeNumericID,
+	constants.TypePhone,
+}
+
+// ValidateNoAuditTypes checks that every type excluded from the audit is a built-in scrub type
+func ValidateNoAuditTypes(types []string) error {
+	for _, valueType := range types {
+		known := false
+		for _, auditType := range auditTypes {
+			if valueType == auditType {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("invalid audit exclusion '%s' (supported: %s)", valueType, strings.Join(auditTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// SetNoAuditTypes leaves values of these types out of the audit files while still scrubbing them
+// Their replacements still count towards the summary, metrics and frequency report
+func (s *Scrubber) SetNoAuditTypes(types []string) {
+	s.noAuditTypes = make(map[string]bool, len(types))
+	for _, valueType := range types {
+		s.noAuditTypes[valueType] = true
+	}
+}
+
+// audited reports whether an audit entry is written to the audit files
+func (s *Scrubber) audited(entry *AuditEntry) bool {
+	return !s.noAuditTypes[entry.Type]
+}
@@ -0,0 +1,51 @@
+package scrubber
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// auditHashPrefix marks audit originals that were replaced by a salted hash
+const auditHashPrefix = "hmac-sha256:"
+
+// SetAuditHashOriginals makes the audit record a salted hash (HMAC-SHA256) of each original value
+// instead of the plaintext. A random salt is generated when salt is empty. Returns the salt in use,
+// which an authorized party needs to verify a specific value with HashAuditValue.
+func (s *Scrubber) SetAuditHashOriginals(enabled bool, salt string) (string, error) {
+	if !enabled {
+		s.auditSalt = nil
+		return "", nil
+	}
+
+	if salt == "" {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return "", fmt.Errorf("generating audit salt: %w", err)
+		}
+		salt = hex.EncodeToString(random)
+	}
+	s.auditSalt = []byte(salt)
+	return salt, nil
+}
+
+// HashAuditValue returns the audit representation of an original value under the given salt
+func HashAuditValue(salt, value string) string {
+	return hashAuditValue([]byte(salt), value)
+}
+
+func hashAuditValue(salt []byte, value string) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(value))
+	return auditHashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// auditOriginal returns the value recorded as an audit entry's original
+func (s *Scrubber) auditOriginal(original string) string {
+	if s.auditSalt == nil {
+		return original
+	}
+	return hashAuditValue(s.auditSalt, original)
+}
@@ -0,0 +1,91 @@
+package scrubber
+
+import "fmt"
+
+// ChunkProgress is the snapshot passed to a chunk callback
+type ChunkProgress struct {
+	Source string // Input being processed, as recorded in the audit
+	Lines  int    // Lines read from this input so far, including empty ones
+	Final  bool   // This input has been read to the end (its output may still be buffered)
+
+	// Run totals so far: every earlier input plus this one up to now
+	Stats RunStats
+
+	// Audit entries created since the previous callback, in the order their values were first replaced.
+	// Only filled when audit entries were requested; entries are copies and types left out of the audit
+	// by SetNoAuditTypes are skipped. TimesReplaced is the count at the time of the callback.
+	NewAuditEntries []AuditEntry
+}
+
+// ChunkFunc receives a progress snapshot; returning an error stops processing with that error
+type ChunkFunc func(ChunkProgress) error
+
+// chunkCallback is a registered ChunkFunc and its cadence
+type chunkCallback struct {
+	fn           ChunkFunc
+	every        int
+	withAudit    bool
+	pending      int      // Scrubbed lines since the last call
+	newAuditKeys []string // Originals of audit entries created since the last call
+}
+
+// SetChunkCallback registers fn to be called during ProcessFile and ScrubStream after every `every`
+// scrubbed lines (lines written, or that would be written on a dry run), and once more when each
+// input ends with Final set. Callbacks run on the processing goroutine, so a slow callback slows
+// scrubbing. With withAudit, each call also carries the audit entries created since the previous one,
+// so a host can stream a partial audit. A nil fn or an every below 1 removes the callback; when none
+// is set the processing loop only pays a nil check per line.
+func (s *Scrubber) SetChunkCallback(every int, withAudit bool, fn ChunkFunc) {
+	if fn == nil || every < 1 {
+		s.chunks = nil
+		return
+	}
+	s.chunks = &chunkCallback{fn: fn, every: every, withAudit: withAudit}
+}
+
+// recordNewAuditEntry notes a new audit entry for the next chunk callback that wants audit entries
+func (s *Scrubber) recordNewAuditEntry(original string) {
+	if s.chunks != nil && s.chunks.withAudit {
+		s.chunks.newAuditKeys = append(s.chunks.newAuditKeys, original)
+	}
+}
+
+// chunkLine counts one scrubbed line and calls the chunk callback when a chunk is complete
+// current returns the counts of the input in progress, which aren't in the run totals yet
+func (s *Scrubber) chunkLine(source string, current func() RunStats) error {
+	s.chunks.pending++
+	if s.chunks.pending < s.chunks.every {
+		return nil
+	}
+	return s.notifyChunk(source, current(), false)
+}
+
+// notifyChunk calls the chunk callback with a snapshot of the run so far
+func (s *Scrubber) notifyChunk(source string, current RunStats, final bool) error {
+	chunks := s.chunks
+	chunks.pending = 0
+
+	stats := s.Stats()
+	stats.Files += current.Files
+	stats.Lines += current.Lines
+	stats.LinesScrubbed += current.LinesScrubbed
+	stats.LinesEmpty += current.LinesEmpty
+	stats.LinesDropped += current.LinesDropped
+	stats.LinesFailed += current.LinesFailed
+	stats.LinesCollapsed += current.LinesCollapsed
+	stats.JSONLines += current.JSONLines
+	stats.JSONFailures += current.JSONFailures
+	progress := ChunkProgress{Source: source, Lines: current.Lines, Final: final, Stats: stats}
+
+	for _, original := range chunks.newAuditKeys {
+		if entry, exists := s.auditEntries[original]; exists && s.audited(entry) {
+			progress.NewAuditEntries = append(progress.NewAuditEntries, *entry)
+		}
+	}
+	chunks.newAuditKeys = chunks.newAuditKeys[:0]
+
+	if err := chunks.fn(progress); err != nil {
+		return fmt.Errorf("chunk callback: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package scrubber
+
+import "fmt"
+
+// SetCollapseRepeats makes consecutive identical scrubbed lines be written once, followed by a
+// "(repeated N times)" line. Lines are compared after scrubbing, so lines that only differed in the
+// values scrubbed away collapse too. The output no longer has one line per input line.
+func (s *Scrubber) SetCollapseRepeats(enabled bool) {
+	s.collapseRepeats = enabled
+}
+
+// repeatMarker is written after a line that occurred count times in a row
+func repeatMarker(count int) string {
+	return fmt.Sprintf("(repeated %d times)", count)
+}
+
+// repeatCollapser holds back the last line written, counting how often it repeats, until a different
+// line arrives or the output ends
+type repeatCollapser struct {
+	enabled bool
+	line    string
+	count   int // Times line occurred in a row; 0 = nothing held back
+	folded  int // Lines left out of the output because they repeated the line before them
+}
+
+// add writes a scrubbed line, or counts it when it repeats the line held back
+func (c *repeatCollapser) add(line string, write func(string) error) error {
+	if !c.enabled {
+		return write(line)
+	}
+	if c.count > 0 && line == c.line {
+		c.count++
+		c.folded++
+		return nil
+	}
+	if err := c.flush(write); err != nil {
+		return err
+	}
+	c.line, c.count = line, 1
+	return nil
+}
+
+// flush writes the line held back, with its repeat marker if it occurred more than once
+func (c *repeatCollapser) flush(write func(string) error) error {
+	if c.count == 0 {
+		return nil
+	}
+	count := c.count
+	c.count = 0
+	if err := write(c.line); err != nil {
+		return err
+	}
+	if count > 1 {
+		return write(repeatMarker(count))
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package scrubber
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// containerField is one top-level field of a container log record, kept in its original order
+type containerField struct {
+	key   string
+	value json.RawMessage
+}
+
+// containerRecord is one line written by Docker's json-file log driver (also used by Kubernetes):
+// {"log":"<app log line>\n","stream":"stdout","time":"..."}
+type containerRecord struct {
+	fields []containerField
+	log    string // Decoded value of the log field
+	stream string // Decoded value of the stream field, used to join partial records
+}
+
+// SetContainerLogs makes ProcessFile treat each input line as a container log record:
+// the app log line inside the "log" field is scrubbed and re-wrapped, and the envelope is kept
+func (s *Scrubber) SetContainerLogs(enabled bool) {
+	s.containerLogs = enabled
+}
+
+// parseContainerRecord decodes a container log record, keeping every envelope field
+// Returns false when the line is not a JSON object with a string "log" field
+func parseContainerRecord(line string) (containerRecord, bool) {
+	var record containerRecord
+	decoder := json.NewDecoder(strings.NewReader(line))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return record, false
+	}
+
+	hasLog := false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return record, false
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return record, false
+		}
+		switch key {
+		case "log":
+			if err := json.Unmarshal(value, &record.log); err != nil {
+				return record, false
+			}
+			hasLog = true
+		case "stream":
+			_ = json.Unmarshal(value, &record.stream)
+		}
+		record.fields = append(record.fields, containerField{key: key, value: value})
+	}
+	if token, err := decoder.Token(); err != nil || token != json.Delim('}') {
+		return record, false
+	}
+	return record, hasLog
+}
+
+// partial reports whether the record is the start or middle of a long line the runtime split
+// into several records; only the final record of a line ends with a newline
+func (r containerRecord) partial() bool {
+	return !strings.HasSuffix(r.log, "\n")
+}
+
+// encode writes the record back with its fields in their original order
+func (r containerRecord) encode() (string, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range r.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := marshalJSONString(field.key)
+		if err != nil {
+			return "", err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(field.value)
+	}
+	buf.WriteByte('}')
+	return buf.String(), nil
+}
+
+// marshalJSONString encodes a string as JSON without escaping HTML characters
+func marshalJSONString(value string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// containerJoiner reassembles lines that the container runtime split across records, per stream
+type containerJoiner struct {
+	pending map[string]*containerRecord // key: stream -> record collecting the parts of a split line
+}
+
+// add returns the complete record once a line's final part arrives; the joined record keeps the
+// envelope of its first part. Joined lines are capped at constants.MaxLineLength.
+func (j *containerJoiner) add(record containerRecord) (containerRecord, bool) {
+	if j.pending == nil {
+		j.pending = make(map[string]*containerRecord)
+	}
+
+	if first, exists := j.pending[record.stream]; exists {
+		first.log += record.log
+		record = *first
+	}
+	if record.partial() && len(record.log) < constants.MaxLineLength {
+		j.pending[record.stream] = &record
+		return containerRecord{}, false
+	}
+	delete(j.pending, record.stream)
+	return record, true
+}
+
+// flush returns the records still waiting for a final part at the end of the input
+func (j *containerJoiner) flush() []containerRecord {
+	streams := make([]string, 0, len(j.pending))
+	for stream := range j.pending {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+
+	records := make([]containerRecord, 0, len(streams))
+	for _, stream := range streams {
+		records = append(records, *j.pending[stream])
+	}
+	j.pending = nil
+	return records
+}
+
+// processContainerRecord scrubs the app log line inside a container record and re-wraps it
+// The inner line is scrubbed like any other line, so it may be JSON or plain text
+func (s *Scrubber) processContainerRecord(record containerRecord, source string, lineNumber int) (string, error) {
+	content := strings.TrimSuffix(record.log, "\n")
+	scrubbed := content
+	if strings.TrimSpace(content) != "" {
+		var err error
+		scrubbed, err = s.processLogLine(content, source, lineNumber)
+		if err != nil {
+			return "", err
+		}
+	}
+	if !record.partial() {
+		scrubbed += "\n"
+	}
+
+	value, err := marshalJSONString(scrubbed)
+	if err != nil {
+		return "", err
+	}
+	for i := range record.fields {
+		if record.fields[i].key == "log" {
+			record.fields[i].value = value
+		}
+	}
+	return record.encode()
+}
@@ -0,0 +1,109 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// CustomPattern is an organization-specific value to scrub, e.g.
+// {"Name": "ticket", "Regex": "INC(\\d{3})\\d{4}", "Replacement": "INC$1****"}
+// The replacement may use $1 or ${name} for the pattern's groups; an empty one gives "[<name>-redacted]".
+// It is written into JSON strings as is, so it can't contain quotes, backslashes or control characters.
+// Matches are recorded in the audit with the pattern's Name as their type.
+type CustomPattern struct {
+	Name        string `json:"Name"`
+	Regex       string `json:"Regex"`
+	Replacement string `json:"Replacement"`
+}
+
+// customPatternMatcher is a compiled custom pattern
+type customPatternMatcher struct {
+	name        string
+	re          *regexp.Regexp
+	replacement string
+}
+
+// AddCustomPatterns compiles custom patterns into the set, naming the failing entry by position and name
+// A name must not be a built-in scrub type, so custom replacements are never mistaken for built-in ones.
+func (set *PatternSet) AddCustomPatterns(patterns []CustomPattern) error {
+	for i, pattern := range patterns {
+		name := strings.TrimSpace(pattern.Name)
+		if name == "" {
+			return fmt.Errorf("custom pattern #%d '%s' has no name", i+1, pattern.Regex)
+		}
+		if isBuiltinType(name) {
+			return fmt.Errorf("custom pattern #%d is named '%s', which is a built-in scrub type", i+1, name)
+		}
+		re, err := regexp.Compile(pattern.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid custom pattern #%d '%s' (%s): %w", i+1, name, pattern.Regex, err)
+		}
+		if re.MatchString("") {
+			return fmt.Errorf("invalid custom pattern #%d '%s' (%s): it matches empty text", i+1, name, pattern.Regex)
+		}
+		replacement := pattern.Replacement
+		if strings.ContainsAny(replacement, "\"\\") || strings.IndexFunc(replacement, unicode.IsControl) >= 0 {
+			return fmt.Errorf("custom pattern #%d '%s' has the replacement %q: quotes, backslashes and control characters would break JSON lines", i+1, name, replacement)
+		}
+		if replacement == "" {
+			replacement = "[" + name + "-redacted]"
+		}
+		set.customs = append(set.customs, customPatternMatcher{name: name, re: re, replacement: replacement})
+	}
+	return nil
+}
+
+// isBuiltinType reports whether name is one of the built-in scrub types
+func isBuiltinType(name string) bool {
+	if name == constants.TypeMessage {
+		return true
+	}
+	for _, valueType := range auditTypes {
+		if name == valueType {
+			return true
+		}
+	}
+	return false
+}
+
+// scrubCustomPatterns replaces matches of the configured custom patterns, in configuration order
+// (all levels). In fixed-width mode matches are masked to their length instead.
+func (s *Scrubber) scrubCustomPatterns(text, source string) string {
+	result := text
+	for _, matcher := range s.customPatterns {
+		matches := matcher.re.FindAllStringSubmatchIndex(result, -1)
+		if matches == nil {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		changed := false
+		for _, loc := range matches {
+			match := result[loc[0]:loc[1]]
+			var replacement string
+			if s.fixedWidth {
+				replacement = s.maskFixedWidth(match, matcher.name)
+			} else {
+				replacement = string(matcher.re.ExpandString(nil, matcher.replacement, result, loc))
+			}
+			if replacement == match {
+				continue
+			}
+			s.trackReplacement(match, replacement, matcher.name, source)
+			b.WriteString(result[last:loc[0]])
+			b.WriteString(replacement)
+			last = loc[1]
+			changed = true
+		}
+		if changed {
+			b.WriteString(result[last:])
+			result = b.String()
+		}
+	}
+	return result
+}
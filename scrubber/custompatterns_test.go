@@ -0,0 +1,74 @@
+package scrubber
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+func TestCustomPatternReplacementMustBeJSONSafe(t *testing.T) {
+	tests := []struct {
+		replacement string
+		wantErr     bool
+	}{
+		{"INC$1****", false},
+		{"", false},
+		{`"INC"`, true},
+		{`INC\d`, true},
+		{"INC\n", true},
+	}
+	for _, tt := range tests {
+		set := &PatternSet{}
+		err := set.AddCustomPatterns([]CustomPattern{{Name: "ticket", Regex: `INC(\d{3})\d{4}`, Replacement: tt.replacement}})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("replacement %q: err = %v, want error %t", tt.replacement, err, tt.wantErr)
+		}
+	}
+}
+
+// brokenJSONLine is valid JSON whose "msg" key and opening quote a pattern can match together
+const brokenJSONLine = `{"user":"alice","email":"alice@example.com","msg":"ticket from 10.1.2.3"}`
+
+func TestBrokenJSONNeverWritesOriginal(t *testing.T) {
+	setups := []struct {
+		name  string
+		setup func(s *Scrubber) error
+	}{
+		{"custom pattern spanning a quote", func(s *Scrubber) error {
+			set := &PatternSet{}
+			if err := set.AddCustomPatterns([]CustomPattern{{Name: "ticket", Regex: `msg":"ticket`}}); err != nil {
+				return err
+			}
+			s.SetPatterns(set)
+			return nil
+		}},
+		{"redact regex spanning a quote", func(s *Scrubber) error {
+			return s.SetRedactList([]string{`/"msg":"/`})
+		}},
+	}
+
+	for _, setup := range setups {
+		for _, action := range []string{constants.JSONFailureScrub, constants.JSONFailureDrop, constants.JSONFailureRedact} {
+			s := NewScrubber(constants.ScrubLevelHigh, false)
+			s.SetJSONFailureAction(action)
+			if err := setup.setup(s); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := s.ScrubLine(brokenJSONLine, "test.log")
+			if err != nil && !errors.Is(err, ErrLineDropped) {
+				t.Fatalf("%s, %s: %v", setup.name, action, err)
+			}
+			for _, original := range []string{"alice", "10.1.2.3"} {
+				if strings.Contains(got, original) {
+					t.Errorf("%s, %s: %q leaked into %s", setup.name, action, original, got)
+				}
+			}
+			if stats := s.Stats(); stats.JSONFailures != 1 || stats.JSONLines != 0 {
+				t.Errorf("%s, %s: %d JSON lines and %d failures, want the line counted as a failure", setup.name, action, stats.JSONLines, stats.JSONFailures)
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package scrubber
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrDeadlineExceeded is returned, wrapped with how far processing got, when the deadline set by
+// SetDeadline passes. Everything processed before it is complete: the output is closed normally and
+// the lines read so far are in the stats and the audit.
+var ErrDeadlineExceeded = errors.New("processing deadline exceeded")
+
+// SetDeadline makes ProcessFile, ScrubStream and MapUsers stop reading once the deadline has passed.
+// The deadline is checked between lines and ends a throttle wait early, but a single slow line or a
+// read blocked on a socket is finished first. A zero time removes the deadline.
+func (s *Scrubber) SetDeadline(deadline time.Time) {
+	s.deadline = deadline
+}
+
+// pastDeadline reports whether a deadline is set and has passed
+func (s *Scrubber) pastDeadline() bool {
+	return !s.deadline.IsZero() && time.Now().After(s.deadline)
+}
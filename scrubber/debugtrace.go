@@ -0,0 +1,109 @@
+package scrubber
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// debugTraceHeader opens every trace file, since unlike the scrubbed log it holds original values
+const debugTraceHeader = `# Scrub trace - CONTAINS ORIGINAL, UNSCRUBBED VALUES. Do not share; delete it when done.
+# One block per input line: the path it took, each detector match (type: "original" -> "replacement"),
+# and the line before and after scrubbing.
+`
+
+// debugTrace collects the decisions made for the line being scrubbed and writes them to the trace file
+type debugTrace struct {
+	file    *os.File
+	writer  *bufio.Writer
+	path    string   // How the current line was handled, e.g. json or plain text
+	matches []string // Replacements made on the current line
+}
+
+// OpenTrace starts writing a per-line trace of scrubbing decisions to filePath, for diagnosing
+// missed or over-matched values. The trace contains original values and grows several times larger
+// than the input, so it is meant for small inputs. Call CloseTrace when processing is done.
+// Returns the actual trace path used (which may differ if renamed)
+func (s *Scrubber) OpenTrace(filePath string, overwriteAction string) (string, error) {
+	finalPath, err := s.resolveFileConflict(filePath, overwriteAction, "Trace file")
+	if err != nil {
+		return "", err
+	}
+
+	// Readable by the owner only, as it holds the values the scrubber removes
+	file, err := os.OpenFile(finalPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create trace file: %w", err)
+	}
+	s.debugTrace = &debugTrace{file: file, writer: bufio.NewWriter(file)}
+	if _, err := s.debugTrace.writer.WriteString(debugTraceHeader); err != nil {
+		s.CloseTrace()
+		return "", fmt.Errorf("failed to write trace file: %w", err)
+	}
+	return finalPath, nil
+}
+
+// CloseTrace flushes and closes the trace file, if one is open
+func (s *Scrubber) CloseTrace() error {
+	if s.debugTrace == nil {
+		return nil
+	}
+	trace := s.debugTrace
+	s.debugTrace = nil
+
+	if err := trace.writer.Flush(); err != nil {
+		trace.file.Close()
+		return fmt.Errorf("failed to write trace file: %w", err)
+	}
+	return trace.file.Close()
+}
+
+// tracePath records how the current line is being handled
+func (s *Scrubber) tracePath(path string) {
+	if s.debugTrace != nil {
+		s.debugTrace.path = path
+	}
+}
+
+// traceMatch records a replacement made on the current line
+func (s *Scrubber) traceMatch(valueType, original, newValue string) {
+	if s.debugTrace != nil {
+		s.debugTrace.matches = append(s.debugTrace.matches, fmt.Sprintf("%s: %q -> %q", valueType, original, newValue))
+	}
+}
+
+// traceLine writes the block for one input line (or a multiline stack trace starting at lineNumber)
+// and resets the collected decisions for the next line
+func (s *Scrubber) traceLine(source string, lineNumber int, before, after string, err error) {
+	trace := s.debugTrace
+	if trace == nil {
+		return
+	}
+
+	var block strings.Builder
+	fmt.Fprintf(&block, "\n[%s:%d] %s\n", source, lineNumber, trace.path)
+	fmt.Fprintf(&block, "  before: %s\n", indentTraceText(before))
+	for _, match := range trace.matches {
+		fmt.Fprintf(&block, "  %s\n", match)
+	}
+	switch {
+	case errors.Is(err, ErrLineDropped):
+		block.WriteString("  after:  (line dropped)\n")
+	case err != nil:
+		fmt.Fprintf(&block, "  error:  %v (original line written)\n", err)
+	case after == before:
+		block.WriteString("  after:  (unchanged)\n")
+	default:
+		fmt.Fprintf(&block, "  after:  %s\n", indentTraceText(after))
+	}
+	trace.writer.WriteString(block.String())
+
+	trace.path, trace.matches = "", nil
+}
+
+// indentTraceText aligns the continuation lines of a multiline stack trace under its first line
+func indentTraceText(text string) string {
+	return strings.ReplaceAll(text, "\n", "\n          ")
+}
@@ -0,0 +1,148 @@
+package scrubber
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// Compressed input formats, recognized by their magic bytes
+const (
+	compressionGzip  = "gzip"
+	compressionBzip2 = "bzip2"
+	compressionXZ    = "xz"
+)
+
+// compressionMagic maps each compressed input format to the bytes its files start with
+var compressionMagic = map[string][]byte{
+	compressionGzip:  {0x1f, 0x8b},
+	compressionBzip2: []byte("BZh"),
+	compressionXZ:    {0xfd, '7', 'z', 'X', 'Z', 0x00},
+}
+
+// compressionExts maps compressed file extensions to their format
+var compressionExts = map[string]string{
+	constants.ExtGZ:  compressionGzip,
+	constants.ExtBZ2: compressionBzip2,
+	constants.ExtXZ:  compressionXZ,
+}
+
+// TrimCompressedExt removes a .gz, .bz2 or .xz extension, so app.log.xz is named like app.log
+func TrimCompressedExt(path string) string {
+	ext := filepath.Ext(path)
+	if _, ok := compressionExts[strings.ToLower(ext)]; ok {
+		return strings.TrimSuffix(path, ext)
+	}
+	return path
+}
+
+// sniffCompression returns the compressed format the input starts with, or "" for plain input
+func sniffCompression(input *bufio.Reader) string {
+	header, _ := input.Peek(len(compressionMagic[compressionXZ]))
+	for format, magic := range compressionMagic {
+		if bytes.HasPrefix(header, magic) {
+			return format
+		}
+	}
+	return ""
+}
+
+// newDecompressor returns a reader of the decompressed data for a sniffed compression format
+// The gzip header is validated here, so a file cut off inside it fails before anything is scrubbed.
+func newDecompressor(input io.Reader, format string) (io.Reader, error) {
+	switch format {
+	case compressionGzip:
+		gzipReader, err := gzip.NewReader(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip input: %w", compressedReadError(format, err))
+		}
+		return truncationReader{Reader: gzipReader, format: format}, nil
+	case compressionBzip2:
+		return truncationReader{Reader: bzip2.NewReader(input), format: format}, nil
+	case compressionXZ:
+		xzReader, err := xz.NewReader(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read xz input: %w", compressedReadError(format, err))
+		}
+		return truncationReader{Reader: xzReader, format: format}, nil
+	}
+	return input, nil
+}
+
+// truncationReader reads decompressed data, explaining an early end of the compressed data
+type truncationReader struct {
+	io.Reader
+	format string
+}
+
+func (r truncationReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = compressedReadError(r.format, err)
+	}
+	return n, err
+}
+
+// compressedReadError explains a decompression error: an unexpected EOF means the archive is
+// truncated, e.g. by an interrupted copy, and a checksum mismatch means it is corrupt
+func compressedReadError(format string, err error) error {
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("%s data is truncated (the file ends before its compressed data does; was it fully copied?): %w", format, err)
+	case errors.Is(err, gzip.ErrChecksum), errors.Is(err, gzip.ErrHeader):
+		return fmt.Errorf("%s data is corrupt: %w", format, err)
+	}
+	return err
+}
+
+// decompressInput transparently decompresses gzip, bzip2 and xz input files, detected by their
+// magic bytes. A file whose extension names a compression format it doesn't contain is an error,
+// so a truncated or mislabelled archive isn't scrubbed as garbage text.
+// Decompressed input is held to the size limit as it is read, since the file size only
+// reflects the compressed data.
+func (s *Scrubber) decompressInput(inputFile io.ReadCloser, inputPath string) (io.ReadCloser, error) {
+	input := bufio.NewReader(inputFile)
+	format := sniffCompression(input)
+
+	ext := strings.ToLower(filepath.Ext(inputPath))
+	if expected, ok := compressionExts[ext]; ok && format != expected {
+		return nil, fmt.Errorf("input file '%s' has a %s extension but is not %s-compressed", inputPath, ext, expected)
+	}
+
+	if format == "" {
+		// Plain files are rewound after sniffing and read directly, so byte ranges can still seek
+		if seeker, ok := inputFile.(io.Seeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err == nil {
+				return inputFile, nil
+			}
+		}
+		return wrappedInput{Reader: input, Closer: inputFile}, nil
+	}
+
+	reader, err := newDecompressor(input, format)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := s.maxInputSize
+	if s.inputRange.active() {
+		limit = 0
+	}
+	return &sizeLimitedReader{ReadCloser: wrappedInput{Reader: reader, Closer: inputFile}, limit: limit}, nil
+}
+
+// wrappedInput reads through a decompressor or buffer and closes the underlying file
+type wrappedInput struct {
+	io.Reader
+	io.Closer
+}
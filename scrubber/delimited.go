@@ -0,0 +1,153 @@
+package scrubber
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ScrubColumn is a column of a delimited log selected for scrubbing, by 0-based index or header name,
+// with the type to scrub its values as (empty = inferred from each value)
+type ScrubColumn struct {
+	Index int // -1 when the column is named
+	Name  string
+	Type  string
+}
+
+// delimitedFormat describes tab- or space-delimited input where only the selected columns are scrubbed
+type delimitedFormat struct {
+	delimiter string
+	columns   []ScrubColumn
+	named     bool           // Some columns are named, so each input starts with a header line
+	indexes   map[int]string // key: column index -> scrub type, resolved for the current input
+}
+
+// errMissingColumn is returned for a header line without a named scrub column; the input can't be
+// scrubbed safely, so processing stops instead of writing the column unscrubbed
+var errMissingColumn = errors.New("scrub column is not in the header line")
+
+// ParseDelimiter turns a delimiter setting into the separator it stands for; `\t` (or an empty setting) is a tab
+func ParseDelimiter(delimiter string) string {
+	if delimiter == "" || delimiter == `\t` {
+		return "\t"
+	}
+	return delimiter
+}
+
+// ParseScrubColumns parses a comma-separated column list such as "1,4" or "0=ip,client=username"
+// A column is a 0-based index or a header name, optionally followed by =type
+func ParseScrubColumns(spec string) ([]ScrubColumn, error) {
+	var columns []ScrubColumn
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		column := ScrubColumn{Index: -1}
+		if name, valueType, found := strings.Cut(item, "="); found {
+			item = strings.TrimSpace(name)
+			column.Type = strings.ToLower(strings.TrimSpace(valueType))
+			if !isScrubPathType(column.Type) {
+				return nil, fmt.Errorf("invalid scrub column '%s': unknown type '%s' (supported: %s)", item, valueType, strings.Join(scrubPathTypes, ", "))
+			}
+		}
+		if item == "" {
+			return nil, fmt.Errorf("invalid scrub column: column name is empty")
+		}
+		if index, err := strconv.Atoi(item); err == nil {
+			if index < 0 {
+				return nil, fmt.Errorf("invalid scrub column '%s': index must not be negative", item)
+			}
+			column.Index = index
+		} else {
+			column.Name = item
+		}
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no scrub columns given")
+	}
+	return columns, nil
+}
+
+// SetDelimitedInput treats each input line as delimiter-separated columns and scrubs only the given
+// columns; every other byte of the line, including the delimiters, is written out unchanged.
+// When any column is named, the first line of each input is its header: it resolves the names and
+// is written out as-is.
+func (s *Scrubber) SetDelimitedInput(delimiter string, columns []ScrubColumn) {
+	format := &delimitedFormat{delimiter: ParseDelimiter(delimiter), columns: columns}
+	for _, column := range columns {
+		if column.Index < 0 {
+			format.named = true
+		}
+	}
+	s.delimited = format
+	s.delimited.reset()
+}
+
+// reset prepares for a new input, whose header resolves named columns afresh
+func (f *delimitedFormat) reset() {
+	f.indexes = nil
+	if f.named {
+		return
+	}
+	f.indexes = make(map[int]string, len(f.columns))
+	for _, column := range f.columns {
+		f.indexes[column.Index] = column.Type
+	}
+}
+
+// resolveHeader maps the named columns to their index in a header line
+func (f *delimitedFormat) resolveHeader(header string) error {
+	positions := make(map[string]int)
+	for i, name := range strings.Split(header, f.delimiter) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, exists := positions[name]; !exists {
+			positions[name] = i
+		}
+	}
+
+	f.indexes = make(map[int]string, len(f.columns))
+	for _, column := range f.columns {
+		index := column.Index
+		if index < 0 {
+			position, found := positions[strings.ToLower(column.Name)]
+			if !found {
+				return fmt.Errorf("%w: '%s'", errMissingColumn, column.Name)
+			}
+			index = position
+		}
+		f.indexes[index] = column.Type
+	}
+	return nil
+}
+
+// scrubDelimitedLine scrubs the selected columns of a delimited log line
+func (s *Scrubber) scrubDelimitedLine(line, source string) (string, error) {
+	if s.delimited.indexes == nil {
+		s.tracePath("delimited header line")
+		if err := s.delimited.resolveHeader(line); err != nil {
+			return "", err
+		}
+		return line, nil
+	}
+
+	s.tracePath("delimited")
+	fields := strings.Split(line, s.delimited.delimiter)
+	for i, field := range fields {
+		// Redact list terms are removed from every column; a column they changed isn't scrubbed further
+		if redacted := s.scrubRedactList(field, source); redacted != field {
+			fields[i] = redacted
+			continue
+		}
+		valueType, selected := s.delimited.indexes[i]
+		// Empty columns and "-" placeholders have nothing to scrub
+		if selected && strings.TrimSpace(field) != "" && field != "-" {
+			field = s.scrubValueAs(valueType, field, source)
+		}
+		// Always-scrub values are mapped in every column, like redact list terms
+		fields[i] = s.scrubAlwaysScrub(field, source)
+	}
+	return strings.Join(fields, s.delimited.delimiter), nil
+}
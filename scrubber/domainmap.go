@@ -0,0 +1,108 @@
+package scrubber
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// autoDomainRegex matches the domainN tokens generated for unmapped domains
+var autoDomainRegex = regexp.MustCompile(`(?i)^domain\d+$`)
+
+// LoadDomainMap reads a JSON object of fixed domain mappings, e.g. {"acme.com": "companyA.test"}
+// Keys are lowercased; each replacement must be unique and must not look like an automatic domainN token
+func LoadDomainMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read domain map: %w", err)
+	}
+
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse domain map %s: %w", path, err)
+	}
+
+	mapping := make(map[string]string, len(raw))
+	replacements := make(map[string]string, len(raw))
+	for original, replacement := range raw {
+		key := strings.ToLower(strings.TrimSpace(original))
+		replacement = strings.TrimSpace(replacement)
+		if key == "" || replacement == "" {
+			return nil, fmt.Errorf("domain map %s: empty domain or replacement for '%s'", path, original)
+		}
+		if _, exists := mapping[key]; exists {
+			return nil, fmt.Errorf("domain map %s: domain '%s' is listed more than once", path, key)
+		}
+		if autoDomainRegex.MatchString(replacement) {
+			return nil, fmt.Errorf("domain map %s: replacement '%s' for '%s' collides with automatic domainN tokens", path, replacement, key)
+		}
+		if other, exists := replacements[strings.ToLower(replacement)]; exists {
+			return nil, fmt.Errorf("domain map %s: '%s' and '%s' both map to '%s'", path, other, key, replacement)
+		}
+		mapping[key] = replacement
+		replacements[strings.ToLower(replacement)] = key
+	}
+	return mapping, nil
+}
+
+// SetDomainMap pre-seeds fixed domain mappings loaded by LoadDomainMap
+// source names the map file and is recorded in the audit for each fixed mapping used
+func (s *Scrubber) SetDomainMap(mapping map[string]string, source string) {
+	s.fixedDomains = make(map[string]bool, len(mapping))
+	s.domainMapSource = source
+	for domain, replacement := range mapping {
+		s.domainMap[domain] = replacement
+		s.fixedDomains[domain] = true
+	}
+}
+
+// mapDomain returns the mapped name for a domain, creating the next domainN token if it is new
+// Domains are keyed in lowercase so emails, URLs and hostnames that differ only in case share one token.
+// Fixed mappings from the domain map are recorded in the audit each time they are used.
+func (s *Scrubber) mapDomain(domain string) string {
+	domain = strings.ToLower(domain)
+	if s.fixedDomains[domain] {
+		mapped := s.domainMap[domain]
+		s.trackFixedDomain(domain, mapped)
+		return mapped
+	}
+
+	if mapped, exists := s.domainMap[domain]; exists {
+		return mapped
+	}
+
+	s.domainCounter++
+	mapped := fmt.Sprintf("%s%d", s.domainPrefix, s.domainCounter)
+	if s.hashMode {
+		mapped = s.hashToken(s.domainPrefix, domain)
+	}
+	s.domainMap[domain] = mapped
+
+	if s.verbose {
+		fmt.Fprintf(s.messages, "Created domain mapping: %s -> %s\n", domain, mapped)
+	}
+
+	return mapped
+}
+
+// trackFixedDomain records a use of a fixed domain mapping in the audit
+// Entries are keyed separately so they never merge with an FQDN entry for the same text
+func (s *Scrubber) trackFixedDomain(domain, mapped string) {
+	s.traceMatch(constants.TypeDomain, domain, mapped)
+	key := constants.TypeDomain + ":" + domain
+	if entry, exists := s.auditEntries[key]; exists {
+		entry.TimesReplaced++
+		return
+	}
+	s.auditEntries[key] = &AuditEntry{
+		OriginalValue: s.auditOriginal(domain),
+		NewValue:      mapped,
+		TimesReplaced: 1,
+		Type:          constants.TypeDomain,
+		Source:        s.domainMapSource,
+	}
+}
@@ -0,0 +1,105 @@
+package scrubber
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dottedTaggedEmails are distinct addresses that only differ by dots, tags or case
+var dottedTaggedEmails = []string{
+	"first.last@Example.com",
+	"first.last+alerts@example.com",
+	"firstlast@example.com",
+	"FIRST.LAST@EXAMPLE.COM",
+	"first.last+alerts@example.com",
+}
+
+// scrubEmailsOneRun scrubs each email on its own line in a new Scrubber, with the domain map at
+// mapPath loaded fresh as a separate run would, and returns the replacement of each address
+func scrubEmailsOneRun(t *testing.T, mapPath string, emails []string, hashSalt string) map[string]string {
+	t.Helper()
+	s := NewScrubber(1, false)
+	mapping, err := LoadDomainMap(mapPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetDomainMap(mapping, mapPath)
+	if hashSalt != "" {
+		s.SetHashMode(true, hashSalt)
+	}
+
+	replacements := make(map[string]string)
+	for _, email := range emails {
+		got, err := s.ScrubLine(`{"msg":"mail to `+email+`"}`, "test.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		replacements[email] = strings.TrimSuffix(strings.TrimPrefix(got, `{"msg":"mail to `), `"}`)
+	}
+	return replacements
+}
+
+func TestDottedAndTaggedEmailsAcrossDomainMapReload(t *testing.T) {
+	mapPath := filepath.Join(t.TempDir(), "domains.json")
+	if err := os.WriteFile(mapPath, []byte(`{"Example.com": "acme.test"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	first := scrubEmailsOneRun(t, mapPath, dottedTaggedEmails, "")
+
+	// Every address keeps the mapped domain, and only case variants share a token
+	for email, replaced := range first {
+		if !strings.HasSuffix(replaced, "@acme.test") {
+			t.Errorf("%s -> %s, want the mapped domain acme.test", email, replaced)
+		}
+	}
+	if first["first.last@Example.com"] != first["FIRST.LAST@EXAMPLE.COM"] {
+		t.Errorf("case variants differ: %s and %s", first["first.last@Example.com"], first["FIRST.LAST@EXAMPLE.COM"])
+	}
+	distinct := []string{"first.last@Example.com", "first.last+alerts@example.com", "firstlast@example.com"}
+	for i := range distinct {
+		for j := i + 1; j < len(distinct); j++ {
+			if first[distinct[i]] == first[distinct[j]] {
+				t.Errorf("%s and %s share %s", distinct[i], distinct[j], first[distinct[i]])
+			}
+		}
+	}
+
+	// A second run, reloading the map and seeing the addresses in reverse order, keeps the domain
+	reversed := make([]string, len(dottedTaggedEmails))
+	for i, email := range dottedTaggedEmails {
+		reversed[len(reversed)-1-i] = email
+	}
+	second := scrubEmailsOneRun(t, mapPath, reversed, "")
+	for email, replaced := range second {
+		if !strings.HasSuffix(replaced, "@acme.test") {
+			t.Errorf("second run: %s -> %s, want the mapped domain acme.test", email, replaced)
+		}
+	}
+}
+
+func TestDottedAndTaggedEmailsStableWithHashMode(t *testing.T) {
+	mapPath := filepath.Join(t.TempDir(), "domains.json")
+	if err := os.WriteFile(mapPath, []byte(`{"example.com": "acme.test"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reversed := make([]string, len(dottedTaggedEmails))
+	for i, email := range dottedTaggedEmails {
+		reversed[len(reversed)-1-i] = email
+	}
+	first := scrubEmailsOneRun(t, mapPath, dottedTaggedEmails, "team-salt")
+	second := scrubEmailsOneRun(t, mapPath, reversed, "team-salt")
+
+	// With hash tokens the whole replacement no longer depends on first-seen order
+	for email, replaced := range first {
+		if second[email] != replaced {
+			t.Errorf("%s -> %s in the first run but %s in the second", email, replaced, second[email])
+		}
+	}
+	if first["first.last@Example.com"] == first["first.last+alerts@example.com"] {
+		t.Error("tagged address shares the token of the untagged one")
+	}
+}
@@ -0,0 +1,68 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// Email template placeholders
+const (
+	emailPlaceholderN      = "{n}"      // Mapped user number, e.g. 5
+	emailPlaceholderToken  = "{token}"  // Mapped user token, e.g. user5 or admin2
+	emailPlaceholderDomain = "{domain}" // Mapped domain, e.g. domain1
+)
+
+// fullEmailRegex matches a value that is exactly one email address as emailRegex finds them
+var fullEmailRegex = regexp.MustCompile(`^` + emailRegex.String() + `$`)
+
+// ValidateEmailTemplate checks that a template for anonymized emails, e.g. "anon.{n}@{domain}",
+// names each user uniquely and renders as an address emailRegex recognizes when the domain is a
+// real one (an internal or domain map domain), so scrubbed output scrubbed again still finds it.
+// With role tokens, {n} alone would give admin1 and user1 the same address, so {token} is required.
+// In hash mode {n} is still a per-run counter, so {token} is required for addresses stable across runs.
+func ValidateEmailTemplate(template string, roleTokens, hashMode bool) error {
+	if !strings.Contains(template, emailPlaceholderN) && !strings.Contains(template, emailPlaceholderToken) {
+		return fmt.Errorf("email template '%s' must contain %s or %s so each user gets a distinct address", template, emailPlaceholderN, emailPlaceholderToken)
+	}
+	if roleTokens && !strings.Contains(template, emailPlaceholderToken) {
+		return fmt.Errorf("email template '%s' must contain %s with role tokens, since role counters each start at 1", template, emailPlaceholderToken)
+	}
+	if hashMode && !strings.Contains(template, emailPlaceholderToken) {
+		return fmt.Errorf("email template '%s' must contain %s in hash mode, since %s is a counter that differs between runs", template, emailPlaceholderToken, emailPlaceholderN)
+	}
+	sample := renderEmailTemplate(template, 1, constants.UserTokenPrefix+"1", "example.com")
+	if !fullEmailRegex.MatchString(sample) {
+		return fmt.Errorf("email template '%s' renders as '%s', which is not an email address", template, sample)
+	}
+	return nil
+}
+
+// SetEmailTemplate sets the format of anonymized email addresses; empty keeps constants.DefaultEmailTemplate
+func (s *Scrubber) SetEmailTemplate(template string) {
+	if template == "" {
+		template = constants.DefaultEmailTemplate
+	}
+	s.emailTemplate = template
+}
+
+// renderEmailTemplate fills in an email template's placeholders
+func renderEmailTemplate(template string, n int, token, domain string) string {
+	return strings.NewReplacer(
+		emailPlaceholderN, strconv.Itoa(n),
+		emailPlaceholderToken, token,
+		emailPlaceholderDomain, domain,
+	).Replace(template)
+}
+
+// formatMappedEmail returns the anonymized email address of email, mapped to a user and domain
+func (s *Scrubber) formatMappedEmail(mapping *UserMapping, email, domain string) string {
+	template := s.emailTemplate
+	if template == "" {
+		template = constants.DefaultEmailTemplate
+	}
+	return renderEmailTemplate(template, mapping.MappedID, s.userToken(mapping, email), domain)
+}
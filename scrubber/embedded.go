@@ -0,0 +1,112 @@
+package scrubber
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// embeddedJSONHint matches the start of a JSON string whose content begins with an object or array
+// holding a string, e.g. "{\"from\": or "[\"; lines without it have no embedded document worth decoding
+var embeddedJSONHint = regexp.MustCompile(`"(?:\s|\\[nrt])*[\[{](?:\s|\\[nrt]|[\[{])*\\"`)
+
+// embeddedJSON is a string value holding a JSON document, with its location in the outer document
+type embeddedJSON struct {
+	value    *jsonValue
+	location []pathSegment
+}
+
+// collectEmbeddedJSON walks the document and returns the string values that hold a JSON object or array
+// The caller skips those inside passthrough fields, which are never scrubbed
+func collectEmbeddedJSON(value *jsonValue, location []pathSegment, found []embeddedJSON) []embeddedJSON {
+	switch value.kind {
+	case jsonObjectKind:
+		for _, f := range value.fields {
+			found = collectEmbeddedJSON(f.value, appendSegment(location, pathSegment{key: f.key}), found)
+		}
+	case jsonArrayKind:
+		for i, item := range value.items {
+			found = collectEmbeddedJSON(item, appendSegment(location, pathSegment{isIndex: true, index: i}), found)
+		}
+	case jsonStringKind:
+		if isEmbeddedJSON(value.str) {
+			found = append(found, embeddedJSON{value: value, location: location})
+		}
+	}
+	return found
+}
+
+// appendSegment returns location extended by segment without sharing the backing array of location
+func appendSegment(location []pathSegment, segment pathSegment) []pathSegment {
+	return append(append(make([]pathSegment, 0, len(location)+1), location...), segment)
+}
+
+// isEmbeddedJSON reports whether a decoded string is a JSON object or array
+func isEmbeddedJSON(value string) bool {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return false
+	}
+	return json.Valid([]byte(trimmed))
+}
+
+// innerPaths returns the scrub paths that continue into a document embedded at location,
+// relative to the embedded document, e.g. props.from for a document stored as a string in props
+func innerPaths(paths []JSONPath, location []pathSegment) []JSONPath {
+	var inner []JSONPath
+	for _, path := range paths {
+		if len(path.segments) <= len(location) {
+			continue
+		}
+		matches := true
+		for i, segment := range location {
+			if !path.segments[i].matches(segment) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			inner = append(inner, JSONPath{Expr: path.Expr, Type: path.Type, segments: path.segments[len(location):]})
+		}
+	}
+	return inner
+}
+
+// matches reports whether a path segment selects the concrete key or index of segment
+func (p pathSegment) matches(segment pathSegment) bool {
+	if p.isIndex != segment.isIndex {
+		return false
+	}
+	if p.wildcard {
+		return true
+	}
+	if p.isIndex {
+		return p.index == segment.index
+	}
+	return p.key == segment.key
+}
+
+// scrubEmbeddedJSON scrubs a JSON document that was stored as a string, the same way a JSON log line is
+// scrubbed, with paths relative to the document. ok is false when nothing changed or the result isn't
+// valid JSON, in which case the string is left for the regex scrubbers like any other text.
+func (s *Scrubber) scrubEmbeddedJSON(text, source string, paths []JSONPath, depth int) (string, bool) {
+	s.tracePath(fmt.Sprintf("embedded JSON document (depth %d)", depth))
+
+	if object, err := decodeJSONObject(text); err == nil && !s.fixedWidth {
+		s.detectAndMapUser(object)
+	}
+
+	var spans protectedSpans
+	result := s.scrubStructured(text, source, &spans, paths, depth)
+	if !s.strictAllowlist {
+		result = s.protectPreserved(result, &spans)
+		result = s.scrubJSONString(result, source)
+	}
+	result = spans.restore(result)
+
+	if result == text || !json.Valid([]byte(result)) {
+		return text, false
+	}
+	return result, true
+}
@@ -0,0 +1,64 @@
+package scrubber
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+func TestEmbeddedJSONScrubbed(t *testing.T) {
+	// props is stored as a JSON string, once nested twice; the author name is only recognizable
+	// through its scrub path or field type, which reach into the stringified documents
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"stringified props", `{"msg":"post","props":"{\"author\":\"carol\",\"from\":\"bob@example.com\"}"}`},
+		{"nested twice", `{"msg":"post","props":"{\"attachment\":\"{\\\"principal\\\":\\\"carol\\\"}\"}"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScrubber(constants.ScrubLevelHigh, false)
+			if err := s.SetScrubPaths([]string{"props.author=username"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetFieldTypes(map[string]string{"principal": constants.TypeUsername}); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := s.ScrubLine(tt.line, "test.log")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(got, "carol") || strings.Contains(got, "bob@example.com") {
+				t.Errorf("embedded values were not scrubbed: %s", got)
+			}
+			var outer struct{ Props string }
+			if err := json.Unmarshal([]byte(got), &outer); err != nil || !isEmbeddedJSON(outer.Props) {
+				t.Errorf("embedded document is no longer valid JSON: %s", got)
+			}
+		})
+	}
+}
+
+func TestIsEmbeddedJSON(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{`{"a":1}`, true},
+		{` [1, 2] `, true},
+		{`{"a":`, false},
+		{`"quoted"`, false},
+		{`42`, false},
+		{`plain text`, false},
+	}
+	for _, tt := range tests {
+		if got := isEmbeddedJSON(tt.value); got != tt.want {
+			t.Errorf("isEmbeddedJSON(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
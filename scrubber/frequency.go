@@ -0,0 +1,79 @@
+package scrubber
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// FrequencyEntry is how often one anonymized value appeared in the run
+type FrequencyEntry struct {
+	NewValue string
+	Type     string
+	Count    int
+}
+
+// FrequencyTable ranks the anonymized values from the audit by how often they were replaced,
+// most frequent first. Entries whose originals share a token (e.g. a fixed-width mask) are combined.
+func (s *Scrubber) FrequencyTable() []FrequencyEntry {
+	counts := make(map[string]*FrequencyEntry)
+	var entries []*FrequencyEntry
+	for _, entry := range s.auditEntries {
+		key := entry.Type + "\x00" + entry.NewValue
+		freq, exists := counts[key]
+		if !exists {
+			freq = &FrequencyEntry{NewValue: entry.NewValue, Type: entry.Type}
+			counts[key] = freq
+			entries = append(entries, freq)
+		}
+		freq.Count += entry.TimesReplaced
+	}
+
+	table := make([]FrequencyEntry, 0, len(entries))
+	for _, freq := range entries {
+		table = append(table, *freq)
+	}
+	sort.SliceStable(table, func(i, j int) bool {
+		if table[i].Count != table[j].Count {
+			return table[i].Count > table[j].Count
+		}
+		if table[i].Type != table[j].Type {
+			return table[i].Type < table[j].Type
+		}
+		return table[i].NewValue < table[j].NewValue
+	})
+	return table
+}
+
+// WriteFrequencyReport writes the frequency table to a CSV file. It only contains anonymized values,
+// so it can be shared wherever the scrubbed log can.
+// Returns the actual report path used (which may differ if renamed)
+func (s *Scrubber) WriteFrequencyReport(filePath string, overwriteAction string) (string, error) {
+	finalPath, err := s.resolveFileConflict(filePath, overwriteAction, "Frequency report")
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Create(finalPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create frequency report: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"New Value", "Count", "Type"}); err != nil {
+		return "", fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for _, entry := range s.FrequencyTable() {
+		if err := writer.Write([]string{entry.NewValue, fmt.Sprintf("%d", entry.Count), entry.Type}); err != nil {
+			return "", fmt.Errorf("failed to write CSV record: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", fmt.Errorf("failed to write frequency report: %w", err)
+	}
+
+	return finalPath, nil
+}
@@ -0,0 +1,108 @@
+package scrubber
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// hashTokenLength is the number of hex characters of the hash in a hash-mode token, e.g. user_a1b2c3d4
+const hashTokenLength = 8
+
+// hashPrefixLength is the number of hex characters of the cluster prefix put before the hash with
+// SetHashPrefix, e.g. the 3f of user_3fa1b2c3d4
+const hashPrefixLength = 2
+
+// hashPrefixRunes is the number of leading characters of the original that the cluster prefix hashes
+const hashPrefixRunes = 2
+
+// SetHashMode makes user and domain tokens derive from a salted SHA-256 (HMAC) of the original value,
+// e.g. user_a1b2c3d4 and domain_5e6f7a8b, instead of per-run counters, so runs over different files
+// with the same salt give the same value the same token. Each username and email is hashed on its
+// own, so a user's username and email get different tokens. Hostnames, subdomains and IDs keep their
+// counters. An empty salt is allowed but makes tokens of guessable values reversible by hashing
+// candidates.
+func (s *Scrubber) SetHashMode(enabled bool, salt string) {
+	s.hashMode = enabled
+	s.hashSalt = []byte(salt)
+	s.hashTokens = make(map[string]string)
+}
+
+// SetHashPrefix starts each hash-mode token with a short salted hash of the first characters of the
+// original, e.g. user_3fa1b2c3d4 for alice and user_3f5e6f7a8b for alan, so tokens of related values
+// sort together. Tokens stay non-reversible, but values sharing a prefix can be told apart from those
+// that don't, which leaks a little about the originals.
+func (s *Scrubber) SetHashPrefix(enabled bool) {
+	s.hashPrefix = enabled
+}
+
+// hashToken returns the hash-mode token for a value under prefix, e.g. user_a1b2c3d4
+// The value is hashed as normalized, so case variants share a token. Two values whose hashes share
+// the first hashTokenLength characters would share a token, so the later one gets a longer hash;
+// only that value's token then depends on what else the run has seen.
+func (s *Scrubber) hashToken(prefix, value string) string {
+	key := s.normalizeKey(value)
+	sum := s.hashHex(key)
+	length := hashTokenLength
+	if s.hashPrefix {
+		sum = s.clusterPrefix(key) + sum
+		length += hashPrefixLength
+	}
+
+	token := prefix + "_" + sum
+	for ; length < len(sum); length += 4 {
+		candidate := prefix + "_" + sum[:length]
+		if owner, taken := s.hashTokens[candidate]; !taken || owner == key {
+			token = candidate
+			break
+		}
+		if s.verbose {
+			fmt.Fprintf(s.messages, "Hash token collision on %s: using a longer hash\n", candidate)
+		}
+	}
+	s.hashTokens[token] = key
+	return token
+}
+
+// hashHex returns the salted hash of a normalized value in hex
+func (s *Scrubber) hashHex(key string) string {
+	mac := hmac.New(sha256.New, s.hashSalt)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// clusterPrefix returns the cluster prefix of a normalized value, from the salted hash of its first
+// hashPrefixRunes characters
+func (s *Scrubber) clusterPrefix(key string) string {
+	runes := []rune(key)
+	if len(runes) > hashPrefixRunes {
+		runes = runes[:hashPrefixRunes]
+	}
+	return s.hashHex("prefix:" + string(runes))[:hashPrefixLength]
+}
+
+// newUserMapping returns a mapping for a new user first seen as value, with the next counter number
+// and, in hash mode, a token derived from value. Regular users get the configured user prefix.
+func (s *Scrubber) newUserMapping(prefix, value string) *UserMapping {
+	mapping := &UserMapping{MappedID: s.nextMappedID(prefix), Prefix: prefix}
+	if prefix == "" || prefix == constants.UserTokenPrefix {
+		mapping.Prefix = s.userPrefix
+	}
+	if s.hashMode {
+		mapping.HashToken = s.hashToken(mapping.Prefix, value)
+	}
+	return mapping
+}
+
+// userToken returns the token that replaces value, one of mapping's usernames, emails or user IDs. In
+// hash mode each value is hashed on its own rather than taking the token of whichever value of the user
+// the run saw first, so its token is the same in every file and run whatever it appears with.
+func (s *Scrubber) userToken(mapping *UserMapping, value string) string {
+	if !s.hashMode {
+		return mapping.Token()
+	}
+	return s.hashToken(mapping.Prefix, value)
+}
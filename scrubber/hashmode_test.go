@@ -0,0 +1,112 @@
+package scrubber
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// userFieldRegex captures the scrubbed user field of a line
+var userFieldRegex = regexp.MustCompile(`"user":"([^"]+)"`)
+
+// hashTokensFor scrubs one username/email pair per name in hash mode and returns each user's token
+func hashTokensFor(t *testing.T, salt string, prefix bool, names ...string) map[string]string {
+	t.Helper()
+	s := NewScrubber(1, false)
+	s.SetHashMode(true, salt)
+	s.SetHashPrefix(prefix)
+	tokens := make(map[string]string)
+	for _, name := range names {
+		got, err := s.ScrubLine(`{"user":"`+name+`","email":"`+name+`@example.com"}`, "test.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[name] = userFieldRegex.FindStringSubmatch(got)[1]
+	}
+	return tokens
+}
+
+func TestHashPrefixClustersRelatedValues(t *testing.T) {
+	tokens := hashTokensFor(t, "team-salt", true, "alice", "alan", "ALISON", "bob")
+
+	tokenRegex := regexp.MustCompile(`^user_[0-9a-f]{10}$`)
+	for name, token := range tokens {
+		if !tokenRegex.MatchString(token) {
+			t.Errorf("%s -> %s, want user_ and 10 hex characters", name, token)
+		}
+	}
+	cluster := func(name string) string { return tokens[name][len("user_") : len("user_")+hashPrefixLength] }
+	if cluster("alice") != cluster("alan") || cluster("alice") != cluster("ALISON") {
+		t.Errorf("values starting with al don't share a prefix: %v", tokens)
+	}
+	if cluster("alice") == cluster("bob") {
+		t.Errorf("alice and bob share the prefix %s", cluster("bob"))
+	}
+	if tokens["alice"] == tokens["alan"] {
+		t.Errorf("alice and alan share the token %s", tokens["alice"])
+	}
+
+	// The prefix is salted like the rest, so the same value under another salt is unrelated
+	other := hashTokensFor(t, "other-salt", true, "alice", "bob")
+	if other["alice"] == tokens["alice"] || other["bob"] == tokens["bob"] {
+		t.Errorf("tokens don't depend on the salt: %v and %v", tokens, other)
+	}
+}
+
+func TestHashPrefixKeepsTheHash(t *testing.T) {
+	plain := hashTokensFor(t, "team-salt", false, "alice")
+	prefixed := hashTokensFor(t, "team-salt", true, "alice")
+
+	// The prefix is put before the same hash, so tokens with and without it are easy to relate
+	if len(plain["alice"]) != len("user_")+hashTokenLength {
+		t.Errorf("token without a prefix is %s", plain["alice"])
+	}
+	if want := strings.TrimPrefix(plain["alice"], "user_"); !strings.HasSuffix(prefixed["alice"], want) {
+		t.Errorf("prefixed token %s doesn't end with the hash %s", prefixed["alice"], want)
+	}
+	if err := ValidateAlwaysScrub([]string{prefixed["alice"]}, "user", "domain", constants.DefaultEmailTemplate); err == nil {
+		t.Errorf("prefixed token %s isn't recognized as a mapped token", prefixed["alice"])
+	}
+}
+
+func TestHashTokensDontDependOnOrder(t *testing.T) {
+	// The same values, seen alone and together in a different order in each file
+	files := []string{
+		`{"msg":"mail to alice@example.com bounced"}
+{"user":"alice","email":"alice@example.com"}
+{"msg":"alice logged in"}
+`,
+		`{"user":"alice","email":"alice@example.com"}
+{"msg":"mail to alice@example.com bounced"}
+{"username":"alice"}
+`,
+	}
+	emailRegex := regexp.MustCompile(`user_[0-9a-f]{8}@domain_[0-9a-f]{8}`)
+
+	var emailTokens, userTokens []string
+	for i, content := range files {
+		input := writeTestInput(t, "mattermost.log", content)
+		s := NewScrubber(constants.ScrubLevelHigh, false)
+		s.SetHashMode(true, "team-salt")
+		output := filepath.Join(t.TempDir(), "out.log")
+		if _, err := s.ProcessFile(input, output, false, false, constants.OverwriteOverwrite); err != nil {
+			t.Fatal(err)
+		}
+		scrubbed := string(readTestFile(t, output))
+		emails := emailRegex.FindAllString(scrubbed, -1)
+		if len(emails) != 2 || emails[0] != emails[1] {
+			t.Fatalf("file %d: email tokens %v, want one token twice:\n%s", i+1, emails, scrubbed)
+		}
+		emailTokens = append(emailTokens, emails[0])
+		userTokens = append(userTokens, userFieldRegex.FindStringSubmatch(scrubbed)[1])
+	}
+	if emailTokens[0] != emailTokens[1] {
+		t.Errorf("alice@example.com became %s in one file and %s in the other", emailTokens[0], emailTokens[1])
+	}
+	if userTokens[0] != userTokens[1] {
+		t.Errorf("alice became %s in one file and %s in the other", userTokens[0], userTokens[1])
+	}
+}
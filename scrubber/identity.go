@@ -0,0 +1,189 @@
+package scrubber
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// identityReportWarning heads every identity report, since unlike the audit it is organized for lookup by person
+const identityReportWarning = "CONTAINS ORIGINAL, UNSCRUBBED VALUES grouped by person. Do not share; delete it when done."
+
+// IdentityValue is one original value of a person and the token it was replaced with
+// NewValue is empty for a linked ID that was never replaced (e.g. one too short to be detected)
+type IdentityValue struct {
+	OriginalValue string
+	NewValue      string
+	TimesReplaced int
+}
+
+// Identity groups every value that was mapped to one user token
+type Identity struct {
+	Token     string
+	Usernames []IdentityValue
+	Emails    []IdentityValue
+	IDs       []IdentityValue // User IDs seen in the same JSON object as the username or email
+}
+
+// identityReport is the file written by WriteIdentityReport
+type identityReport struct {
+	Warning    string
+	Identities []Identity
+}
+
+// userIDField returns the user ID of a JSON object that also names a user, from user_id or else id
+func userIDField(object map[string]interface{}) string {
+	for _, key := range []string{"user_id", "id"} {
+		if id, ok := object[key].(string); ok && id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// SetIdentityReport enables linking user IDs to the usernames and emails they appear with, for
+// IdentityReport. Call it before processing, as links are collected while scrubbing.
+func (s *Scrubber) SetIdentityReport(enabled bool) {
+	s.identityReport = enabled
+}
+
+// linkUserID records a user ID seen alongside a username and/or email, so the identity report can
+// list it with the person. The link is kept by value, as the user may not be mapped yet.
+func (s *Scrubber) linkUserID(username, email, id string) {
+	if id == "" {
+		return
+	}
+	for _, value := range []string{username, email} {
+		if value == "" {
+			continue
+		}
+		key := s.normalizeKey(value)
+		linked := false
+		for _, existing := range s.userIDLinks[key] {
+			if existing == id {
+				linked = true
+				break
+			}
+		}
+		if !linked {
+			s.userIDLinks[key] = append(s.userIDLinks[key], id)
+		}
+	}
+}
+
+// IdentityReport groups the audited usernames and emails by the user token they were mapped to,
+// with any user IDs linked to them, ordered by token
+// Originals are recorded as in the audit, so they are hashed with --audit-hash-originals
+func (s *Scrubber) IdentityReport() []Identity {
+	originals := make([]string, 0, len(s.auditEntries))
+	for original := range s.auditEntries {
+		originals = append(originals, original)
+	}
+	sort.Strings(originals)
+
+	identities := make(map[*UserMapping]*Identity)
+	linkKeys := make(map[*UserMapping][]string)
+	var mappings []*UserMapping
+	for _, original := range originals {
+		entry := s.auditEntries[original]
+		if !s.audited(entry) || (entry.Type != constants.TypeUsername && entry.Type != constants.TypeEmail) {
+			continue
+		}
+		key := s.normalizeKey(original)
+		mapping, exists := s.userMappings[key]
+		if !exists {
+			continue
+		}
+
+		identity, exists := identities[mapping]
+		if !exists {
+			identity = &Identity{Token: mapping.Token()}
+			identities[mapping] = identity
+			mappings = append(mappings, mapping)
+		}
+		value := IdentityValue{OriginalValue: entry.OriginalValue, NewValue: entry.NewValue, TimesReplaced: entry.TimesReplaced}
+		if entry.Type == constants.TypeUsername {
+			identity.Usernames = append(identity.Usernames, value)
+		} else {
+			identity.Emails = append(identity.Emails, value)
+		}
+		linkKeys[mapping] = append(linkKeys[mapping], key)
+	}
+
+	// Standalone mappings leave the prefix empty, which means the user prefix
+	prefix := func(m *UserMapping) string {
+		if m.Prefix == "" {
+			return constants.UserTokenPrefix
+		}
+		return m.Prefix
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		if prefix(mappings[i]) != prefix(mappings[j]) {
+			return prefix(mappings[i]) < prefix(mappings[j])
+		}
+		return mappings[i].MappedID < mappings[j].MappedID
+	})
+
+	report := make([]Identity, 0, len(mappings))
+	for _, mapping := range mappings {
+		identity := identities[mapping]
+		seen := make(map[string]bool)
+		for _, key := range linkKeys[mapping] {
+			for _, id := range s.userIDLinks[key] {
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+
+				value := IdentityValue{OriginalValue: s.auditOriginal(id)}
+				if entry, exists := s.auditEntries[id]; exists {
+					if !s.audited(entry) {
+						continue
+					}
+					value.NewValue = entry.NewValue
+					value.TimesReplaced = entry.TimesReplaced
+				}
+				identity.IDs = append(identity.IDs, value)
+			}
+		}
+		report = append(report, *identity)
+	}
+	return report
+}
+
+// IdentityCount returns the number of distinct user tokens handed out, i.e. people anonymized
+// Fixed-width masks don't map users, so it is always 0 with them
+func (s *Scrubber) IdentityCount() int {
+	seen := make(map[*UserMapping]bool)
+	for _, mapping := range s.userMappings {
+		seen[mapping] = true
+	}
+	return len(seen)
+}
+
+// WriteIdentityReport writes the identity report as JSON to a file readable by the owner only,
+// as it ties each person's original values together
+// Returns the actual report path used (which may differ if renamed)
+func (s *Scrubber) WriteIdentityReport(filePath string, overwriteAction string) (string, error) {
+	finalPath, err := s.resolveFileConflict(filePath, overwriteAction, "Identity report")
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(finalPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create identity report: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(identityReport{Warning: identityReportWarning, Identities: s.IdentityReport()}); err != nil {
+		return "", fmt.Errorf("failed to write identity report: %w", err)
+	}
+
+	return finalPath, nil
+}
@@ -0,0 +1,79 @@
+package scrubber
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// IsUnixSocketInput reports whether the input path refers to a Unix domain socket (unix:///path/to/sock)
+func IsUnixSocketInput(inputPath string) bool {
+	return strings.HasPrefix(inputPath, constants.UnixSocketScheme)
+}
+
+// IsStdio reports whether a path means standard input or standard output ("-")
+func IsStdio(path string) bool {
+	return path == constants.StdioPath
+}
+
+// inputSourceName returns the name recorded as the source of audit entries for an input path
+func inputSourceName(inputPath string) string {
+	if IsStdio(inputPath) {
+		return constants.StdinSourceName
+	}
+	return filepath.Base(strings.TrimPrefix(inputPath, constants.UnixSocketScheme))
+}
+
+// openInput opens the input for reading, connecting to a Unix domain socket when requested
+// Compressed files are decompressed as they are read
+func (s *Scrubber) openInput(inputPath string) (io.ReadCloser, error) {
+	if IsUnixSocketInput(inputPath) {
+		socketPath := strings.TrimPrefix(inputPath, constants.UnixSocketScheme)
+		conn, err := net.Dial("unix", socketPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to socket '%s': %w", socketPath, err)
+		}
+
+		// Streams cannot be checked up front, so enforce the size limit while reading
+		return &sizeLimitedReader{ReadCloser: conn, limit: s.maxInputSize}, nil
+	}
+
+	// Standard input is a stream like a socket, and may be compressed like a file
+	var inputFile io.ReadCloser
+	if IsStdio(inputPath) {
+		inputFile = &sizeLimitedReader{ReadCloser: io.NopCloser(os.Stdin), limit: s.maxInputSize}
+	} else {
+		file, err := os.Open(inputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input file: %w", err)
+		}
+		inputFile = file
+	}
+	input, err := s.decompressInput(inputFile, inputPath)
+	if err != nil {
+		inputFile.Close()
+		return nil, err
+	}
+	return input, nil
+}
+
+// sizeLimitedReader fails once more than limit bytes have been read in total
+type sizeLimitedReader struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	if r.limit > 0 && r.read > r.limit {
+		return n, fmt.Errorf("input stream exceeded maximum allowed size of %d bytes. Use --max-file-size or config setting to override", r.limit)
+	}
+	return n, err
+}
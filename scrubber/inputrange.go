@@ -0,0 +1,128 @@
+package scrubber
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// InputRange limits ProcessFile to a window of the input
+// Line ranges are 1-based and inclusive; byte ranges select every line that starts at or after
+// Start and before End, skipping a partial first line when Start falls inside one
+type InputRange struct {
+	ByBytes bool  // Start and End are byte offsets rather than line numbers
+	Start   int64 // First line or byte offset (0 = from the beginning)
+	End     int64 // Last line, or byte offset the window stops before (0 = to the end)
+}
+
+// active reports whether a range was set
+func (r InputRange) active() bool {
+	return r.Start > 0 || r.End > 0
+}
+
+// String describes the range as given, e.g. "lines 500000-520000" or "bytes 1024-end"
+func (r InputRange) String() string {
+	unit := "lines"
+	if r.ByBytes {
+		unit = "bytes"
+	}
+	end := "end"
+	if r.End > 0 {
+		end = fmt.Sprintf("%d", r.End)
+	}
+	return fmt.Sprintf("%s %d-%s", unit, r.Start, end)
+}
+
+// SetInputRange makes ProcessFile scrub only a line or byte window of each input
+// Mappings and the audit are built from the window alone
+func (s *Scrubber) SetInputRange(r InputRange) {
+	s.inputRange = r
+}
+
+// rangeTracker follows the position of each scanned line against the configured range
+type rangeTracker struct {
+	r           InputRange
+	offset      int64 // Byte offset where the next line starts
+	advance     int   // Bytes consumed by the last line, including its terminator
+	skipPartial bool  // The first scanned line is the tail of a line that starts before the window
+
+	firstLine, lastLine int   // Line numbers processed (relative to the window start for byte ranges)
+	firstByte, endByte  int64 // Byte offsets processed
+}
+
+// seekToStart positions the input at the byte range start and notes whether that falls mid-line
+// Line ranges and byte ranges starting at 0 read from the beginning
+func (t *rangeTracker) seekToStart(input io.Reader) error {
+	if !t.r.ByBytes || t.r.Start == 0 {
+		return nil
+	}
+
+	// Skip to the byte before the window; if it isn't a newline the window starts mid-line
+	skip := t.r.Start - 1
+	if seeker, ok := input.(io.Seeker); ok {
+		if _, err := seeker.Seek(skip, io.SeekStart); err != nil {
+			return fmt.Errorf("seeking to byte %d: %w", t.r.Start, err)
+		}
+	} else if _, err := io.CopyN(io.Discard, input, skip); err != nil {
+		return fmt.Errorf("skipping to byte %d: %w", t.r.Start, err)
+	}
+
+	previous := make([]byte, 1)
+	if _, err := io.ReadFull(input, previous); err != nil {
+		return fmt.Errorf("byte range starts at %d, past the end of the input", t.r.Start)
+	}
+	t.offset = t.r.Start
+	t.skipPartial = previous[0] != '\n'
+	return nil
+}
+
+// split wraps bufio.ScanLines to record how many bytes each line consumed
+func (t *rangeTracker) split(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if token != nil {
+		t.advance = advance
+	}
+	return advance, token, err
+}
+
+// next accounts for a scanned line and reports whether to skip it and whether the window has ended
+func (t *rangeTracker) next(lineNumber int) (skip, done bool) {
+	lineStart := t.offset
+	t.offset += int64(t.advance)
+
+	if t.r.ByBytes {
+		if t.skipPartial {
+			t.skipPartial = false
+			return true, false
+		}
+		if t.r.End > 0 && lineStart >= t.r.End {
+			return false, true
+		}
+	} else {
+		if int64(lineNumber) < t.r.Start {
+			return true, false
+		}
+		if t.r.End > 0 && int64(lineNumber) > t.r.End {
+			return false, true
+		}
+	}
+
+	if t.firstLine == 0 {
+		t.firstLine, t.firstByte = lineNumber, lineStart
+	}
+	t.lastLine, t.endByte = lineNumber, t.offset
+	return false, false
+}
+
+// report prints the part of the input that was actually processed to w
+func (t *rangeTracker) report(w io.Writer) {
+	if t.firstLine == 0 {
+		fmt.Fprintf(w, "Requested range (%s) contained no lines\n", t.r)
+		return
+	}
+	if t.r.ByBytes {
+		fmt.Fprintf(w, "Processed range: bytes %d-%d (%d lines)\n", t.firstByte, t.endByte, t.lastLine-t.firstLine+1)
+	} else {
+		fmt.Fprintf(w, "Processed range: lines %d-%d (bytes %d-%d)\n", t.firstLine, t.lastLine, t.firstByte, t.endByte)
+	}
+}
@@ -0,0 +1,51 @@
+package scrubber
+
+import (
+	"fmt"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// ValidateInternalDomains checks that each internal domain looks like a domain name, e.g. acme.com
+func ValidateInternalDomains(domains []string) error {
+	for _, domain := range domains {
+		if !strings.Contains(domain, ".") || strings.ContainsAny(domain, "@/ ") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+			return fmt.Errorf("invalid internal domain '%s' (expected a domain such as acme.com)", domain)
+		}
+	}
+	return nil
+}
+
+// SetInternalDomains treats email addresses in these domains (and their subdomains) as internal:
+// they keep their domain and only the local part is mapped. Every other address is external and
+// gets constants.ExternalEmailDomain instead of a domainN token, so nothing about it survives.
+// An empty list keeps the usual userN@domainN mapping for every address.
+func (s *Scrubber) SetInternalDomains(domains []string) {
+	s.internalDomains = make([]string, 0, len(domains))
+	for _, domain := range domains {
+		s.internalDomains = append(s.internalDomains, strings.ToLower(domain))
+	}
+}
+
+// isInternalDomain reports whether domain is one of the internal domains or a subdomain of one
+func (s *Scrubber) isInternalDomain(domain string) bool {
+	domain = strings.ToLower(domain)
+	for _, internal := range s.internalDomains {
+		if domain == internal || strings.HasSuffix(domain, "."+internal) {
+			return true
+		}
+	}
+	return false
+}
+
+// emailDomainReplacement returns the domain written for an email address's domain when internal
+// domains are set: internal domains are kept as they are, all others become the external placeholder
+func (s *Scrubber) emailDomainReplacement(domain string) string {
+	if s.isInternalDomain(domain) {
+		s.stats.InternalEmails++
+		return domain
+	}
+	s.stats.ExternalEmails++
+	return constants.ExternalEmailDomain
+}
@@ -0,0 +1,214 @@
+package scrubber
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// jsonKind identifies the type of a decoded JSON value
+type jsonKind int
+
+const (
+	jsonObjectKind jsonKind = iota
+	jsonArrayKind
+	jsonStringKind
+	jsonNumberKind
+	jsonBoolKind
+	jsonNullKind
+)
+
+// jsonValue is a decoded JSON value that remembers where it sits in the original text,
+// so structured scrubbing can splice replacements into a line without re-encoding the rest of it
+type jsonValue struct {
+	kind   jsonKind
+	str    string       // decoded string, or the literal text of a number
+	start  int          // byte offset of the first character of the value
+	end    int          // byte offset just past the value
+	fields []jsonField  // object members in their original order
+	items  []*jsonValue // array elements
+}
+
+// jsonField is a single member of a JSON object
+type jsonField struct {
+	key   string
+	value *jsonValue
+}
+
+// jsonEdit replaces text[start:end] with text
+type jsonEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// decodeJSONValue decodes a single JSON document into a jsonValue tree
+func decodeJSONValue(text string) (*jsonValue, error) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	value, err := decodeNextValue(decoder, text)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after top-level JSON value")
+	}
+	return value, nil
+}
+
+// decodeNextValue reads the next value from the decoder, recording its position in text
+func decodeNextValue(decoder *json.Decoder, text string) (*jsonValue, error) {
+	start := skipJSONSeparators(text, int(decoder.InputOffset()))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	value := &jsonValue{start: start}
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			value.kind = jsonObjectKind
+			for decoder.More() {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := keyToken.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid object key %v", keyToken)
+				}
+				child, err := decodeNextValue(decoder, text)
+				if err != nil {
+					return nil, err
+				}
+				value.fields = append(value.fields, jsonField{key: key, value: child})
+			}
+		case '[':
+			value.kind = jsonArrayKind
+			for decoder.More() {
+				child, err := decodeNextValue(decoder, text)
+				if err != nil {
+					return nil, err
+				}
+				value.items = append(value.items, child)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected delimiter %v", t)
+		}
+		// Consume the closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		value.kind = jsonStringKind
+		value.str = t
+	case json.Number:
+		value.kind = jsonNumberKind
+		value.str = t.String()
+	case bool:
+		value.kind = jsonBoolKind
+	case nil:
+		value.kind = jsonNullKind
+	}
+
+	value.end = int(decoder.InputOffset())
+	return value, nil
+}
+
+// skipJSONSeparators advances past whitespace and the separators between tokens
+func skipJSONSeparators(text string, offset int) int {
+	for offset < len(text) {
+		switch text[offset] {
+		case ' ', '\t', '\n', '\r', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// field returns the value of the first member named key, or nil
+func (v *jsonValue) field(key string) *jsonValue {
+	for _, f := range v.fields {
+		if f.key == key {
+			return f.value
+		}
+	}
+	return nil
+}
+
+// applyJSONEdits splices non-overlapping edits into text
+func applyJSONEdits(text string, edits []jsonEdit) string {
+	if len(edits) == 0 {
+		return text
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var builder strings.Builder
+	last := 0
+	for _, edit := range edits {
+		if edit.start < last {
+			continue // Overlaps an earlier edit
+		}
+		builder.WriteString(text[last:edit.start])
+		builder.WriteString(edit.text)
+		last = edit.end
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// encodeJSONString encodes a string as a JSON string literal without HTML escaping
+func encodeJSONString(value string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return `""`
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// decodeJSONObject decodes a JSON object line for reading its fields, keeping numbers as
+// json.Number so that values outside float64's range (e.g. 1e400) don't make a valid line fail
+// Scrubbed lines are never re-encoded from the result, which is what keeps numbers, booleans and
+// null byte-identical in the output
+func decodeJSONObject(text string) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after top-level JSON value")
+	}
+	return object, nil
+}
+
+// canonicalizeJSON re-marshals a JSON document with object keys sorted and compact formatting
+// Numbers keep their original text and HTML characters are not escaped
+func canonicalizeJSON(text string) (string, error) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
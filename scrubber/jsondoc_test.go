@@ -0,0 +1,100 @@
+package scrubber
+
+import (
+	"fmt"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// typedJSONLines hold numbers, booleans and null that must come out of every structured path
+// unchanged, including numbers float64 can't represent exactly (or at all, on their own line so a
+// path failing on it can't hide changes to the rest)
+var typedJSONLines = []string{
+	`{"user":"alice.smith","email":"alice.smith@example.com","count":9007199254740993,"ratio":0.1,` +
+		`"precise":123456789.123456789012,"tiny":1e-7,"whole":1.0,"trailing":2.50,"neg":-0,` +
+		`"ok":true,"off":false,"none":null,"props":{"list":[1.10,true,null,"203.0.113.42"]}}`,
+	`{"user":"alice.smith","huge":1e400,"count":18446744073709551616}`,
+	`{"user":"alice.smith","pi":3.141592653589793238462643383279,"small":4.9e-324,"exp":6.02214076E+23,"signed":-1.5e-10}`,
+}
+
+// collectJSONScalars records the literal text of every number, boolean and null in a document by its path
+func collectJSONScalars(text string, value *jsonValue, path string, scalars map[string]string) {
+	switch value.kind {
+	case jsonObjectKind:
+		for _, field := range value.fields {
+			collectJSONScalars(text, field.value, path+"."+field.key, scalars)
+		}
+	case jsonArrayKind:
+		for i, item := range value.items {
+			collectJSONScalars(text, item, fmt.Sprintf("%s[%d]", path, i), scalars)
+		}
+	case jsonNumberKind, jsonBoolKind, jsonNullKind:
+		scalars[path] = text[value.start:value.end]
+	}
+}
+
+func TestJSONValueTypesSurviveScrubbing(t *testing.T) {
+	paths := []struct {
+		name  string
+		setup func(s *Scrubber) error
+	}{
+		{"regular", func(s *Scrubber) error { return nil }},
+		{"scrub paths and field types", func(s *Scrubber) error {
+			if err := s.SetScrubPaths([]string{"user", "props.list[3]"}); err != nil {
+				return err
+			}
+			return s.SetFieldTypes(map[string]string{"email": constants.TypeEmail, "count": constants.TypeUsername})
+		}},
+		{"canonical JSON", func(s *Scrubber) error {
+			s.SetCanonicalJSON(true)
+			return nil
+		}},
+		{"wide line", nil},
+	}
+
+	for lineNumber, line := range typedJSONLines {
+		original, err := decodeJSONValue(line)
+		if err != nil {
+			t.Fatalf("line %d: %v", lineNumber+1, err)
+		}
+		want := make(map[string]string)
+		collectJSONScalars(line, original, "", want)
+
+		for _, path := range paths {
+			t.Run(fmt.Sprintf("%s/line %d", path.name, lineNumber+1), func(t *testing.T) {
+				s := NewScrubber(constants.ScrubLevelHigh, false)
+				var output string
+				if path.setup == nil {
+					scrubbed, ok := s.processWideJSONLine(line, "test.log")
+					if !ok {
+						t.Fatal("wide line path did not accept the line")
+					}
+					output = scrubbed
+				} else {
+					if err := path.setup(s); err != nil {
+						t.Fatal(err)
+					}
+					if output, err = s.processLogLine(line, "test.log", lineNumber+1); err != nil {
+						t.Fatal(err)
+					}
+					if s.jsonFailureCount > 0 {
+						t.Fatal("line was treated as invalid JSON")
+					}
+				}
+
+				scrubbed, err := decodeJSONValue(output)
+				if err != nil {
+					t.Fatalf("invalid JSON output %s: %v", output, err)
+				}
+				got := make(map[string]string)
+				collectJSONScalars(output, scrubbed, "", got)
+				for location, text := range want {
+					if got[location] != text {
+						t.Errorf("%s changed from %s to %q", location, text, got[location])
+					}
+				}
+			})
+		}
+	}
+}
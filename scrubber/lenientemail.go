@@ -0,0 +1,51 @@
+package scrubber
+
+import (
+	"regexp"
+	"strings"
+)
+
+// emailGap is whitespace that may surround the @ of a lenient email: spaces, tabs and line
+// breaks, either literal or JSON-escaped (\n, \r, \t) as they appear in a raw log line
+const emailGap = `(?:[ \t\r\n]|\\[nrt])*`
+
+// emailWrap is a line break, with any spaces around it, that may follow a dot in the domain of a
+// lenient email, as when a long address is wrapped in pasted content
+const emailWrap = `[ \t]*(?:\r?\n|\\r\\n|\\n)[ \t]*`
+
+// lenientEmailRegex finds emails as emailRegex does, but also with whitespace around the @ or
+// wrapped after a dot in the domain, e.g. "alice @ acme.com" or "alice@acme.\ncom". A label after a
+// wrap must be lowercase and end at a dot or the end of a word, so the first word of the next line
+// isn't taken for one: "bob@acme.com.\nThanks" is bob@acme.com followed by a sentence.
+var lenientEmailRegex = regexp.MustCompile(lenientEmailPattern(emailGap, emailWrap))
+
+// lenientInlineEmailRegex is lenientEmailRegex without line breaks, for text joined from several log
+// lines, such as a stack trace block, where a break is always the end of a line
+var lenientInlineEmailRegex = regexp.MustCompile(lenientEmailPattern(`[ \t]*`, ""))
+
+// lenientEmailPattern returns the lenient email pattern with gap allowed around the @ and, when wrap
+// isn't empty, wrap allowed after a dot in the domain
+func lenientEmailPattern(gap, wrap string) string {
+	label, tld := `[a-zA-Z0-9-]+`, `[a-zA-Z]{2,}`
+	if wrap != "" {
+		label = `(?:` + label + `|` + wrap + `[a-z0-9-]+\b)`
+		tld = `(?:` + tld + `|` + wrap + `[a-z]{2,}\b)`
+	}
+	return `[a-zA-Z0-9._%+-]+` + gap + `@` + gap + `[a-zA-Z0-9-]+(?:\.` + label + `)*\.` + tld
+}
+
+// emailGapRegex matches the whitespace and escapes removed to normalize a lenient email
+var emailGapRegex = regexp.MustCompile(`[ \t\r\n]+|\\[nrt]`)
+
+// SetLenientEmails makes email detection tolerate whitespace and line wraps inside addresses,
+// which the strict pattern misses. Each variant is mapped as the address without them, so
+// "alice @ acme.com" gets the same token as alice@acme.com. Off by default, as it can match
+// text that only looks like a spaced-out address, e.g. "me @ acme.com".
+func (s *Scrubber) SetLenientEmails(enabled bool) {
+	s.lenientEmails = enabled
+}
+
+// normalizeLenientEmail removes the whitespace and escaped line breaks from a lenient email match
+func normalizeLenientEmail(match string) string {
+	return strings.TrimSpace(emailGapRegex.ReplaceAllString(match, ""))
+}
@@ -0,0 +1,114 @@
+package scrubber
+
+import (
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+func TestLenientEmailsMapToOneToken(t *testing.T) {
+	// Spaced and wrapped forms of one address, as JSON log lines and plain text
+	variants := []struct {
+		name string
+		line string
+	}{
+		{"plain address", `{"msg":"contact alice.smith@example.com"}`},
+		{"spaces around @", `{"msg":"contact alice.smith @ example.com"}`},
+		{"space after @", `{"msg":"contact alice.smith@ example.com"}`},
+		{"wrapped before @", `{"msg":"contact alice.smith\n@example.com"}`},
+		{"wrapped in domain", `{"msg":"contact alice.smith@example.\ncom"}`},
+		{"wrapped with indent", `{"msg":"contact alice.smith@example.\r\n  com"}`},
+		{"tabs in plain text", "contact alice.smith\t@\texample.com in plain text"},
+	}
+
+	s := NewScrubber(constants.ScrubLevelHigh, false)
+	s.SetLenientEmails(true)
+
+	var token string
+	for i, tt := range variants {
+		scrubbed, err := s.ScrubLine(tt.line, "test.log")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if strings.Contains(scrubbed, "alice.smith") || strings.Contains(scrubbed, "example") {
+			t.Errorf("%s was not scrubbed: %s", tt.name, scrubbed)
+		}
+		if i == 0 {
+			token = s.emailMap[s.normalizeKey("alice.smith@example.com")]
+		}
+		if token == "" || !strings.Contains(scrubbed, token) {
+			t.Errorf("%s was not mapped to %q: %s", tt.name, token, scrubbed)
+		}
+	}
+
+	if entries := s.AuditEntries(); len(entries) != 1 || entries[0].TimesReplaced != len(variants) {
+		t.Errorf("audit = %+v, want one entry replaced %d times", entries, len(variants))
+	}
+}
+
+func TestLenientEmailsOff(t *testing.T) {
+	s := NewScrubber(constants.ScrubLevelHigh, false)
+	line := `{"msg":"contact alice.smith @ example.com"}`
+	scrubbed, err := s.ScrubLine(line, "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(scrubbed, "alice.smith @ example.com") {
+		t.Errorf("spaced address was changed without --lenient-emails: %s", scrubbed)
+	}
+}
+
+func TestLenientEmailsDontTakeTheNextLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{
+			name: "sentence ending in an email",
+			line: `{"msg":"Please write to bob@example.com."}`,
+			want: `{"msg":"Please write to user1@domain1."}`,
+		},
+		{
+			name: "trailing dot before a capitalized word",
+			line: `{"msg":"Write to bob@example.com.\nThanks for waiting"}`,
+			want: `{"msg":"Write to user1@domain1.\nThanks for waiting"}`,
+		},
+		{
+			name: "trailing dot before a word running on",
+			line: "Write to bob@example.com.\nthanksAgain",
+			want: "Write to user1@domain1.\nthanksAgain",
+		},
+	}
+	for _, tt := range tests {
+		s := NewScrubber(constants.ScrubLevelHigh, false)
+		s.SetLenientEmails(true)
+		got, err := s.ScrubLine(tt.line, "test.log")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s:\n got  %s\n want %s", tt.name, got, tt.want)
+		}
+		if entries := s.AuditEntries(); len(entries) != 1 || entries[0].OriginalValue != "bob@example.com" {
+			t.Errorf("%s: audit = %+v, want bob@example.com alone", tt.name, entries)
+		}
+	}
+}
+
+func TestLenientEmailsKeepStackTraceLines(t *testing.T) {
+	input := "panic: mail to bob@example.\ngoroutine 1 [running]:\nmain.main()\n\t/home/carol/app/main.go:12 +0x1d\n"
+	s := NewScrubber(constants.ScrubLevelHigh, false)
+	s.SetLenientEmails(true)
+	var out strings.Builder
+	if err := s.ScrubStream(strings.NewReader(input), &out, "test.log"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Count(out.String(), "\n"), strings.Count(input, "\n"); got != want {
+		t.Errorf("output has %d lines, want %d:\n%s", got, want, out.String())
+	}
+	if !strings.Contains(out.String(), "\ngoroutine 1 [running]:\n") {
+		t.Errorf("the line after the address was changed:\n%s", out.String())
+	}
+}
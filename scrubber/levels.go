@@ -1,6 +1,8 @@
 package scrubber
 
 import (
+	"fmt"
+	"net"
 	"strings"
 
 	"mattermost-log-scrubber/constants"
@@ -20,20 +22,20 @@ func (s *Scrubber) scrubEmailByLevel(email string) string {
 	case constants.ScrubLevelLow:
 		// Keep last 3 characters of local part
 		if len(localPart) <= 3 {
-			return strings.Repeat("*", len(localPart)) + "@" + domain
+			return s.mask(constants.TypeEmail, len(localPart)) + "@" + domain
 		}
-		masked := strings.Repeat("*", len(localPart)-3) + localPart[len(localPart)-3:]
+		masked := s.mask(constants.TypeEmail, len(localPart)-3) + localPart[len(localPart)-3:]
 		return masked + "@" + domain
 
 	case constants.ScrubLevelMedium:
 		// Mask entire local part
-		masked := strings.Repeat("*", len(localPart))
+		masked := s.mask(constants.TypeEmail, len(localPart))
 		return masked + "@" + domain
 
 	case constants.ScrubLevelHigh:
 		// Mask everything including domain
-		localMasked := strings.Repeat("*", len(localPart))
-		domainMasked := strings.Repeat("*", len(domain))
+		localMasked := s.mask(constants.TypeEmail, len(localPart))
+		domainMasked := s.mask(constants.TypeEmail, len(domain))
 		return localMasked + "@" + domainMasked
 
 	default:
@@ -47,13 +49,13 @@ func (s *Scrubber) scrubUsernameByLevel(username string) string {
 	case constants.ScrubLevelLow:
 		// Keep last 3 characters
 		if len(username) <= 3 {
-			return strings.Repeat("*", len(username))
+			return s.mask(constants.TypeUsername, len(username))
 		}
-		return strings.Repeat("*", len(username)-3) + username[len(username)-3:]
+		return s.mask(constants.TypeUsername, len(username)-3) + username[len(username)-3:]
 
 	case constants.ScrubLevelMedium, constants.ScrubLevelHigh:
 		// Mask entire username
-		return strings.Repeat("*", len(username))
+		return s.mask(constants.TypeUsername, len(username))
 
 	default:
 		return username
@@ -70,17 +72,36 @@ func (s *Scrubber) scrubIPByLevel(ip string) string {
 	switch s.level {
 	case constants.ScrubLevelMedium:
 		// Keep last octet only
-		return "***.***.***." + parts[3]
+		octet := s.mask(constants.TypeIP, 3)
+		return octet + "." + octet + "." + octet + "." + parts[3]
 
 	case constants.ScrubLevelHigh:
 		// Mask entire IP
-		return "***.***.***.***"
+		octet := s.mask(constants.TypeIP, 3)
+		return octet + "." + octet + "." + octet + "." + octet
 
 	default:
 		return ip
 	}
 }
 
+// scrubIPByClass replaces an IP address with a label that keeps whether it was private or public
+// Each distinct address gets a stable per-class ID, e.g. ip_private_1 or ip_public_42
+func (s *Scrubber) scrubIPByClass(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return s.scrubIPByLevel(ip) // Not a valid address, fall back to masking
+	}
+
+	class := "public"
+	if parsed.IsPrivate() || parsed.IsLoopback() || parsed.IsLinkLocalUnicast() || parsed.IsUnspecified() {
+		class = "private"
+	}
+
+	s.ipClassCounter[class]++
+	return fmt.Sprintf("ip_%s_%d", class, s.ipClassCounter[class])
+}
+
 // scrubUIDByLevel scrubs UIDs/Channel IDs/Team IDs based on the scrubbing level (level 3 only)
 func (s *Scrubber) scrubUIDByLevel(uid string) string {
 	if s.level != constants.ScrubLevelHigh {
@@ -89,7 +110,7 @@ func (s *Scrubber) scrubUIDByLevel(uid string) string {
 
 	// For level 3: mask all but last 8 characters, keep total length at 26
 	if len(uid) < constants.UIDKeepChars {
-		return strings.Repeat("*", len(uid))
+		return s.mask(constants.TypeUID, len(uid))
 	}
 
 	lastChars := uid[len(uid)-constants.UIDKeepChars:]
@@ -100,7 +121,7 @@ func (s *Scrubber) scrubUIDByLevel(uid string) string {
 		maskedLength = len(uid) - constants.UIDKeepChars
 	}
 	
-	masked := strings.Repeat("*", maskedLength)
+	masked := s.mask(constants.TypeUID, maskedLength)
 	return masked + lastChars
 }
 
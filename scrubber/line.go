@@ -0,0 +1,47 @@
+package scrubber
+
+import (
+	"errors"
+	"strings"
+)
+
+// ScrubLine scrubs a single log line, for hosts that read logs themselves, e.g. a service tailing
+// Mattermost logs. The line is scrubbed as ProcessFile scrubs each line, honoring every option set on
+// the Scrubber, and mappings and the audit accumulate across calls so a value keeps its token.
+// Multiline stack traces and split container records are only joined by ProcessFile and ScrubStream.
+// An empty line is returned unchanged, and a line the JSON failure action drops returns ErrLineDropped.
+// source is recorded as the source of audit entries. Nothing is printed or written to disk, and the
+// line counts of Stats include every call.
+func (s *Scrubber) ScrubLine(line, source string) (string, error) {
+	s.lineCalls++
+	s.stats.Lines++
+	if strings.TrimSpace(line) == "" {
+		s.stats.LinesEmpty++
+		return line, nil
+	}
+
+	jsonLines, jsonFailures := s.jsonSuccessCount, s.jsonFailureCount
+	scrubbed, err := s.processLogLine(line, source, s.lineCalls)
+	s.stats.JSONLines += s.jsonSuccessCount - jsonLines
+	s.stats.JSONFailures += s.jsonFailureCount - jsonFailures
+	if errors.Is(err, ErrLineDropped) {
+		s.stats.LinesDropped++
+		return "", err
+	}
+	if err != nil {
+		s.stats.LinesFailed++
+		return "", err
+	}
+	s.stats.LinesScrubbed++
+	return scrubbed, nil
+}
+
+// AuditEntries returns copies of the audit entries accumulated so far, in the order of the audit
+// files: by type, then original value. Types left out of the audit by SetNoAuditTypes are skipped.
+func (s *Scrubber) AuditEntries() []AuditEntry {
+	entries := make([]AuditEntry, 0, len(s.auditEntries))
+	for _, entry := range s.sortedAuditEntries() {
+		entries = append(entries, *entry)
+	}
+	return entries
+}
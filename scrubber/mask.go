@@ -0,0 +1,88 @@
+package scrubber
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// maskCharTypes are the scrub types whose replacements can contain mask characters
+var maskCharTypes = []string{
+	constants.TypeEmail,
+	constants.TypeUsername,
+	constants.TypeIP,
+	constants.TypeUID,
+	constants.TypeFQDN,
+	constants.TypeHost,
+	constants.TypeMessage,
+	constants.TypeNationalID,
+	constants.TypeRedact,
+	constants.TypePhone,
+}
+
+// ValidateMaskChar checks that a mask character is a single printable rune that can't break a JSON string.
+// Fixed-width mode also needs a single-byte character, since it preserves byte lengths.
+func ValidateMaskChar(char string, fixedWidth bool) error {
+	if utf8.RuneCountInString(char) != 1 {
+		return fmt.Errorf("mask character '%s' must be a single character", char)
+	}
+	r, _ := utf8.DecodeRuneInString(char)
+	if r == utf8.RuneError || r == '"' || r == '\\' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+		return fmt.Errorf("mask character %q is not allowed", char)
+	}
+	if fixedWidth && len(char) != 1 {
+		return fmt.Errorf("mask character '%s' must be ASCII in fixed-width mode, which preserves byte lengths", char)
+	}
+	return nil
+}
+
+// ValidateMaskChars checks per-type mask characters, keyed by scrub type
+func ValidateMaskChars(maskChars map[string]string, fixedWidth bool) error {
+	for valueType, char := range maskChars {
+		if !isMaskCharType(valueType) {
+			return fmt.Errorf("invalid mask character type '%s' (supported: %s)", valueType, strings.Join(maskCharTypes, ", "))
+		}
+		if err := ValidateMaskChar(char, fixedWidth); err != nil {
+			return fmt.Errorf("%s: %w", valueType, err)
+		}
+	}
+	return nil
+}
+
+// isMaskCharType reports whether valueType accepts its own mask character
+func isMaskCharType(valueType string) bool {
+	for _, known := range maskCharTypes {
+		if valueType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMaskChars sets the character used in masks, globally and per scrub type (e.g. {"ip": "x"})
+// Types without their own character use the global one; an empty global keeps the default "*"
+func (s *Scrubber) SetMaskChars(global string, byType map[string]string) {
+	if global == "" {
+		global = constants.DefaultMaskChar
+	}
+	s.maskChar = global
+	s.maskChars = byType
+}
+
+// mask returns n mask characters for valueType
+func (s *Scrubber) mask(valueType string, n int) string {
+	char := s.maskChars[valueType]
+	if char == "" {
+		char = s.maskChar
+	}
+	return strings.Repeat(char, n)
+}
+
+// maskFixedWidth masks a value with exactly as many bytes as the original
+// Used in fixed-width mode, where mapping consistency is traded for preserving string lengths
+func (s *Scrubber) maskFixedWidth(value, valueType string) string {
+	return s.mask(valueType, len(value))
+}
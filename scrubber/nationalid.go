@@ -0,0 +1,88 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// NationalIDPattern is a user-supplied national ID format, e.g. {"Name": "ca_sin", "Pattern": "\\b\\d{3}-\\d{3}-\\d{3}\\b"}
+// Matches are replaced with "[<name>-redacted]"
+type NationalIDPattern struct {
+	Name    string `json:"Name"`
+	Pattern string `json:"Pattern"`
+}
+
+// nationalIDMatcher is a compiled national ID pattern with an optional check to reject false positives
+type nationalIDMatcher struct {
+	name  string
+	re    *regexp.Regexp
+	valid func(match []string) bool
+}
+
+// ssnRegex matches US Social Security Numbers in the ddd-dd-dddd format
+var ssnRegex = regexp.MustCompile(`\b(\d{3})-(\d{2})-(\d{4})\b`)
+
+// isValidSSN rejects numbers the SSA never issues: area 000, 666 or 900-999, group 00, serial 0000
+func isValidSSN(match []string) bool {
+	area, _ := strconv.Atoi(match[1])
+	group, _ := strconv.Atoi(match[2])
+	serial, _ := strconv.Atoi(match[3])
+	return area != 0 && area != 666 && area < 900 && group != 0 && serial != 0
+}
+
+// defaultNationalIDMatchers are always applied; config patterns are added after them
+var defaultNationalIDMatchers = []nationalIDMatcher{
+	{name: "ssn", re: ssnRegex, valid: isValidSSN},
+}
+
+// SetNationalIDPatterns adds national ID formats to detect alongside the built-in US SSN check
+func (s *Scrubber) SetNationalIDPatterns(patterns []NationalIDPattern) error {
+	matchers, err := compileNationalIDPatterns(patterns)
+	if err != nil {
+		return err
+	}
+	s.nationalIDMatchers = matchers
+	return nil
+}
+
+// compileNationalIDPatterns compiles configured national ID formats after the built-in ones,
+// naming the failing entry by position and name
+func compileNationalIDPatterns(patterns []NationalIDPattern) ([]nationalIDMatcher, error) {
+	matchers := append([]nationalIDMatcher{}, defaultNationalIDMatchers...)
+	for i, pattern := range patterns {
+		name := strings.TrimSpace(pattern.Name)
+		if name == "" {
+			return nil, fmt.Errorf("national ID pattern #%d '%s' has no name", i+1, pattern.Pattern)
+		}
+		re, err := regexp.Compile(pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid national ID pattern #%d '%s' (%s): %w", i+1, name, pattern.Pattern, err)
+		}
+		matchers = append(matchers, nationalIDMatcher{name: name, re: re})
+	}
+	return matchers, nil
+}
+
+// scrubNationalIDs redacts SSNs and configured national IDs (all levels)
+func (s *Scrubber) scrubNationalIDs(text, source string) string {
+	result := text
+	for _, matcher := range s.nationalIDMatchers {
+		result = replaceAllStringFunc(matcher.re, result, func(match string) string {
+			if matcher.valid != nil && !matcher.valid(matcher.re.FindStringSubmatch(match)) {
+				return match
+			}
+
+			scrubbed := "[" + matcher.name + "-redacted]"
+			if s.fixedWidth {
+				scrubbed = s.maskFixedWidth(match, constants.TypeNationalID)
+			}
+			s.trackReplacement(match, scrubbed, constants.TypeNationalID, source)
+			return scrubbed
+		})
+	}
+	return result
+}
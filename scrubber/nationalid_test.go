@@ -0,0 +1,82 @@
+package scrubber
+
+import (
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+func TestSSNsVersusRandomDigitRuns(t *testing.T) {
+	tests := []struct {
+		text   string
+		redact bool
+	}{
+		{"ssn 123-45-6789 on file", true},
+		{"ssn 078-05-1120 on file", true},
+		{"ssn 899-99-9999 on file", true},
+		{"area 000 in 000-12-3456", false},
+		{"area 666 in 666-12-3456", false},
+		{"area 9xx in 912-34-5678", false},
+		{"group 00 in 123-00-4567", false},
+		{"serial 0000 in 123-45-0000", false},
+		{"longer run 1234-56-7890", false},
+		{"phone 555-123-4567", false},
+		{"build 20240115-12-3456", false},
+		{"no dashes 123456789", false},
+	}
+
+	for _, tt := range tests {
+		s := NewScrubber(1, false)
+		got, err := s.ScrubLine(tt.text, "test.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if redacted := strings.Contains(got, "[ssn-redacted]"); redacted != tt.redact {
+			t.Errorf("%q -> %q, redacted = %v, want %v", tt.text, got, redacted, tt.redact)
+		}
+		if tt.redact {
+			entries := s.AuditEntries()
+			if len(entries) != 1 || entries[0].Type != constants.TypeNationalID {
+				t.Errorf("%q audit = %+v, want one %s entry", tt.text, entries, constants.TypeNationalID)
+			}
+		}
+	}
+}
+
+func TestConfiguredNationalIDPatterns(t *testing.T) {
+	s := NewScrubber(1, false)
+	if err := s.SetNationalIDPatterns([]NationalIDPattern{{Name: "ca_sin", Pattern: `\b\d{3} \d{3} \d{3}\b`}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.ScrubLine(`{"msg":"sin 046 454 286, ssn 123-45-6789"}`, "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"msg":"sin [ca_sin-redacted], ssn [ssn-redacted]"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestNationalIDPatternErrors(t *testing.T) {
+	tests := []struct {
+		pattern NationalIDPattern
+		wantErr string
+	}{
+		{NationalIDPattern{Name: "uk_nino", Pattern: `[A-Z]{2}\d{6}[A-D]`}, ""},
+		{NationalIDPattern{Name: "", Pattern: `\d{9}`}, "has no name"},
+		{NationalIDPattern{Name: "broken", Pattern: `(\d{3}`}, "invalid national ID pattern #1 'broken'"},
+	}
+	for _, tt := range tests {
+		_, err := compileNationalIDPatterns([]NationalIDPattern{tt.pattern})
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%+v: unexpected error %v", tt.pattern, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%+v: err = %v, want %q", tt.pattern, err, tt.wantErr)
+		}
+	}
+}
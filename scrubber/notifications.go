@@ -0,0 +1,70 @@
+package scrubber
+
+import (
+	"fmt"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// notificationFieldTypes maps push-notification payload fields in notifications.log to scrub types
+// Message previews are redacted outright; sender and recipient identifiers reuse the username and UID scrubbers
+var notificationFieldTypes = map[string]string{
+	"message":           constants.TypeMessage,
+	"sender_name":       constants.TypeUsername,
+	"override_username": constants.TypeUsername,
+	"sender_id":         constants.TypeUID,
+	"user_id":           constants.TypeUID,
+	"recipient_id":      constants.TypeUID,
+	"device_id":         constants.TypeUID,
+}
+
+// SetLogKind selects field handling for a specific Mattermost log: constants.LogKindApp,
+// constants.LogKindNotifications, or constants.LogKindAuto (default) to detect notification lines
+func (s *Scrubber) SetLogKind(kind string) {
+	if kind == "" {
+		kind = constants.LogKindAuto
+	}
+	s.logKind = kind
+}
+
+// isNotificationLine reports whether a JSON line should get notification payload handling
+// Auto-detection matches a notifications.log input or a line whose logSource is "notifications"
+func (s *Scrubber) isNotificationLine(root *jsonValue, source string) bool {
+	switch s.logKind {
+	case constants.LogKindNotifications:
+		return true
+	case constants.LogKindApp:
+		return false
+	}
+
+	if strings.HasPrefix(strings.ToLower(source), "notifications") {
+		return true
+	}
+	for _, key := range []string{"logSource", "log_source"} {
+		if value := root.field(key); value != nil && value.kind == jsonStringKind && strings.EqualFold(value.str, "notifications") {
+			return true
+		}
+	}
+	return false
+}
+
+// mayBeNotificationLine is a cheap pre-check so app logs aren't decoded twice during auto-detection
+func (s *Scrubber) mayBeNotificationLine(line, source string) bool {
+	switch s.logKind {
+	case constants.LogKindNotifications:
+		return true
+	case constants.LogKindApp:
+		return false
+	}
+	return strings.HasPrefix(strings.ToLower(source), "notifications") || strings.Contains(line, `"notifications"`)
+}
+
+// scrubMessageValue redacts a message body, keeping only its length
+// Message text is never written to the audit file
+func (s *Scrubber) scrubMessageValue(message string) string {
+	if s.fixedWidth {
+		return s.maskFixedWidth(message, constants.TypeMessage)
+	}
+	return fmt.Sprintf("[message redacted, %d chars]", len([]rune(message)))
+}
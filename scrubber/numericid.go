@@ -0,0 +1,49 @@
+package scrubber
+
+import (
+	"math/big"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// isIntegerID reports whether a value is a non-negative integer written as plain digits
+func isIntegerID(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// scrubNumericID returns a consistent synthetic number for an integer ID, such as a numeric user_id
+// The synthetic ID keeps the original's digit count, counting up from the smallest number of that
+// length (4821 -> 1000, the next 4-digit ID -> 1001), so it stays a valid ID and fixed-width output
+// keeps its byte lengths. Values that aren't plain integers (decimals, negatives) are left unchanged.
+func (s *Scrubber) scrubNumericID(id, source string) string {
+	if !isIntegerID(id) {
+		return id
+	}
+	if scrubbed, exists := s.numericIDMap[id]; exists {
+		s.trackReplacement(id, scrubbed, constants.TypeNumericID, source)
+		return scrubbed
+	}
+
+	// Each digit count has room for every distinct ID of that length, so synthetic IDs never collide
+	digits := len(id)
+	synthetic := big.NewInt(int64(s.numericIDCounter[digits]))
+	if digits > 1 {
+		base, _ := new(big.Int).SetString("1"+strings.Repeat("0", digits-1), 10)
+		synthetic.Add(synthetic, base)
+	}
+	s.numericIDCounter[digits]++
+
+	scrubbed := synthetic.String()
+	s.numericIDMap[id] = scrubbed
+	s.trackReplacement(id, scrubbed, constants.TypeNumericID, source)
+	return scrubbed
+}
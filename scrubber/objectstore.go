@@ -0,0 +1,407 @@
+package scrubber
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// objectPartSize is the size of each part of a multipart upload; S3 requires at least 5MB
+// Objects smaller than one part are written with a single PUT
+const objectPartSize = 8 * 1024 * 1024
+
+// IsObjectStoreURI reports whether an output or audit path refers to an object in an S3-compatible bucket (s3://bucket/key)
+func IsObjectStoreURI(path string) bool {
+	return strings.HasPrefix(path, constants.ObjectStoreScheme)
+}
+
+// JoinArtifactPath joins an output directory and a file name, as a key prefix for s3:// directories
+func JoinArtifactPath(dir, name string) string {
+	if IsObjectStoreURI(dir) {
+		return strings.TrimSuffix(dir, "/") + "/" + name
+	}
+	return filepath.Join(dir, name)
+}
+
+// siblingPath returns the path of a file named name in the same directory (or key prefix) as path
+func siblingPath(path, name string) string {
+	if IsObjectStoreURI(path) {
+		return path[:strings.LastIndex(path, "/")+1] + name
+	}
+	return filepath.Join(filepath.Dir(path), name)
+}
+
+// PathExists reports whether a local file, or an object at an s3:// URI, already exists
+// An object that can't be checked returns an error
+func PathExists(path string) (bool, error) {
+	return checkFileExists(path)
+}
+
+// objectStore is a minimal client for the S3 API, signing requests with AWS Signature Version 4
+// With a custom endpoint, buckets are addressed by path (endpoint/bucket/key) as most
+// S3-compatible services expect; otherwise AWS virtual-hosted addressing is used
+type objectStore struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+	endpoint     *url.URL // nil for AWS
+	client       *http.Client
+}
+
+// newObjectStoreFromEnv configures the client from the standard AWS environment variables
+func newObjectStoreFromEnv() (*objectStore, error) {
+	store := &objectStore{
+		accessKey:    os.Getenv(constants.EnvS3AccessKey),
+		secretKey:    os.Getenv(constants.EnvS3SecretKey),
+		sessionToken: os.Getenv(constants.EnvS3SessionToken),
+		region:       os.Getenv(constants.EnvS3Region),
+		client:       &http.Client{Timeout: 10 * time.Minute},
+	}
+	if store.accessKey == "" || store.secretKey == "" {
+		return nil, fmt.Errorf("s3:// paths need credentials in %s and %s", constants.EnvS3AccessKey, constants.EnvS3SecretKey)
+	}
+	if store.region == "" {
+		store.region = os.Getenv(constants.EnvS3DefaultRegion)
+	}
+	if store.region == "" {
+		store.region = constants.DefaultS3Region
+	}
+
+	endpoint := os.Getenv(constants.EnvS3Endpoint)
+	if endpoint == "" {
+		endpoint = os.Getenv(constants.EnvEndpoint)
+	}
+	if endpoint != "" {
+		parsed, err := url.Parse(endpoint)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return nil, fmt.Errorf("invalid object store endpoint '%s': must be an http:// or https:// URL", endpoint)
+		}
+		store.endpoint = parsed
+	}
+	return store, nil
+}
+
+// ValidateObjectStore checks that the object store can be used for s3:// output and audit paths
+func ValidateObjectStore() error {
+	_, err := newObjectStoreFromEnv()
+	return err
+}
+
+// parseObjectURI splits s3://bucket/key into the bucket and key
+func parseObjectURI(uri string) (string, string, error) {
+	rest := strings.TrimPrefix(uri, constants.ObjectStoreScheme)
+	bucket, key, found := strings.Cut(rest, "/")
+	if !found || bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		return "", "", fmt.Errorf("invalid object store path '%s': expected s3://bucket/key", uri)
+	}
+	return bucket, key, nil
+}
+
+// objectURL returns the request URL of an object, with an optional query string
+func (o *objectStore) objectURL(bucket, key, query string) *url.URL {
+	u := &url.URL{Scheme: "https", Host: bucket + ".s3." + o.region + ".amazonaws.com", Path: "/" + key}
+	if o.endpoint != nil {
+		u = &url.URL{Scheme: o.endpoint.Scheme, Host: o.endpoint.Host, Path: strings.TrimSuffix(o.endpoint.Path, "/") + "/" + bucket + "/" + key}
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = query
+	return u
+}
+
+// do sends a signed request and returns the response with its body read
+func (o *objectStore) do(method string, u *url.URL, body []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	payloadHash := sha256.Sum256(body)
+	o.sign(req, hex.EncodeToString(payloadHash[:]), time.Now().UTC())
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, respBody, nil
+}
+
+// sign adds the Signature Version 4 authorization to a request, covering the host and every header already set
+func (o *objectStore) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if o.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", o.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + o.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+o.secretKey), date)
+	for _, part := range []string{o.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", o.accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes everything but unreserved characters, and '/' too when keepSlash is set
+func s3Escape(value string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3EscapePath encodes an object path as Signature Version 4 expects, keeping the '/' separators
+func s3EscapePath(path string) string {
+	return s3Escape(path, true)
+}
+
+// s3CanonicalQuery returns the query parameters sorted and encoded for signing
+func s3CanonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(name, false)+"="+s3Escape(value, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// objectStoreError returns an error for a failed request, with the S3 error code and message when given
+func objectStoreError(action string, resp *http.Response, body []byte) error {
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+		return fmt.Errorf("%s: %s (%s: %s)", action, resp.Status, s3Err.Code, s3Err.Message)
+	}
+	return fmt.Errorf("%s: %s", action, resp.Status)
+}
+
+// objectExists reports whether an object exists, with a HEAD request
+func objectExists(uri string) (bool, error) {
+	store, err := newObjectStoreFromEnv()
+	if err != nil {
+		return false, err
+	}
+	bucket, key, err := parseObjectURI(uri)
+	if err != nil {
+		return false, err
+	}
+	resp, body, err := store.do(http.MethodHead, store.objectURL(bucket, key, ""), nil)
+	if err != nil {
+		return false, fmt.Errorf("checking '%s': %w", uri, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, objectStoreError("checking '"+uri+"'", resp, body)
+}
+
+// objectWriter streams data to an object: the first part is buffered, and anything larger than one
+// part becomes a multipart upload so the whole object is never held in memory. The object only
+// appears in the bucket once Close succeeds; a failed upload is aborted.
+type objectWriter struct {
+	store    *objectStore
+	uri      string
+	bucket   string
+	key      string
+	buf      bytes.Buffer
+	uploadID string
+	etags    []string
+	err      error // First failure; later writes and Close return it
+}
+
+// createObject opens a writer for the object at an s3:// URI
+func createObject(uri string) (*objectWriter, error) {
+	store, err := newObjectStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	bucket, key, err := parseObjectURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return &objectWriter{store: store, uri: uri, bucket: bucket, key: key}, nil
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buf.Write(p)
+	for w.buf.Len() >= objectPartSize {
+		if err := w.uploadPart(w.buf.Next(objectPartSize)); err != nil {
+			w.fail(err)
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close writes the buffered data and completes the object
+func (w *objectWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.uploadID == "" {
+		resp, body, err := w.store.do(http.MethodPut, w.store.objectURL(w.bucket, w.key, ""), w.buf.Bytes())
+		if err != nil {
+			return w.fail(fmt.Errorf("writing '%s': %w", w.uri, err))
+		}
+		if resp.StatusCode != http.StatusOK {
+			return w.fail(objectStoreError("writing '"+w.uri+"'", resp, body))
+		}
+		w.err = fmt.Errorf("object '%s' is already closed", w.uri)
+		return nil
+	}
+
+	if w.buf.Len() > 0 {
+		if err := w.uploadPart(w.buf.Bytes()); err != nil {
+			return w.fail(err)
+		}
+	}
+	var complete bytes.Buffer
+	complete.WriteString("<CompleteMultipartUpload>")
+	for i, etag := range w.etags {
+		fmt.Fprintf(&complete, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag></Part>", i+1, xmlEscape(etag))
+	}
+	complete.WriteString("</CompleteMultipartUpload>")
+
+	query := "uploadId=" + s3Escape(w.uploadID, false)
+	resp, body, err := w.store.do(http.MethodPost, w.store.objectURL(w.bucket, w.key, query), complete.Bytes())
+	if err != nil {
+		return w.fail(fmt.Errorf("completing '%s': %w", w.uri, err))
+	}
+	// S3 may report a failed completion with a 200 status and an error document
+	if resp.StatusCode != http.StatusOK || bytes.Contains(body, []byte("<Error>")) {
+		return w.fail(objectStoreError("completing '"+w.uri+"'", resp, body))
+	}
+	w.err = fmt.Errorf("object '%s' is already closed", w.uri)
+	return nil
+}
+
+// uploadPart uploads the next part, starting the multipart upload first if needed
+func (w *objectWriter) uploadPart(data []byte) error {
+	if w.uploadID == "" {
+		resp, body, err := w.store.do(http.MethodPost, w.store.objectURL(w.bucket, w.key, "uploads="), nil)
+		if err != nil {
+			return fmt.Errorf("starting upload of '%s': %w", w.uri, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return objectStoreError("starting upload of '"+w.uri+"'", resp, body)
+		}
+		var result struct {
+			UploadID string `xml:"UploadId"`
+		}
+		if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+			return fmt.Errorf("starting upload of '%s': no upload ID in the response", w.uri)
+		}
+		w.uploadID = result.UploadID
+	}
+
+	query := fmt.Sprintf("partNumber=%d&uploadId=%s", len(w.etags)+1, s3Escape(w.uploadID, false))
+	resp, body, err := w.store.do(http.MethodPut, w.store.objectURL(w.bucket, w.key, query), data)
+	if err != nil {
+		return fmt.Errorf("uploading part %d of '%s': %w", len(w.etags)+1, w.uri, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return objectStoreError(fmt.Sprintf("uploading part %d of '%s'", len(w.etags)+1, w.uri), resp, body)
+	}
+	w.etags = append(w.etags, resp.Header.Get("ETag"))
+	return nil
+}
+
+// fail records the first error and aborts the multipart upload, so no partial object is left behind
+func (w *objectWriter) fail(err error) error {
+	if w.err == nil {
+		w.err = err
+		if w.uploadID != "" {
+			w.store.do(http.MethodDelete, w.store.objectURL(w.bucket, w.key, "uploadId="+s3Escape(w.uploadID, false)), nil)
+		}
+	}
+	return w.err
+}
+
+// xmlEscape escapes a value for an XML element
+func xmlEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+// createArtifact creates a local file, or an object for an s3:// URI, to write an artifact to
+func createArtifact(path string) (io.WriteCloser, error) {
+	if IsObjectStoreURI(path) {
+		return createObject(path)
+	}
+	return os.Create(path)
+}
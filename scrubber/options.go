@@ -0,0 +1,109 @@
+package scrubber
+
+import (
+	"io"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// SetFixedWidth enables fixed-width mode, where every replacement is a mask with the
+// same byte length as the original value. Mapped tokens (userN, domainN, hostN) are not
+// used in this mode, so the same person or host can no longer be correlated across lines.
+func (s *Scrubber) SetFixedWidth(enabled bool) {
+	s.fixedWidth = enabled
+}
+
+// SetMessageOutput sets where progress, warnings and summaries are printed (default: standard output)
+// The CLI uses standard error when the scrubbed log itself goes to standard output, so they never mix
+func (s *Scrubber) SetMessageOutput(w io.Writer) {
+	s.messages = w
+}
+
+// SetMaxInputSize sets the cumulative byte limit applied to streamed input such as Unix sockets.
+// Regular files are checked against the limit before processing starts.
+func (s *Scrubber) SetMaxInputSize(limit int64) {
+	s.maxInputSize = limit
+}
+
+// SetSkipOutput makes ProcessFile perform full scrubbing and mapping without writing the scrubbed log,
+// so only the audit and other reports are produced
+func (s *Scrubber) SetSkipOutput(enabled bool) {
+	s.skipOutput = enabled
+}
+
+// SetIPStrategy selects how IP addresses are replaced: constants.IPStrategyMask (default)
+// masks octets by level, constants.IPStrategyClass uses stable ip_private_N / ip_public_N labels
+func (s *Scrubber) SetIPStrategy(strategy string) {
+	if strategy == "" {
+		strategy = constants.IPStrategyMask
+	}
+	s.ipStrategy = strategy
+}
+
+// SetJSONFailureAction controls lines that fail JSON parsing: constants.JSONFailureScrub (default) scrubs
+// them as plain text, constants.JSONFailureDrop omits them, constants.JSONFailureRedact replaces them
+// with constants.RedactedLineMarker. Drop and redact also apply to multiline stack traces.
+func (s *Scrubber) SetJSONFailureAction(action string) {
+	if action == "" {
+		action = constants.JSONFailureScrub
+	}
+	s.jsonFailureAction = action
+}
+
+// SetRenameScheme selects how renamed files are suffixed: constants.RenameSchemeTimestamp (default)
+// or constants.RenameSchemeSequential
+func (s *Scrubber) SetRenameScheme(scheme string) {
+	if scheme == "" {
+		scheme = constants.RenameSchemeTimestamp
+	}
+	s.renameScheme = scheme
+}
+
+// SetFailOnEmpty makes ProcessFile return an error when the input has no non-empty lines
+func (s *Scrubber) SetFailOnEmpty(enabled bool) {
+	s.failOnEmpty = enabled
+}
+
+// SetCancelScope controls what a cancelled file conflict does: constants.CancelScopeRun (default)
+// aborts the run, constants.CancelScopeFile skips only that artifact and returns ErrArtifactSkipped for it
+func (s *Scrubber) SetCancelScope(scope string) {
+	if scope == "" {
+		scope = constants.CancelScopeRun
+	}
+	s.cancelScope = scope
+}
+
+// SetCanonicalJSON makes JSON lines be re-marshaled with sorted keys and compact formatting,
+// so repeated runs produce diff-friendly output. This changes key order from the original log.
+func (s *Scrubber) SetCanonicalJSON(enabled bool) {
+	s.canonicalJSON = enabled
+}
+
+// canonicalOutput returns a scrubbed JSON line in canonical form when enabled
+func (s *Scrubber) canonicalOutput(scrubbedJSON string) string {
+	if !s.canonicalJSON {
+		return scrubbedJSON
+	}
+	canonical, err := canonicalizeJSON(scrubbedJSON)
+	if err != nil {
+		return scrubbedJSON
+	}
+	return canonical
+}
+
+// SetChecksums makes ProcessFile compute SHA-256 checksums of the input and output as they stream through
+func (s *Scrubber) SetChecksums(enabled bool) {
+	s.checksums = enabled
+}
+
+// Checksums returns the hex SHA-256 of the input and output from the last ProcessFile run.
+// Values are empty when checksums are disabled or nothing was written.
+func (s *Scrubber) Checksums() (input, output string) {
+	return s.inputChecksum, s.outputChecksum
+}
+
+// SetPlainTextInput makes every line be scrubbed as plain text, for logs known not to be JSON.
+// JSON parsing is never attempted, so no line counts as a JSON failure and none is dropped or redacted.
+func (s *Scrubber) SetPlainTextInput(enabled bool) {
+	s.plainTextInput = enabled
+}
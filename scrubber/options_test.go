@@ -0,0 +1,107 @@
+package scrubber
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+func TestFixedWidthCreatesNoMappings(t *testing.T) {
+	s := NewScrubber(2, false)
+	s.SetFixedWidth(true)
+	s.SetIPStrategy(constants.IPStrategyClass)
+
+	line := `alice@corp.com logged in from 10.0.0.5`
+	scrubbed, err := s.ScrubLine(line, "test.log")
+	if err != nil {
+		t.Fatalf("ScrubLine: %v", err)
+	}
+	if len(scrubbed) != len(line) {
+		t.Errorf("length changed from %d to %d: %s", len(line), len(scrubbed), scrubbed)
+	}
+	if len(s.userMappings) != 0 || s.userCounter != 0 || s.domainCounter != 0 {
+		t.Errorf("email created mappings: %d users, user counter %d, domain counter %d", len(s.userMappings), s.userCounter, s.domainCounter)
+	}
+	if len(s.ipClassCounter) != 0 {
+		t.Errorf("IP advanced class counters: %v", s.ipClassCounter)
+	}
+}
+
+func TestMessageOutput(t *testing.T) {
+	input := writeTestInput(t, "mattermost.log", `{"user":"alice","email":"alice@example.com"}`+"\n")
+	s := NewScrubber(1, false)
+	var messages bytes.Buffer
+	s.SetMessageOutput(&messages)
+	if _, err := s.ProcessFile(input, filepath.Join(t.TempDir(), "out.log"), false, false, constants.OverwriteOverwrite); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(messages.String(), "Processed 1 lines out of 1 total lines") {
+		t.Errorf("summary not written to the message output:\n%s", messages.String())
+	}
+}
+
+// plainLogLines returns n distinct plain-text log lines, a fifth of which hold an email and IP
+func plainLogLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		if i%5 == 0 {
+			lines[i] = fmt.Sprintf("2026-01-15 10:04:%02d INFO login by user%d@example.com from 10.0.%d.%d", i%60, i, i%250, i%200)
+			continue
+		}
+		lines[i] = fmt.Sprintf("2026-01-15 10:04:%02d DEBUG worker %d finished job %d in %dms", i%60, i%16, i, i%900)
+	}
+	return lines
+}
+
+func TestPlainTextInputMatchesAutoDetect(t *testing.T) {
+	auto := NewScrubber(2, false)
+	plain := NewScrubber(2, false)
+	plain.SetPlainTextInput(true)
+
+	for _, line := range plainLogLines(50) {
+		want, err := auto.ScrubLine(line, "test.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := plain.ScrubLine(line, "test.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%q: plain input gave %q, auto-detect %q", line, got, want)
+		}
+	}
+	if plain.jsonFailureCount != 0 || plain.jsonSuccessCount != 0 {
+		t.Errorf("plain input counted JSON lines: %d parsed, %d failed", plain.jsonSuccessCount, plain.jsonFailureCount)
+	}
+}
+
+// BenchmarkPlainTextInput compares scrubbing a plain-text log with JSON auto-detection, which
+// attempts and fails a parse on every line, against --input-format plain
+func BenchmarkPlainTextInput(b *testing.B) {
+	lines := plainLogLines(1000)
+	for _, mode := range []struct {
+		name  string
+		plain bool
+	}{
+		{"auto-detect", false},
+		{"plain", true},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s := NewScrubber(2, false)
+				s.SetPlainTextInput(mode.plain)
+				for _, line := range lines {
+					if _, err := s.ScrubLine(line, "bench.log"); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
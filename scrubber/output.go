@@ -0,0 +1,204 @@
+package scrubber
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// outputPart is one scrubbed output file, optionally gzip-compressed and hashed as it is written
+type outputPart struct {
+	path   string
+	file   io.WriteCloser // Local file or object store upload
+	gzip   *gzip.Writer
+	hash   hash.Hash
+	writer io.Writer
+	size   int64 // Uncompressed bytes written
+}
+
+// createOutputPart creates a part file; the checksum covers the bytes that reach disk (after compression)
+func createOutputPart(path string, compress, checksum bool) (*outputPart, error) {
+	var file io.WriteCloser
+	if IsStdio(path) {
+		file = stdoutWriter{bufio.NewWriter(os.Stdout)}
+	} else {
+		var err error
+		file, err = createArtifact(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output file: %w", err)
+		}
+	}
+
+	part := &outputPart{path: path, file: file}
+	var fileWriter io.Writer = file
+	if checksum {
+		part.hash = sha256.New()
+		fileWriter = io.MultiWriter(file, part.hash)
+	}
+	part.writer = fileWriter
+	if compress {
+		part.gzip = gzip.NewWriter(fileWriter)
+		part.writer = part.gzip
+	}
+	return part, nil
+}
+
+// stdoutWriter buffers the scrubbed log on its way to standard output, which stays open when the
+// part is closed
+type stdoutWriter struct {
+	*bufio.Writer
+}
+
+func (w stdoutWriter) Close() error {
+	return w.Flush()
+}
+
+func (p *outputPart) write(data string) error {
+	n, err := io.WriteString(p.writer, data)
+	p.size += int64(n)
+	return err
+}
+
+// close flushes the gzip trailer and closes the file, which completes the upload of an object
+func (p *outputPart) close() error {
+	if p.gzip != nil {
+		if err := p.gzip.Close(); err != nil {
+			p.file.Close()
+			return fmt.Errorf("failed to finish compressed output: %w", err)
+		}
+	}
+	if err := p.file.Close(); err != nil {
+		return fmt.Errorf("failed to finish output file: %w", err)
+	}
+	return nil
+}
+
+// checksum returns the hex SHA-256 of the part, or "" when checksums are disabled
+func (p *outputPart) checksum() string {
+	if p.hash == nil {
+		return ""
+	}
+	return hex.EncodeToString(p.hash.Sum(nil))
+}
+
+// logOutput writes scrubbed lines to a single file, or across numbered parts when a split size is set
+type logOutput struct {
+	s               *Scrubber
+	path            string
+	compress        bool
+	overwriteAction string
+	current         *outputPart
+	parts           []*outputPart
+}
+
+// openLogOutput resolves conflicts for and creates the first output file
+// Returns ErrArtifactSkipped when the user skipped the output with a file-level cancel scope
+func (s *Scrubber) openLogOutput(path string, compress bool, overwriteAction string) (*logOutput, error) {
+	out := &logOutput{s: s, path: path, compress: compress, overwriteAction: overwriteAction}
+	if err := out.nextPart(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// splitPartPath returns the numbered path for a split part, keeping a .gz extension last
+// e.g. out.log -> out.log.001, out.log.gz -> out.log.001.gz
+func splitPartPath(path string, number int) string {
+	suffix := fmt.Sprintf(".%03d", number)
+	if strings.HasSuffix(path, constants.ExtGZ) {
+		return strings.TrimSuffix(path, constants.ExtGZ) + suffix + constants.ExtGZ
+	}
+	return path + suffix
+}
+
+// nextPart closes the current part and opens the next one
+func (o *logOutput) nextPart() error {
+	if o.current != nil {
+		if err := o.current.close(); err != nil {
+			return err
+		}
+	}
+
+	path := o.path
+	if o.s.splitSize > 0 {
+		path = splitPartPath(o.path, len(o.parts)+1)
+	}
+
+	// Standard output has nothing to conflict with
+	finalPath, err := path, error(nil)
+	if !IsStdio(path) {
+		finalPath, err = o.s.resolveFileConflict(path, o.overwriteAction, "Output")
+	}
+	if err != nil {
+		if len(o.parts) > 0 && errors.Is(err, ErrArtifactSkipped) {
+			return fmt.Errorf("output part '%s' cannot be skipped once earlier parts are written", path)
+		}
+		return err
+	}
+
+	part, err := createOutputPart(finalPath, o.compress, o.s.checksums)
+	if err != nil {
+		return err
+	}
+	o.current = part
+	o.parts = append(o.parts, part)
+	return nil
+}
+
+// writeLine writes a line, starting a new part first if it would push the current one past the split size
+// A single line longer than the split size gets a part of its own
+func (o *logOutput) writeLine(line string) error {
+	data := line + "\n"
+	if o.s.splitSize > 0 && o.current.size > 0 && o.current.size+int64(len(data)) > o.s.splitSize {
+		if err := o.nextPart(); err != nil {
+			return err
+		}
+	}
+	if err := o.current.write(data); err != nil {
+		return fmt.Errorf("failed to write to output file: %w", err)
+	}
+	return nil
+}
+
+// close finishes the last part
+func (o *logOutput) close() error {
+	if o.current == nil {
+		return nil
+	}
+	err := o.current.close()
+	o.current = nil
+	return err
+}
+
+// abort closes an output left unfinished by an error; an object upload is cancelled rather than
+// completed, so a truncated log never appears in the bucket
+func (o *logOutput) abort() {
+	if o.current == nil {
+		return
+	}
+	if object, ok := o.current.file.(*objectWriter); ok {
+		object.fail(errors.New("output aborted"))
+	}
+	o.current.file.Close()
+	o.current = nil
+}
+
+// SetSplitSize makes ProcessFile write the scrubbed log across numbered parts (out.001, out.002, ...)
+// of at most limit uncompressed bytes each, split on line boundaries. Zero writes a single file.
+func (s *Scrubber) SetSplitSize(limit int64) {
+	s.splitSize = limit
+}
+
+// OutputParts returns the paths of every scrubbed output file written by the last ProcessFile run
+func (s *Scrubber) OutputParts() []string {
+	return s.outputParts
+}
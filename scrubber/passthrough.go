@@ -0,0 +1,74 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SetPassthroughFields sets the field names whose values are never changed, matched case-insensitively
+// wherever they appear in a JSON document. Their values are set aside before any scrubbing, so they
+// win over scrub paths, field types and every pattern detector. In plain-text lines, values written as
+// name=value, name: value or "name":"value" are kept on a best-effort basis.
+func (s *Scrubber) SetPassthroughFields(fields []string) error {
+	s.passthroughFields = nil
+	s.passthroughTextRegex = nil
+	if len(fields) == 0 {
+		return nil
+	}
+
+	s.passthroughFields = make(map[string]bool, len(fields))
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			return fmt.Errorf("invalid passthrough field: field name is empty")
+		}
+		s.passthroughFields[field] = true
+		names = append(names, regexp.QuoteMeta(field))
+	}
+	s.passthroughTextRegex = regexp.MustCompile(`(?i)(?:^|[^\w.-])"?(?:` + strings.Join(names, "|") + `)"?\s*[=:]\s*("(?:[^"\\]|\\.)*"|[^\s,;"}\]]+)`)
+	return nil
+}
+
+// collectPassthroughValues walks the document and returns the values of passthrough fields
+// A passthrough object or array is returned whole, so nothing inside it is scrubbed either
+func collectPassthroughValues(value *jsonValue, fields map[string]bool, values []*jsonValue) []*jsonValue {
+	switch value.kind {
+	case jsonObjectKind:
+		for _, f := range value.fields {
+			if fields[strings.ToLower(f.key)] {
+				values = append(values, f.value)
+				continue
+			}
+			values = collectPassthroughValues(f.value, fields, values)
+		}
+	case jsonArrayKind:
+		for _, item := range value.items {
+			values = collectPassthroughValues(item, fields, values)
+		}
+	}
+	return values
+}
+
+// protectPassthroughText sets aside the values of passthrough fields written in plain text
+func (s *Scrubber) protectPassthroughText(text string, spans *protectedSpans) string {
+	if s.passthroughTextRegex == nil {
+		return text
+	}
+	matches := s.passthroughTextRegex.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[2], match[3]
+		builder.WriteString(text[last:start])
+		builder.WriteString(spans.add(text[start:end]))
+		last = end
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
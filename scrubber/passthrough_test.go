@@ -0,0 +1,73 @@
+package scrubber
+
+import (
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// passthroughTraceID is a trace ID the UID detector would mask if its field weren't passed through
+const passthroughTraceID = "4f9c2a7b8e1d4c3fa0b6e5d7c8a9b0c1"
+
+func TestPassthroughFieldsSurvive(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		keep     []string // Text that must be in the output verbatim
+		scrubbed []string // Text that must be gone
+	}{
+		{
+			name:     "top-level fields next to scrubbed values",
+			line:     `{"level":"info","trace_id":"` + passthroughTraceID + `","user_id":"` + passthroughTraceID + `","email":"bob@example.com"}`,
+			keep:     []string{`"level":"info"`, `"trace_id":"` + passthroughTraceID + `"`},
+			scrubbed: []string{`"user_id":"` + passthroughTraceID + `"`, "bob@example.com"},
+		},
+		{
+			name:     "nested field matched case-insensitively",
+			line:     `{"msg":"call","request":{"Trace_ID":"` + passthroughTraceID + `","ip":"10.20.30.40"}}`,
+			keep:     []string{`"Trace_ID":"` + passthroughTraceID + `"`},
+			scrubbed: []string{"10.20.30.40"},
+		},
+		{
+			name:     "value a detector would match",
+			line:     `{"level":"bob@example.com","email":"bob@example.com"}`,
+			keep:     []string{`"level":"bob@example.com"`},
+			scrubbed: []string{`"email":"bob@example.com"`},
+		},
+		{
+			name:     "name=value in plain text",
+			line:     `level=warn trace_id=` + passthroughTraceID + ` ip=10.20.30.40 mail=bob@example.com`,
+			keep:     []string{"level=warn", "trace_id=" + passthroughTraceID},
+			scrubbed: []string{"10.20.30.40", "bob@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScrubber(constants.ScrubLevelHigh, false)
+			if err := s.SetPassthroughFields([]string{"trace_id", "level"}); err != nil {
+				t.Fatal(err)
+			}
+			// A field type would otherwise scrub the trace ID
+			if err := s.SetFieldTypes(map[string]string{"trace_id": constants.TypeUID}); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := s.ScrubLine(tt.line, "test.log")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, text := range tt.keep {
+				if !strings.Contains(got, text) {
+					t.Errorf("%s was not kept: %s", text, got)
+				}
+			}
+			for _, text := range tt.scrubbed {
+				if strings.Contains(got, text) {
+					t.Errorf("%s was not scrubbed: %s", text, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package scrubber
+
+import "regexp"
+
+// PatternSet holds every user-supplied regular expression, compiled once at startup
+// so bad patterns are reported before any processing begins
+type PatternSet struct {
+	preserve    []*regexp.Regexp
+	nationalIDs []nationalIDMatcher
+	customs     []customPatternMatcher
+}
+
+// CompilePatterns compiles preserve patterns and national ID formats
+// Errors name the setting, the entry's position and the pattern that failed
+func CompilePatterns(preserve []string, nationalIDs []NationalIDPattern) (*PatternSet, error) {
+	set := &PatternSet{}
+	var err error
+	if set.preserve, err = compilePreservePatterns(preserve); err != nil {
+		return nil, err
+	}
+	if set.nationalIDs, err = compileNationalIDPatterns(nationalIDs); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// SetPatterns installs patterns compiled by CompilePatterns
+func (s *Scrubber) SetPatterns(set *PatternSet) {
+	if set == nil {
+		return
+	}
+	s.preservePatterns = set.preserve
+	s.nationalIDMatchers = set.nationalIDs
+	s.customPatterns = set.customs
+}
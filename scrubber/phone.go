@@ -0,0 +1,127 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// phoneRegex matches North American style phone numbers with an optional +country code, written as
+// +1-555-123-4567, (555) 123-4567 or 555.123.4567. Digits must be separated, so plain digit runs
+// like epoch timestamps and numeric IDs never match.
+// Groups: 1 country code, 2 area code in parentheses, 3 area code, 4 separator after it,
+// 5 exchange, 6 separator after it, 7 line number
+var phoneRegex = regexp.MustCompile(`(?:\+(\d{1,3})[-. ]?)?(?:\((\d{3})\) ?|(\d{3})([-.]))(\d{3})([-.])(\d{4})`)
+
+// phonePlaceholder is the replacement of the Nth distinct phone number, keeping its country code
+const phonePlaceholder = "+%s-XXX-XXX-%04d"
+
+// defaultPhoneCountryCode is assumed for numbers written without a country code
+const defaultPhoneCountryCode = "1"
+
+// scrubPhoneNumbers replaces phone numbers with a placeholder per distinct number, e.g.
+// +1-XXX-XXX-0001 (levels 2 and 3). A number keeps its placeholder however it is written, so
+// (555) 123-4567 and +1-555-123-4567 share one.
+func (s *Scrubber) scrubPhoneNumbers(text, source string) string {
+	matches := phoneRegex.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range matches {
+		key, ok := phoneKey(text, loc)
+		if !ok {
+			continue
+		}
+		match := text[loc[0]:loc[1]]
+
+		scrubbed, exists := s.phoneMap[key]
+		if !exists {
+			s.phoneCounter++
+			countryCode := key[:strings.Index(key, " ")]
+			scrubbed = fmt.Sprintf(phonePlaceholder, countryCode, s.phoneCounter)
+			s.phoneMap[key] = scrubbed
+		}
+		replacement := scrubbed
+		if s.fixedWidth {
+			replacement = s.maskFixedWidth(match, constants.TypePhone)
+		}
+		s.trackReplacement(match, replacement, constants.TypePhone, source)
+
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(replacement)
+		last = loc[1]
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// phoneKey returns the mapping key of a phone number match, its country code and ten digits, and
+// false for a false positive. A match must stand on its own: one continuing a version string
+// (2.555.123.4567), a dashed identifier, a longer number or a host with a port (555.123.4567:443) is
+// rejected, as are mixed separators (555-123.4567) and area codes starting with 0 or 1, which North
+// American numbers never have.
+func phoneKey(text string, loc []int) (string, bool) {
+	group := func(i int) string {
+		if loc[2*i] < 0 {
+			return ""
+		}
+		return text[loc[2*i]:loc[2*i+1]]
+	}
+
+	start, end := loc[0], loc[1]
+	if start > 0 && text[start] != '(' && text[start] != '+' {
+		if before := text[start-1]; isPhoneWordByte(before) || before == '.' || before == '-' {
+			return "", false
+		}
+	}
+	if end < len(text) {
+		after := text[end]
+		if isPhoneWordByte(after) {
+			return "", false
+		}
+		if (after == '.' || after == '-' || after == ':') && end+1 < len(text) && text[end+1] >= '0' && text[end+1] <= '9' {
+			return "", false
+		}
+	}
+
+	area := group(2)
+	if area == "" {
+		area = group(3)
+		if group(4) != group(6) {
+			return "", false
+		}
+	}
+	if area[0] == '0' || area[0] == '1' {
+		return "", false
+	}
+
+	countryCode := group(1)
+	if countryCode == "" {
+		countryCode = defaultPhoneCountryCode
+	}
+	return countryCode + " " + area + group(5) + group(7), true
+}
+
+// phoneNumberKey returns the mapping key of a whole value written as a phone number, so differently
+// written originals of one number can be recognized as the same
+func phoneNumberKey(value string) (string, bool) {
+	loc := phoneRegex.FindStringSubmatchIndex(value)
+	if loc == nil || loc[0] != 0 || loc[1] != len(value) {
+		return "", false
+	}
+	return phoneKey(value, loc)
+}
+
+// isPhoneWordByte reports whether b continues a word or number, so a phone match next to it is
+// part of something longer
+func isPhoneWordByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
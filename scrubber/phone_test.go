@@ -0,0 +1,67 @@
+package scrubber
+
+import (
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+func TestPhoneNumbersIgnoreLookalikes(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"version string", "upgraded to server 2.555.123.4567"},
+		{"dotted version", "plugin v9.555.123.4567-beta"},
+		{"date and time", "at 2026-01-15 12:34:56.789"},
+		{"epoch milliseconds", `"create_at":1705322096789`},
+		{"IP address", "from 203.155.123.45 and 10.255.123.4567"},
+		{"host and port", "listening on 0.0.0.0:8065, proxy 555.123.4567:443"},
+		{"long numeric ID", "order 5551234567890 and 555-123-456789"},
+		{"dashed identifier", "build abc-555-123-4567 and 555-123-4567-01"},
+		{"mixed separators", "code 555-123.4567"},
+		{"area code starting with 1", "ref 155-123-4567"},
+	}
+	for _, tt := range tests {
+		s := NewScrubber(constants.ScrubLevelMedium, false)
+		if got := s.scrubPhoneNumbers(tt.text, "test.log"); got != tt.text {
+			t.Errorf("%s: %q became %q", tt.name, tt.text, got)
+		}
+	}
+}
+
+func TestPhoneNumbersShareAPlaceholder(t *testing.T) {
+	s := NewScrubber(constants.ScrubLevelMedium, false)
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"call 555-123-4567", "call +1-XXX-XXX-0001"},
+		{"call (555) 123-4567", "call +1-XXX-XXX-0001"},
+		{"call 555.123.4567", "call +1-XXX-XXX-0001"},
+		{"call +1-555-123-4567", "call +1-XXX-XXX-0001"},
+		{"call +1 (555) 123-4567.", "call +1-XXX-XXX-0001."},
+		{"call +44 207-123-4567", "call +44-XXX-XXX-0002"},
+		{"call 555-987-6543 or 555-123-4567", "call +1-XXX-XXX-0003 or +1-XXX-XXX-0001"},
+	}
+	for _, tt := range tests {
+		if got := s.scrubPhoneNumbers(tt.text, "test.log"); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPhoneNumbersByLevel(t *testing.T) {
+	line := `{"msg":"call alice at (555) 123-4567"}`
+	for _, level := range []int{constants.ScrubLevelLow, constants.ScrubLevelMedium, constants.ScrubLevelHigh} {
+		s := NewScrubber(level, false)
+		got, err := s.ScrubLine(line, "test.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scrubbed := !strings.Contains(got, "(555) 123-4567"); scrubbed != (level >= constants.ScrubLevelMedium) {
+			t.Errorf("level %d: %s", level, got)
+		}
+	}
+}
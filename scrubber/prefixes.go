@@ -0,0 +1,45 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// tokenPrefixRegex matches a safe mapped-value prefix: a letter, then letters, digits, _ or -, so
+// tokens need no escaping in JSON strings and still read as one word in emails and hostnames
+var tokenPrefixRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,31}$`)
+
+// ValidateTokenPrefixes checks the user and domain prefixes of mapped values. They must differ, so
+// user1 and domain1 can't be confused in the audit, and the user prefix can't be a role prefix,
+// whose tokens are numbered separately.
+func ValidateTokenPrefixes(userPrefix, domainPrefix string) error {
+	for _, prefix := range []struct{ name, value string }{{"user", userPrefix}, {"domain", domainPrefix}} {
+		if !tokenPrefixRegex.MatchString(prefix.value) {
+			return fmt.Errorf("%s prefix '%s' must start with a letter and contain only letters, digits, _ and - (at most 32 characters)", prefix.name, prefix.value)
+		}
+	}
+	if userPrefix == domainPrefix {
+		return fmt.Errorf("user and domain prefixes must differ, both are '%s'", userPrefix)
+	}
+	for _, candidate := range roleTokenPrefixes {
+		if userPrefix == candidate.prefix {
+			return fmt.Errorf("user prefix '%s' is the role token prefix for %s", userPrefix, candidate.role)
+		}
+	}
+	return nil
+}
+
+// SetTokenPrefixes sets the prefixes of mapped users and domains, e.g. "member" for member1 and
+// "org" for org1. An empty prefix keeps the default (user, domain).
+func (s *Scrubber) SetTokenPrefixes(userPrefix, domainPrefix string) {
+	if userPrefix == "" {
+		userPrefix = constants.UserTokenPrefix
+	}
+	if domainPrefix == "" {
+		domainPrefix = constants.DomainTokenPrefix
+	}
+	s.userPrefix = userPrefix
+	s.domainPrefix = domainPrefix
+}
@@ -0,0 +1,78 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Placeholders wrap an index in NUL bytes, which never occur in log text and match no scrubber pattern
+const preservePlaceholderMarker = "\x00"
+
+// protectedSpans holds text set aside from the regex scrubbers, indexed by placeholder number
+type protectedSpans []string
+
+// add sets text aside and returns the placeholder that stands in for it
+func (p *protectedSpans) add(text string) string {
+	*p = append(*p, text)
+	return preservePlaceholderMarker + strconv.Itoa(len(*p)-1) + preservePlaceholderMarker
+}
+
+// restore puts the original spans back in place of their placeholders
+func (p protectedSpans) restore(text string) string {
+	for i := len(p) - 1; i >= 0; i-- {
+		placeholder := preservePlaceholderMarker + strconv.Itoa(i) + preservePlaceholderMarker
+		text = strings.Replace(text, placeholder, p[i], 1)
+	}
+	return text
+}
+
+// SetPreservePatterns sets the patterns for values that were already anonymized by another tool,
+// such as [REDACTED] or <anon:123>. Matching text passes through verbatim and is never added to the audit.
+func (s *Scrubber) SetPreservePatterns(patterns []string) error {
+	compiled, err := compilePreservePatterns(patterns)
+	if err != nil {
+		return err
+	}
+	s.preservePatterns = compiled
+	return nil
+}
+
+// compilePreservePatterns compiles preserve patterns, naming the failing entry by position
+func compilePreservePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preserve pattern #%d '%s': %w", i+1, pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// protectPreserved swaps every span matching a preserve pattern for a placeholder
+func (s *Scrubber) protectPreserved(text string, spans *protectedSpans) string {
+	result := text
+	for _, re := range s.preservePatterns {
+		result = replaceAllStringFunc(re, result, func(match string) string {
+			// Leave placeholders from an earlier pattern alone
+			if strings.Contains(match, preservePlaceholderMarker) {
+				return match
+			}
+			return spans.add(match)
+		})
+	}
+	return result
+}
+
+// isPreserved reports whether an entire value matches a preserve pattern
+func (s *Scrubber) isPreserved(value string) bool {
+	for _, re := range s.preservePatterns {
+		if loc := re.FindStringIndex(value); loc != nil && loc[0] == 0 && loc[1] == len(value) {
+			return true
+		}
+	}
+	return false
+}
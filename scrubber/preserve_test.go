@@ -0,0 +1,80 @@
+package scrubber
+
+import (
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// testPreservePatterns are markers another anonymizer leaves behind
+var testPreservePatterns = []string{`\[REDACTED\]`, `<anon:\d+>`, `anon-[0-9a-f]{8}@masked\.example`}
+
+func TestPreservedMarkersPassThrough(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string // Expected output; "" means the line must come out unchanged
+	}{
+		{
+			name: "markers in JSON fields",
+			line: `{"user":"[REDACTED]","email":"<anon:123>","msg":"login by <anon:123>"}`,
+		},
+		{
+			name: "marker shaped like an email",
+			line: `{"msg":"mail to anon-1a2b3c4d@masked.example bounced"}`,
+		},
+		{
+			name: "marker shaped like a UID",
+			line: `{"msg":"request <anon:12345678901234567890123456> failed"}`,
+		},
+		{
+			name: "markers in plain text",
+			line: `user [REDACTED] connected from <anon:7>`,
+		},
+		{
+			name: "markers next to values still scrubbed",
+			line: `{"msg":"[REDACTED] wrote to alice@example.com from 10.1.2.3"}`,
+			want: `{"msg":"[REDACTED] wrote to user1@domain1 from ***.***.***.***"}`,
+		},
+		{
+			name: "pair with a marker creates no mapping",
+			line: `{"user":"bob","email":"[REDACTED]"}`,
+			want: `{"user":"user1","email":"[REDACTED]"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScrubber(constants.ScrubLevelHigh, false)
+			if err := s.SetPreservePatterns(testPreservePatterns); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := s.ScrubLine(tt.line, "test.log")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.line
+			}
+			if got != want {
+				t.Errorf("got  %s\nwant %s", got, want)
+			}
+			for _, entry := range s.AuditEntries() {
+				if strings.Contains(entry.OriginalValue, "REDACTED") || strings.Contains(entry.OriginalValue, "anon") {
+					t.Errorf("marker was audited: %+v", entry)
+				}
+			}
+		})
+	}
+}
+
+func TestSetPreservePatternsBadRegex(t *testing.T) {
+	s := NewScrubber(1, false)
+	err := s.SetPreservePatterns([]string{`\[REDACTED\]`, `<anon:(\d+>`})
+	if err == nil || !strings.Contains(err.Error(), "#2") {
+		t.Errorf("err = %v, want an error naming pattern #2", err)
+	}
+}
@@ -0,0 +1,129 @@
+package scrubber
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// LoadRedactList reads a redact list: one entry per line, either a literal string or a /regex/.
+// Blank lines and lines starting with # are skipped. Every entry is checked, so a bad regex is
+// reported with its line number when the list is loaded rather than when it is first used.
+func LoadRedactList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redact list: %w", err)
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		if _, err := compileRedactEntry(entry); err != nil {
+			return nil, fmt.Errorf("redact list %s line %d: %w", path, lineNumber, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read redact list %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// compileRedactEntry compiles a single redact list entry
+// A /regex/ entry is used as written. A literal matches case-insensitively and only as a whole word:
+// an end that is a letter, digit or underscore must not run on into another one, so "falcon" matches
+// in "project falcon." but not in "falconry"
+func compileRedactEntry(entry string) (*regexp.Regexp, error) {
+	if len(entry) > 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/") {
+		re, err := regexp.Compile(entry[1 : len(entry)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %s: %w", entry, err)
+		}
+		if re.MatchString("") {
+			return nil, fmt.Errorf("regex %s matches an empty string", entry)
+		}
+		return re, nil
+	}
+	return regexp.MustCompile(`(?i)` + literalRedactPattern(entry)), nil
+}
+
+// literalRedactPattern returns the pattern of a literal entry with word boundaries at its word-character ends
+func literalRedactPattern(literal string) string {
+	pattern := regexp.QuoteMeta(literal)
+	if first, _ := utf8.DecodeRuneInString(literal); isRedactWordRune(first) {
+		pattern = `\b` + pattern
+	}
+	if last, _ := utf8.DecodeLastRuneInString(literal); isRedactWordRune(last) {
+		pattern += `\b`
+	}
+	return pattern
+}
+
+// isRedactWordRune reports whether r is an ASCII word character, the characters \b treats as a word
+func isRedactWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// SetRedactList sets the entries loaded by LoadRedactList. Every match is replaced with "[redacted]"
+// and tracked under the redact type, before any of the typed scrubbers run, so organization-specific
+// terms are removed even where a typed scrubber would otherwise have mapped part of them.
+func (s *Scrubber) SetRedactList(entries []string) error {
+	s.redactPatterns = nil
+	var literals []string
+	for _, entry := range entries {
+		if len(entry) > 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/") {
+			re, err := compileRedactEntry(entry)
+			if err != nil {
+				return err
+			}
+			s.redactPatterns = append(s.redactPatterns, re)
+			continue
+		}
+		literals = append(literals, entry)
+	}
+	if len(literals) == 0 {
+		return nil
+	}
+
+	// All literals share one alternation, longest first so a term wins over a shorter term it contains
+	sort.SliceStable(literals, func(i, j int) bool { return len(literals[i]) > len(literals[j]) })
+	alternatives := make([]string, len(literals))
+	for i, literal := range literals {
+		alternatives[i] = literalRedactPattern(literal)
+	}
+	literalRegex := regexp.MustCompile(`(?i)(?:` + strings.Join(alternatives, "|") + `)`)
+	s.redactPatterns = append([]*regexp.Regexp{literalRegex}, s.redactPatterns...)
+	return nil
+}
+
+// scrubRedactList replaces matches of the redact list (all levels)
+func (s *Scrubber) scrubRedactList(text, source string) string {
+	result := text
+	for _, re := range s.redactPatterns {
+		result = replaceAllStringFunc(re, result, func(match string) string {
+			// Placeholders of protected values are never matched into
+			if strings.Contains(match, preservePlaceholderMarker) {
+				return match
+			}
+			scrubbed := constants.RedactedTermMarker
+			if s.fixedWidth {
+				scrubbed = s.maskFixedWidth(match, constants.TypeRedact)
+			}
+			s.trackReplacement(match, scrubbed, constants.TypeRedact, source)
+			return scrubbed
+		})
+	}
+	return result
+}
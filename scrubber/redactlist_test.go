@@ -0,0 +1,89 @@
+package scrubber
+
+import (
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+func TestRedactListLiterals(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		text    string
+		want    string
+	}{
+		{
+			name:    "whole words only",
+			entries: []string{"falcon"},
+			text:    "project Falcon. falconry and falcon_v2 and refalcon",
+			want:    "project [redacted]. falconry and falcon_v2 and refalcon",
+		},
+		{
+			name:    "metacharacters are literal",
+			entries: []string{"a.b(c)*", "x+y"},
+			text:    "a.b(c)* and aXb(c) and x+y but xxy",
+			want:    "[redacted] and aXb(c) and [redacted] but xxy",
+		},
+		{
+			name:    "longest term wins",
+			entries: []string{"falcon", "falcon ops"},
+			text:    "ask falcon ops about falcon",
+			want:    "ask [redacted] about [redacted]",
+		},
+		{
+			name:    "non-word ends match anywhere",
+			entries: []string{"#secret#"},
+			text:    "tag#secret#tag",
+			want:    "tag[redacted]tag",
+		},
+	}
+	for _, tt := range tests {
+		s := NewScrubber(constants.ScrubLevelLow, false)
+		if err := s.SetRedactList(tt.entries); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.scrubRedactList(tt.text, "test.log"); got != tt.want {
+			t.Errorf("%s:\n got  %s\n want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRedactListRegexEntries(t *testing.T) {
+	s := NewScrubber(constants.ScrubLevelLow, false)
+	if err := s.SetRedactList([]string{`/PRJ-\d{4}/`, "falcon"}); err != nil {
+		t.Fatal(err)
+	}
+	got := s.scrubRedactList("PRJ-1234 and PRJ-12 for falcon", "test.log")
+	if want := "[redacted] and PRJ-12 for [redacted]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if entries := s.AuditEntries(); len(entries) != 2 || entries[0].Type != constants.TypeRedact {
+		t.Errorf("audit = %+v, want both terms under %s", entries, constants.TypeRedact)
+	}
+
+	for _, entry := range []string{`/PRJ-(\d/`, `/x*/`} {
+		if err := s.SetRedactList([]string{entry}); err == nil {
+			t.Errorf("%s was accepted", entry)
+		}
+	}
+}
+
+func TestRedactListMatchSpanningAQuote(t *testing.T) {
+	s := NewScrubber(constants.ScrubLevelLow, false)
+	if err := s.SetRedactList([]string{`/falcon","email/`}); err != nil {
+		t.Fatal(err)
+	}
+	line := `{"project":"falcon","email":"alice@example.com","user":"alice"}`
+	got, err := s.ScrubLine(line, "test.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "alice") || strings.Contains(got, "falcon") {
+		t.Errorf("original values leaked: %s", got)
+	}
+	if stats := s.Stats(); stats.JSONFailures != 1 {
+		t.Errorf("%d JSON failures, want the broken line counted as one", stats.JSONFailures)
+	}
+}
@@ -0,0 +1,55 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// FieldScrubber is a custom scrubber for identifier formats the built-in scrubbers don't know about.
+// Library consumers register implementations with RegisterScrubber.
+type FieldScrubber interface {
+	// Type returns the type recorded in the audit for replacements made by this scrubber
+	Type() string
+	// Scrub returns the replacement for value at the given scrubbing level, and false if value doesn't match
+	Scrub(value string, level int) (string, bool)
+}
+
+// Token pattern - runs of characters other than whitespace, quotes and JSON/punctuation delimiters
+var tokenRegex = regexp.MustCompile(`[^\s"'{}\[\],:;=<>()\x00]+`)
+
+// RegisterScrubber adds a custom scrubber to the scrubbing pipeline.
+//
+// Ordering guarantees:
+//   - Custom scrubbers run after all built-in scrubbers (emails, usernames, FQDNs, hosts, IPs, UIDs),
+//     so they only see values the built-in scrubbers left in place.
+//   - Custom scrubbers run in the order they were registered, and each one sees the output of the previous one.
+//   - Each scrubber is offered every token of the line, where a token is a run of characters other than
+//     whitespace, quotes and the delimiters { } [ ] , : ; = < > ( ).
+//
+// Every replacement is recorded in the audit under the scrubber's Type.
+func (s *Scrubber) RegisterScrubber(fs FieldScrubber) error {
+	if fs == nil {
+		return fmt.Errorf("scrubber must not be nil")
+	}
+	if fs.Type() == "" {
+		return fmt.Errorf("scrubber type must not be empty")
+	}
+	s.customScrubbers = append(s.customScrubbers, fs)
+	return nil
+}
+
+// applyCustomScrubbers runs registered custom scrubbers over the text in registration order
+func (s *Scrubber) applyCustomScrubbers(text, source string) string {
+	result := text
+	for _, fs := range s.customScrubbers {
+		result = replaceAllStringFunc(tokenRegex, result, func(token string) string {
+			scrubbed, matched := fs.Scrub(token, s.level)
+			if !matched {
+				return token
+			}
+			s.trackReplacement(token, scrubbed, fs.Type(), source)
+			return scrubbed
+		})
+	}
+	return result
+}
@@ -0,0 +1,40 @@
+package scrubber
+
+import (
+	"regexp"
+	"strings"
+)
+
+// replaceAllStringFunc behaves like re.ReplaceAllStringFunc, except that when no match is replaced
+// with different text the original string is returned as-is rather than rebuilt. Most log lines
+// contain nothing to scrub, so this avoids copying them once per scrubber; it also lets callers
+// detect an unchanged line cheaply, since an identical string shares the original's memory.
+func replaceAllStringFunc(re *regexp.Regexp, text string, repl func(string) string) string {
+	matches := re.FindAllStringIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	changed := false
+	for _, loc := range matches {
+		match := text[loc[0]:loc[1]]
+		replacement := repl(match)
+		if replacement == match {
+			continue
+		}
+		if !changed {
+			b.Grow(len(text))
+			changed = true
+		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(replacement)
+		last = loc[1]
+	}
+	if !changed {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
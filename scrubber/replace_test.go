@@ -0,0 +1,120 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestReplaceAllStringFunc(t *testing.T) {
+	re := regexp.MustCompile(`[0-9]+`)
+	double := func(match string) string {
+		if match == "7" {
+			return match
+		}
+		return match + match
+	}
+	tests := []string{"", "no digits", "a1b22c333", "7 stays, 8 doubles", "7 and 7", "12"}
+	for _, text := range tests {
+		if got, want := replaceAllStringFunc(re, text, double), re.ReplaceAllStringFunc(text, double); got != want {
+			t.Errorf("replaceAllStringFunc(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestReplaceAllStringFuncReturnsUnchangedText(t *testing.T) {
+	re := regexp.MustCompile(`[0-9]+`)
+	for _, text := range []string{"no digits", "7 and 7"} {
+		got := replaceAllStringFunc(re, text, func(match string) string { return match })
+		if unsafe.StringData(got) != unsafe.StringData(text) {
+			t.Errorf("%q was rebuilt rather than returned as-is", text)
+		}
+	}
+}
+
+// sparseLogLines returns n log lines of which one in 100 holds PII, like a typical app log
+func sparseLogLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		if i%100 == 0 {
+			lines[i] = fmt.Sprintf(`{"timestamp":"2026-01-15 10:04:%02d.345 Z","level":"info","msg":"User logged in","user":"user%d","email":"user%d@example.com","ip":"10.0.%d.%d"}`, i%60, i, i, i%250, i%200)
+			continue
+		}
+		lines[i] = fmt.Sprintf(`{"timestamp":"2026-01-15 10:04:%02d.345 Z","level":"debug","msg":"Cache refreshed","caller":"app/cache.go:%d","entries":%d,"duration_ms":%d.5}`, i%60, i%500, i*3, i%90)
+	}
+	return lines
+}
+
+func TestUnchangedLinesAreByteIdentical(t *testing.T) {
+	lines := append(sparseLogLines(200),
+		`{ "level" : "info",  "msg" : "spaced out" }`,
+		`{"msg":"escaped é and \/ slash","n":1.50}`,
+		"plain text with trailing spaces   ",
+	)
+	s := NewScrubber(2, false)
+	for i, line := range lines {
+		got, err := s.ScrubLine(line, "test.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%100 == 0 && i < 200 {
+			if got == line {
+				t.Errorf("line %d holds PII but was not changed", i+1)
+			}
+			continue
+		}
+		if got != line {
+			t.Errorf("line %d changed:\n got %s\nwant %s", i+1, got, line)
+		}
+	}
+}
+
+// BenchmarkReplaceAllStringFunc compares the regexp package's replacement, which rebuilds every
+// string it matched in, with replaceAllStringFunc on PII-sparse lines where matches are kept
+func BenchmarkReplaceAllStringFunc(b *testing.B) {
+	lines := sparseLogLines(1000)
+	keep := func(match string) string {
+		if strings.Contains(match, "@") {
+			return "user1@domain1"
+		}
+		return match
+	}
+	for _, impl := range []struct {
+		name    string
+		replace func(re *regexp.Regexp, text string, repl func(string) string) string
+	}{
+		{"regexp", func(re *regexp.Regexp, text string, repl func(string) string) string {
+			return re.ReplaceAllStringFunc(text, repl)
+		}},
+		{"passthrough", replaceAllStringFunc},
+	} {
+		b.Run(impl.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, line := range lines {
+					// Timestamps and durations match the IP and UID-like patterns but are kept
+					impl.replace(ipRegex, line, keep)
+					impl.replace(emailRegex, line, keep)
+					impl.replace(uidRegex, line, keep)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkScrubSparseLines scrubs a PII-sparse log at level 2, where nearly every line passes through
+func BenchmarkScrubSparseLines(b *testing.B) {
+	lines := sparseLogLines(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := NewScrubber(2, false)
+		for _, line := range lines {
+			if _, err := s.ScrubLine(line, "bench.log"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package scrubber
+
+import (
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// roleTokenPrefixes maps Mattermost system roles to mapped token prefixes, most privileged first
+var roleTokenPrefixes = []struct {
+	role   string
+	prefix string
+}{
+	{"system_admin", constants.AdminTokenPrefix},
+	{"system_guest", constants.GuestTokenPrefix},
+}
+
+// SetRoleTokens makes users whose log object carries a "roles" field map to role-based tokens
+// (adminN, guestN) instead of userN. Users seen without a role keep the userN scheme.
+func (s *Scrubber) SetRoleTokens(enabled bool) {
+	s.roleTokens = enabled
+}
+
+// roleTokenPrefix returns the token prefix for a Mattermost "roles" value (a space-separated role list)
+func roleTokenPrefix(roles interface{}) string {
+	rolesStr, ok := roles.(string)
+	if !ok {
+		return constants.UserTokenPrefix
+	}
+
+	fields := strings.Fields(rolesStr)
+	for _, candidate := range roleTokenPrefixes {
+		for _, role := range fields {
+			if role == candidate.role {
+				return candidate.prefix
+			}
+		}
+	}
+	return constants.UserTokenPrefix
+}
+
+// nextMappedID returns the next token number for a prefix
+// Regular users share the original user counter; each role prefix has its own
+func (s *Scrubber) nextMappedID(prefix string) int {
+	if prefix == "" || prefix == constants.UserTokenPrefix {
+		s.userCounter++
+		return s.userCounter
+	}
+	s.roleCounters[prefix]++
+	return s.roleCounters[prefix]
+}
+
+// RoleTokenLegend describes the role-to-token scheme, or returns nil when role tokens are disabled
+func (s *Scrubber) RoleTokenLegend() []string {
+	if !s.roleTokens {
+		return nil
+	}
+
+	suffix := "N"
+	if s.hashMode {
+		suffix = "_<hash>"
+	}
+	var legend []string
+	for _, candidate := range roleTokenPrefixes {
+		legend = append(legend, candidate.prefix+suffix+" = "+candidate.role)
+	}
+	legend = append(legend, s.userPrefix+suffix+" = other or unknown role")
+	return legend
+}
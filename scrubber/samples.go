@@ -0,0 +1,100 @@
+package scrubber
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// ChangeSample is one before/after example of a replacement, for a quick look at what a run changed
+type ChangeSample struct {
+	Original      string // Shown as chosen by ChangeSamples: plain, hashed like the audit, or masked
+	NewValue      string
+	TimesReplaced int
+}
+
+// ChangeSamples returns up to perType of the most replaced values of each type, keyed by type.
+// Ties are broken by original value so the samples are the same across runs. Types left out of the
+// audit by SetNoAuditTypes are left out here too. originals is one of the SampleOriginals constants:
+// show records the original the way the audit does (hashed with SetAuditHashOriginals), mask keeps
+// only the first character of each word, and hide leaves it empty.
+func (s *Scrubber) ChangeSamples(perType int, originals string) map[string][]ChangeSample {
+	byType := make(map[string][]*AuditEntry)
+	keys := make(map[*AuditEntry]string)
+	for original, entry := range s.auditEntries {
+		if s.audited(entry) {
+			byType[entry.Type] = append(byType[entry.Type], entry)
+			keys[entry] = original
+		}
+	}
+
+	samples := make(map[string][]ChangeSample, len(byType))
+	for valueType, entries := range byType {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].TimesReplaced != entries[j].TimesReplaced {
+				return entries[i].TimesReplaced > entries[j].TimesReplaced
+			}
+			return keys[entries[i]] < keys[entries[j]]
+		})
+		if len(entries) > perType {
+			entries = entries[:perType]
+		}
+		for _, entry := range entries {
+			sample := ChangeSample{NewValue: entry.NewValue, TimesReplaced: entry.TimesReplaced}
+			switch originals {
+			case constants.SampleOriginalsShow:
+				sample.Original = entry.OriginalValue
+			case constants.SampleOriginalsMask:
+				sample.Original = maskSampleOriginal(keys[entry])
+			}
+			samples[valueType] = append(samples[valueType], sample)
+		}
+	}
+	return samples
+}
+
+// maskSampleOriginal keeps the first character of each run of letters and digits and masks the rest,
+// so alice@acme.com shows as a****@a***.com: enough to recognize the shape, not the value
+func maskSampleOriginal(original string) string {
+	var builder strings.Builder
+	inWord := false
+	for _, r := range original {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && inWord {
+			builder.WriteRune('*')
+		} else {
+			builder.WriteRune(r)
+		}
+		inWord = word
+	}
+	return builder.String()
+}
+
+// PrintChangeSamples prints up to perType before/after examples for each replaced type, in the order
+// of the replacement summary, as a quick check that values were replaced as expected
+func (s *Scrubber) PrintChangeSamples(perType int, originals string) {
+	samples := s.ChangeSamples(perType, originals)
+	if len(samples) == 0 {
+		return
+	}
+
+	stats := s.Stats()
+	fmt.Fprintf(s.messages, "Sample changes (up to %d per type, most replaced first):\n", perType)
+	types := make([]string, 0, len(samples))
+	for valueType := range samples {
+		types = append(types, valueType)
+	}
+	for _, valueType := range summaryTypeOrder(types) {
+		fmt.Fprintf(s.messages, "  %s (%d replacements, %d unique values):\n", valueType, stats.Replacements[valueType], stats.UniqueValues[valueType])
+		for _, sample := range samples[valueType] {
+			if originals == constants.SampleOriginalsHide {
+				fmt.Fprintf(s.messages, "    -> %s (%d times)\n", sample.NewValue, sample.TimesReplaced)
+			} else {
+				fmt.Fprintf(s.messages, "    %s -> %s (%d times)\n", sample.Original, sample.NewValue, sample.TimesReplaced)
+			}
+		}
+	}
+}
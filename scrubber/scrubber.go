@@ -2,17 +2,23 @@ package scrubber
 
 import (
 	"bufio"
-	"compress/gzip"
-	"encoding/csv"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+
 	"mattermost-log-scrubber/constants"
 )
 
@@ -20,6 +26,20 @@ type UserMapping struct {
 	Username string
 	Email    string
 	MappedID int
+	Prefix   string // Token prefix: "user", or a role prefix such as "admin"
+	HashToken string // Token in hash mode, e.g. user_a1b2c3d4, used instead of Prefix and MappedID
+}
+
+// Token returns the mapped token for the user, e.g. user3 or admin1
+func (m *UserMapping) Token() string {
+	if m.HashToken != "" {
+		return m.HashToken
+	}
+	prefix := m.Prefix
+	if prefix == "" {
+		prefix = constants.UserTokenPrefix
+	}
+	return fmt.Sprintf("%s%d", prefix, m.MappedID)
 }
 
 type AuditEntry struct {
@@ -37,24 +57,97 @@ type JSONFailure struct {
 }
 
 type Scrubber struct {
-	level            int
-	verbose          bool
-	emailMap         map[string]string
-	userMap          map[string]string
-	ipMap            map[string]string
-	uidMap           map[string]string
-	fqdnMap          map[string]string
-	userMappings     map[string]*UserMapping // key: username or email -> UserMapping
-	userCounter      int
-	auditEntries     map[string]*AuditEntry // key: original value -> AuditEntry
-	domainMap        map[string]string      // key: original domain -> mapped domain
-	domainCounter    int
-	subdomainMap     map[string]string      // key: full subdomain.domain -> mapped subdomain
-	subdomainCounter map[string]int         // key: base domain -> subdomain counter for that domain
-	jsonSuccessCount int
-	jsonFailureCount int
-	jsonFailures     []JSONFailure // Store sample of failed lines
-	userOverwriteChoice string     // Remembers user's choice for file conflicts across the session
+	level                int
+	verbose              bool
+	emailMap             map[string]string
+	userMap              map[string]string
+	ipMap                map[string]string
+	uidMap               map[string]string
+	fqdnMap              map[string]string
+	hostMap              map[string]string // key: lowercase hostname -> mapped hostN token
+	hostCounter          int
+	userMappings         map[string]*UserMapping // key: username or email -> UserMapping
+	userIDLinks          map[string][]string     // key: username or email -> user IDs seen alongside it
+	identityReport       bool                    // Link user IDs to users for the identity report
+	userCounter          int
+	auditEntries         map[string]*AuditEntry // key: original value -> AuditEntry
+	domainMap            map[string]string      // key: lowercase original domain -> mapped domain
+	domainCounter        int
+	fixedDomains         map[string]bool   // key: lowercase domain pre-seeded from the domain map
+	domainMapSource      string            // Domain map file recorded as the audit source of fixed mappings
+	subdomainMap         map[string]string // key: full subdomain.domain -> mapped subdomain
+	subdomainCounter     map[string]int    // key: base domain -> subdomain counter for that domain
+	jsonSuccessCount     int
+	jsonFailureCount     int
+	jsonFailures         []JSONFailure     // Store sample of failed lines
+	userOverwriteChoice  string            // Remembers user's choice for file conflicts across the session
+	keyFolder            cases.Caser       // Case folder used to normalize mapping keys
+	fixedWidth           bool              // Replace values with same-length masks instead of mapped tokens
+	maxInputSize         int64             // Cumulative byte limit enforced on streamed input (0 = unlimited)
+	failOnEmpty          bool              // Return an error when no non-empty lines were processed
+	customScrubbers      []FieldScrubber   // Library-registered scrubbers, run after the built-in ones
+	ipStrategy           string            // How IP addresses are replaced: mask or class
+	ipClassCounter       map[string]int    // key: IP class (private/public) -> counter for class labels
+	preservePatterns     []*regexp.Regexp  // Already-anonymized markers that pass through untouched
+	skipOutput           bool              // Scrub and build mappings but don't write the scrubbed log
+	scrubPaths           []JSONPath        // JSON paths whose values are always scrubbed
+	fieldTypes           map[string]string // key: lowercase JSON field name -> scrub type
+	passthroughFields    map[string]bool   // key: lowercase JSON field name whose value is never changed
+	passthroughTextRegex *regexp.Regexp    // Passthrough field values written as name=value in plain text
+	numericIDMap         map[string]string // key: original integer ID -> synthetic ID with the same digit count
+	numericIDCounter     map[int]int       // key: digit count -> counter for synthetic IDs of that length
+	phoneMap             map[string]string // key: country code and digits of a phone number -> placeholder
+	phoneCounter         int
+	cancelScope          string                 // Whether a cancelled file conflict aborts the run or skips the file
+	logKind              string                 // Which Mattermost log format to apply field handling for
+	roleTokens           bool                   // Use role-based user tokens (adminN) when a roles field is present
+	roleCounters         map[string]int         // key: role token prefix -> counter for that prefix
+	userPrefix           string                 // Prefix of regular user tokens, e.g. user in user1
+	domainPrefix         string                 // Prefix of mapped domains, e.g. domain in domain1
+	auditSalt            []byte                 // When set, audit originals are recorded as salted hashes
+	auditCSVDelimiter    rune                   // Field delimiter of the CSV audit (0 = comma)
+	auditCSVQuoteAll     bool                   // Quote every CSV audit field, not just those that need it
+	nationalIDMatchers   []nationalIDMatcher    // SSN and configured national ID formats to redact
+	customPatterns       []customPatternMatcher // Configured organization-specific patterns, applied after the built-in scrubbers
+	redactPatterns       []*regexp.Regexp       // Redact list entries replaced with [redacted] before typed scrubbing
+	alwaysScrubRegex     *regexp.Regexp         // Literal values mapped to user tokens wherever they appear (nil = none)
+	allowlistValues      map[string]bool        // Normalized literal values never scrubbed as emails, usernames or IPs
+	allowlistPatterns    []*regexp.Regexp       // Whole-value patterns never scrubbed as emails, usernames or IPs
+	checksums            bool                   // Hash the input and output while processing
+	inputChecksum        string                 // Hex SHA-256 of the input read by the last ProcessFile
+	outputChecksum       string                 // Hex SHA-256 of the output written by the last ProcessFile
+	lineLimiter          *tokenBucket           // Caps lines processed per second (nil = unlimited)
+	byteLimiter          *tokenBucket           // Caps bytes processed per second (nil = unlimited)
+	splitSize            int64                  // Maximum uncompressed bytes per output part (0 = single file)
+	canonicalJSON        bool                   // Re-marshal JSON lines with sorted keys for stable diffs
+	outputParts          []string               // Output files written by the last ProcessFile
+	jsonFailureAction    string                 // What to do with lines that aren't valid JSON: scrub, drop or redact
+	strictAllowlist      bool                   // Only scrub values at scrub paths and field types; never pattern-match
+	stats                RunStats               // Line counts totalled across every ProcessFile call
+	lineCalls            int                    // Lines passed to ScrubLine, numbering them for traces and failure records
+	inputRange           InputRange             // Line or byte window of each input to process (zero = whole input)
+	renameScheme         string                 // How renamed artifacts are suffixed: timestamp or sequential
+	maskChar             string                 // Character used in masks
+	maskChars            map[string]string      // key: scrub type -> mask character overriding maskChar
+	containerLogs        bool                   // Input lines are container log records wrapping the app log line
+	timeFormat           string                 // Format timestamp fields are normalized to (empty = unchanged)
+	timeOrigin           time.Time              // First timestamp seen, the zero point of relative times
+	noAuditTypes         map[string]bool        // Scrub types counted but left out of the audit files
+	internalDomains      []string               // Lowercase email domains kept as-is; other email domains are hidden
+	debugTrace           *debugTrace            // Per-line decision trace for --trace (nil = off)
+	emailTemplate        string                 // Format of anonymized emails, e.g. {token}@{domain}
+	lenientEmails        bool                   // Also detect emails with whitespace around the @ or wrapped in the domain
+	scrubbingBlock       bool                   // Scrubbing lines joined with line breaks, so lenient emails must not wrap
+	delimited            *delimitedFormat       // Columns of delimited input to scrub (nil = JSON/plain-text input)
+	plainTextInput       bool                   // Scrub every line as plain text without attempting to parse JSON
+	collapseRepeats      bool                   // Write runs of identical scrubbed lines once with a repeat count
+	chunks               *chunkCallback         // Called every N scrubbed lines for embedding hosts (nil = none)
+	hashMode             bool                   // Derive user and domain tokens from a salted hash instead of counters
+	hashSalt             []byte                 // HMAC key of hash-mode tokens
+	hashPrefix           bool                   // Start hash-mode tokens with a hash of the original's first characters
+	hashTokens           map[string]string      // key: hash-mode token -> normalized value it was derived from
+	deadline             time.Time              // Stop reading inputs once this time has passed (zero = no deadline)
+	messages             io.Writer              // Progress, warnings and summaries (default: standard output)
 }
 
 func NewScrubber(level int, verbose bool) *Scrubber {
@@ -66,8 +159,15 @@ func NewScrubber(level int, verbose bool) *Scrubber {
 		ipMap:            make(map[string]string),
 		uidMap:           make(map[string]string),
 		fqdnMap:          make(map[string]string),
+		hostMap:          make(map[string]string),
+		hostCounter:      0,
 		userMappings:     make(map[string]*UserMapping),
+		userIDLinks:      make(map[string][]string),
 		userCounter:      0,
+		roleCounters:     make(map[string]int),
+		userPrefix:       constants.UserTokenPrefix,
+		domainPrefix:     constants.DomainTokenPrefix,
+		nationalIDMatchers: defaultNationalIDMatchers,
 		auditEntries:     make(map[string]*AuditEntry),
 		domainMap:        make(map[string]string),
 		domainCounter:    0,
@@ -77,130 +177,174 @@ func NewScrubber(level int, verbose bool) *Scrubber {
 		jsonFailureCount: 0,
 		jsonFailures:     make([]JSONFailure, 0),
 		userOverwriteChoice: "",
+		keyFolder:        cases.Fold(),
+		ipStrategy:       constants.IPStrategyMask,
+		maskChar:         constants.DefaultMaskChar,
+		jsonFailureAction: constants.JSONFailureScrub,
+		ipClassCounter:   make(map[string]int),
+		numericIDMap:     make(map[string]string),
+		numericIDCounter: make(map[int]int),
+		phoneMap:         make(map[string]string),
+		messages:         os.Stdout,
 	}
 }
 
+// normalizeKey returns the lookup key for a mapped value
+// Values are NFC-normalized and case-folded so the same logical name always maps to one token,
+// even when it is written with different Unicode compositions or letter cases
+func (s *Scrubber) normalizeKey(value string) string {
+	return s.keyFolder.String(norm.NFC.String(value))
+}
+
 // ProcessFile processes the input file and writes scrubbed output
 // Returns the actual output path used (which may differ from inputPath if renamed)
 func (s *Scrubber) ProcessFile(inputPath, outputPath string, dryRun bool, compress bool, overwriteAction string) (string, error) {
-	inputFile, err := os.Open(inputPath)
+	inputFile, err := s.openInput(inputPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to open input file: %w", err)
+		return "", err
 	}
 	defer inputFile.Close()
 
-	var outputWriter io.Writer
-	var outputFile *os.File
-	var gzipWriter *gzip.Writer
-	
-	// Track the final output path (may change if renamed)
-	finalOutputPath := outputPath
-	
-	if !dryRun {
-		// Check if output file already exists
-		if checkFileExists(outputPath) {
-			choice, err := s.handleFileConflict(outputPath, overwriteAction)
-			if err != nil {
-				return "", fmt.Errorf("failed to handle file conflict: %w", err)
-			}
-			
-			switch choice {
-			case "cancel":
-				return "", createCancelError(outputPath, overwriteAction)
-			case "rename":
-				finalOutputPath = generateTimestampSuffix(outputPath)
-				fmt.Printf("Output will be written to: %s\n", finalOutputPath)
-			case "overwrite":
-				// Continue with original path
-			}
-		}
-		
-		outputFile, err = os.Create(finalOutputPath)
-		if err != nil {
-			return "", fmt.Errorf("failed to create output file: %w", err)
-		}
-		defer outputFile.Close()
-		
-		if compress {
-			gzipWriter = gzip.NewWriter(outputFile)
-			defer gzipWriter.Close()
-			outputWriter = gzipWriter
-		} else {
-			outputWriter = outputFile
-		}
+	// Skip to the start of a byte range before anything else reads the input
+	tracker := &rangeTracker{r: s.inputRange}
+	if err := tracker.seekToStart(inputFile); err != nil {
+		return "", err
 	}
 
-	scanner := bufio.NewScanner(inputFile)
-	lineCount := 0
-	processedCount := 0
-	emptyCount := 0
-	failedCount := 0
-	
-	// Progress tracking (only if not verbose)
-	var startTime, lastProgressTime time.Time
-	progressInterval := constants.ProgressInterval // Show progress every N lines
-	
-	if !s.verbose {
-		startTime = time.Now()
-		lastProgressTime = startTime
-		fmt.Print("Processing... ")
+	// Hash the input as it is read, so checksums don't need a second pass
+	var inputReader io.Reader = inputFile
+	var inputHash hash.Hash
+	if s.checksums {
+		inputHash = sha256.New()
+		inputReader = io.TeeReader(inputFile, inputHash)
 	}
 
-	for scanner.Scan() {
-		lineCount++
-		line := scanner.Text()
-		
-		if strings.TrimSpace(line) == "" {
-			emptyCount++
-			continue
+	// The scrubbed log is only written for real runs that haven't asked to skip it
+	writeLog := !dryRun && !s.skipOutput
+	s.outputParts = nil
+
+	var output *logOutput
+	if writeLog {
+		// Check if output file already exists and create it (or its first part)
+		output, err = s.openLogOutput(outputPath, compress, overwriteAction)
+		if errors.Is(err, ErrArtifactSkipped) {
+			// Keep scrubbing so the audit and reports are still produced
+			writeLog = false
+		} else if err != nil {
+			return "", err
 		}
+	}
+	if output != nil {
+		defer output.abort()
+	}
 
-		scrubbedLine, err := s.processLogLine(line, filepath.Base(inputPath), lineCount)
-		if err != nil {
-			failedCount++
-			fmt.Printf("\nWarning: Failed to process line %d: %v\n", lineCount, err)
-			// Write original line if processing fails
-			scrubbedLine = line
+	sink := lineSink{
+		source:     inputSourceName(inputPath),
+		tracker:    tracker,
+		throttle:   true,
+		keepFailed: true,
+	}
+	if writeLog {
+		sink.write = output.writeLine
+	} else if dryRun && s.verbose {
+		sink.onScrubbed = func(lineNumber int) {
+			fmt.Fprintf(s.messages, "Line %d would be scrubbed\n", lineNumber)
 		}
+	}
 
-		processedCount++
+	// Progress tracking (only if not verbose)
+	if !s.verbose {
+		startTime := time.Now()
+		lastProgressTime := startTime
+		progressInterval := constants.ProgressInterval // Show progress every N lines
+		fmt.Fprint(s.messages, "Processing... ")
 
-		if !dryRun {
-			if _, err := outputWriter.Write([]byte(scrubbedLine + "\n")); err != nil {
-				return "", fmt.Errorf("failed to write to output file: %w", err)
-			}
-		} else if s.verbose {
-			fmt.Printf("Line %d would be scrubbed\n", lineCount)
-		}
-		
-		// Show progress every 1000 lines or every second (only if not verbose)
-		if !s.verbose {
+		// Show progress every 1000 lines or every second
+		sink.onLine = func(lineCount int) {
 			now := time.Now()
 			if lineCount%progressInterval == 0 || now.Sub(lastProgressTime) >= time.Second {
-				fmt.Printf("\rProcessing... %d lines", lineCount)
+				if s.isThrottled() {
+					// Report the effective (throttled) throughput
+					rate := float64(lineCount) / now.Sub(startTime).Seconds()
+					fmt.Fprintf(s.messages, "\rProcessing... %d lines (%.0f lines/s, throttled)", lineCount, rate)
+				} else {
+					fmt.Fprintf(s.messages, "\rProcessing... %d lines", lineCount)
+				}
 				lastProgressTime = now
 			}
 		}
 	}
-	
+
+	result, err := s.scrubLines(inputReader, sink)
+
 	// Clear progress line (only if not verbose)
 	if !s.verbose {
-		fmt.Print("\r" + strings.Repeat(" ", 50) + "\r")
+		fmt.Fprint(s.messages, "\r"+strings.Repeat(" ", 50)+"\r")
+	}
+	if err != nil {
+		return "", err
 	}
+	stopped := result.stopped
 
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("error reading input file: %w", err)
+	// Finish the output now so checksums cover the complete files
+	if output != nil {
+		if err := output.close(); err != nil {
+			return "", err
+		}
+		for _, part := range output.parts {
+			s.outputParts = append(s.outputParts, part.path)
+		}
 	}
 
 	// Always show processed lines count with breakdown
-	fmt.Printf("Processed %d lines out of %d total lines", processedCount, lineCount)
-	if emptyCount > 0 {
-		fmt.Printf(" (%d empty lines skipped)", emptyCount)
+	if s.inputRange.active() {
+		// Lines after the window were never read, so there is no meaningful total
+		tracker.report(s.messages)
+		fmt.Fprintf(s.messages, "Processed %d lines", result.scrubbed)
+	} else {
+		fmt.Fprintf(s.messages, "Processed %d lines out of %d total lines", result.scrubbed, result.lines)
+	}
+	if result.empty > 0 {
+		fmt.Fprintf(s.messages, " (%d empty lines skipped)", result.empty)
+	}
+	if result.failed > 0 {
+		fmt.Fprintf(s.messages, " (%d lines failed processing but were included)", result.failed)
+	}
+	if result.dropped > 0 {
+		fmt.Fprintf(s.messages, " (%d unparseable lines dropped)", result.dropped)
 	}
-	if failedCount > 0 {
-		fmt.Printf(" (%d lines failed processing but were included)", failedCount)
+	if result.collapsed > 0 {
+		fmt.Fprintf(s.messages, " (%d repeated lines collapsed)", result.collapsed)
+	}
+	if stopped != nil {
+		fmt.Fprint(s.messages, " (stopped at the deadline; the rest of the input was not read)")
+	}
+	fmt.Fprintln(s.messages)
+
+	// Show checksums for chain-of-custody records
+	s.inputChecksum, s.outputChecksum = "", ""
+	if inputHash != nil {
+		s.inputChecksum = hex.EncodeToString(inputHash.Sum(nil))
+		fmt.Fprintf(s.messages, "Input SHA-256: %s\n", s.inputChecksum)
+	}
+	if output != nil && s.checksums {
+		if len(output.parts) == 1 {
+			s.outputChecksum = output.parts[0].checksum()
+			fmt.Fprintf(s.messages, "Output SHA-256: %s\n", s.outputChecksum)
+		} else {
+			for _, part := range output.parts {
+				fmt.Fprintf(s.messages, "Output SHA-256 (%s): %s\n", part.path, part.checksum())
+			}
+		}
+	}
+
+	// An input without any content usually means the wrong file was given
+	if result.scrubbed == 0 && stopped == nil {
+		fmt.Fprintln(s.messages, "Warning: 0 lines scrubbed. The input contained no non-empty lines; check that the correct file was specified.")
+		if s.failOnEmpty {
+			return "", fmt.Errorf("input '%s' contained no non-empty lines (--fail-on-empty)", inputPath)
+		}
 	}
-	fmt.Println()
 	
 	// Show JSON processing statistics
 	if s.jsonSuccessCount > 0 || s.jsonFailureCount > 0 {
@@ -208,87 +352,170 @@ func (s *Scrubber) ProcessFile(inputPath, outputPath string, dryRun bool, compre
 		if totalProcessed > 0 {
 			jsonPercent := float64(s.jsonSuccessCount) / float64(totalProcessed) * 100
 			plainPercent := float64(s.jsonFailureCount) / float64(totalProcessed) * 100
-			fmt.Printf("JSON processed: %d lines (%.1f%%)\n", s.jsonSuccessCount, jsonPercent)
-			fmt.Printf("Plain text processed: %d lines (%.1f%%)\n", s.jsonFailureCount, plainPercent)
+			fmt.Fprintf(s.messages, "JSON processed: %d lines (%.1f%%)\n", s.jsonSuccessCount, jsonPercent)
+			if s.jsonFailureAction == constants.JSONFailureScrub {
+				fmt.Fprintf(s.messages, "Plain text processed: %d lines (%.1f%%)\n", s.jsonFailureCount, plainPercent)
+			} else {
+				fmt.Fprintf(s.messages, "Not valid JSON (%s): %d lines (%.1f%%)\n", s.jsonFailureAction, s.jsonFailureCount, plainPercent)
+			}
 		}
 	}
 	
 	// Show JSON issues summary if any occurred
 	if s.jsonFailureCount > 0 {
-		fmt.Printf("\nJSON Processing Issues:\n")
-		fmt.Printf("  %d lines had JSON parsing issues and were processed as plain text\n", s.jsonFailureCount)
+		fmt.Fprintf(s.messages, "\nJSON Processing Issues:\n")
+		fmt.Fprintf(s.messages, "  %d lines had JSON parsing issues and %s\n", s.jsonFailureCount, s.jsonFailureOutcome())
 		
 		// Show line numbers of first few failures
 		if len(s.jsonFailures) > 0 {
-			fmt.Print("  Lines with issues: ")
+			fmt.Fprint(s.messages, "  Lines with issues: ")
 			for i, failure := range s.jsonFailures {
 				if i >= 5 { // Show first 5 line numbers
-					fmt.Printf("... and %d more", s.jsonFailureCount-5)
+					fmt.Fprintf(s.messages, "... and %d more", s.jsonFailureCount-5)
 					break
 				}
 				if i > 0 {
-					fmt.Print(", ")
+					fmt.Fprint(s.messages, ", ")
 				}
-				fmt.Printf("%d", failure.LineNumber)
+				fmt.Fprintf(s.messages, "%d", failure.LineNumber)
 			}
-			fmt.Println()
+			fmt.Fprintln(s.messages)
 		}
 		
 		// In verbose mode, show detailed sample of failed lines
 		if s.verbose && len(s.jsonFailures) > 0 {
-			fmt.Println("  Sample failure details:")
+			fmt.Fprintln(s.messages, "  Sample failure details:")
 			for i, failure := range s.jsonFailures {
 				if i >= 3 { // Limit to first 3 in verbose output
-					fmt.Printf("    ... and %d more failures\n", len(s.jsonFailures)-3)
+					fmt.Fprintf(s.messages, "    ... and %d more failures\n", len(s.jsonFailures)-3)
 					break
 				}
-				fmt.Printf("    Line %d: %s\n", failure.LineNumber, failure.SampleContent)
-				fmt.Printf("      Error: %s\n", failure.Error)
+				fmt.Fprintf(s.messages, "    Line %d: %s\n", failure.LineNumber, failure.SampleContent)
+				fmt.Fprintf(s.messages, "      Error: %s\n", failure.Error)
 			}
 		}
 	}
 
-	// Return the actual path used (for dry run, return original path)
+	// Return the actual path used (for dry run, return original path; empty if no output was written)
 	if dryRun {
-		return outputPath, nil
+		return outputPath, stopped
 	}
-	return finalOutputPath, nil
+	if !writeLog {
+		return "", stopped
+	}
+	return output.parts[0].path, stopped
 }
 
 // processLogLine processes a single log line and returns the scrubbed version
 func (s *Scrubber) processLogLine(line, source string, lineNumber int) (string, error) {
+	if s.debugTrace == nil {
+		return s.scrubLogLine(line, source, lineNumber)
+	}
+	scrubbed, err := s.scrubLogLine(line, source, lineNumber)
+	s.traceLine(source, lineNumber, line, scrubbed, err)
+	return scrubbed, err
+}
+
+// scrubLogLine scrubs a single log line as JSON, falling back to plain text
+func (s *Scrubber) scrubLogLine(line, source string, lineNumber int) (string, error) {
+	if s.delimited != nil {
+		return s.scrubDelimitedLine(line, source)
+	}
+	if s.plainTextInput {
+		s.tracePath("plain text (input format)")
+		return s.scrubPlainTextLine(line, source), nil
+	}
+	if s.strictAllowlist {
+		return s.scrubStrictLine(line, source, lineNumber)
+	}
+
+	// Very wide JSON lines are scrubbed by streaming their tokens instead of building a map
+	if len(line) >= constants.WideLineThreshold {
+		if scrubbed, ok := s.processWideJSONLine(line, source); ok {
+			s.jsonSuccessCount++
+			s.tracePath("json (wide line, streamed)")
+			return s.canonicalOutput(s.normalizeTimestamps(scrubbed)), nil
+		}
+	}
+
 	// Try to parse as JSON to validate and extract user mapping data
-	var rawData map[string]interface{}
-	if err := json.Unmarshal([]byte(line), &rawData); err != nil {
+	rawData, err := decodeJSONObject(line)
+	if err != nil {
 		// Track JSON failure and show warning
 		s.trackJSONFailure(lineNumber, line, err)
-		return s.scrubPlainText(line, source), nil
+		s.tracePath(fmt.Sprintf("not JSON (%v); json failure action %s", err, s.jsonFailureAction))
+		switch s.jsonFailureAction {
+		case constants.JSONFailureDrop:
+			return "", ErrLineDropped
+		case constants.JSONFailureRedact:
+			return constants.RedactedLineMarker, nil
+		}
+		return s.scrubPlainTextLine(line, source), nil
 	}
 
 	// Successfully parsed as JSON
 	s.jsonSuccessCount++
+	s.tracePath("json")
 	
-	// If using mapping mode, detect and create user mappings first
-	// Always detect and create user mappings
-	s.detectAndMapUser(rawData)
+	// Detect and create user mappings (not used when replacing with fixed-width masks)
+	if !s.fixedWidth {
+		s.detectAndMapUser(rawData)
+	}
 
 	// Work directly with the JSON string to preserve field order
-	scrubbedJSON := s.scrubJSONString(line, source)
+	// Values at configured JSON paths are scrubbed first, and both they and
+	// already-anonymized markers are set aside so the regex scrubbers don't touch them
+	var spans protectedSpans
+	protected := s.applyStructuredScrubbing(line, source, &spans)
+	protected = s.protectPreserved(protected, &spans)
+	scrubbedJSON := spans.restore(s.scrubJSONString(protected, source))
+	scrubbedJSON = s.normalizeTimestamps(scrubbedJSON)
+
+	// Nothing was replaced, so the original line is written through without re-validating it
+	if scrubbedJSON == line {
+		return s.canonicalOutput(line), nil
+	}
 	
-	// Validate that the result is still valid JSON
-	var temp interface{}
-	if err := json.Unmarshal([]byte(scrubbedJSON), &temp); err != nil {
-		// If scrubbing broke JSON, return original
-		return line, nil
+	// A match that spanned a quote can break the JSON. The original line is never written in that case:
+	// the line counts as a JSON failure, and its scrubbed text is written as plain text, dropped or
+	// redacted as --json-failure-action says
+	if !json.Valid([]byte(scrubbedJSON)) {
+		s.jsonSuccessCount--
+		s.trackJSONFailure(lineNumber, scrubbedJSON, errScrubbedJSONInvalid)
+		s.tracePath(fmt.Sprintf("json; scrubbed result was not valid JSON; json failure action %s", s.jsonFailureAction))
+		switch s.jsonFailureAction {
+		case constants.JSONFailureDrop:
+			return "", ErrLineDropped
+		case constants.JSONFailureRedact:
+			return constants.RedactedLineMarker, nil
+		}
+		return scrubbedJSON, nil
 	}
 
-	return scrubbedJSON, nil
+	return s.canonicalOutput(scrubbedJSON), nil
+}
+
+// scrubPlainTextLine scrubs a whole line as plain text, keeping preserved markers and passthrough values
+func (s *Scrubber) scrubPlainTextLine(line, source string) string {
+	var spans protectedSpans
+	protected := s.protectPreserved(line, &spans)
+	protected = s.protectPassthroughText(protected, &spans)
+	return spans.restore(s.scrubPlainText(protected, source))
 }
 
 // scrubJSONString scrubs sensitive data from a JSON string
 func (s *Scrubber) scrubJSONString(jsonStr, source string) string {
 	result := jsonStr
 
+	// Redact organization-specific terms from the redact list (all levels)
+	result = s.scrubRedactList(result, source)
+
+	// Scrub SSNs and other national IDs (all levels)
+	result = s.scrubNationalIDs(result, source)
+
+	// Link names in Markdown mailto links and Slack user references to their user (all levels)
+	result = s.scrubUserLinks(result, source)
+
 	// Scrub emails (all levels)
 	result = s.scrubEmails(result, source)
 
@@ -298,8 +525,10 @@ func (s *Scrubber) scrubJSONString(jsonStr, source string) string {
 	// Scrub FQDNs (all levels)
 	result = s.scrubFQDNs(result, source)
 
-	// Scrub IP addresses (levels 2 and 3 only)
+	// Scrub phone numbers, hostnames and IP addresses (levels 2 and 3 only)
 	if s.level >= 2 {
+		result = s.scrubPhoneNumbers(result, source)
+		result = s.scrubHostnames(result, source)
 		result = s.scrubIPAddresses(result, source)
 	}
 
@@ -308,6 +537,13 @@ func (s *Scrubber) scrubJSONString(jsonStr, source string) string {
 		result = s.scrubUIDs(result, source)
 	}
 
+	// Map always-scrub values the heuristics missed to user tokens (all levels)
+	result = s.scrubAlwaysScrub(result, source)
+
+	// Apply configured custom patterns, then registered custom scrubbers (all levels)
+	result = s.scrubCustomPatterns(result, source)
+	result = s.applyCustomScrubbers(result, source)
+
 	return result
 }
 
@@ -315,6 +551,15 @@ func (s *Scrubber) scrubJSONString(jsonStr, source string) string {
 func (s *Scrubber) scrubPlainText(text, source string) string {
 	result := text
 
+	// Redact organization-specific terms from the redact list (all levels)
+	result = s.scrubRedactList(result, source)
+
+	// Scrub SSNs and other national IDs (all levels)
+	result = s.scrubNationalIDs(result, source)
+
+	// Link names in Markdown mailto links and Slack user references to their user (all levels)
+	result = s.scrubUserLinks(result, source)
+
 	// Scrub emails (all levels)
 	result = s.scrubEmails(result, source)
 
@@ -324,8 +569,10 @@ func (s *Scrubber) scrubPlainText(text, source string) string {
 	// Scrub FQDNs (all levels)
 	result = s.scrubFQDNs(result, source)
 
-	// Scrub IP addresses (levels 2 and 3 only)
+	// Scrub phone numbers, hostnames and IP addresses (levels 2 and 3 only)
 	if s.level >= 2 {
+		result = s.scrubPhoneNumbers(result, source)
+		result = s.scrubHostnames(result, source)
 		result = s.scrubIPAddresses(result, source)
 	}
 
@@ -334,6 +581,13 @@ func (s *Scrubber) scrubPlainText(text, source string) string {
 		result = s.scrubUIDs(result, source)
 	}
 
+	// Map always-scrub values the heuristics missed to user tokens (all levels)
+	result = s.scrubAlwaysScrub(result, source)
+
+	// Apply configured custom patterns, then registered custom scrubbers (all levels)
+	result = s.scrubCustomPatterns(result, source)
+	result = s.applyCustomScrubbers(result, source)
+
 	return result
 }
 
@@ -341,37 +595,77 @@ func (s *Scrubber) scrubPlainText(text, source string) string {
 var emailRegex = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
 
 func (s *Scrubber) scrubEmails(text, source string) string {
-	return emailRegex.ReplaceAllStringFunc(text, func(email string) string {
-		emailLower := strings.ToLower(email)
-		if scrubbed, exists := s.emailMap[emailLower]; exists {
-			s.trackReplacement(email, scrubbed, constants.TypeEmail, source)
-			return scrubbed
+	if s.lenientEmails {
+		re := lenientEmailRegex
+		if s.scrubbingBlock {
+			re = lenientInlineEmailRegex
 		}
+		return replaceAllStringFunc(re, text, func(email string) string {
+			return s.scrubEmailValue(normalizeLenientEmail(email), source)
+		})
+	}
+	return replaceAllStringFunc(emailRegex, text, func(email string) string {
+		return s.scrubEmailValue(email, source)
+	})
+}
 
-		// Always use user mapping for emails
-		scrubbed := s.getUserMappedEmail(email)
-		
-		s.emailMap[emailLower] = scrubbed
+// scrubEmailValue returns the mapped replacement for a single email address
+func (s *Scrubber) scrubEmailValue(email, source string) string {
+	// Allowlisted values are kept and never audited
+	if s.isAllowlisted(email) {
+		return email
+	}
+	emailLower := s.normalizeKey(email)
+	if scrubbed, exists := s.emailMap[emailLower]; exists {
 		s.trackReplacement(email, scrubbed, constants.TypeEmail, source)
 		return scrubbed
-	})
+	}
+
+	// Always use user mapping for emails, except for fixed-width masks, which create no mapping
+	var scrubbed string
+	if s.fixedWidth {
+		scrubbed = s.maskFixedWidth(email, constants.TypeEmail)
+	} else {
+		scrubbed = s.getUserMappedEmail(email)
+	}
+
+	s.emailMap[emailLower] = scrubbed
+	s.trackReplacement(email, scrubbed, constants.TypeEmail, source)
+	return scrubbed
 }
 
 // IP address regex pattern
 var ipRegex = regexp.MustCompile(`\b(?:[0-9]{1,3}\.){3}[0-9]{1,3}\b`)
 
 func (s *Scrubber) scrubIPAddresses(text, source string) string {
-	return ipRegex.ReplaceAllStringFunc(text, func(ip string) string {
-		if scrubbed, exists := s.ipMap[ip]; exists {
-			s.trackReplacement(ip, scrubbed, constants.TypeIP, source)
-			return scrubbed
-		}
+	return replaceAllStringFunc(ipRegex, text, func(ip string) string {
+		return s.scrubIPValue(ip, source)
+	})
+}
 
-		scrubbed := s.scrubIPByLevel(ip)
-		s.ipMap[ip] = scrubbed
+// scrubIPValue returns the replacement for a single IP address
+func (s *Scrubber) scrubIPValue(ip, source string) string {
+	// Allowlisted values are kept and never audited
+	if s.isAllowlisted(ip) {
+		return ip
+	}
+	if scrubbed, exists := s.ipMap[ip]; exists {
 		s.trackReplacement(ip, scrubbed, constants.TypeIP, source)
 		return scrubbed
-	})
+	}
+
+	var scrubbed string
+	switch {
+	case s.fixedWidth:
+		scrubbed = s.maskFixedWidth(ip, constants.TypeIP)
+	case s.ipStrategy == constants.IPStrategyClass:
+		scrubbed = s.scrubIPByClass(ip)
+	default:
+		scrubbed = s.scrubIPByLevel(ip)
+	}
+	s.ipMap[ip] = scrubbed
+	s.trackReplacement(ip, scrubbed, constants.TypeIP, source)
+	return scrubbed
 }
 
 // Username patterns - look for quoted usernames in JSON and word boundaries in plain text
@@ -379,7 +673,7 @@ var usernameRegex = regexp.MustCompile(`"(?:user|username)"\s*:\s*"([^"]+)"`)
 
 func (s *Scrubber) scrubUsernames(text, source string) string {
 	// Scrub usernames in JSON format
-	result := usernameRegex.ReplaceAllStringFunc(text, func(match string) string {
+	result := replaceAllStringFunc(usernameRegex, text, func(match string) string {
 		// Extract just the username value
 		parts := strings.Split(match, `":"`)
 		if len(parts) != 2 {
@@ -388,50 +682,84 @@ func (s *Scrubber) scrubUsernames(text, source string) string {
 		
 		key := parts[0] + `":"`
 		username := strings.TrimSuffix(parts[1], `"`)
-		
-		usernameLower := strings.ToLower(username)
-		if scrubbed, exists := s.userMap[usernameLower]; exists {
-			s.trackReplacement(username, scrubbed, constants.TypeUsername, source)
-			return key + scrubbed + `"`
+
+		// Leave preserved markers from other anonymizers untouched
+		if strings.Contains(username, preservePlaceholderMarker) {
+			return match
 		}
 
-		// Always use user mapping for usernames
-		scrubbed := s.getUserMappedName(username)
-		
-		s.userMap[usernameLower] = scrubbed
-		s.trackReplacement(username, scrubbed, constants.TypeUsername, source)
-		return key + scrubbed + `"`
+		return key + s.scrubUsernameValue(username, source) + `"`
 	})
 
 	return result
 }
 
+// scrubUsernameValue returns the mapped replacement for a single username
+func (s *Scrubber) scrubUsernameValue(username, source string) string {
+	// Allowlisted values are kept and never audited, which also puts them ahead of always-scrub values
+	if s.isAllowlisted(username) {
+		return username
+	}
+	usernameLower := s.normalizeKey(username)
+	if scrubbed, exists := s.userMap[usernameLower]; exists {
+		s.trackReplacement(username, scrubbed, constants.TypeUsername, source)
+		return scrubbed
+	}
+
+	// Always use user mapping for usernames
+	var scrubbed string
+	if s.fixedWidth {
+		scrubbed = s.maskFixedWidth(username, constants.TypeUsername)
+	} else {
+		scrubbed = s.getUserMappedName(username)
+	}
+
+	s.userMap[usernameLower] = scrubbed
+	s.trackReplacement(username, scrubbed, constants.TypeUsername, source)
+	return scrubbed
+}
+
 // UID patterns - look for long alphanumeric strings that look like IDs
 var uidRegex = regexp.MustCompile(`\b[a-z0-9]{` + fmt.Sprintf("%d", constants.MinUIDLength) + `,}\b`)
 
 func (s *Scrubber) scrubUIDs(text, source string) string {
-	return uidRegex.ReplaceAllStringFunc(text, func(uid string) string {
+	return replaceAllStringFunc(uidRegex, text, func(uid string) string {
 		if len(uid) < constants.MinUIDLength {
 			return uid
 		}
-
-		if scrubbed, exists := s.uidMap[uid]; exists {
-			s.trackReplacement(uid, scrubbed, constants.TypeUID, source)
-			return scrubbed
+		// A run of digits alone is a number, e.g. a large JSON number, not a Mattermost ID of random
+		// letters and digits; masking it would also leave a JSON line invalid
+		if strings.Trim(uid, "0123456789") == "" {
+			return uid
 		}
 
-		scrubbed := s.scrubUIDByLevel(uid)
-		s.uidMap[uid] = scrubbed
+		return s.scrubUIDValue(uid, source)
+	})
+}
+
+// scrubUIDValue returns the replacement for a single UID
+func (s *Scrubber) scrubUIDValue(uid, source string) string {
+	if scrubbed, exists := s.uidMap[uid]; exists {
 		s.trackReplacement(uid, scrubbed, constants.TypeUID, source)
 		return scrubbed
-	})
+	}
+
+	var scrubbed string
+	if s.fixedWidth {
+		scrubbed = s.maskFixedWidth(uid, constants.TypeUID)
+	} else {
+		scrubbed = s.scrubUIDByLevel(uid)
+	}
+	s.uidMap[uid] = scrubbed
+	s.trackReplacement(uid, scrubbed, constants.TypeUID, source)
+	return scrubbed
 }
 
 // FQDN patterns - look for http:// and https:// URLs
 var fqdnRegex = regexp.MustCompile(`https?://([a-zA-Z0-9.-]+\.[a-zA-Z]{2,})(/[^\s"',}\]]*)?`)
 
 func (s *Scrubber) scrubFQDNs(text, source string) string {
-	return fqdnRegex.ReplaceAllStringFunc(text, func(match string) string {
+	return replaceAllStringFunc(fqdnRegex, text, func(match string) string {
 		// Extract protocol, domain, and path
 		parts := fqdnRegex.FindStringSubmatch(match)
 		if len(parts) < 2 {
@@ -450,6 +778,14 @@ func (s *Scrubber) scrubFQDNs(text, source string) string {
 			s.trackReplacement(match, scrubbed, constants.TypeFQDN, source)
 			return scrubbed
 		}
+
+		// Fixed-width mode keeps the protocol and masks the rest of the URL in place
+		if s.fixedWidth {
+			scrubbedFQDN := protocol + s.maskFixedWidth(match[len(protocol):], constants.TypeFQDN)
+			s.fqdnMap[match] = scrubbedFQDN
+			s.trackReplacement(match, scrubbedFQDN, constants.TypeFQDN, source)
+			return scrubbedFQDN
+		}
 		
 		// Extract the base domain (remove subdomains for matching)
 		domainParts := strings.Split(domain, ".")
@@ -461,16 +797,7 @@ func (s *Scrubber) scrubFQDNs(text, source string) string {
 		}
 		
 		// Check if this domain matches any of our email domains
-		var mappedDomain string
-		if mapped, exists := s.domainMap[baseDomain]; exists {
-			// Found matching email domain
-			mappedDomain = mapped
-		} else {
-			// Not found in email domains, create new mapping
-			s.domainCounter++
-			mappedDomain = fmt.Sprintf("domain%d", s.domainCounter)
-			s.domainMap[baseDomain] = mappedDomain
-		}
+		mappedDomain := s.mapDomain(baseDomain)
 		
 		// Build scrubbed FQDN based on level
 		var scrubbedDomain string
@@ -515,6 +842,75 @@ func (s *Scrubber) scrubFQDNs(text, source string) string {
 	})
 }
 
+// Hostname patterns - look for quoted host, hostname and server fields in JSON
+var hostnameRegex = regexp.MustCompile(`"(?:host|hostname|server)"\s*:\s*"([^"]+)"`)
+
+func (s *Scrubber) scrubHostnames(text, source string) string {
+	return replaceAllStringFunc(hostnameRegex, text, func(match string) string {
+		parts := hostnameRegex.FindStringSubmatchIndex(match)
+		if len(parts) < 4 {
+			return match
+		}
+
+		key := match[:parts[2]]
+		value := match[parts[2]:parts[3]]
+
+		return key + s.scrubHostPortValue(value, source) + `"`
+	})
+}
+
+// scrubHostPortValue maps the host portion of a "host[:port]" value, keeping any port
+func (s *Scrubber) scrubHostPortValue(value, source string) string {
+	// Separate an optional port so only the host portion is mapped
+	host, port := value, ""
+	if idx := strings.LastIndex(value, ":"); idx > 0 && !strings.Contains(value[:idx], ":") {
+		host, port = value[:idx], value[idx:]
+	}
+
+	// Leave IP addresses for the IP scrubber and preserved markers untouched
+	if ipRegex.MatchString(host) || strings.Contains(host, preservePlaceholderMarker) {
+		return value
+	}
+
+	return s.scrubHostValue(host, source) + port
+}
+
+// scrubHostValue returns the mapped replacement for a single hostname
+func (s *Scrubber) scrubHostValue(host, source string) string {
+	hostLower := s.normalizeKey(host)
+	scrubbed, exists := s.hostMap[hostLower]
+	if !exists {
+		if s.fixedWidth {
+			scrubbed = s.maskFixedWidth(host, constants.TypeHost)
+		} else {
+			scrubbed = s.getMappedHost(hostLower)
+		}
+		s.hostMap[hostLower] = scrubbed
+	}
+
+	s.trackReplacement(host, scrubbed, constants.TypeHost, source)
+	return scrubbed
+}
+
+// getMappedHost creates a hostN token for a hostname, keeping the domain consistent with FQDN scrubbing
+func (s *Scrubber) getMappedHost(hostLower string) string {
+	s.hostCounter++
+	token := fmt.Sprintf("host%d", s.hostCounter)
+
+	// Fully qualified hostnames share the base domain mapping used for URLs and emails
+	domainParts := strings.Split(hostLower, ".")
+	if len(domainParts) > 2 {
+		baseDomain := strings.Join(domainParts[len(domainParts)-2:], ".")
+		token += "." + s.mapDomain(baseDomain)
+	}
+
+	if s.verbose {
+		fmt.Fprintf(s.messages, "Created host mapping: %s -> %s\n", hostLower, token)
+	}
+
+	return token
+}
+
 // detectAndMapUser detects username and email pairs in JSON data and creates user mappings
 func (s *Scrubber) detectAndMapUser(data map[string]interface{}) {
 	s.findUserMappingsRecursive(data)
@@ -545,14 +941,32 @@ func (s *Scrubber) findUserMappingsRecursive(data interface{}) {
 			}
 		}
 		
+		// With role tokens enabled, the object's roles field selects the token prefix
+		prefix := constants.UserTokenPrefix
+		if s.roleTokens {
+			prefix = roleTokenPrefix(v["roles"])
+		}
+
 		// If we found both username and email in this object, create mapping
-		if username != "" && email != "" {
-			s.createUserMapping(username, email)
+		// A known role is enough to map a lone username or email, so it gets the role token
+		// Values that were already anonymized by another tool are never mapped
+		hasPair := username != "" && email != ""
+		hasRole := prefix != constants.UserTokenPrefix && (username != "" || email != "")
+		if (hasPair || hasRole) && !s.isPreserved(username) && !s.isPreserved(email) && !s.isAllowlisted(username) && !s.isAllowlisted(email) {
+			s.createUserMapping(username, email, prefix)
+		}
+		if s.identityReport && !s.isPreserved(username) && !s.isPreserved(email) {
+			s.linkUserID(username, email, userIDField(v))
 		}
 		
-		// Recursively search all nested objects
-		for _, value := range v {
-			s.findUserMappingsRecursive(value)
+		// Recursively search all nested objects, in key order so tokens are assigned the same way every run
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s.findUserMappingsRecursive(v[key])
 		}
 		
 	case []interface{}:
@@ -563,25 +977,26 @@ func (s *Scrubber) findUserMappingsRecursive(data interface{}) {
 	}
 }
 
-// createUserMapping creates a mapping for a username/email pair
-func (s *Scrubber) createUserMapping(username, email string) {
+// createUserMapping creates a mapping for a username/email pair, either of which may be empty
+// A user that is already mapped keeps its existing token, even if a role is seen later
+func (s *Scrubber) createUserMapping(username, email, prefix string) {
 	// Normalize case for consistent lookups
-	usernameLower := strings.ToLower(username)
-	emailLower := strings.ToLower(email)
+	usernameLower := s.normalizeKey(username)
+	emailLower := s.normalizeKey(email)
 	
 	// Check if we already have a mapping for either username or email (case insensitive)
-	if mapping, exists := s.userMappings[usernameLower]; exists {
+	if mapping, exists := s.userMappings[usernameLower]; exists && username != "" {
 		// Link the email to existing mapping if not already linked
-		if mapping.Email == "" {
+		if mapping.Email == "" && email != "" {
 			mapping.Email = email
 			s.userMappings[emailLower] = mapping
 		}
 		return
 	}
 	
-	if mapping, exists := s.userMappings[emailLower]; exists {
+	if mapping, exists := s.userMappings[emailLower]; exists && email != "" {
 		// Link the username to existing mapping if not already linked
-		if mapping.Username == "" {
+		if mapping.Username == "" && username != "" {
 			mapping.Username = username
 			s.userMappings[usernameLower] = mapping
 		}
@@ -589,61 +1004,61 @@ func (s *Scrubber) createUserMapping(username, email string) {
 	}
 	
 	// Create new user mapping
-	s.userCounter++
-	mapping := &UserMapping{
-		Username: username,
-		Email:    email,
-		MappedID: s.userCounter,
+	firstSeen := username
+	if firstSeen == "" {
+		firstSeen = email
 	}
+	mapping := s.newUserMapping(prefix, firstSeen)
+	mapping.Username = username
+	mapping.Email = email
 	
-	s.userMappings[usernameLower] = mapping
-	s.userMappings[emailLower] = mapping
+	if username != "" {
+		s.userMappings[usernameLower] = mapping
+	}
+	if email != "" {
+		s.userMappings[emailLower] = mapping
+	}
 	
 	if s.verbose {
-		fmt.Printf("Created user mapping: %s / %s -> user%d\n", username, email, s.userCounter)
+		fmt.Fprintf(s.messages, "Created user mapping: %s / %s -> %s\n", username, email, mapping.Token())
 	}
 }
 
 // getUserMappedName returns the mapped username for a given original username
 func (s *Scrubber) getUserMappedName(username string) string {
-	usernameLower := strings.ToLower(username)
+	usernameLower := s.normalizeKey(username)
 	if mapping, exists := s.userMappings[usernameLower]; exists {
-		return fmt.Sprintf("user%d", mapping.MappedID)
+		return s.userToken(mapping, username)
 	}
 	// If no mapping exists, create one for standalone username
-	s.userCounter++
-	mapping := &UserMapping{
-		Username: username,
-		MappedID: s.userCounter,
-	}
+	mapping := s.newUserMapping(constants.UserTokenPrefix, username)
+	mapping.Username = username
 	s.userMappings[usernameLower] = mapping
 	
 	if s.verbose {
-		fmt.Printf("Created standalone user mapping: %s -> user%d\n", username, s.userCounter)
+		fmt.Fprintf(s.messages, "Created standalone user mapping: %s -> %s\n", username, s.userToken(mapping, username))
 	}
 	
-	return fmt.Sprintf("user%d", mapping.MappedID)
+	return s.userToken(mapping, username)
 }
 
 // getUserMappedEmail returns the mapped email for a given original email
 func (s *Scrubber) getUserMappedEmail(email string) string {
-	emailLower := strings.ToLower(email)
+	emailLower := s.normalizeKey(email)
 	if mapping, exists := s.userMappings[emailLower]; exists {
-		return fmt.Sprintf("user%d@%s", mapping.MappedID, s.getMappedDomain(email))
+		return s.formatMappedEmail(mapping, email, s.getMappedDomain(email))
 	}
 	// If no mapping exists, create one for standalone email
-	s.userCounter++
-	mapping := &UserMapping{
-		Email: email,
-		MappedID: s.userCounter,
-	}
+	mapping := s.newUserMapping(constants.UserTokenPrefix, email)
+	mapping.Email = email
 	s.userMappings[emailLower] = mapping
 	
+	scrubbed := s.formatMappedEmail(mapping, email, s.getMappedDomain(email))
 	if s.verbose {
-		fmt.Printf("Created standalone email mapping: %s -> user%d@%s\n", email, s.userCounter, s.getMappedDomain(email))
+		fmt.Fprintf(s.messages, "Created standalone email mapping: %s -> %s\n", email, scrubbed)
 	}
 	
-	return fmt.Sprintf("user%d@%s", mapping.MappedID, s.getMappedDomain(email))
+	return scrubbed
 }
 
 // getMappedDomain returns the mapped domain for a given email address
@@ -651,80 +1066,145 @@ func (s *Scrubber) getMappedDomain(email string) string {
 	// Extract domain from email
 	parts := strings.Split(email, "@")
 	if len(parts) != 2 {
-		return "domain1" // fallback for invalid emails
+		return s.domainPrefix + "1" // fallback for invalid emails
 	}
-	
-	originalDomain := strings.ToLower(parts[1])
-	
-	// Check if we already have a mapping for this domain
-	if mappedDomain, exists := s.domainMap[originalDomain]; exists {
-		return mappedDomain
+
+	// Internal domains are kept and external ones hidden entirely
+	if len(s.internalDomains) > 0 {
+		return s.emailDomainReplacement(parts[1])
 	}
 	
-	// Create new domain mapping
-	s.domainCounter++
-	mappedDomain := fmt.Sprintf("domain%d", s.domainCounter)
-	s.domainMap[originalDomain] = mappedDomain
-	
-	if s.verbose {
-		fmt.Printf("Created domain mapping: %s -> %s\n", originalDomain, mappedDomain)
+	return s.mapDomain(parts[1])
+}
+
+// jsonFailureOutcome describes what happened to lines that failed JSON parsing, for the summary
+func (s *Scrubber) jsonFailureOutcome() string {
+	switch s.jsonFailureAction {
+	case constants.JSONFailureDrop:
+		return "were dropped"
+	case constants.JSONFailureRedact:
+		return "were replaced with " + constants.RedactedLineMarker
 	}
-	
-	return mappedDomain
+	return "were processed as plain text"
 }
 
 // trackReplacement tracks a replacement for audit purposes
 func (s *Scrubber) trackReplacement(original, newValue, valueType, source string) {
+	s.traceMatch(valueType, original, newValue)
 	if entry, exists := s.auditEntries[original]; exists {
 		entry.TimesReplaced++
 	} else {
-		s.auditEntries[original] = &AuditEntry{
-			OriginalValue: original,
+		entry := &AuditEntry{
 			NewValue:      newValue,
 			TimesReplaced: 1,
 			Type:          valueType,
 			Source:        source,
 		}
+		// Excluded types are only counted, so their originals are neither kept nor hashed
+		if !s.noAuditTypes[valueType] {
+			entry.OriginalValue = s.auditOriginal(original)
+		}
+		s.auditEntries[original] = entry
+		s.recordNewAuditEntry(original)
+	}
+}
+
+// PrintReplacementSummary prints, per scrub type, the total number of replacements (every occurrence,
+// including repeats on one line) and the number of distinct original values, across every file processed
+func (s *Scrubber) PrintReplacementSummary() {
+	stats := s.Stats()
+	if s.timeFormat != "" {
+		fmt.Fprintf(s.messages, "Timestamps normalized to %s: %d values\n", s.timeFormat, stats.TimestampsNormalized)
+	}
+	if stats.LinesCollapsed > 0 {
+		fmt.Fprintf(s.messages, "Repeated lines collapsed: %d lines written as repeat counts, so the output has fewer lines than the input\n", stats.LinesCollapsed)
+	}
+	if len(s.internalDomains) > 0 {
+		fmt.Fprintf(s.messages, "Email addresses: %d internal (domain kept), %d external (domain removed)\n", stats.InternalEmails, stats.ExternalEmails)
+	}
+
+	totals := stats.Replacements
+	if len(totals) == 0 {
+		return
+	}
+	types := make([]string, 0, len(totals))
+	for valueType := range totals {
+		types = append(types, valueType)
+	}
+
+	fmt.Fprintln(s.messages, "Replacements by type:")
+	var totalReplacements, totalUnique int
+	for _, valueType := range summaryTypeOrder(types) {
+		count := totals[valueType]
+		fmt.Fprintf(s.messages, "  %s: %d replacements, %d unique values\n", valueType, count, stats.UniqueValues[valueType])
+		// Fixed domain mappings are also counted within the emails and URLs they appear in
+		if valueType != constants.TypeDomain {
+			totalReplacements += count
+			totalUnique += stats.UniqueValues[valueType]
+		}
+	}
+	fmt.Fprintf(s.messages, "  total: %d replacements, %d unique values\n", totalReplacements, totalUnique)
+}
+
+// summaryTypeOrder orders scrub types for the summaries: built-in types first in pipeline order,
+// then any others alphabetically
+func summaryTypeOrder(types []string) []string {
+	order := []string{constants.TypeRedact, constants.TypeNationalID, constants.TypeEmail, constants.TypeUsername, constants.TypeFQDN, constants.TypePhone, constants.TypeHost, constants.TypeIP, constants.TypeUID, constants.TypeNumericID}
+	present := make(map[string]bool, len(types))
+	for _, valueType := range types {
+		present[valueType] = true
+	}
+
+	var ordered, others []string
+	for _, valueType := range order {
+		if present[valueType] {
+			ordered = append(ordered, valueType)
+			delete(present, valueType)
+		}
 	}
+	for valueType := range present {
+		others = append(others, valueType)
+	}
+	sort.Strings(others)
+	return append(ordered, others...)
 }
 
 // WriteAuditFile writes the audit log to a CSV file
 func (s *Scrubber) WriteAuditFile(filePath string, overwriteAction string) (string, error) {
 	// Check if audit file already exists
-	finalAuditPath := filePath
-	if checkFileExists(filePath) {
-		choice, err := s.handleFileConflict(filePath, overwriteAction)
-		if err != nil {
-			return "", fmt.Errorf("failed to handle file conflict: %w", err)
-		}
-		
-		switch choice {
-		case "cancel":
-			return "", createCancelError(filePath, overwriteAction)
-		case "rename":
-			finalAuditPath = generateTimestampSuffix(filePath)
-			fmt.Printf("Audit file will be written to: %s\n", finalAuditPath)
-		case "overwrite":
-			// Continue with original path
-		}
+	finalAuditPath, err := s.resolveFileConflict(filePath, overwriteAction, "Audit file")
+	if err != nil {
+		return "", err
 	}
 	
-	file, err := os.Create(finalAuditPath)
+	file, err := createArtifact(finalAuditPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to create audit file: %w", err)
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	if err := s.WriteAuditCSV(file); err != nil {
+		return "", err
+	}
+	// Objects are only uploaded on close, so its error matters
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write audit file: %w", err)
+	}
+
+	return finalAuditPath, nil
+}
+
+// WriteAuditCSV writes the audit entries as CSV with a header row, in the format set by SetAuditCSVFormat
+func (s *Scrubber) WriteAuditCSV(w io.Writer) error {
+	writer := s.newAuditCSVWriter(w)
 
 	// Write header
 	if err := writer.Write([]string{"Original Value", "New Value", "Times Replaced", "Type", "Source"}); err != nil {
-		return "", fmt.Errorf("failed to write CSV header: %w", err)
+		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
 	// Write audit entries
-	for _, entry := range s.auditEntries {
+	for _, entry := range s.sortedAuditEntries() {
 		record := []string{
 			entry.OriginalValue,
 			entry.NewValue,
@@ -733,11 +1213,38 @@ func (s *Scrubber) WriteAuditFile(filePath string, overwriteAction string) (stri
 			entry.Source,
 		}
 		if err := writer.Write(record); err != nil {
-			return "", fmt.Errorf("failed to write CSV record: %w", err)
+			return fmt.Errorf("failed to write CSV record: %w", err)
 		}
 	}
 
-	return finalAuditPath, nil
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write CSV audit: %w", err)
+	}
+	return nil
+}
+
+// sortedAuditEntries returns the audited entries ordered by type, then original value, so audit
+// files are identical across runs over the same input
+func (s *Scrubber) sortedAuditEntries() []*AuditEntry {
+	originals := make([]string, 0, len(s.auditEntries))
+	for original, entry := range s.auditEntries {
+		if s.audited(entry) {
+			originals = append(originals, original)
+		}
+	}
+	sort.Slice(originals, func(i, j int) bool {
+		a, b := s.auditEntries[originals[i]], s.auditEntries[originals[j]]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return originals[i] < originals[j]
+	})
+
+	entries := make([]*AuditEntry, 0, len(originals))
+	for _, original := range originals {
+		entries = append(entries, s.auditEntries[original])
+	}
+	return entries
 }
 
 // trackJSONFailure records a JSON parsing failure for reporting
@@ -763,9 +1270,68 @@ func (s *Scrubber) trackJSONFailure(lineNumber int, line string, err error) {
 }
 
 // checkFileExists returns true if the file exists
-func checkFileExists(filePath string) bool {
+// For an s3:// URI it checks the object, and returns an error when it can't be checked (denied access,
+// network failure, missing credentials) rather than risk overwriting it
+func checkFileExists(filePath string) (bool, error) {
+	if IsObjectStoreURI(filePath) {
+		return objectExists(filePath)
+	}
 	_, err := os.Stat(filePath)
-	return err == nil
+	return err == nil, nil
+}
+
+// resolveFileConflict applies the overwrite action when filePath already exists
+// Returns the path to write to, which has a timestamp suffix if the user chose to rename
+func (s *Scrubber) resolveFileConflict(filePath string, overwriteAction string, label string) (string, error) {
+	exists, err := checkFileExists(filePath)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return filePath, nil
+	}
+
+	choice, err := s.handleFileConflict(filePath, overwriteAction)
+	if err != nil {
+		return "", fmt.Errorf("failed to handle file conflict: %w", err)
+	}
+
+	switch choice {
+	case "cancel":
+		if s.cancelScope == constants.CancelScopeFile {
+			fmt.Fprintf(s.messages, "Warning: %s '%s' already exists and will not be written (--cancel-scope file)\n", label, filePath)
+			return "", ErrArtifactSkipped
+		}
+		return "", createCancelError(filePath, overwriteAction)
+	case "rename":
+		renamedPath, err := s.renamedPath(filePath)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(s.messages, "%s will be written to: %s\n", label, renamedPath)
+		return renamedPath, nil
+	default:
+		// Continue with original path
+		return filePath, nil
+	}
+}
+
+// ErrArtifactSkipped is returned when a file conflict was cancelled with a file-level cancel scope.
+// The artifact is not written, but the rest of the run continues.
+var ErrArtifactSkipped = errors.New("artifact skipped due to file conflict")
+
+// ErrLineDropped is returned for a line that must be left out of the output, e.g. by ScrubLine for a
+// line that failed JSON parsing with the drop failure action
+var ErrLineDropped = errors.New("line dropped")
+
+// errScrubbedJSONInvalid is recorded as the JSON failure of a line that was valid JSON until a
+// replacement spanning a quote broke it
+var errScrubbedJSONInvalid = errors.New("scrubbing left the line invalid JSON")
+
+// ResolveArtifactPath applies the overwrite action to an additional artifact (manifest, report, etc.)
+// Returns the path to write to, which may differ from filePath if renamed
+func (s *Scrubber) ResolveArtifactPath(filePath string, overwriteAction string, label string) (string, error) {
+	return s.resolveFileConflict(filePath, overwriteAction, label)
 }
 
 // createCancelError creates an appropriate error message based on the overwrite action
@@ -798,7 +1364,7 @@ func (s *Scrubber) handleFileConflict(filePath string, overwriteAction string) (
 		if err == nil && choice != "cancel" {
 			// Remember the choice for subsequent files
 			s.userOverwriteChoice = choice
-			fmt.Printf("This choice will be applied to all subsequent file conflicts in this session.\n")
+			fmt.Fprintf(s.messages, "This choice will be applied to all subsequent file conflicts in this session.\n")
 		}
 		return choice, err
 	default:
@@ -809,7 +1375,7 @@ func (s *Scrubber) handleFileConflict(filePath string, overwriteAction string) (
 		choice, err := s.promptUserChoice(filePath)
 		if err == nil && choice != "cancel" {
 			s.userOverwriteChoice = choice
-			fmt.Printf("This choice will be applied to all subsequent file conflicts in this session.\n")
+			fmt.Fprintf(s.messages, "This choice will be applied to all subsequent file conflicts in this session.\n")
 		}
 		return choice, err
 	}
@@ -818,8 +1384,12 @@ func (s *Scrubber) handleFileConflict(filePath string, overwriteAction string) (
 // promptUserChoice prompts the user to choose how to handle an existing file
 // Returns: "overwrite", "cancel", or "rename"
 func (s *Scrubber) promptUserChoice(filePath string) (string, error) {
-	fmt.Printf("File '%s' already exists.\n", filePath)
-	fmt.Print("Choose an option: (o)verwrite, (c)ancel, or (r)ename with timestamp? ")
+	fmt.Fprintf(s.messages, "File '%s' already exists.\n", filePath)
+	renameWith := "timestamp"
+	if s.renameScheme == constants.RenameSchemeSequential {
+		renameWith = "number"
+	}
+	fmt.Fprintf(s.messages, "Choose an option: (o)verwrite, (c)ancel, or (r)ename with %s? ", renameWith)
 	
 	var choice string
 	_, err := fmt.Scanln(&choice)
@@ -836,65 +1406,117 @@ func (s *Scrubber) promptUserChoice(filePath string) (string, error) {
 	case "r", "rename":
 		return "rename", nil
 	default:
-		fmt.Println("Invalid choice. Please enter 'o', 'c', or 'r'.")
+		fmt.Fprintln(s.messages, "Invalid choice. Please enter 'o', 'c', or 'r'.")
 		return s.promptUserChoice(filePath) // Recursive call for invalid input
 	}
 }
 
+// renamedPath returns the name an existing file's replacement is written to, using the rename scheme
+func (s *Scrubber) renamedPath(originalPath string) (string, error) {
+	if s.renameScheme == constants.RenameSchemeSequential {
+		return generateSequentialSuffix(originalPath)
+	}
+	return generateTimestampSuffix(originalPath), nil
+}
+
+// generateSequentialSuffix appends the first _N suffix (starting at 1) that gives a name not already in use
+// Unlike timestamps, this stays unique for repeated runs within the same second
+func generateSequentialSuffix(originalPath string) (string, error) {
+	base := filepath.Base(originalPath)
+	ext := filepath.Ext(base)
+	nameWithoutExt := strings.TrimSuffix(base, ext)
+
+	for n := 1; ; n++ {
+		candidate := siblingPath(originalPath, fmt.Sprintf("%s_%d%s", nameWithoutExt, n, ext))
+		exists, err := checkFileExists(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+	}
+}
+
 // generateTimestampSuffix creates a timestamp suffix for filenames
 func generateTimestampSuffix(originalPath string) string {
 	timestamp := time.Now().Format("20060102_150405")
 	
 	// Split the path into directory, name, and extension
-	dir := filepath.Dir(originalPath)
 	base := filepath.Base(originalPath)
 	ext := filepath.Ext(base)
 	nameWithoutExt := strings.TrimSuffix(base, ext)
 	
 	newName := fmt.Sprintf("%s_%s%s", nameWithoutExt, timestamp, ext)
-	return filepath.Join(dir, newName)
+	return siblingPath(originalPath, newName)
 }
 
 // WriteAuditFileJSON writes the audit log to a JSON file
 // Returns the actual file path used (which may differ if renamed)
 func (s *Scrubber) WriteAuditFileJSON(filePath string, overwriteAction string) (string, error) {
 	// Check if audit file already exists
-	finalAuditPath := filePath
-	if checkFileExists(filePath) {
-		choice, err := s.handleFileConflict(filePath, overwriteAction)
-		if err != nil {
-			return "", fmt.Errorf("failed to handle file conflict: %w", err)
-		}
-		
-		switch choice {
-		case "cancel":
-			return "", createCancelError(filePath, overwriteAction)
-		case "rename":
-			finalAuditPath = generateTimestampSuffix(filePath)
-			fmt.Printf("Audit file will be written to: %s\n", finalAuditPath)
-		case "overwrite":
-			// Continue with original path
-		}
+	finalAuditPath, err := s.resolveFileConflict(filePath, overwriteAction, "Audit file")
+	if err != nil {
+		return "", err
 	}
 	
-	file, err := os.Create(finalAuditPath)
+	file, err := createArtifact(finalAuditPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to create audit file: %w", err)
 	}
 	defer file.Close()
 
-	// Convert audit entries to a slice for JSON serialization
-	auditData := make([]AuditEntry, 0, len(s.auditEntries))
-	for _, entry := range s.auditEntries {
-		auditData = append(auditData, *entry)
+	if err := s.WriteAuditJSON(file); err != nil {
+		return "", fmt.Errorf("failed to write JSON audit file: %w", err)
 	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write JSON audit file: %w", err)
+	}
+
+	return finalAuditPath, nil
+}
 
+// WriteAuditJSON writes the audit entries as an indented JSON array
+func (s *Scrubber) WriteAuditJSON(w io.Writer) error {
 	// Write JSON with proper formatting
-	encoder := json.NewEncoder(file)
+	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(auditData); err != nil {
-		return "", fmt.Errorf("failed to write JSON audit file: %w", err)
+	return encoder.Encode(s.AuditEntries())
+}
+
+// WriteAuditFileNDJSON writes the audit log to a newline-delimited JSON file
+// Returns the actual file path used (which may differ if renamed)
+func (s *Scrubber) WriteAuditFileNDJSON(filePath string, overwriteAction string) (string, error) {
+	finalAuditPath, err := s.resolveFileConflict(filePath, overwriteAction, "Audit file")
+	if err != nil {
+		return "", err
+	}
+
+	file, err := createArtifact(finalAuditPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create audit file: %w", err)
+	}
+	defer file.Close()
+
+	if err := s.WriteAuditNDJSON(file); err != nil {
+		return "", fmt.Errorf("failed to write NDJSON audit file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write NDJSON audit file: %w", err)
 	}
 
 	return finalAuditPath, nil
+}
+
+// WriteAuditNDJSON writes each audit entry as one compact JSON object per line, in the same order
+// as the other formats, so SIEMs and log shippers can ingest records one line at a time
+func (s *Scrubber) WriteAuditNDJSON(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range s.AuditEntries() {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
 }
\ No newline at end of file
@@ -0,0 +1,154 @@
+package scrubber
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// writeTestInput writes a log file into a temporary directory and returns its path
+func writeTestInput(t testing.TB, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readTestFile returns the contents of a file written by a test
+func readTestFile(t testing.TB, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDeterministicRunsAreByteIdentical(t *testing.T) {
+	input := writeTestInput(t, "mattermost.log", selfTestSample+
+		`{"team":{"owner":{"user":"dave","email":"dave@example.org"}},"admin":{"user":"erin","email":"erin@example.org"},"msg":"from 10.1.2.3"}`+"\n"+
+		"plain text from carol@example.net at 198.51.100.7\n")
+
+	var runs [2][]byte
+	for i := range runs {
+		s := NewScrubber(constants.ScrubLevelHigh, false)
+		s.SetCanonicalJSON(true)
+
+		dir := t.TempDir()
+		output := filepath.Join(dir, "out.log")
+		if _, err := s.ProcessFile(input, output, false, false, constants.OverwriteOverwrite); err != nil {
+			t.Fatalf("run %d: ProcessFile: %v", i+1, err)
+		}
+		csvPath, err := s.WriteAuditFile(filepath.Join(dir, "audit.csv"), constants.OverwriteOverwrite)
+		if err != nil {
+			t.Fatalf("run %d: WriteAuditFile: %v", i+1, err)
+		}
+		jsonPath, err := s.WriteAuditFileJSON(filepath.Join(dir, "audit.json"), constants.OverwriteOverwrite)
+		if err != nil {
+			t.Fatalf("run %d: WriteAuditFileJSON: %v", i+1, err)
+		}
+
+		runs[i] = append(runs[i], readTestFile(t, output)...)
+		runs[i] = append(runs[i], readTestFile(t, csvPath)...)
+		runs[i] = append(runs[i], readTestFile(t, jsonPath)...)
+	}
+
+	if len(runs[0]) == 0 {
+		t.Fatal("runs wrote nothing")
+	}
+	if !bytes.Equal(runs[0], runs[1]) {
+		t.Errorf("two runs differ:\n%s\n---\n%s", runs[0], runs[1])
+	}
+}
+
+func TestNonASCIIUsernamesShareOneToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []string // Spellings of one username that must map to one token
+	}{
+		{"composed and decomposed accents", []string{"Jos\u00e9", "Jose\u0301", "JOSE\u0301"}},
+		{"German sharp s", []string{"straße", "STRASSE", "Strasse"}},
+		{"Greek final sigma", []string{"ΟΔΥΣΣΕΥΣ", "οδυσσευς", "οδυσσευσ"}},
+		{"full-width letters", []string{"ＡＬＩＣＥ", "ａｌｉｃｅ"}},
+		{"Cyrillic", []string{"Дмитрий", "ДМИТРИЙ"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScrubber(1, false)
+			var token string
+			for _, variant := range tt.variants {
+				got, err := s.ScrubLine(`{"user":"`+variant+`"}`, "test.log")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if token == "" {
+					token = got
+				}
+				if got != token {
+					t.Errorf("%q scrubbed to %s, want %s like %q", variant, got, token, tt.variants[0])
+				}
+			}
+			if token != `{"user":"user1"}` {
+				t.Errorf("first spelling scrubbed to %s", token)
+			}
+		})
+	}
+}
+
+func TestNormalizeKeyKeepsDistinctNames(t *testing.T) {
+	s := NewScrubber(1, false)
+	distinct := [][2]string{
+		{"José", "Jose"},
+		{"ålice", "alice"},
+		{"ｓａｍ", "sam"},
+	}
+	for _, pair := range distinct {
+		if s.normalizeKey(pair[0]) == s.normalizeKey(pair[1]) {
+			t.Errorf("%q and %q normalize to the same key", pair[0], pair[1])
+		}
+	}
+}
+
+func TestRepeatedIPCounts(t *testing.T) {
+	s := NewScrubber(2, false)
+	lines := []string{
+		`{"allowed":"10.1.2.3,10.1.2.3,10.1.2.3,10.1.2.3,10.1.2.3"}`,
+		`{"msg":"retry from 10.1.2.3 and 192.168.7.9"}`,
+		`plain 192.168.7.9 again`,
+	}
+	var outputs []string
+	for _, line := range lines {
+		got, err := s.ScrubLine(line, "test.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, got)
+	}
+
+	// Every occurrence of one address, on one line or across lines, maps identically
+	if want := `{"allowed":"***.***.***.3,***.***.***.3,***.***.***.3,***.***.***.3,***.***.***.3"}`; outputs[0] != want {
+		t.Errorf("line 1 = %s, want %s", outputs[0], want)
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range s.AuditEntries() {
+		counts[entry.OriginalValue] = entry.TimesReplaced
+	}
+	if counts["10.1.2.3"] != 6 || counts["192.168.7.9"] != 2 {
+		t.Errorf("times replaced = %v, want 10.1.2.3: 6 and 192.168.7.9: 2", counts)
+	}
+
+	stats := s.Stats()
+	if stats.Replacements[constants.TypeIP] != 8 || stats.UniqueValues[constants.TypeIP] != 2 {
+		t.Errorf("ip replacements = %d, unique = %d, want 8 and 2", stats.Replacements[constants.TypeIP], stats.UniqueValues[constants.TypeIP])
+	}
+	if stats.TotalReplacements() != 8 {
+		t.Errorf("total replacements = %d, want 8", stats.TotalReplacements())
+	}
+}
@@ -0,0 +1,113 @@
+package scrubber
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// selfTestSample is a small log with one value of every supported PII type
+//
+//go:embed selftest_sample.log
+var selfTestSample string
+
+// selfTestSource is the file name reported for the embedded sample
+const selfTestSource = "selftest_sample.log"
+
+// SelfTestResult is the outcome of checking one PII category in the self-test
+type SelfTestResult struct {
+	Category string
+	Passed   bool
+	Detail   string
+}
+
+// selfTestCase names a value from the sample that must not survive scrubbing
+// auditType is the audit entry type expected for it ("" when the value is never audited)
+type selfTestCase struct {
+	category  string
+	original  string
+	auditType string
+}
+
+var selfTestCases = []selfTestCase{
+	{"Emails", "alice.smith@example.com", constants.TypeEmail},
+	{"Usernames", "alice.smith", ""},
+	{"URLs", "https://chat.example.com/login", constants.TypeFQDN},
+	{"Hostnames", "app-01.example.com", constants.TypeHost},
+	{"IP addresses", "203.0.113.42", constants.TypeIP},
+	{"Internal IDs", "k3j9x8w2q7p4m1n6b5v0c8z2a1", constants.TypeUID},
+	{"SSNs / national IDs", "123-45-6789", constants.TypeNationalID},
+	{"Phone numbers", "(555) 234-5678", constants.TypePhone},
+	{"Push message previews", "lunch at noon?", ""},
+	{"Push sender names", "bob.jones", constants.TypeUsername},
+}
+
+// RunSelfTest scrubs the embedded sample at the highest level and checks that every
+// supported PII type was replaced and audited. It needs no files and writes nothing.
+func RunSelfTest() ([]SelfTestResult, error) {
+	s := NewScrubber(constants.ScrubLevelHigh, false)
+	output, err := s.scrubSelfTestSample()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SelfTestResult, 0, len(selfTestCases)+1)
+	for _, tc := range selfTestCases {
+		results = append(results, s.checkSelfTestCase(tc, output))
+	}
+
+	// Scrubbing must never turn a JSON line into invalid JSON
+	jsonResult := SelfTestResult{Category: "JSON structure", Passed: s.jsonFailureCount == 0}
+	if !jsonResult.Passed {
+		jsonResult.Detail = fmt.Sprintf("%d sample line(s) failed to parse", s.jsonFailureCount)
+	}
+	results = append(results, jsonResult)
+
+	return results, nil
+}
+
+// scrubSelfTestSample scrubs the embedded sample line by line and returns the scrubbed text
+func (s *Scrubber) scrubSelfTestSample() (string, error) {
+	var output strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(selfTestSample))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		scrubbed, err := s.processLogLine(scanner.Text(), selfTestSource, lineNumber)
+		if err != nil {
+			return "", fmt.Errorf("self-test line %d: %w", lineNumber, err)
+		}
+		output.WriteString(scrubbed)
+		output.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("reading self-test sample: %w", err)
+	}
+	return output.String(), nil
+}
+
+// checkSelfTestCase verifies one value is gone from the output and, when audited, recorded with the right type
+func (s *Scrubber) checkSelfTestCase(tc selfTestCase, output string) SelfTestResult {
+	result := SelfTestResult{Category: tc.category}
+	if strings.Contains(output, tc.original) {
+		result.Detail = fmt.Sprintf("'%s' is still present in the output", tc.original)
+		return result
+	}
+	if tc.auditType != "" {
+		entry, exists := s.auditEntries[tc.original]
+		if !exists {
+			result.Detail = fmt.Sprintf("'%s' has no audit entry", tc.original)
+			return result
+		}
+		if entry.Type != tc.auditType {
+			result.Detail = fmt.Sprintf("'%s' was audited as %s, expected %s", tc.original, entry.Type, tc.auditType)
+			return result
+		}
+		result.Detail = fmt.Sprintf("'%s' -> '%s'", tc.original, entry.NewValue)
+	}
+	result.Passed = true
+	return result
+}
@@ -0,0 +1,116 @@
+package scrubber
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Stack trace kinds
+const (
+	traceNone = iota
+	traceGo
+	traceJava
+)
+
+var (
+	// goroutineHeaderRegex matches the header of each goroutine in a Go trace
+	goroutineHeaderRegex = regexp.MustCompile(`^goroutine \d+ \[`)
+	// goFrameFuncRegex matches the unindented function line of a Go frame, e.g. main.run(0x1, ...)
+	goFrameFuncRegex = regexp.MustCompile(`^[\w./*()\[\]-]+\(.*\)$`)
+	// javaExceptionRegex matches the first line of a Java exception
+	javaExceptionRegex = regexp.MustCompile(`^(?:Exception in thread "[^"]*" )?[\w$.]+(?:Exception|Error|Throwable)(?::|$)`)
+	// homePathRegex matches the user directory segment of home paths, which often holds a real username
+	homePathRegex = regexp.MustCompile(`(/home/|/Users/|[A-Za-z]:\\Users\\)([^/\\\s:"']+)`)
+)
+
+// sharedHomeDirs are directories under /Users or C:\Users that don't belong to a person
+var sharedHomeDirs = map[string]bool{"shared": true, "public": true, "default": true, "all users": true}
+
+// stackTrace buffers consecutive lines that belong to one multiline stack trace
+type stackTrace struct {
+	kind  int
+	start int // Line number of the first line
+	lines []string
+}
+
+// stackTraceKind reports whether a line starts a Go or Java stack trace
+func stackTraceKind(line string) int {
+	switch {
+	case strings.HasPrefix(line, "panic: ") || goroutineHeaderRegex.MatchString(line):
+		return traceGo
+	case javaExceptionRegex.MatchString(line):
+		return traceJava
+	default:
+		return traceNone
+	}
+}
+
+// active reports whether lines are being buffered
+func (t *stackTrace) active() bool {
+	return len(t.lines) > 0
+}
+
+// begin starts buffering a new trace if the line opens one
+func (t *stackTrace) begin(lineNumber int, line string) bool {
+	kind := stackTraceKind(line)
+	if kind == traceNone {
+		return false
+	}
+	t.kind, t.start, t.lines = kind, lineNumber, []string{line}
+	return true
+}
+
+// continues reports whether a line belongs to the trace being buffered
+func (t *stackTrace) continues(line string) bool {
+	if strings.TrimSpace(line) == "" {
+		return false
+	}
+
+	switch t.kind {
+	case traceGo:
+		return strings.HasPrefix(line, "\t") ||
+			strings.HasPrefix(line, "created by ") ||
+			strings.HasPrefix(line, "panic: ") ||
+			goroutineHeaderRegex.MatchString(line) ||
+			goFrameFuncRegex.MatchString(line)
+	case traceJava:
+		return strings.HasPrefix(line, "\t") ||
+			strings.HasPrefix(line, " ") ||
+			strings.HasPrefix(line, "Caused by: ") ||
+			strings.HasPrefix(line, "Suppressed: ")
+	default:
+		return false
+	}
+}
+
+// scrubStackTrace scrubs the buffered trace as one block so paths and identifiers map
+// consistently across its frames, and returns the scrubbed lines
+func (s *Scrubber) scrubStackTrace(trace *stackTrace, source string) []string {
+	original := strings.Join(trace.lines, "\n")
+	var spans protectedSpans
+	block := s.protectPreserved(original, &spans)
+	block = s.scrubHomePaths(block, source)
+	// The block is split into its lines again afterwards, so no match may span a line break
+	s.scrubbingBlock = true
+	block = spans.restore(s.scrubPlainText(block, source))
+	s.scrubbingBlock = false
+
+	s.tracePath(fmt.Sprintf("stack trace (%d lines, scrubbed as plain text)", len(trace.lines)))
+	s.traceLine(source, trace.start, original, block, nil)
+
+	trace.kind, trace.lines = traceNone, nil
+	return strings.Split(block, "\n")
+}
+
+// scrubHomePaths maps the username in home directory paths such as /home/alice/go/src
+func (s *Scrubber) scrubHomePaths(text, source string) string {
+	return replaceAllStringFunc(homePathRegex, text, func(match string) string {
+		parts := homePathRegex.FindStringSubmatch(match)
+		prefix, name := parts[1], parts[2]
+		if sharedHomeDirs[strings.ToLower(name)] || strings.Contains(name, preservePlaceholderMarker) {
+			return match
+		}
+		return prefix + s.scrubUsernameValue(name, source)
+	})
+}
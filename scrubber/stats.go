@@ -0,0 +1,45 @@
+package scrubber
+
+import "mattermost-log-scrubber/constants"
+
+// RunStats totals what every ProcessFile call on a Scrubber read and replaced
+type RunStats struct {
+	Files                int
+	Lines                int            // Lines read, including empty ones
+	LinesScrubbed        int            // Lines written (or that would be written) after scrubbing
+	LinesEmpty           int            // Empty lines skipped
+	LinesDropped         int            // Unparseable lines dropped by --json-failure-action drop
+	LinesFailed          int            // Lines that failed processing and were passed through
+	LinesCollapsed       int            // Repeated lines left out by --collapse-repeats
+	JSONLines            int            // Lines parsed as JSON
+	JSONFailures         int            // Lines that weren't valid JSON
+	TimestampsNormalized int            // Timestamp fields rewritten by --normalize-time
+	InternalEmails       int            // Distinct email addresses in an internal domain
+	ExternalEmails       int            // Distinct email addresses outside the internal domains, with internal domains set
+	Replacements         map[string]int // key: scrub type -> total replacements
+	UniqueValues         map[string]int // key: scrub type -> distinct original values replaced
+}
+
+// TotalReplacements returns the replacements across all types
+// Fixed domain mappings are left out, since they are also counted within the emails and URLs they appear in
+func (stats RunStats) TotalReplacements() int {
+	total := 0
+	for valueType, count := range stats.Replacements {
+		if valueType != constants.TypeDomain {
+			total += count
+		}
+	}
+	return total
+}
+
+// Stats returns totals across every file processed so far
+func (s *Scrubber) Stats() RunStats {
+	stats := s.stats
+	stats.Replacements = make(map[string]int)
+	stats.UniqueValues = make(map[string]int)
+	for _, entry := range s.auditEntries {
+		stats.Replacements[entry.Type] += entry.TimesReplaced
+		stats.UniqueValues[entry.Type]++
+	}
+	return stats
+}
@@ -0,0 +1,280 @@
+package scrubber
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// ScrubStream scrubs a log read from r and writes the scrubbed lines to w, without printing progress
+// or summaries. gzip, bzip2 and xz input is decompressed, and the size limit applies to the
+// decompressed data. Mappings, the audit and run totals accumulate as they do for ProcessFile;
+// line and byte ranges, throttling and output splitting only apply to ProcessFile.
+// source is recorded as the source of audit entries.
+// A Scrubber is not safe for concurrent use, so concurrent streams each need their own.
+func (s *Scrubber) ScrubStream(r io.Reader, w io.Writer, source string) error {
+	input := bufio.NewReader(r)
+	reader, err := newDecompressor(input, sniffCompression(input))
+	if err != nil {
+		return err
+	}
+	if s.maxInputSize > 0 {
+		reader = &sizeLimitedReader{ReadCloser: io.NopCloser(reader), limit: s.maxInputSize}
+	}
+
+	output := bufio.NewWriter(w)
+	result, err := s.scrubLines(reader, lineSink{
+		source: source,
+		write: func(line string) error {
+			if _, err := output.WriteString(line + "\n"); err != nil {
+				return fmt.Errorf("failed to write scrubbed output: %w", err)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := output.Flush(); err != nil {
+		return fmt.Errorf("failed to write scrubbed output: %w", err)
+	}
+	if result.stopped != nil {
+		return result.stopped
+	}
+	if result.scrubbed == 0 && s.failOnEmpty {
+		return fmt.Errorf("input '%s' contained no non-empty lines (--fail-on-empty)", source)
+	}
+	return nil
+}
+
+// lineSink is where scrubLines writes the scrubbed lines of one input, and how it handles the
+// parts of a run only ProcessFile has: line and byte ranges, throttling, warnings and progress
+type lineSink struct {
+	source     string
+	write      func(line string) error // Writes one scrubbed line; nil when no log is written
+	tracker    *rangeTracker           // Selects the lines of an input range; nil to scrub every line
+	throttle   bool                    // Pace reading when a throttle is configured
+	keepFailed bool                    // Write a line that fails to scrub unchanged, with a warning, instead of stopping
+	onScrubbed func(lineNumber int)    // Called for each scrubbed line, if set
+	onLine     func(lineNumber int)    // Called after each input line is scrubbed and written, if set
+}
+
+// scrubResult holds the line counts of one input scrubbed by scrubLines
+type scrubResult struct {
+	lines     int
+	scrubbed  int
+	empty     int
+	failed    int
+	dropped   int
+	collapsed int
+	stopped   error // Set when the deadline passed; the lines read before it were still written
+}
+
+// scrubLines is the read, scrub and write loop shared by ProcessFile and ScrubStream. It scrubs
+// each line read from r, joining stack traces and split container lines, writes the results to the
+// sink and adds the input's counts to the run totals.
+func (s *Scrubber) scrubLines(r io.Reader, sink lineSink) (scrubResult, error) {
+	var result scrubResult
+	source := sink.source
+
+	// JSON statistics are reported per input; mappings and the audit carry over between inputs
+	s.jsonSuccessCount, s.jsonFailureCount = 0, 0
+	if s.delimited != nil {
+		s.delimited.reset()
+	}
+	s.jsonFailures = s.jsonFailures[:0]
+
+	// Runs of identical scrubbed lines are held back and written once when collapsing repeats
+	repeats := repeatCollapser{enabled: s.collapseRepeats}
+
+	// currentCounts returns this input's counts so far, for chunk callbacks
+	currentCounts := func() RunStats {
+		return RunStats{Files: 1, Lines: result.lines, LinesScrubbed: result.scrubbed, LinesEmpty: result.empty, LinesDropped: result.dropped,
+			LinesFailed: result.failed, LinesCollapsed: repeats.folded, JSONLines: s.jsonSuccessCount, JSONFailures: s.jsonFailureCount}
+	}
+
+	// writeScrubbed writes one scrubbed line to the sink
+	writeScrubbed := func(lineNumber int, scrubbedLine string) error {
+		result.scrubbed++
+		if sink.write != nil {
+			if err := repeats.add(scrubbedLine, sink.write); err != nil {
+				return err
+			}
+		}
+		if sink.onScrubbed != nil {
+			sink.onScrubbed(lineNumber)
+		}
+		if s.chunks != nil {
+			return s.chunkLine(source, currentCounts)
+		}
+		return nil
+	}
+
+	// Lines of a multiline stack trace are buffered and scrubbed together
+	var trace stackTrace
+	flushTrace := func() error {
+		start, count := trace.start, len(trace.lines)
+		for i, scrubbedLine := range s.scrubStackTrace(&trace, source) {
+			if err := writeScrubbed(start+i, scrubbedLine); err != nil {
+				return err
+			}
+		}
+		// Stack trace lines are plain text, counted as lines that weren't JSON unless no JSON was expected
+		if !s.plainTextInput {
+			s.jsonFailureCount += count
+		}
+		return nil
+	}
+
+	// Container log records of a split line are joined before scrubbing
+	var partials containerJoiner
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), constants.MaxLineLength)
+	ranged := sink.tracker != nil && s.inputRange.active()
+	if ranged {
+		scanner.Split(sink.tracker.split)
+	}
+	for scanner.Scan() {
+		if s.pastDeadline() {
+			result.stopped = fmt.Errorf("%w: stopped after line %d of %s", ErrDeadlineExceeded, result.lines, source)
+			break
+		}
+		result.lines++
+		lineCount := result.lines
+		line := scanner.Text()
+
+		// Only lines inside the requested range are scrubbed; reading stops once it ends
+		if ranged {
+			skip, done := sink.tracker.next(lineCount)
+			if done {
+				break
+			}
+			if skip {
+				continue
+			}
+		}
+
+		// Pace the read/write loop when a throttle is configured; the deadline cuts a wait short,
+		// leaving this line unprocessed
+		if sink.throttle && s.isThrottled() && !s.throttle(len(line)+1) {
+			result.lines--
+			result.stopped = fmt.Errorf("%w: stopped after line %d of %s", ErrDeadlineExceeded, result.lines, source)
+			break
+		}
+
+		if trace.active() {
+			if trace.continues(line) {
+				trace.lines = append(trace.lines, line)
+				continue
+			}
+			if err := flushTrace(); err != nil {
+				return result, err
+			}
+		}
+
+		if strings.TrimSpace(line) == "" {
+			result.empty++
+			continue
+		}
+
+		var record containerRecord
+		isRecord := false
+		if s.containerLogs {
+			record, isRecord = parseContainerRecord(line)
+		}
+
+		var scrubbedLine string
+		var err error
+		if isRecord {
+			complete := false
+			if record, complete = partials.add(record); !complete {
+				continue
+			}
+			scrubbedLine, err = s.processContainerRecord(record, source, lineCount)
+		} else {
+			// Stack traces are only grouped when unparseable lines are scrubbed; otherwise each line is dropped or redacted
+			if s.delimited == nil && s.jsonFailureAction == constants.JSONFailureScrub && trace.begin(lineCount, line) {
+				continue
+			}
+			scrubbedLine, err = s.processLogLine(line, source, lineCount)
+		}
+		if errors.Is(err, ErrLineDropped) {
+			result.dropped++
+			continue
+		}
+		if err != nil {
+			// A stream has no console to warn on, so a line that can't be scrubbed stops it
+			if !sink.keepFailed {
+				return result, fmt.Errorf("scrubbing line %d: %w", lineCount, err)
+			}
+			if errors.Is(err, errMissingColumn) {
+				return result, fmt.Errorf("line %d of %s: %w", lineCount, source, err)
+			}
+			result.failed++
+			fmt.Fprintf(s.messages, "\nWarning: Failed to process line %d: %v\n", lineCount, err)
+			// Write original line if processing fails
+			scrubbedLine = line
+		}
+
+		if err := writeScrubbed(lineCount, scrubbedLine); err != nil {
+			return result, err
+		}
+		if sink.onLine != nil {
+			sink.onLine(lineCount)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("error reading input: %w", err)
+	}
+
+	// Write a trace that ran to the end of the input
+	if trace.active() {
+		if err := flushTrace(); err != nil {
+			return result, err
+		}
+	}
+
+	// Write split container lines whose final part never arrived, still unterminated
+	for _, record := range partials.flush() {
+		scrubbedLine, err := s.processContainerRecord(record, source, result.lines)
+		if errors.Is(err, ErrLineDropped) {
+			result.dropped++
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("scrubbing unterminated container log line: %w", err)
+		}
+		if err := writeScrubbed(result.lines, scrubbedLine); err != nil {
+			return result, err
+		}
+	}
+
+	if sink.write != nil {
+		if err := repeats.flush(sink.write); err != nil {
+			return result, err
+		}
+	}
+	result.collapsed = repeats.folded
+	if s.chunks != nil {
+		if err := s.notifyChunk(source, currentCounts(), true); err != nil {
+			return result, err
+		}
+	}
+
+	// Add this input to the run totals
+	s.stats.Files++
+	s.stats.Lines += result.lines
+	s.stats.LinesScrubbed += result.scrubbed
+	s.stats.LinesEmpty += result.empty
+	s.stats.LinesDropped += result.dropped
+	s.stats.LinesFailed += result.failed
+	s.stats.LinesCollapsed += result.collapsed
+	s.stats.JSONLines += s.jsonSuccessCount
+	s.stats.JSONFailures += s.jsonFailureCount
+	return result, nil
+}
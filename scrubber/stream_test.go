@@ -0,0 +1,96 @@
+package scrubber
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// streamTestInput covers each kind of line the shared loop handles: JSON, plain text, empty lines,
+// a stack trace and repeats
+const streamTestInput = `{"user":"alice","email":"alice@example.com","msg":"login from 10.1.2.3"}
+
+plain text from bob@example.com
+panic: runtime error: invalid memory address
+goroutine 1 [running]:
+main.main()
+	/home/carol/app/main.go:12 +0x1d
+{"msg":"retry","ip":"10.1.2.3"}
+{"msg":"retry","ip":"10.1.2.3"}
+`
+
+func TestScrubStreamMatchesProcessFile(t *testing.T) {
+	for _, collapse := range []bool{false, true} {
+		input := writeTestInput(t, "mattermost.log", streamTestInput)
+		fileScrubber := NewScrubber(constants.ScrubLevelHigh, false)
+		fileScrubber.SetCollapseRepeats(collapse)
+		output := filepath.Join(t.TempDir(), "out.log")
+		if _, err := fileScrubber.ProcessFile(input, output, false, false, constants.OverwriteOverwrite); err != nil {
+			t.Fatal(err)
+		}
+
+		streamScrubber := NewScrubber(constants.ScrubLevelHigh, false)
+		streamScrubber.SetCollapseRepeats(collapse)
+		var streamed bytes.Buffer
+		if err := streamScrubber.ScrubStream(strings.NewReader(streamTestInput), &streamed, input); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := streamed.String(), string(readTestFile(t, output)); got != want {
+			t.Errorf("collapse %v: streamed output differs from ProcessFile\nstream:\n%s\nfile:\n%s", collapse, got, want)
+		}
+		if got, want := streamScrubber.Stats(), fileScrubber.Stats(); got.Lines != want.Lines || got.LinesScrubbed != want.LinesScrubbed ||
+			got.LinesEmpty != want.LinesEmpty || got.LinesCollapsed != want.LinesCollapsed || got.JSONFailures != want.JSONFailures {
+			t.Errorf("collapse %v: stream stats %+v, file stats %+v", collapse, got, want)
+		}
+	}
+}
+
+func TestMissingColumnStopsBothPaths(t *testing.T) {
+	const csv = "when,who\n2026-01-15,alice@example.com\n"
+	newDelimited := func() *Scrubber {
+		s := NewScrubber(1, false)
+		s.SetDelimitedInput(",", []ScrubColumn{{Index: -1, Name: "email"}})
+		return s
+	}
+
+	var out bytes.Buffer
+	err := newDelimited().ScrubStream(strings.NewReader(csv), &out, "test.csv")
+	if err == nil || !strings.Contains(err.Error(), "scrubbing line 1") {
+		t.Errorf("ScrubStream err = %v, want a failure naming line 1", err)
+	}
+
+	input := writeTestInput(t, "test.csv", csv)
+	_, err = newDelimited().ProcessFile(input, filepath.Join(t.TempDir(), "out.csv"), false, false, constants.OverwriteOverwrite)
+	if err == nil || !strings.Contains(err.Error(), "line 1 of") {
+		t.Errorf("ProcessFile err = %v, want a failure naming line 1", err)
+	}
+}
+
+func TestDeadlineCutsThrottleWaitShort(t *testing.T) {
+	input := writeTestInput(t, "mattermost.log", "one\ntwo\nthree\n")
+	output := filepath.Join(t.TempDir(), "out.log")
+	s := NewScrubber(constants.ScrubLevelLow, false)
+	s.SetThrottle(1, 0) // The second line waits a full second
+	s.SetDeadline(time.Now().Add(100 * time.Millisecond))
+
+	start := time.Now()
+	_, err := s.ProcessFile(input, output, false, false, constants.OverwriteOverwrite)
+	if !errors.Is(err, ErrDeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, ErrDeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("stopped after %v, want soon after the 100ms deadline", elapsed)
+	}
+	if got := string(readTestFile(t, output)); got != "one\n" {
+		t.Errorf("output = %q, want only the line read before the deadline", got)
+	}
+	if !strings.Contains(err.Error(), "stopped after line 1 of") {
+		t.Errorf("err = %v, want it stopped after line 1", err)
+	}
+}
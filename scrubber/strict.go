@@ -0,0 +1,39 @@
+package scrubber
+
+import (
+	"fmt"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// SetStrictAllowlist limits scrubbing to the values at the configured scrub paths and field types.
+// Pattern detection, notification handling and timestamp normalization never run, so every other
+// byte of a JSON line is written out unchanged, and lines that aren't JSON are dropped or redacted
+// as SetJSONFailureAction says rather than scrubbed as plain text.
+func (s *Scrubber) SetStrictAllowlist(enabled bool) {
+	s.strictAllowlist = enabled
+}
+
+// scrubStrictLine scrubs a log line in strict allowlist mode
+func (s *Scrubber) scrubStrictLine(line, source string, lineNumber int) (string, error) {
+	rawData, err := decodeJSONObject(line)
+	if err != nil {
+		s.trackJSONFailure(lineNumber, line, err)
+		s.tracePath(fmt.Sprintf("not JSON (%v); strict allowlist, json failure action %s", err, s.jsonFailureAction))
+		if s.jsonFailureAction == constants.JSONFailureDrop {
+			return "", ErrLineDropped
+		}
+		return constants.RedactedLineMarker, nil
+	}
+
+	s.jsonSuccessCount++
+	s.tracePath("json (strict allowlist)")
+
+	// Mappings only decide which token an allowlisted value gets; they never change the line
+	if !s.fixedWidth {
+		s.detectAndMapUser(rawData)
+	}
+
+	var spans protectedSpans
+	return s.canonicalOutput(spans.restore(s.applyStructuredScrubbing(line, source, &spans))), nil
+}
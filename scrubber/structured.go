@@ -0,0 +1,329 @@
+package scrubber
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// JSONPath is a parsed --scrub-path expression such as props.acct.email or data[0].user
+// An optional "=type" suffix selects the scrubber used for the value (e.g. props.contact=email)
+type JSONPath struct {
+	Expr     string
+	Type     string
+	segments []pathSegment
+}
+
+// pathSegment is one step of a JSONPath: an object key or an array index, either of which may be a wildcard
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// scrubPathTypes are the value types a JSONPath can route to
+var scrubPathTypes = []string{constants.TypeEmail, constants.TypeUsername, constants.TypeIP, constants.TypeUID, constants.TypeHost, constants.TypeFQDN, constants.TypeMessage, constants.TypeNumericID, constants.TypePhone}
+
+// ParseJSONPath parses a simple JSONPath-like expression
+// Supported syntax: dotted keys, [n] array indexes, * and [*] wildcards, an optional leading "$." and "=type" suffix
+func ParseJSONPath(expr string) (JSONPath, error) {
+	path := JSONPath{Expr: expr}
+
+	body := strings.TrimSpace(expr)
+	if idx := strings.LastIndex(body, "="); idx >= 0 {
+		path.Type = strings.ToLower(strings.TrimSpace(body[idx+1:]))
+		body = strings.TrimSpace(body[:idx])
+		if !isScrubPathType(path.Type) {
+			return path, fmt.Errorf("invalid scrub path '%s': unknown type '%s' (supported: %s)", expr, path.Type, strings.Join(scrubPathTypes, ", "))
+		}
+	}
+	body = strings.TrimPrefix(strings.TrimPrefix(body, "$"), ".")
+	if body == "" {
+		return path, fmt.Errorf("invalid scrub path '%s': path is empty", expr)
+	}
+
+	for _, part := range strings.Split(body, ".") {
+		key := part
+		var indexes []string
+		if open := strings.Index(part, "["); open >= 0 {
+			key = part[:open]
+			rest := part[open:]
+			for rest != "" {
+				if rest[0] != '[' {
+					return path, fmt.Errorf("invalid scrub path '%s': unexpected '%s'", expr, rest)
+				}
+				end := strings.Index(rest, "]")
+				if end < 0 {
+					return path, fmt.Errorf("invalid scrub path '%s': missing ']'", expr)
+				}
+				indexes = append(indexes, rest[1:end])
+				rest = rest[end+1:]
+			}
+		}
+
+		if key == "" && len(indexes) == 0 {
+			return path, fmt.Errorf("invalid scrub path '%s': empty segment", expr)
+		}
+		if key != "" {
+			path.segments = append(path.segments, pathSegment{key: key, wildcard: key == "*"})
+		}
+		for _, index := range indexes {
+			if index == "*" {
+				path.segments = append(path.segments, pathSegment{isIndex: true, wildcard: true})
+				continue
+			}
+			n, err := strconv.Atoi(index)
+			if err != nil || n < 0 {
+				return path, fmt.Errorf("invalid scrub path '%s': array index '%s' must be a non-negative integer or *", expr, index)
+			}
+			path.segments = append(path.segments, pathSegment{isIndex: true, index: n})
+		}
+	}
+
+	return path, nil
+}
+
+// isScrubPathType reports whether valueType is a type a JSONPath can route to
+func isScrubPathType(valueType string) bool {
+	for _, t := range scrubPathTypes {
+		if valueType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// SetScrubPaths sets the JSON paths whose values are always scrubbed, in addition to pattern detection
+func (s *Scrubber) SetScrubPaths(exprs []string) error {
+	paths := make([]JSONPath, 0, len(exprs))
+	for _, expr := range exprs {
+		path, err := ParseJSONPath(expr)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+	}
+	s.scrubPaths = paths
+	return nil
+}
+
+// ValidateFieldTypes checks that every field in a field-to-type mapping names a supported scrub type
+func ValidateFieldTypes(fieldTypes map[string]string) error {
+	for field, valueType := range fieldTypes {
+		if strings.TrimSpace(field) == "" {
+			return fmt.Errorf("invalid field type mapping: field name is empty")
+		}
+		if !isScrubPathType(strings.ToLower(strings.TrimSpace(valueType))) {
+			return fmt.Errorf("invalid field type mapping '%s': unknown type '%s' (supported: %s)", field, valueType, strings.Join(scrubPathTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// SetFieldTypes maps JSON field names to scrub types, e.g. {"remote_addr": "ip", "principal": "username"}
+// Matching fields are scrubbed as that type wherever they appear in a document; names are matched case-insensitively
+func (s *Scrubber) SetFieldTypes(fieldTypes map[string]string) error {
+	if err := ValidateFieldTypes(fieldTypes); err != nil {
+		return err
+	}
+	s.fieldTypes = make(map[string]string, len(fieldTypes))
+	for field, valueType := range fieldTypes {
+		s.fieldTypes[strings.ToLower(strings.TrimSpace(field))] = strings.ToLower(strings.TrimSpace(valueType))
+	}
+	return nil
+}
+
+// structuredTarget is a string or number value selected for scrubbing along with the type to scrub it as
+type structuredTarget struct {
+	value     *jsonValue
+	valueType string
+}
+
+// collectFieldTargets walks the document and selects values of fields listed in a field-to-type mapping
+// Arrays under a mapped field have each of their string items selected
+func collectFieldTargets(value *jsonValue, fieldTypes map[string]string, targets []structuredTarget) []structuredTarget {
+	switch value.kind {
+	case jsonObjectKind:
+		for _, f := range value.fields {
+			if valueType, ok := fieldTypes[strings.ToLower(f.key)]; ok {
+				targets = append(targets, structuredTarget{value: f.value, valueType: valueType})
+				if f.value.kind == jsonArrayKind {
+					for _, item := range f.value.items {
+						targets = append(targets, structuredTarget{value: item, valueType: valueType})
+					}
+				}
+			}
+			targets = collectFieldTargets(f.value, fieldTypes, targets)
+		}
+	case jsonArrayKind:
+		for _, item := range value.items {
+			targets = collectFieldTargets(item, fieldTypes, targets)
+		}
+	}
+	return targets
+}
+
+// resolve returns every value in the document that the path points to
+func (p JSONPath) resolve(root *jsonValue) []*jsonValue {
+	current := []*jsonValue{root}
+	for _, segment := range p.segments {
+		var next []*jsonValue
+		for _, value := range current {
+			switch {
+			case segment.isIndex && value.kind == jsonArrayKind:
+				if segment.wildcard {
+					next = append(next, value.items...)
+				} else if segment.index < len(value.items) {
+					next = append(next, value.items[segment.index])
+				}
+			case !segment.isIndex && value.kind == jsonObjectKind:
+				for _, f := range value.fields {
+					if segment.wildcard || f.key == segment.key {
+						next = append(next, f.value)
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// applyStructuredScrubbing scrubs values at configured JSON paths, mapped field names and notification payload fields
+// Paths are applied first, then the configured mapping, so they win when several select the same value
+// Replacements are set aside as protected spans so the regex scrubbers don't map them a second time
+// Passthrough field values are set aside unchanged before anything else, so nothing inside them is scrubbed
+// String values holding a JSON document of their own are scrubbed as documents and re-encoded
+func (s *Scrubber) applyStructuredScrubbing(line, source string, spans *protectedSpans) string {
+	return s.scrubStructured(line, source, spans, s.scrubPaths, 0)
+}
+
+// scrubStructured applies structured scrubbing to a document with the given scrub paths
+// depth counts the string-encoded documents this one is nested in
+func (s *Scrubber) scrubStructured(line, source string, spans *protectedSpans, paths []JSONPath, depth int) string {
+	embedded := depth < constants.MaxEmbeddedJSONDepth && embeddedJSONHint.MatchString(line)
+	if len(paths) == 0 && len(s.fieldTypes) == 0 && len(s.passthroughFields) == 0 && !embedded && !s.mayBeNotificationLine(line, source) {
+		return line
+	}
+
+	root, err := decodeJSONValue(line)
+	if err != nil {
+		return line
+	}
+
+	var edits []jsonEdit
+	var kept [][2]int
+	for _, value := range collectPassthroughValues(root, s.passthroughFields, nil) {
+		edits = append(edits, jsonEdit{start: value.start, end: value.end, text: spans.add(line[value.start:value.end])})
+		kept = append(kept, [2]int{value.start, value.end})
+	}
+	insideKept := func(value *jsonValue) bool {
+		for _, r := range kept {
+			if value.start >= r[0] && value.start < r[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var targets []structuredTarget
+	for _, path := range paths {
+		for _, value := range path.resolve(root) {
+			targets = append(targets, structuredTarget{value: value, valueType: path.Type})
+		}
+	}
+	if len(s.fieldTypes) > 0 {
+		targets = collectFieldTargets(root, s.fieldTypes, targets)
+	}
+	if !s.strictAllowlist && s.isNotificationLine(root, source) {
+		targets = collectFieldTargets(root, notificationFieldTypes, targets)
+	}
+
+	seen := make(map[int]bool)
+	for _, target := range targets {
+		value := target.value
+		if insideKept(value) {
+			continue
+		}
+		if target.valueType == constants.TypeNumericID && value.kind == jsonNumberKind && !seen[value.start] {
+			// Integer IDs stay JSON numbers, so the synthetic ID is written unquoted
+			seen[value.start] = true
+			edits = append(edits, jsonEdit{start: value.start, end: value.end, text: spans.add(s.scrubNumericID(value.str, source))})
+			continue
+		}
+		if value.kind != jsonStringKind || seen[value.start] || s.isPreserved(value.str) {
+			continue
+		}
+		seen[value.start] = true
+
+		scrubbed := s.scrubValueAs(target.valueType, value.str, source)
+		edits = append(edits, jsonEdit{start: value.start, end: value.end, text: spans.add(encodeJSONString(scrubbed))})
+	}
+
+	if embedded {
+		for _, doc := range collectEmbeddedJSON(root, nil, nil) {
+			value := doc.value
+			if insideKept(value) || seen[value.start] {
+				continue
+			}
+			scrubbed, ok := s.scrubEmbeddedJSON(value.str, source, innerPaths(paths, doc.location), depth+1)
+			if !ok {
+				continue
+			}
+			edits = append(edits, jsonEdit{start: value.start, end: value.end, text: spans.add(encodeJSONString(scrubbed))})
+		}
+	}
+
+	return applyJSONEdits(line, edits)
+}
+
+// scrubValueAs scrubs a whole value with the scrubber for valueType
+// An empty type is inferred from the value's shape, defaulting to a username
+func (s *Scrubber) scrubValueAs(valueType, value, source string) string {
+	if valueType == "" {
+		valueType = inferValueType(value)
+	}
+
+	switch valueType {
+	case constants.TypeEmail:
+		return s.scrubEmails(value, source)
+	case constants.TypeFQDN:
+		return s.scrubFQDNs(value, source)
+	case constants.TypeIP:
+		return s.scrubIPAddresses(value, source)
+	case constants.TypeUID:
+		return s.scrubUIDValue(value, source)
+	case constants.TypeHost:
+		return s.scrubHostValue(value, source)
+	case constants.TypeMessage:
+		return s.scrubMessageValue(value)
+	case constants.TypeNumericID:
+		return s.scrubNumericID(value, source)
+	case constants.TypePhone:
+		return s.scrubPhoneNumbers(value, source)
+	default:
+		return s.scrubUsernameValue(value, source)
+	}
+}
+
+// inferValueType guesses the scrub type of a whole value from its shape
+func inferValueType(value string) string {
+	switch {
+	case isFullMatch(emailRegex.FindStringIndex(value), value):
+		return constants.TypeEmail
+	case isFullMatch(ipRegex.FindStringIndex(value), value):
+		return constants.TypeIP
+	case isFullMatch(fqdnRegex.FindStringIndex(value), value):
+		return constants.TypeFQDN
+	default:
+		return constants.TypeUsername
+	}
+}
+
+// isFullMatch reports whether a match location covers the whole value
+func isFullMatch(loc []int, value string) bool {
+	return loc != nil && loc[0] == 0 && loc[1] == len(value)
+}
@@ -0,0 +1,78 @@
+package scrubber
+
+import "time"
+
+// tokenBucket paces work to a steady rate, allowing bursts of up to one second's worth
+type tokenBucket struct {
+	rate   float64 // tokens added per second
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int64) *tokenBucket {
+	return &tokenBucket{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// wait takes n tokens, sleeping until the bucket has refilled enough to cover them. It returns false
+// if the deadline passes first; a zero deadline never does.
+func (b *tokenBucket) wait(n int, deadline time.Time) bool {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens < 0 {
+		return sleepBefore(time.Duration(-b.tokens/b.rate*float64(time.Second)), deadline)
+	}
+	return true
+}
+
+// sleepBefore sleeps for d, returning false as soon as the deadline passes if that comes first
+func sleepBefore(d time.Duration, deadline time.Time) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		deadlineTimer := time.NewTimer(time.Until(deadline))
+		defer deadlineTimer.Stop()
+		expired = deadlineTimer.C
+	}
+	select {
+	case <-timer.C:
+		return true
+	case <-expired:
+		return false
+	}
+}
+
+// SetThrottle caps how fast ProcessFile reads and writes, in lines per second and/or bytes per second.
+// A zero value leaves that dimension unlimited.
+func (s *Scrubber) SetThrottle(linesPerSecond, bytesPerSecond int64) {
+	s.lineLimiter, s.byteLimiter = nil, nil
+	if linesPerSecond > 0 {
+		s.lineLimiter = newTokenBucket(linesPerSecond)
+	}
+	if bytesPerSecond > 0 {
+		s.byteLimiter = newTokenBucket(bytesPerSecond)
+	}
+}
+
+// throttle blocks until the configured limits allow another line of the given length. It returns false
+// without waiting any longer once the deadline set by SetDeadline passes.
+func (s *Scrubber) throttle(lineBytes int) bool {
+	if s.lineLimiter != nil && !s.lineLimiter.wait(1, s.deadline) {
+		return false
+	}
+	if s.byteLimiter != nil && !s.byteLimiter.wait(lineBytes, s.deadline) {
+		return false
+	}
+	return true
+}
+
+// isThrottled reports whether any processing rate limit is active
+func (s *Scrubber) isThrottled() bool {
+	return s.lineLimiter != nil || s.byteLimiter != nil
+}
@@ -0,0 +1,105 @@
+package scrubber
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// timeFieldRegex matches known timestamp fields with a string or integer value
+var timeFieldRegex = regexp.MustCompile(`"(time|timestamp|ts|create_at|update_at|delete_at|edit_at|last_activity_at|expires_at)"(\s*:\s*)("(?:[^"\\]|\\.)*"|-?\d+)`)
+
+// timestampLayouts are the string formats recognized in timestamp fields
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.000 Z07:00", // Mattermost server log format
+	"2006-01-02 15:04:05.000 MST",
+}
+
+// Millisecond epochs outside this range are left alone, so small counters and zero
+// values like "delete_at": 0 (not deleted) aren't mistaken for times
+const (
+	minEpochMillis = 100000000000   // 1973-03-03
+	maxEpochMillis = 10000000000000 // 2286-11-20
+)
+
+// SetTimeFormat normalizes timestamp fields in JSON lines to one format: constants.TimeFormatRFC3339,
+// constants.TimeFormatEpochMS, or constants.TimeFormatRelative (offset from the first timestamp seen)
+// An empty format leaves timestamps unchanged
+func (s *Scrubber) SetTimeFormat(format string) {
+	s.timeFormat = format
+}
+
+// normalizeTimestamps rewrites every recognized timestamp field in a JSON line in the configured format
+func (s *Scrubber) normalizeTimestamps(jsonStr string) string {
+	if s.timeFormat == "" {
+		return jsonStr
+	}
+	return replaceAllStringFunc(timeFieldRegex, jsonStr, func(match string) string {
+		parts := timeFieldRegex.FindStringSubmatch(match)
+		if s.passthroughFields[strings.ToLower(parts[1])] {
+			return match
+		}
+		t, ok := parseTimestamp(parts[3])
+		if !ok {
+			return match
+		}
+		s.stats.TimestampsNormalized++
+		formatted := s.formatTimestamp(t)
+		s.traceMatch("timestamp", strings.Trim(parts[3], `"`), strings.Trim(formatted, `"`))
+		return `"` + parts[1] + `"` + parts[2] + formatted
+	})
+}
+
+// parseTimestamp reads a JSON string timestamp or a millisecond epoch integer
+func parseTimestamp(raw string) (time.Time, bool) {
+	if strings.HasPrefix(raw, `"`) {
+		var value string
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return time.Time{}, false
+		}
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+
+	millis, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || millis < minEpochMillis || millis >= maxEpochMillis {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(millis), true
+}
+
+// formatTimestamp returns the JSON value for a timestamp in the configured format
+func (s *Scrubber) formatTimestamp(t time.Time) string {
+	switch s.timeFormat {
+	case constants.TimeFormatEpochMS:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case constants.TimeFormatRelative:
+		if s.timeOrigin.IsZero() {
+			s.timeOrigin = t
+		}
+		return `"` + formatOffset(t.Sub(s.timeOrigin)) + `"`
+	default:
+		return `"` + t.UTC().Format("2006-01-02T15:04:05.000Z07:00") + `"`
+	}
+}
+
+// formatOffset formats a duration as a signed offset like +01:02:03.456
+func formatOffset(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	millis := d.Milliseconds()
+	return fmt.Sprintf("%s%02d:%02d:%02d.%03d", sign, millis/3600000, millis/60000%60, millis/1000%60, millis%1000)
+}
@@ -0,0 +1,104 @@
+package scrubber
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// MapUsers is the first pass of two-pass scrubbing: it reads an input without scrubbing or writing
+// anything and creates the user mapping for every username/email pair (or role) it contains.
+// Called for every input before ProcessFile, it makes a username seen in free text early in a log
+// get the same token as the pair that only appears later, instead of a standalone one.
+// The input is read twice in total, so it must be a file rather than a stream.
+func (s *Scrubber) MapUsers(inputPath string) error {
+	// Fixed-width masks don't use user mappings
+	if s.fixedWidth {
+		return nil
+	}
+
+	inputFile, err := s.openInput(inputPath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	tracker := &rangeTracker{r: s.inputRange}
+	if err := tracker.seekToStart(inputFile); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(inputFile)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), constants.MaxLineLength)
+	if s.inputRange.active() {
+		scanner.Split(tracker.split)
+	}
+
+	if !s.verbose {
+		fmt.Fprint(s.messages, "Mapping users... ")
+	}
+
+	var partials containerJoiner
+	lineCount := 0
+	for scanner.Scan() {
+		if s.pastDeadline() {
+			if !s.verbose {
+				fmt.Fprint(s.messages, "\r"+strings.Repeat(" ", 50)+"\r")
+			}
+			return fmt.Errorf("%w: stopped mapping users after line %d of %s", ErrDeadlineExceeded, lineCount, inputSourceName(inputPath))
+		}
+		lineCount++
+		line := scanner.Text()
+
+		if s.inputRange.active() {
+			skip, done := tracker.next(lineCount)
+			if done {
+				break
+			}
+			if skip {
+				continue
+			}
+		}
+		if s.isThrottled() {
+			s.throttle(len(line) + 1)
+		}
+
+		if s.containerLogs {
+			if record, isRecord := parseContainerRecord(line); isRecord {
+				if record, complete := partials.add(record); complete {
+					s.mapLineUsers(strings.TrimSuffix(record.log, "\n"))
+				}
+				continue
+			}
+		}
+		s.mapLineUsers(line)
+	}
+
+	if !s.verbose {
+		fmt.Fprint(s.messages, "\r"+strings.Repeat(" ", 50)+"\r")
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading input file: %w", err)
+	}
+	return nil
+}
+
+// mapLineUsers creates the user mappings for one JSON log line, as scrubbing it would
+// Lines that aren't JSON have no username/email pairs to link
+func (s *Scrubber) mapLineUsers(line string) {
+	if len(line) >= constants.WideLineThreshold {
+		s.streamJSONTokens(line, func(frames []*wideFrame, tok json.Token, prefix string, isKey bool) {
+			if !isKey {
+				s.collectWideUserMapping(frames, tok)
+			}
+		})
+		return
+	}
+
+	if rawData, err := decodeJSONObject(line); err == nil {
+		s.detectAndMapUser(rawData)
+	}
+}
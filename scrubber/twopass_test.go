@@ -0,0 +1,75 @@
+package scrubber
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// twoPassInput mentions alice's email and username apart before the line that pairs them, and
+// carol's email in free text before her pair
+const twoPassInput = `{"msg":"mail to alice@example.com bounced"}
+{"username":"alice","msg":"retrying"}
+{"username":"bob","email":"bob@example.com"}
+{"username":"alice","email":"alice@example.com"}
+{"msg":"invite sent to carol@example.com"}
+{"user":"carol","email":"carol@example.com"}
+`
+
+func scrubTwoPassInput(t *testing.T, twoPass bool) []string {
+	t.Helper()
+	input := writeTestInput(t, "mattermost.log", twoPassInput)
+	s := NewScrubber(constants.ScrubLevelHigh, false)
+	if twoPass {
+		if err := s.MapUsers(input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := filepath.Join(t.TempDir(), "out.log")
+	if _, err := s.ProcessFile(input, output, false, false, constants.OverwriteOverwrite); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(readTestFile(t, output)), "\n"), "\n")
+}
+
+func TestTwoPassLinksUsersSeenBeforeTheirPair(t *testing.T) {
+	single := scrubTwoPassInput(t, false)
+	double := scrubTwoPassInput(t, true)
+
+	// A single pass gives alice's username a standalone token before the pair links it to the email
+	if want := `{"username":"user2","email":"user1@domain1"}`; single[3] != want {
+		t.Errorf("single pass: got %s, want %s", single[3], want)
+	}
+
+	// The mapping pass sees every pair first, so each user has one token throughout
+	want := []string{
+		`{"msg":"mail to user2@domain1 bounced"}`,
+		`{"username":"user2","msg":"retrying"}`,
+		`{"username":"user1","email":"user1@domain1"}`,
+		`{"username":"user2","email":"user2@domain1"}`,
+		`{"msg":"invite sent to user3@domain1"}`,
+		`{"user":"user3","email":"user3@domain1"}`,
+	}
+	if len(double) != len(want) {
+		t.Fatalf("two-pass output has %d lines, want %d:\n%s", len(double), len(want), strings.Join(double, "\n"))
+	}
+	for i := range want {
+		if double[i] != want[i] {
+			t.Errorf("two-pass line %d: got %s, want %s", i+1, double[i], want[i])
+		}
+	}
+}
+
+func TestTwoPassSkipsFixedWidth(t *testing.T) {
+	input := writeTestInput(t, "mattermost.log", twoPassInput)
+	s := NewScrubber(constants.ScrubLevelHigh, false)
+	s.SetFixedWidth(true)
+	if err := s.MapUsers(input); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.userMappings) != 0 {
+		t.Errorf("fixed-width mapping pass created %d user mappings", len(s.userMappings))
+	}
+}
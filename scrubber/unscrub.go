@@ -0,0 +1,222 @@
+package scrubber
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// UnscrubResult reports what Unscrub restored and what it had to leave scrubbed
+type UnscrubResult struct {
+	Lines        int            // Lines read from the scrubbed log
+	Restored     int            // Replaced values put back
+	Entries      int            // New values that could be reversed
+	Ambiguous    map[string]int // New values shared by several originals, with the number of originals
+	HashedValues int            // Audit entries whose originals were recorded as hashes
+}
+
+// Unscrub restores original values in a scrubbed log using the JSON audit of the run that scrubbed it.
+// Each line is searched for the audit's new values, longest first, and each match is replaced by its
+// original. A match inside a longer word is left alone, so user1 isn't restored inside user12.
+// New values shared by several distinct originals, like a masked IP at level 3, are ambiguous and left
+// scrubbed, as are entries of an audit written with hashed originals. outputPath may be "-" for
+// standard output.
+func Unscrub(auditPath, inputPath, outputPath string) (UnscrubResult, error) {
+	result := UnscrubResult{Ambiguous: make(map[string]int)}
+
+	entries, err := loadAuditJSON(auditPath)
+	if err != nil {
+		return result, err
+	}
+	// Originals differing only in case, or phone numbers written differently, were scrubbed as one
+	// value, so they aren't ambiguous
+	originals := make(map[string]map[string]string)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.OriginalValue, auditHashPrefix) {
+			result.HashedValues++
+			continue
+		}
+		if entry.NewValue == "" || entry.NewValue == entry.OriginalValue {
+			continue
+		}
+		if originals[entry.NewValue] == nil {
+			originals[entry.NewValue] = make(map[string]string)
+		}
+		key := strings.ToLower(entry.OriginalValue)
+		if phone, ok := phoneNumberKey(entry.OriginalValue); ok && entry.Type == constants.TypePhone {
+			key = phone
+		}
+		if existing, seen := originals[entry.NewValue][key]; !seen || entry.OriginalValue < existing {
+			originals[entry.NewValue][key] = entry.OriginalValue
+		}
+	}
+
+	reverse := make(map[string]string, len(originals))
+	for newValue, values := range originals {
+		if len(values) > 1 {
+			result.Ambiguous[newValue] = len(values)
+			continue
+		}
+		for _, original := range values {
+			reverse[newValue] = original
+		}
+	}
+	result.Entries = len(reverse)
+
+	input, err := openScrubbedLog(inputPath)
+	if err != nil {
+		return result, err
+	}
+	defer input.Close()
+
+	var output io.WriteCloser
+	if IsStdio(outputPath) {
+		output = stdoutWriter{bufio.NewWriter(os.Stdout)}
+	} else {
+		output, err = os.Create(outputPath)
+		if err != nil {
+			return result, fmt.Errorf("failed to create output file: %w", err)
+		}
+	}
+	defer output.Close()
+	writer := bufio.NewWriter(output)
+
+	pattern := reversePattern(reverse)
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), constants.MaxLineLength)
+	for scanner.Scan() {
+		line := scanner.Text()
+		result.Lines++
+		if pattern != nil {
+			var restored int
+			line, restored = restoreLine(line, pattern, reverse)
+			result.Restored += restored
+		}
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return result, fmt.Errorf("failed to write output file: %w", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("error reading input file: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return result, fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := output.Close(); err != nil {
+		return result, fmt.Errorf("failed to write output file: %w", err)
+	}
+	return result, nil
+}
+
+// loadAuditJSON reads the entries of a JSON audit file: an array, or one object per line for NDJSON
+func loadAuditJSON(auditPath string) ([]AuditEntry, error) {
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+	var entries []AuditEntry
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &entries)
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		for decoder.More() {
+			var entry AuditEntry
+			if err = decoder.Decode(&entry); err != nil {
+				break
+			}
+			entries = append(entries, entry)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse audit file '%s' (write one with --audit-type json or ndjson): %w", auditPath, err)
+	}
+	return entries, nil
+}
+
+// openScrubbedLog opens a scrubbed log, decompressing it if it was written with --compress
+func openScrubbedLog(inputPath string) (io.ReadCloser, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	input := bufio.NewReader(file)
+	reader, err := newDecompressor(input, sniffCompression(input))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return wrappedInput{Reader: reader, Closer: file}, nil
+}
+
+// reversePattern returns a pattern matching any of the new values, longest first so a new value
+// isn't cut short by another it starts with, or nil when there is nothing to reverse
+func reversePattern(reverse map[string]string) *regexp.Regexp {
+	if len(reverse) == 0 {
+		return nil
+	}
+	newValues := make([]string, 0, len(reverse))
+	for newValue := range reverse {
+		newValues = append(newValues, newValue)
+	}
+	sort.Slice(newValues, func(i, j int) bool {
+		if len(newValues[i]) != len(newValues[j]) {
+			return len(newValues[i]) > len(newValues[j])
+		}
+		return newValues[i] < newValues[j]
+	})
+	for i, newValue := range newValues {
+		newValues[i] = regexp.QuoteMeta(newValue)
+	}
+	return regexp.MustCompile(strings.Join(newValues, "|"))
+}
+
+// restoreLine replaces the new values in a line with their originals and returns the count replaced
+func restoreLine(line string, pattern *regexp.Regexp, reverse map[string]string) (string, int) {
+	matches := pattern.FindAllStringIndex(line, -1)
+	if matches == nil {
+		return line, 0
+	}
+	var builder strings.Builder
+	restored := 0
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if !tokenBoundary(line, start, end) {
+			continue
+		}
+		builder.WriteString(line[last:start])
+		builder.WriteString(reverse[line[start:end]])
+		last = end
+		restored++
+	}
+	builder.WriteString(line[last:])
+	return builder.String(), restored
+}
+
+// tokenBoundary reports whether line[start:end] isn't part of a longer word: a value that starts or
+// ends with a letter or digit mustn't continue into one
+func tokenBoundary(line string, start, end int) bool {
+	first, _ := utf8.DecodeRuneInString(line[start:end])
+	if before, _ := utf8.DecodeLastRuneInString(line[:start]); start > 0 && isWordRune(first) && isWordRune(before) {
+		return false
+	}
+	lastRune, _ := utf8.DecodeLastRuneInString(line[start:end])
+	if after, _ := utf8.DecodeRuneInString(line[end:]); end < len(line) && isWordRune(lastRune) && isWordRune(after) {
+		return false
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
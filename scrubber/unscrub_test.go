@@ -0,0 +1,68 @@
+package scrubber
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// writeTestAudit writes entries as a JSON audit and returns its path
+func writeTestAudit(t *testing.T, entries []AuditEntry) string {
+	t.Helper()
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "audit.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUnscrubLeavesAmbiguousValuesScrubbed(t *testing.T) {
+	audit := writeTestAudit(t, []AuditEntry{
+		{OriginalValue: "alice", NewValue: "user1", Type: constants.TypeUsername},
+		{OriginalValue: "ALICE", NewValue: "user1", Type: constants.TypeUsername}, // Case variant of one value
+		{OriginalValue: "(555) 123-4567", NewValue: "+1-XXX-XXX-0001", Type: constants.TypePhone},
+		{OriginalValue: "555.123.4567", NewValue: "+1-XXX-XXX-0001", Type: constants.TypePhone}, // Same number
+		{OriginalValue: "10.1.2.3", NewValue: "***.***.***.***", Type: constants.TypeIP},
+		{OriginalValue: "10.4.5.6", NewValue: "***.***.***.***", Type: constants.TypeIP},
+		{OriginalValue: "bob", NewValue: "user2", Type: constants.TypeUsername},
+		{OriginalValue: "carol", NewValue: "user2", Type: constants.TypeUsername}, // Conflicting entries
+		{OriginalValue: auditHashPrefix + "0a1b2c", NewValue: "user3", Type: constants.TypeUsername},
+	})
+	input := writeTestInput(t, "mattermost_scrubbed.log",
+		`{"user":"user1","ip":"***.***.***.***","phone":"+1-XXX-XXX-0001"}`+"\n"+`{"user":"user2","by":"user3"}`+"\n")
+	output := filepath.Join(t.TempDir(), "restored.log")
+
+	result, err := Unscrub(audit, input, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"user":"ALICE","ip":"***.***.***.***","phone":"(555) 123-4567"}` + "\n" + `{"user":"user2","by":"user3"}` + "\n"
+	if got := string(readTestFile(t, output)); got != want {
+		t.Errorf("restored log:\n got  %s\n want %s", got, want)
+	}
+	if wantAmbiguous := map[string]int{"***.***.***.***": 2, "user2": 2}; !reflect.DeepEqual(result.Ambiguous, wantAmbiguous) {
+		t.Errorf("ambiguous = %v, want %v", result.Ambiguous, wantAmbiguous)
+	}
+	if result.Entries != 2 || result.Restored != 2 || result.HashedValues != 1 || result.Lines != 2 {
+		t.Errorf("result = %+v, want 2 entries, 2 restored, 1 hashed value and 2 lines", result)
+	}
+}
+
+func TestUnscrubRejectsCSVAudit(t *testing.T) {
+	audit := filepath.Join(t.TempDir(), "audit.csv")
+	if err := os.WriteFile(audit, []byte("Original Value,New Value\nalice,user1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := writeTestInput(t, "mattermost_scrubbed.log", "user1\n")
+	if _, err := Unscrub(audit, input, filepath.Join(t.TempDir(), "restored.log")); err == nil {
+		t.Error("a CSV audit was accepted")
+	}
+}
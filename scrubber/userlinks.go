@@ -0,0 +1,106 @@
+package scrubber
+
+import (
+	"regexp"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// Markdown mailto links such as [Alice](mailto:alice@example.com)
+var markdownMailtoRegex = regexp.MustCompile(`\[([^\[\]\n]+)\]\(mailto:([^()\s]+)\)`)
+
+// Slack user references such as <@U12345> or <@U12345|alice>
+var slackUserRegex = regexp.MustCompile(`<@([UW][A-Z0-9]{2,})(?:\|([^<>|\n]+))?>`)
+
+// slackUserKeyPrefix sets Slack user IDs apart from usernames and emails in userMappings
+const slackUserKeyPrefix = "<@"
+
+// scrubUserLinks maps the display names in Markdown mailto links and the IDs and names in Slack user
+// references to the same user as the email or ID they belong to, so imported integration content
+// reads as the same person as the rest of the log. The email in a mailto link is left for scrubEmails,
+// which maps it through the same user.
+func (s *Scrubber) scrubUserLinks(text, source string) string {
+	result := replaceAllStringFunc(markdownMailtoRegex, text, func(link string) string {
+		parts := markdownMailtoRegex.FindStringSubmatch(link)
+		name, email := parts[1], parts[2]
+		if !isFullMatch(emailRegex.FindStringIndex(email), email) || s.skipUserLinkValue(name) || s.isPreserved(email) {
+			return link
+		}
+		// A display name that is itself the address is scrubbed as an email
+		if isFullMatch(emailRegex.FindStringIndex(name), name) {
+			return link
+		}
+
+		if !s.fixedWidth {
+			mapping := s.linkUserValues(email, name)
+			if mapping.Email == "" {
+				mapping.Email = email
+			}
+			if mapping.Username == "" {
+				mapping.Username = name
+			}
+		}
+		return "[" + s.scrubUsernameValue(name, source) + "](mailto:" + email + ")"
+	})
+
+	return replaceAllStringFunc(slackUserRegex, result, func(ref string) string {
+		parts := slackUserRegex.FindStringSubmatch(ref)
+		id, name := parts[1], parts[2]
+		linkName := name != "" && !s.skipUserLinkValue(name)
+
+		// The ID becomes the user's token in capitals, e.g. <@USER5|user5>
+		var scrubbedID string
+		switch {
+		case s.fixedWidth:
+			scrubbedID = s.maskFixedWidth(id, constants.TypeUID)
+		case linkName:
+			mapping := s.linkUserValues(slackUserKeyPrefix+id, name)
+			if mapping.Username == "" {
+				mapping.Username = name
+			}
+			scrubbedID = strings.ToUpper(s.userToken(mapping, slackUserKeyPrefix+id))
+		default:
+			scrubbedID = strings.ToUpper(s.userToken(s.linkUserValues(slackUserKeyPrefix+id), slackUserKeyPrefix+id))
+		}
+		s.trackReplacement(id, scrubbedID, constants.TypeUID, source)
+
+		switch {
+		case name == "":
+			return "<@" + scrubbedID + ">"
+		case !linkName:
+			return "<@" + scrubbedID + "|" + name + ">"
+		}
+		return "<@" + scrubbedID + "|" + s.scrubUsernameValue(name, source) + ">"
+	})
+}
+
+// skipUserLinkValue reports whether a link's name is left alone: a preserved marker from another
+// anonymizer, or one already set aside as a protected span
+func (s *Scrubber) skipUserLinkValue(name string) bool {
+	return strings.Contains(name, preservePlaceholderMarker) || s.isPreserved(name)
+}
+
+// linkUserValues returns the user mapping of the first value that already has one, creating a new
+// mapping when none does, and links every unmapped value to it so they all map to one token.
+// A value already mapped to a different user keeps its own mapping, so earlier output stays consistent.
+func (s *Scrubber) linkUserValues(values ...string) *UserMapping {
+	var mapping *UserMapping
+	for _, value := range values {
+		if existing, exists := s.userMappings[s.normalizeKey(value)]; exists {
+			mapping = existing
+			break
+		}
+	}
+	if mapping == nil {
+		mapping = s.newUserMapping(constants.UserTokenPrefix, values[0])
+	}
+
+	for _, value := range values {
+		key := s.normalizeKey(value)
+		if _, exists := s.userMappings[key]; !exists {
+			s.userMappings[key] = mapping
+		}
+	}
+	return mapping
+}
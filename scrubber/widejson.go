@@ -0,0 +1,358 @@
+package scrubber
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// wideFrame tracks an open object or array while streaming a wide JSON line
+type wideFrame struct {
+	object    bool
+	expectKey bool
+	key       string // Key of the value being read (objects only)
+	count     int    // Members read so far
+
+	// User mapping candidates found directly in this object
+	username, email string
+	userKeySeen     bool
+	userID          string // From user_id, or else id
+	userIDKeySeen   bool
+	roles           interface{}
+
+	// Containers nested directly in this one, kept so user mappings can be created in the order of
+	// findUserMappingsRecursive once the whole line is read
+	children []wideChild
+}
+
+// wideChild is a container nested in an object (under key) or an array
+type wideChild struct {
+	key   string
+	frame *wideFrame
+}
+
+// setChild records a nested container; in an object a repeated key replaces the earlier value, as
+// json.Unmarshal does, and a scalar (nil frame) just removes it
+func (f *wideFrame) setChild(frame *wideFrame) {
+	if !f.object {
+		if frame != nil {
+			f.children = append(f.children, wideChild{frame: frame})
+		}
+		return
+	}
+	for i, child := range f.children {
+		if child.key == f.key {
+			f.children = append(f.children[:i], f.children[i+1:]...)
+			break
+		}
+	}
+	if frame != nil {
+		f.children = append(f.children, wideChild{key: f.key, frame: frame})
+	}
+}
+
+// wideToken is one token of a wide JSON line, kept as text so it can be scrubbed and re-emitted
+type wideToken struct {
+	prefix   string // Separator written before the token ("", ",", or ":")
+	text     string // Literal text for delimiters, numbers, booleans and null
+	str      string // Decoded value for strings
+	isString bool
+	isKey    bool
+	key      string         // Object key a string value belongs to
+	spans    protectedSpans // Preserved text set aside from this string
+}
+
+// processWideJSONLine scrubs a very wide JSON object line using json.Decoder tokens rather than
+// unmarshalling it into a map. Each scrubber runs over all strings in document order, like the
+// regex passes over the raw line, so mappings and output match the regular path apart from
+// insignificant whitespace and string escaping. Returns false when the line is not a single JSON
+// object, or when options that need the whole document (scrub paths, field types, passthrough
+// fields, notification handling) apply, so the caller falls back to the regular path.
+func (s *Scrubber) processWideJSONLine(line, source string) (string, bool) {
+	if len(s.scrubPaths) > 0 || len(s.fieldTypes) > 0 || len(s.passthroughFields) > 0 || s.mayBeNotificationLine(line, source) {
+		return "", false
+	}
+	if !strings.HasPrefix(strings.TrimSpace(line), "{") {
+		return "", false
+	}
+
+	// Read the tokens; user mappings are created once the whole object is read
+	var tokens []wideToken
+	if err := s.streamJSONTokens(line, func(frames []*wideFrame, tok json.Token, prefix string, isKey bool) {
+		token := wideToken{prefix: prefix, isKey: isKey}
+		switch v := tok.(type) {
+		case json.Delim:
+			token.text = v.String()
+		case string:
+			token.str, token.isString = v, true
+			if top := frames[len(frames)-1]; !isKey && top.object {
+				token.key = top.key
+			}
+		case json.Number:
+			token.text = v.String()
+		case bool:
+			token.text = "false"
+			if v {
+				token.text = "true"
+			}
+		case nil:
+			token.text = "null"
+		}
+		tokens = append(tokens, token)
+
+		if !isKey {
+			s.collectWideUserMapping(frames, tok)
+		}
+	}); err != nil {
+		return "", false
+	}
+
+	s.scrubWideTokens(tokens, source)
+
+	var out strings.Builder
+	out.Grow(len(line))
+	for _, token := range tokens {
+		out.WriteString(token.prefix)
+		if token.isString {
+			out.WriteString(encodeJSONString(token.str))
+		} else {
+			out.WriteString(token.text)
+		}
+	}
+	return out.String(), true
+}
+
+// scrubWideTokens runs the JSON scrubbing pipeline over the string tokens of a wide line
+// Each stage covers every string before the next starts, matching the order of scrubJSONString
+func (s *Scrubber) scrubWideTokens(tokens []wideToken, source string) {
+	var strs []*wideToken
+	for i := range tokens {
+		if tokens[i].isString {
+			tokens[i].str = s.protectPreserved(tokens[i].str, &tokens[i].spans)
+			strs = append(strs, &tokens[i])
+		}
+	}
+
+	stage := func(keys []string, scrub func(value string) string) {
+		for _, token := range strs {
+			if keys != nil && (token.isKey || !containsKey(keys, token.key) || token.str == "") {
+				continue
+			}
+			token.str = scrub(token.str)
+		}
+	}
+
+	// Redact organization-specific terms from the redact list (all levels)
+	stage(nil, func(v string) string { return s.scrubRedactList(v, source) })
+
+	// Scrub national IDs, user links, emails, then usernames (all levels)
+	stage(nil, func(v string) string { return s.scrubNationalIDs(v, source) })
+	stage(nil, func(v string) string { return s.scrubUserLinks(v, source) })
+	stage(nil, func(v string) string { return s.scrubEmails(v, source) })
+	stage([]string{"user", "username"}, func(v string) string {
+		if strings.Contains(v, preservePlaceholderMarker) {
+			return v
+		}
+		return s.scrubUsernameValue(v, source)
+	})
+
+	// Scrub FQDNs (all levels)
+	stage(nil, func(v string) string { return s.scrubFQDNs(v, source) })
+
+	// Scrub phone numbers, hostnames and IP addresses (levels 2 and 3 only)
+	if s.level >= 2 {
+		stage(nil, func(v string) string { return s.scrubPhoneNumbers(v, source) })
+		stage([]string{"host", "hostname", "server"}, func(v string) string { return s.scrubHostPortValue(v, source) })
+		stage(nil, func(v string) string { return s.scrubIPAddresses(v, source) })
+	}
+
+	// Scrub UIDs (level 3 only)
+	if s.level == 3 {
+		stage(nil, func(v string) string { return s.scrubUIDs(v, source) })
+	}
+
+	// Map always-scrub values the heuristics missed to user tokens (all levels)
+	stage(nil, func(v string) string { return s.scrubAlwaysScrub(v, source) })
+
+	// Apply configured custom patterns, then registered custom scrubbers (all levels)
+	stage(nil, func(v string) string { return s.scrubCustomPatterns(v, source) })
+	stage(nil, func(v string) string { return s.applyCustomScrubbers(v, source) })
+
+	for _, token := range strs {
+		token.str = token.spans.restore(token.str)
+	}
+}
+
+// containsKey reports whether key is one of keys
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// streamJSONTokens walks a single JSON object token by token, calling visit for every token with the
+// frames open at that point, the separator that precedes it, and whether it is an object key.
+// Opening delimiters are visited before their frame is pushed, closing ones before it is popped.
+// Returns an error if the text is not exactly one valid JSON object.
+func (s *Scrubber) streamJSONTokens(text string, visit func(frames []*wideFrame, tok json.Token, prefix string, isKey bool)) error {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	var frames []*wideFrame
+	// separator returns what precedes a value in the current container
+	separator := func() string {
+		if len(frames) == 0 {
+			return ""
+		}
+		top := frames[len(frames)-1]
+		if top.object {
+			return ":"
+		}
+		if top.count > 0 {
+			return ","
+		}
+		return ""
+	}
+	valueDone := func() {
+		top := frames[len(frames)-1]
+		top.count++
+		if top.object {
+			top.expectKey = true
+		}
+	}
+
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		if v, ok := tok.(json.Delim); ok {
+			switch v {
+			case '{', '[':
+				if len(frames) == 0 && v != '{' {
+					return io.ErrUnexpectedEOF
+				}
+				visit(frames, tok, separator(), false)
+				frame := &wideFrame{object: v == '{', expectKey: v == '{'}
+				if len(frames) > 0 {
+					frames[len(frames)-1].setChild(frame)
+				}
+				frames = append(frames, frame)
+			default:
+				visit(frames, tok, "", false)
+				frames = frames[:len(frames)-1]
+				if len(frames) == 0 {
+					// Trailing data means the line is not a single object
+					if _, err := decoder.Token(); err != io.EOF {
+						return io.ErrUnexpectedEOF
+					}
+					return nil
+				}
+				valueDone()
+			}
+			continue
+		}
+		if len(frames) == 0 {
+			return io.ErrUnexpectedEOF
+		}
+
+		top := frames[len(frames)-1]
+		if key, ok := tok.(string); ok && top.object && top.expectKey {
+			prefix := ""
+			if top.count > 0 {
+				prefix = ","
+			}
+			visit(frames, tok, prefix, true)
+			top.key = key
+			top.expectKey = false
+			continue
+		}
+		visit(frames, tok, separator(), false)
+		top.setChild(nil)
+		valueDone()
+	}
+}
+
+// collectWideUserMapping records username/email/roles fields of each object while streaming and
+// creates the user mappings when the outermost object closes, in findUserMappingsRecursive's order
+func (s *Scrubber) collectWideUserMapping(frames []*wideFrame, tok json.Token) {
+	if s.fixedWidth || len(frames) == 0 {
+		return
+	}
+	top := frames[len(frames)-1]
+
+	if delim, ok := tok.(json.Delim); ok && delim == '}' {
+		if len(frames) == 1 {
+			s.mapWideUsers(top)
+		}
+		return
+	}
+	if delim, ok := tok.(json.Delim); ok && (delim == ']' || !top.object) {
+		// Closing arrays and containers inside arrays don't belong to a field of this object
+		return
+	}
+	if !top.object {
+		return
+	}
+
+	value, isString := tok.(string)
+	switch top.key {
+	case "user":
+		// A "user" field takes precedence over "username", even when it is not a string
+		top.userKeySeen = true
+		top.username = ""
+		if isString {
+			top.username = value
+		}
+	case "username":
+		if !top.userKeySeen && isString {
+			top.username = value
+		}
+	case "email":
+		if isString {
+			top.email = value
+		}
+	case "user_id":
+		if isString && value != "" {
+			top.userIDKeySeen = true
+			top.userID = value
+		}
+	case "id":
+		if !top.userIDKeySeen && isString && value != "" {
+			top.userID = value
+		}
+	case "roles":
+		top.roles = tok
+	}
+}
+
+// mapWideUsers creates the user mappings collected in an object and the containers nested in it:
+// the object itself first, then its members in key order, as findUserMappingsRecursive does, so
+// tokens are numbered the same as on the regular path
+func (s *Scrubber) mapWideUsers(frame *wideFrame) {
+	if frame.object {
+		prefix := constants.UserTokenPrefix
+		if s.roleTokens {
+			prefix = roleTokenPrefix(frame.roles)
+		}
+		hasPair := frame.username != "" && frame.email != ""
+		hasRole := prefix != constants.UserTokenPrefix && (frame.username != "" || frame.email != "")
+		if (hasPair || hasRole) && !s.isPreserved(frame.username) && !s.isPreserved(frame.email) && !s.isAllowlisted(frame.username) && !s.isAllowlisted(frame.email) {
+			s.createUserMapping(frame.username, frame.email, prefix)
+		}
+		if s.identityReport && !s.isPreserved(frame.username) && !s.isPreserved(frame.email) {
+			s.linkUserID(frame.username, frame.email, frame.userID)
+		}
+		sort.SliceStable(frame.children, func(i, j int) bool { return frame.children[i].key < frame.children[j].key })
+	}
+	for _, child := range frame.children {
+		s.mapWideUsers(child.frame)
+	}
+}
@@ -0,0 +1,79 @@
+package scrubber
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/constants"
+)
+
+// scrubPadded scrubs line with its PAD placeholder replaced by padLen spaces, and returns the output
+// with the padding put back to PAD, plus the audit
+func scrubPadded(t *testing.T, level int, line string, padLen int, setup func(s *Scrubber)) (string, []AuditEntry) {
+	t.Helper()
+	s := NewScrubber(level, false)
+	if setup != nil {
+		setup(s)
+	}
+	pad := strings.Repeat(" ", padLen)
+	scrubbed, err := s.ScrubLine(strings.Replace(line, "PAD", pad, 1), "test.log")
+	if err != nil {
+		t.Fatalf("ScrubLine: %v", err)
+	}
+	return strings.Replace(scrubbed, pad, "PAD", 1), s.AuditEntries()
+}
+
+func TestWideJSONLineMatchesRegularPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		level int
+		line  string
+		setup func(s *Scrubber)
+	}{
+		{
+			name:  "nested user pairs map parent first",
+			level: 1,
+			line:  `{"user":"alice","email":"alice@corp.com","nested":{"user":"bob","email":"bob@corp.com"},"pad":"PAD"}`,
+		},
+		{
+			name:  "sibling objects map in key order",
+			level: 1,
+			line:  `{"zeta":{"user":"carol","email":"carol@zeta.com"},"alpha":{"user":"dave","email":"dave@alpha.com"},"pad":"PAD"}`,
+		},
+		{
+			name:  "arrays of users and free text",
+			level: 2,
+			line:  `{"msg":"login from 10.1.2.3 by erin@corp.com","members":[{"username":"frank","email":"frank@corp.com"},{"user":"grace","email":"grace@corp.com"}],"count":3,"ok":true,"pad":"PAD"}`,
+		},
+		{
+			name:  "level 3 ids and hosts",
+			level: 3,
+			line:  `{"user_id":"abcdefghijklmnopqrstuvwxyz","host":"db01.corp.com:5432","ip":"192.168.0.7","pad":"PAD"}`,
+		},
+		{
+			name:  "redact list before typed scrubbers",
+			level: 1,
+			line:  `{"msg":"Project Falcon ships to falcon@corp.com","pad":"PAD"}`,
+			setup: func(s *Scrubber) {
+				if err := s.SetRedactList([]string{"falcon"}); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			narrowOut, narrowAudit := scrubPadded(t, tt.level, tt.line, 16, tt.setup)
+			wideOut, wideAudit := scrubPadded(t, tt.level, tt.line, constants.WideLineThreshold, tt.setup)
+
+			if wideOut != narrowOut {
+				t.Errorf("output differs\n wide: %s\nregular: %s", wideOut, narrowOut)
+			}
+			if !reflect.DeepEqual(wideAudit, narrowAudit) {
+				t.Errorf("audit differs\n wide: %+v\nregular: %+v", wideAudit, narrowAudit)
+			}
+		})
+	}
+}
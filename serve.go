@@ -0,0 +1,266 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"mattermost-log-scrubber/config"
+	"mattermost-log-scrubber/constants"
+)
+
+// Serve mode endpoints
+const (
+	serveScrubPath  = "/scrub"
+	serveHealthPath = "/healthz"
+)
+
+// Response headers and trailers set by serve mode
+const (
+	headerReplacements = "X-Scrub-Replacements" // Trailer: values replaced in the upload
+	headerAuditSalt    = "X-Scrub-Audit-Salt"   // Salt for hashed audit originals, when generated per request
+)
+
+// logServer scrubs uploaded logs over HTTP, one Scrubber per request
+// Settings come from the command line and config file, and each request may override some of them
+type logServer struct {
+	settings config.ResolvedSettings // Validated once at startup, with patterns compiled and files loaded
+}
+
+// runServe starts the HTTP service and blocks until it fails
+func runServe(flags config.CLIFlags) error {
+	configFile, configPath, err := loadConfigFile(flags)
+	if err != nil {
+		return err
+	}
+	profiledConfig, _, err := loadProfile(flags, configFile)
+	if err != nil {
+		return err
+	}
+	scrubConfig, rules, rulesPath, err := loadRulesFile(flags, profiledConfig)
+	if err != nil {
+		return err
+	}
+
+	// Resolve and check the settings once, so a bad config fails at startup rather than on every request
+	// and patterns aren't compiled again for each one
+	settings, _ := config.ResolveSettings(flags, scrubConfig)
+	if err := config.ValidateScrubSettings(&settings); err != nil {
+		return err
+	}
+	// Verbose output would print original values to the server log
+	settings.Verbose = false
+	server := &logServer{settings: settings}
+
+	if configFile != nil {
+		fmt.Printf("Using config file at %s\n", configPath)
+	}
+	if flags.Profile != "" {
+		fmt.Printf("Using profile '%s'\n", flags.Profile)
+	}
+	if rules != nil {
+		fmt.Printf("Using rules file at %s\n", rulesPath)
+	}
+	fmt.Printf("%s v%s serving on %s (POST %s, level %d by default)\n", constants.AppName, constants.Version, flags.ServeAddr, serveScrubPath, settings.ScrubLevel)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(serveScrubPath, server.handleScrub)
+	mux.HandleFunc(serveHealthPath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	httpServer := &http.Server{
+		Addr:              flags.ServeAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return httpServer.ListenAndServe()
+}
+
+// requestSettings returns the settings for one request: query parameters named like the command
+// line flags (e.g. level=3&ip-strategy=class) override the server's own settings. An empty value
+// keeps the server's setting, as an empty flag would.
+func (ls *logServer) requestSettings(query map[string][]string) (config.ResolvedSettings, error) {
+	settings := ls.settings
+	overrides := flag.NewFlagSet(constants.ServeCommand, flag.ContinueOnError)
+	overrides.SetOutput(io.Discard)
+	overrides.IntVar(&settings.ScrubLevel, "level", settings.ScrubLevel, "")
+	overrides.Func("ip-strategy", "", stringOverride(&settings.IPStrategy, false))
+	overrides.Func("mask-char", "", stringOverride(&settings.MaskChar, false))
+	overrides.Func("json-failure-action", "", stringOverride(&settings.JSONFailureAction, true))
+	overrides.Func("log-kind", "", stringOverride(&settings.LogKind, true))
+	overrides.Func("normalize-time", "", stringOverride(&settings.TimeFormat, true))
+	overrides.Func("internal-domains", "", listOverride(&settings.InternalDomains))
+	overrides.Func("no-audit-types", "", listOverride(&settings.NoAuditTypes))
+	overrides.BoolVar(&settings.ContainerLogs, "container-logs", settings.ContainerLogs, "")
+	overrides.BoolVar(&settings.RoleTokens, "role-tokens", settings.RoleTokens, "")
+	overrides.BoolVar(&settings.FixedWidth, "fixed-width", settings.FixedWidth, "")
+	overrides.BoolVar(&settings.CanonicalJSON, "canonical-json", settings.CanonicalJSON, "")
+	for name, values := range query {
+		if name == "audit" {
+			continue
+		}
+		if overrides.Lookup(name) == nil {
+			return config.ResolvedSettings{}, fmt.Errorf("unknown setting '%s'", name)
+		}
+		if err := overrides.Set(name, values[len(values)-1]); err != nil {
+			return config.ResolvedSettings{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	if len(query) == 0 {
+		return settings, nil
+	}
+
+	// Deterministic output is always canonical JSON
+	settings.CanonicalJSON = settings.CanonicalJSON || settings.Deterministic
+	if err := config.CheckScrubSettings(&settings); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// stringOverride returns a flag setter that replaces a setting with a non-empty query value,
+// lowercased for settings that match case-insensitively
+func stringOverride(setting *string, lower bool) func(string) error {
+	return func(value string) error {
+		if value == "" {
+			return nil
+		}
+		if lower {
+			value = strings.ToLower(value)
+		}
+		*setting = value
+		return nil
+	}
+}
+
+// listOverride returns a flag setter that replaces a list setting with a non-empty comma-separated query value
+func listOverride(setting *[]string) func(string) error {
+	return func(value string) error {
+		if list := config.ParseList(value); len(list) > 0 {
+			*setting = list
+		}
+		return nil
+	}
+}
+
+// handleScrub scrubs the request body, or the "file" field of a multipart form upload, and streams
+// the scrubbed log back. With audit=json the response is multipart/mixed, with the scrubbed log
+// followed by the JSON audit.
+func (ls *logServer) handleScrub(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST with the log as the request body", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	auditFormat := query.Get("audit")
+	if auditFormat != "" && auditFormat != constants.AuditTypeJSON {
+		http.Error(w, fmt.Sprintf("audit must be %s or omitted", constants.AuditTypeJSON), http.StatusBadRequest)
+		return
+	}
+	settings, err := ls.requestSettings(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s, auditSalt, err := newScrubber(settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Compressed uploads are held to the limit again once decompressed, by ScrubStream
+	r.Body = http.MaxBytesReader(w, r.Body, settings.MaxInputFileSize)
+	input, source, err := uploadedLog(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if settings.AuditHashOriginals && settings.AuditSalt == "" {
+		w.Header().Set(headerAuditSalt, auditSalt)
+	}
+	w.Header().Set("Trailer", headerReplacements)
+	startTime := time.Now()
+
+	var output io.Writer = w
+	var parts *multipart.Writer
+	if auditFormat == constants.AuditTypeJSON {
+		parts = multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+parts.Boundary())
+		output, err = parts.CreatePart(servePartHeader("scrubbed.log", "text/plain; charset=utf-8"))
+		if err != nil {
+			panic(http.ErrAbortHandler)
+		}
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	// The response is already streaming, so a failure can only cut it short
+	if err := s.ScrubStream(input, output, source); err != nil {
+		fmt.Printf("%s %s: scrubbing '%s' failed: %v\n", r.RemoteAddr, serveScrubPath, source, err)
+		panic(http.ErrAbortHandler)
+	}
+	if parts != nil {
+		auditPart, err := parts.CreatePart(servePartHeader("audit.json", "application/json"))
+		if err != nil {
+			panic(http.ErrAbortHandler)
+		}
+		if err := s.WriteAuditJSON(auditPart); err != nil {
+			panic(http.ErrAbortHandler)
+		}
+		if err := parts.Close(); err != nil {
+			panic(http.ErrAbortHandler)
+		}
+	}
+
+	stats := s.Stats()
+	w.Header().Set(headerReplacements, strconv.Itoa(stats.TotalReplacements()))
+	fmt.Printf("%s %s: scrubbed '%s' at level %d: %d lines, %d replacements in %s\n",
+		r.RemoteAddr, serveScrubPath, source, settings.ScrubLevel, stats.Lines, stats.TotalReplacements(), time.Since(startTime).Round(time.Millisecond))
+}
+
+// uploadedLog returns the log to scrub and the name recorded as its audit source: the "file" field
+// of a multipart form upload, or else the raw request body
+func uploadedLog(r *http.Request) (io.Reader, string, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, "upload", nil
+	}
+
+	form, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", fmt.Errorf("reading multipart upload: %w", err)
+	}
+	for {
+		part, err := form.NextPart()
+		if err == io.EOF {
+			return nil, "", fmt.Errorf("multipart upload has no \"file\" field")
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("reading multipart upload: %w", err)
+		}
+		if part.FormName() == "file" {
+			source := part.FileName()
+			if source == "" {
+				source = "upload"
+			}
+			return part, source, nil
+		}
+	}
+}
+
+// servePartHeader returns the header of one part of a multipart/mixed response
+func servePartHeader(filename, contentType string) textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	return header
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"mattermost-log-scrubber/config"
+	"mattermost-log-scrubber/constants"
+)
+
+// newTestServer returns a logServer with settings resolved and validated as runServe does
+func newTestServer(t *testing.T, flags config.CLIFlags) *logServer {
+	t.Helper()
+	settings, _ := config.ResolveSettings(flags, &config.Config{})
+	settings.PreservePatterns = []string{`<anon:\d+>`}
+	if err := config.ValidateScrubSettings(&settings); err != nil {
+		t.Fatal(err)
+	}
+	return &logServer{settings: settings}
+}
+
+func TestRequestSettingsOverrides(t *testing.T) {
+	server := newTestServer(t, config.CLIFlags{Level: 1})
+
+	settings, err := server.requestSettings(url.Values{
+		"level":            {"2", "3"},
+		"ip-strategy":      {constants.IPStrategyClass},
+		"log-kind":         {"APP"},
+		"internal-domains": {"Corp.example, corp.example"},
+		"mask-char":        {""},
+		"audit":            {constants.AuditTypeJSON},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.ScrubLevel != 3 || settings.IPStrategy != constants.IPStrategyClass || settings.LogKind != constants.LogKindApp {
+		t.Errorf("overrides not applied: level %d, IP strategy %q, log kind %q", settings.ScrubLevel, settings.IPStrategy, settings.LogKind)
+	}
+	if len(settings.InternalDomains) != 1 || settings.InternalDomains[0] != "corp.example" {
+		t.Errorf("internal domains = %v, want [corp.example]", settings.InternalDomains)
+	}
+	if settings.MaskChar != server.settings.MaskChar {
+		t.Errorf("empty mask-char replaced %q with %q", server.settings.MaskChar, settings.MaskChar)
+	}
+
+	// The patterns compiled at startup are shared, and the server's own settings are untouched
+	if settings.Patterns == nil || settings.Patterns != server.settings.Patterns {
+		t.Error("request settings don't reuse the patterns compiled at startup")
+	}
+	if server.settings.ScrubLevel != 1 || server.settings.IPStrategy != constants.IPStrategyMask {
+		t.Errorf("request changed the server settings: level %d, IP strategy %q", server.settings.ScrubLevel, server.settings.IPStrategy)
+	}
+}
+
+func TestRequestSettingsRejectsBadOverrides(t *testing.T) {
+	server := newTestServer(t, config.CLIFlags{Level: 1})
+	tests := []struct {
+		query   url.Values
+		wantErr string
+	}{
+		{url.Values{"level": {"7"}}, "scrubbing level"},
+		{url.Values{"level": {"high"}}, "invalid level"},
+		{url.Values{"output": {"/tmp/x"}}, "unknown setting 'output'"},
+		{url.Values{"log-kind": {"syslog"}}, "log kind"},
+	}
+	for _, tt := range tests {
+		_, err := server.requestSettings(tt.query)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%v: err = %v, want %q", tt.query, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHandleScrub(t *testing.T) {
+	server := newTestServer(t, config.CLIFlags{Level: 1})
+	body := `{"user":"alice","email":"alice@example.com","msg":"<anon:7> from 10.1.2.3"}` + "\n"
+	request := httptest.NewRequest(http.MethodPost, serveScrubPath+"?level=3", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	server.handleScrub(recorder, request)
+
+	response := recorder.Result()
+	scrubbed, _ := io.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", response.StatusCode, scrubbed)
+	}
+	if want := `{"user":"user1","email":"user1@domain1","msg":"<anon:7> from ***.***.***.***"}` + "\n"; string(scrubbed) != want {
+		t.Errorf("got  %s\nwant %s", scrubbed, want)
+	}
+	if got := response.Trailer.Get(headerReplacements); got != "3" {
+		t.Errorf("%s trailer = %q, want 3", headerReplacements, got)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"mattermost-log-scrubber/config"
+	"mattermost-log-scrubber/constants"
+	"mattermost-log-scrubber/scrubber"
+)
+
+// statsSchemaVersion is the version of the --stats JSON layout. It only changes when a field is
+// renamed, removed or changes meaning; new fields may be added within a version.
+const statsSchemaVersion = 1
+
+// statsCoreTypes are always present in the --stats replacement counts, as zero when nothing of
+// that type was found, so tooling can read them without checking for a key
+var statsCoreTypes = []string{constants.TypeEmail, constants.TypeUsername, constants.TypeIP, constants.TypeUID}
+
+// StatsReport is the machine-readable summary written by --stats
+type StatsReport struct {
+	SchemaVersion     int                   `json:"SchemaVersion"`
+	Version           string                `json:"Version"`
+	GeneratedAt       string                `json:"GeneratedAt"`
+	ScrubLevel        int                   `json:"ScrubLevel"`
+	DurationSeconds   float64               `json:"DurationSeconds"`
+	Files             int                   `json:"Files"`
+	Lines             StatsLines            `json:"Lines"`
+	TotalReplacements int                   `json:"TotalReplacements"`
+	Replacements      map[string]StatsCount `json:"Replacements"` // key: scrub type
+}
+
+// StatsLines counts the lines read, by how they were handled
+type StatsLines struct {
+	Total       int `json:"Total"`       // Lines read, including empty ones
+	Empty       int `json:"Empty"`       // Empty lines skipped
+	Failed      int `json:"Failed"`      // Lines that failed processing and were written unchanged
+	Dropped     int `json:"Dropped"`     // Lines dropped by --json-failure-action drop
+	JSON        int `json:"JSON"`        // Lines parsed as JSON
+	InvalidJSON int `json:"InvalidJSON"` // Lines that weren't valid JSON
+	PlainText   int `json:"PlainText"`   // Non-empty lines not parsed as JSON
+}
+
+// StatsCount is the replacements of one scrub type
+type StatsCount struct {
+	Total  int `json:"Total"`  // Values replaced
+	Unique int `json:"Unique"` // Distinct original values replaced
+}
+
+// writeStats writes the run's counts as versioned JSON, to a file or to standard output for "-"
+// Returns the actual stats path used (which may differ if renamed)
+func writeStats(s *scrubber.Scrubber, settings config.ResolvedSettings, duration time.Duration) (string, error) {
+	stats := s.Stats()
+
+	report := StatsReport{
+		SchemaVersion:   statsSchemaVersion,
+		Version:         constants.Version,
+		GeneratedAt:     time.Now().UTC().Format(time.RFC3339),
+		ScrubLevel:      settings.ScrubLevel,
+		DurationSeconds: duration.Seconds(),
+		Files:           stats.Files,
+		Lines: StatsLines{
+			Total:       stats.Lines,
+			Empty:       stats.LinesEmpty,
+			Failed:      stats.LinesFailed,
+			Dropped:     stats.LinesDropped,
+			JSON:        stats.JSONLines,
+			InvalidJSON: stats.JSONFailures,
+			PlainText:   stats.Lines - stats.LinesEmpty - stats.JSONLines,
+		},
+		TotalReplacements: stats.TotalReplacements(),
+		Replacements:      make(map[string]StatsCount),
+	}
+	for _, valueType := range statsCoreTypes {
+		report.Replacements[valueType] = StatsCount{}
+	}
+	for valueType, total := range stats.Replacements {
+		report.Replacements[valueType] = StatsCount{Total: total, Unique: stats.UniqueValues[valueType]}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding stats: %w", err)
+	}
+	data = append(data, '\n')
+
+	if scrubber.IsStdio(settings.StatsPath) {
+		if _, err := os.Stdout.Write(data); err != nil {
+			return "", fmt.Errorf("failed to write stats: %w", err)
+		}
+		return settings.StatsPath, nil
+	}
+	statsPath, err := s.ResolveArtifactPath(settings.StatsPath, settings.OverwriteAction, "Stats file")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(statsPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write stats file: %w", err)
+	}
+	return statsPath, nil
+}